@@ -0,0 +1,143 @@
+// Package blobstore abstracts where file bytes live. The S3 driver is what
+// runs in production; the local-disk and in-memory drivers let the server run
+// without AWS, answering their own presigned URLs.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	DriverS3     = "s3"
+	DriverLocal  = "local"
+	DriverMemory = "memory"
+)
+
+var (
+	ErrNotFound           = errors.New("object not found")
+	ErrNotModified        = errors.New("not modified")
+	ErrInvalidRange       = errors.New("requested range not satisfiable")
+	ErrPreconditionFailed = errors.New("object was changed by another request")
+	ErrUploadNotFound     = errors.New("multipart upload not found")
+	ErrChecksumMismatch   = errors.New("content does not match its checksum")
+)
+
+// BlobStore stores versioned objects under keys. Every write creates a new
+// version and older versions stay readable until they are deleted.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, options PutOptions) (*ObjectInfo, error)
+	// Get opens an object for reading. The caller owns the returned Body and
+	// must close it.
+	Get(ctx context.Context, key string, options GetOptions) (*Object, error)
+	Head(ctx context.Context, key string, versionID string) (*ObjectInfo, error)
+	// Copy makes a version of srcKey (the current one if srcVersionID is
+	// empty) the newest version of dstKey.
+	Copy(ctx context.Context, srcKey string, srcVersionID string, dstKey string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string, versionID string) error
+	// DeleteAllVersions removes every version stored under exactly key.
+	DeleteAllVersions(ctx context.Context, key string) error
+	// List returns the current version of every object whose key starts with
+	// prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// ListPrefixes returns the distinct key segments that follow prefix, up to
+	// the next "/".
+	ListPrefixes(ctx context.Context, prefix string) ([]string, error)
+
+	PresignGet(ctx context.Context, key string, options PresignGetOptions) (string, error)
+	// PresignPut and PresignPost let a client upload exactly one object of
+	// the given size and type without going through the server.
+	PresignPut(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error)
+	PresignPost(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error)
+
+	CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error)
+	PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, expiresIn time.Duration) (string, error)
+	UploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, body io.Reader) (*Part, error)
+	ListParts(ctx context.Context, key string, uploadID string) ([]Part, error)
+	// CompleteMultipartUpload joins the parts, which must be given in order,
+	// into a new version of key.
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part) error
+	// AbortMultipartUpload drops the parts of an upload. Aborting an upload
+	// that is already gone is not an error.
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+	ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error)
+}
+
+// ObjectInfo describes one version of an object. The checksums are base64
+// and cover the whole object; they are empty when the store has none.
+type ObjectInfo struct {
+	Key            string
+	VersionID      string
+	ETag           string
+	ContentType    string
+	Size           int64
+	LastModified   time.Time
+	ChecksumSHA256 string
+	ChecksumCRC32C string
+}
+
+type Object struct {
+	ObjectInfo
+	Body          io.ReadCloser
+	ContentLength int64
+	ContentRange  string
+}
+
+type PutOptions struct {
+	ContentType string
+	// Size is the length of the body, which a body that cannot be rewound
+	// has to declare up front.
+	Size int64
+	// ChecksumSHA256 is verified by the store; the write fails if the bytes
+	// do not match. Without one the store computes its own while the body
+	// streams.
+	ChecksumSHA256 string
+	// ChecksumCRC32C is stored with the object as it is.
+	ChecksumCRC32C string
+	// IfMatch makes the write fail with ErrPreconditionFailed unless the
+	// current version has this ETag.
+	IfMatch string
+}
+
+type GetOptions struct {
+	VersionID string
+	// Range is an HTTP Range header value, e.g. "bytes=0-99".
+	Range string
+	// IfNoneMatch makes Get return ErrNotModified if the ETag matches.
+	IfNoneMatch string
+}
+
+type PresignGetOptions struct {
+	ExpiresIn time.Duration
+	// ContentType and ContentDisposition override the response headers.
+	ContentType        string
+	ContentDisposition string
+}
+
+type PresignUploadOptions struct {
+	ExpiresIn   time.Duration
+	ContentType string
+	Size        int64
+}
+
+// PresignedRequest is what a client needs to send an upload itself: the
+// headers to send with a PUT, or the form fields to send with a POST.
+type PresignedRequest struct {
+	URL     string
+	Headers map[string]string
+	Fields  map[string]string
+}
+
+type Part struct {
+	PartNumber int32
+	ETag       string
+	Size       int64
+}
+
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
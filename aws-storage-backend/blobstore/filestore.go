@@ -0,0 +1,603 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fileSystem is where a FileStore keeps its files: a directory on disk or a
+// map in memory. Names use "/" as the separator; a missing file is reported
+// as fs.ErrNotExist.
+type fileSystem interface {
+	// create writes body to name, replacing any file there only once the
+	// whole body has been written.
+	create(name string, body io.Reader) error
+	open(name string) (io.ReadSeekCloser, error)
+	remove(name string) error
+	removeAll(dir string) error
+	// list returns the names of the entries directly in dir, sorted.
+	list(dir string) ([]string, error)
+}
+
+// FileStore keeps versioned objects as plain files, with an index per key
+// listing its versions oldest first:
+//
+//	objects/<escaped key>/versions.json
+//	objects/<escaped key>/<version ID>
+//	uploads/<upload ID>/upload.json
+//	uploads/<upload ID>/<part number>
+//
+// Presigned URLs point back at the server, which checks them with
+// VerifyURL before calling the store.
+type FileStore struct {
+	files  fileSystem
+	signer *URLSigner
+	// mu guards the indexes; object and part bytes are written to their own
+	// files before the index names them.
+	mu sync.Mutex
+}
+
+type fileUpload struct {
+	Key         string
+	ContentType string
+	Initiated   time.Time
+	Parts       map[int32]Part
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func objectDir(key string) string {
+	return "objects/" + url.PathEscape(key)
+}
+
+func uploadDir(uploadID string) string {
+	return "uploads/" + url.PathEscape(uploadID)
+}
+
+func (s *FileStore) readJSON(name string, v interface{}) error {
+	file, err := s.files.open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(v)
+}
+
+func (s *FileStore) writeJSON(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.files.create(name, bytes.NewReader(data))
+}
+
+func (s *FileStore) versions(key string) ([]ObjectInfo, error) {
+	var versions []ObjectInfo
+	err := s.readJSON(objectDir(key)+"/versions.json", &versions)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return versions, err
+}
+
+func findVersion(versions []ObjectInfo, versionID string) (int, bool) {
+	if versionID == "" {
+		return len(versions) - 1, len(versions) > 0
+	}
+	for i := range versions {
+		if versions[i].VersionID == versionID {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (s *FileStore) Put(ctx context.Context, key string, body io.Reader, options PutOptions) (*ObjectInfo, error) {
+	info := ObjectInfo{
+		Key:         key,
+		VersionID:   uuid.New().String(),
+		ContentType: options.ContentType,
+	}
+	name := objectDir(key) + "/" + info.VersionID
+
+	md5Hash := md5.New()
+	shaHash := sha256.New()
+	crcHash := crc32.New(crc32cTable)
+	counter := &countingWriter{}
+	if err := s.files.create(name, io.TeeReader(body, io.MultiWriter(md5Hash, shaHash, crcHash, counter))); err != nil {
+		return nil, err
+	}
+
+	crcSum := make([]byte, 4)
+	binary.BigEndian.PutUint32(crcSum, crcHash.Sum32())
+
+	info.Size = counter.n
+	info.ETag = `"` + hex.EncodeToString(md5Hash.Sum(nil)) + `"`
+	info.ChecksumSHA256 = base64.StdEncoding.EncodeToString(shaHash.Sum(nil))
+	info.ChecksumCRC32C = base64.StdEncoding.EncodeToString(crcSum)
+	info.LastModified = time.Now().UTC()
+
+	if options.ChecksumSHA256 != "" && options.ChecksumSHA256 != info.ChecksumSHA256 {
+		s.files.remove(name)
+		return nil, ErrChecksumMismatch
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := s.versions(key)
+	if err != nil {
+		s.files.remove(name)
+		return nil, err
+	}
+
+	if options.IfMatch != "" {
+		current, ok := findVersion(versions, "")
+		if !ok || versions[current].ETag != options.IfMatch {
+			s.files.remove(name)
+			return nil, ErrPreconditionFailed
+		}
+	}
+
+	if err := s.writeJSON(objectDir(key)+"/versions.json", append(versions, info)); err != nil {
+		s.files.remove(name)
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, key string, options GetOptions) (*Object, error) {
+	info, err := s.Head(ctx, key, options.VersionID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range strings.Split(options.IfNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate != "" && (candidate == "*" || candidate == info.ETag) {
+			return nil, ErrNotModified
+		}
+	}
+
+	start, end, err := parseRange(options.Range, info.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.files.open(objectDir(key) + "/" + info.VersionID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	object := &Object{
+		ObjectInfo:    *info,
+		Body:          readCloser{io.LimitReader(file, end-start), file},
+		ContentLength: end - start,
+	}
+	if options.Range != "" {
+		object.ContentRange = fmt.Sprintf("bytes %d-%d/%d", start, end-1, info.Size)
+	}
+
+	return object, nil
+}
+
+// parseRange turns a single HTTP byte range into the half-open interval it
+// selects. An empty header selects everything.
+func parseRange(header string, size int64) (int64, int64, error) {
+	if header == "" {
+		return 0, size, nil
+	}
+
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, ErrInvalidRange
+	}
+
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, ErrInvalidRange
+	}
+
+	if first == "" {
+		suffix, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, ErrInvalidRange
+		}
+		return max(size-suffix, 0), size, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, ErrInvalidRange
+	}
+
+	end := size
+	if last != "" {
+		lastByte, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || lastByte < start {
+			return 0, 0, ErrInvalidRange
+		}
+		end = min(lastByte+1, size)
+	}
+
+	return start, end, nil
+}
+
+func (s *FileStore) Head(ctx context.Context, key string, versionID string) (*ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := s.versions(key)
+	if err != nil {
+		return nil, err
+	}
+
+	i, ok := findVersion(versions, versionID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	info := versions[i]
+	return &info, nil
+}
+
+func (s *FileStore) Copy(ctx context.Context, srcKey string, srcVersionID string, dstKey string) (*ObjectInfo, error) {
+	source, err := s.Get(ctx, srcKey, GetOptions{VersionID: srcVersionID})
+	if err != nil {
+		return nil, err
+	}
+	defer source.Body.Close()
+
+	return s.Put(ctx, dstKey, source.Body, PutOptions{
+		ContentType:    source.ContentType,
+		ChecksumSHA256: source.ChecksumSHA256,
+	})
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string, versionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions, err := s.versions(key)
+	if err != nil {
+		return err
+	}
+
+	i, ok := findVersion(versions, versionID)
+	if !ok {
+		return nil
+	}
+	removed := versions[i].VersionID
+
+	if len(versions) == 1 {
+		return s.files.removeAll(objectDir(key))
+	}
+
+	if err := s.writeJSON(objectDir(key)+"/versions.json", append(versions[:i:i], versions[i+1:]...)); err != nil {
+		return err
+	}
+	return s.files.remove(objectDir(key) + "/" + removed)
+}
+
+func (s *FileStore) DeleteAllVersions(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.files.removeAll(objectDir(key))
+}
+
+func (s *FileStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	keys, err := s.keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := []ObjectInfo{}
+	for _, key := range keys {
+		versions, err := s.versions(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) > 0 {
+			objects = append(objects, versions[len(versions)-1])
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *FileStore) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := []string{}
+	for _, key := range keys {
+		segment, _, found := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+		if found && segment != "" && (len(prefixes) == 0 || prefixes[len(prefixes)-1] != segment) {
+			prefixes = append(prefixes, segment)
+		}
+	}
+
+	return prefixes, nil
+}
+
+// keys returns the keys starting with prefix, sorted.
+func (s *FileStore) keys(prefix string) ([]string, error) {
+	names, err := s.files.list("objects")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for _, name := range names {
+		key, err := url.PathUnescape(name)
+		if err == nil && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *FileStore) PresignGet(ctx context.Context, key string, options PresignGetOptions) (string, error) {
+	params := url.Values{}
+	if options.ContentType != "" {
+		params.Set("response-content-type", options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		params.Set("response-content-disposition", options.ContentDisposition)
+	}
+
+	return s.signer.Sign(http.MethodGet, key, params, options.ExpiresIn), nil
+}
+
+func (s *FileStore) PresignPut(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error) {
+	return &PresignedRequest{
+		URL:     s.signer.Sign(http.MethodPut, key, uploadParams(options), options.ExpiresIn),
+		Headers: map[string]string{"Content-Type": options.ContentType},
+	}, nil
+}
+
+func (s *FileStore) PresignPost(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error) {
+	return &PresignedRequest{
+		URL:    s.signer.Sign(http.MethodPost, key, uploadParams(options), options.ExpiresIn),
+		Fields: map[string]string{"Content-Type": options.ContentType},
+	}, nil
+}
+
+func uploadParams(options PresignUploadOptions) url.Values {
+	params := url.Values{}
+	params.Set("content-type", options.ContentType)
+	params.Set("content-length", strconv.FormatInt(options.Size, 10))
+	return params
+}
+
+// VerifyURL checks a request made with one of the store's presigned URLs.
+func (s *FileStore) VerifyURL(method string, key string, query url.Values) error {
+	return s.signer.Verify(method, key, query)
+}
+
+func (s *FileStore) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	uploadID := uuid.New().String()
+
+	err := s.writeJSON(uploadDir(uploadID)+"/upload.json", &fileUpload{
+		Key:         key,
+		ContentType: contentType,
+		Initiated:   time.Now().UTC(),
+		Parts:       map[int32]Part{},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return uploadID, nil
+}
+
+func (s *FileStore) PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, expiresIn time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("uploadId", uploadID)
+	params.Set("partNumber", strconv.Itoa(int(partNumber)))
+	params.Set("content-length", strconv.FormatInt(size, 10))
+
+	return s.signer.Sign(http.MethodPut, key, params, expiresIn), nil
+}
+
+func (s *FileStore) upload(key string, uploadID string) (*fileUpload, error) {
+	var upload fileUpload
+	err := s.readJSON(uploadDir(uploadID)+"/upload.json", &upload)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && upload.Key != key) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (s *FileStore) UploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, body io.Reader) (*Part, error) {
+	s.mu.Lock()
+	_, err := s.upload(key, uploadID)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s/%d", uploadDir(uploadID), partNumber)
+	md5Hash := md5.New()
+	counter := &countingWriter{}
+	if err := s.files.create(name, io.TeeReader(io.LimitReader(body, size+1), io.MultiWriter(md5Hash, counter))); err != nil {
+		return nil, err
+	}
+	if counter.n != size {
+		s.files.remove(name)
+		return nil, fmt.Errorf("part %d has %d bytes, expected %d", partNumber, counter.n, size)
+	}
+
+	part := Part{
+		PartNumber: partNumber,
+		ETag:       `"` + hex.EncodeToString(md5Hash.Sum(nil)) + `"`,
+		Size:       size,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, err := s.upload(key, uploadID)
+	if err != nil {
+		s.files.remove(name)
+		return nil, err
+	}
+	upload.Parts[partNumber] = part
+
+	if err := s.writeJSON(uploadDir(uploadID)+"/upload.json", upload); err != nil {
+		return nil, err
+	}
+
+	return &part, nil
+}
+
+func (s *FileStore) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	upload, err := s.upload(key, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]Part, 0, len(upload.Parts))
+	for _, part := range upload.Parts {
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+
+	return parts, nil
+}
+
+func (s *FileStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part) error {
+	s.mu.Lock()
+	upload, err := s.upload(key, uploadID)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	readers := make([]io.Reader, 0, len(parts))
+	for _, part := range parts {
+		stored, ok := upload.Parts[part.PartNumber]
+		if !ok || stored.ETag != part.ETag {
+			return fmt.Errorf("part %d of upload %s was not uploaded", part.PartNumber, uploadID)
+		}
+
+		file, err := s.files.open(fmt.Sprintf("%s/%d", uploadDir(uploadID), part.PartNumber))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	if _, err := s.Put(ctx, key, io.MultiReader(readers...), PutOptions{ContentType: upload.ContentType}); err != nil {
+		return err
+	}
+
+	return s.AbortMultipartUpload(ctx, key, uploadID)
+}
+
+func (s *FileStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.files.removeAll(uploadDir(uploadID))
+}
+
+func (s *FileStore) ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error) {
+	names, err := s.files.list("uploads")
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploads := []MultipartUpload{}
+	for _, name := range names {
+		uploadID, err := url.PathUnescape(name)
+		if err != nil {
+			continue
+		}
+
+		var upload fileUpload
+		err = s.readJSON(uploadDir(uploadID)+"/upload.json", &upload)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(upload.Key, prefix) {
+			uploads = append(uploads, MultipartUpload{
+				Key:       upload.Key,
+				UploadID:  uploadID,
+				Initiated: upload.Initiated,
+			})
+		}
+	}
+
+	return uploads, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+var _ BlobStore = (*FileStore)(nil)
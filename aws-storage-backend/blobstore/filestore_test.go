@@ -0,0 +1,47 @@
+package blobstore
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		size      int64
+		wantStart int64
+		wantEnd   int64
+		wantErr   bool
+	}{
+		{name: "no range", header: "", size: 10, wantStart: 0, wantEnd: 10},
+		{name: "first bytes", header: "bytes=0-3", size: 10, wantStart: 0, wantEnd: 4},
+		{name: "open ended", header: "bytes=4-", size: 10, wantStart: 4, wantEnd: 10},
+		{name: "last byte past the end", header: "bytes=8-100", size: 10, wantStart: 8, wantEnd: 10},
+		{name: "suffix", header: "bytes=-3", size: 10, wantStart: 7, wantEnd: 10},
+		{name: "suffix longer than the object", header: "bytes=-30", size: 10, wantStart: 0, wantEnd: 10},
+		{name: "start past the end", header: "bytes=10-", size: 10, wantErr: true},
+		{name: "last before first", header: "bytes=5-4", size: 10, wantErr: true},
+		{name: "several ranges", header: "bytes=0-1,4-5", size: 10, wantErr: true},
+		{name: "other unit", header: "items=0-1", size: 10, wantErr: true},
+		{name: "no dash", header: "bytes=3", size: 10, wantErr: true},
+		{name: "empty suffix", header: "bytes=-0", size: 10, wantErr: true},
+		{name: "suffix of an empty object", header: "bytes=-3", size: 0, wantErr: true},
+		{name: "not a number", header: "bytes=a-b", size: 10, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseRange(tt.header, tt.size)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRange) {
+					t.Fatalf("parseRange(%q, %d) = %d, %d, %v; want ErrInvalidRange", tt.header, tt.size, start, end, err)
+				}
+				return
+			}
+			if err != nil || start != tt.wantStart || end != tt.wantEnd {
+				t.Fatalf("parseRange(%q, %d) = %d, %d, %v; want %d, %d", tt.header, tt.size, start, end, err, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
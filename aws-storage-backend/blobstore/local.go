@@ -0,0 +1,97 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NewLocalStore keeps objects in files under root, which is created if it
+// does not exist.
+func NewLocalStore(root string, signer *URLSigner) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		files:  diskFileSystem{root: root},
+		signer: signer,
+	}, nil
+}
+
+type diskFileSystem struct {
+	root string
+}
+
+// temporaryPrefix marks files that are still being written; list skips them.
+const temporaryPrefix = ".tmp-"
+
+func (d diskFileSystem) path(name string) string {
+	return filepath.Join(d.root, filepath.FromSlash(name))
+}
+
+func (d diskFileSystem) create(name string, body io.Reader) error {
+	path := d.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), temporaryPrefix+"*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return nil
+}
+
+func (d diskFileSystem) open(name string) (io.ReadSeekCloser, error) {
+	return os.Open(d.path(name))
+}
+
+func (d diskFileSystem) remove(name string) error {
+	err := os.Remove(d.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (d diskFileSystem) removeAll(dir string) error {
+	return os.RemoveAll(d.path(dir))
+}
+
+func (d diskFileSystem) list(dir string) ([]string, error) {
+	entries, err := os.ReadDir(d.path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), temporaryPrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,99 @@
+package blobstore
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NewMemoryStore keeps objects in memory. Everything is lost when the process
+// exits, so it is only meant for development and tests.
+func NewMemoryStore(signer *URLSigner) *FileStore {
+	return &FileStore{
+		files:  &memoryFileSystem{files: map[string][]byte{}},
+		signer: signer,
+	}
+}
+
+type memoryFileSystem struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error {
+	return nil
+}
+
+func (m *memoryFileSystem) create(name string, body io.Reader) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files[name] = data
+	return nil
+}
+
+func (m *memoryFileSystem) open(name string) (io.ReadSeekCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	// Files are replaced, never changed in place, so readers can share data.
+	return memoryFile{bytes.NewReader(data)}, nil
+}
+
+func (m *memoryFileSystem) remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.files, name)
+	return nil
+}
+
+func (m *memoryFileSystem) removeAll(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.files {
+		if strings.HasPrefix(name, dir+"/") {
+			delete(m.files, name)
+		}
+	}
+	return nil
+}
+
+func (m *memoryFileSystem) list(dir string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := map[string]bool{}
+	names := []string{}
+	for name := range m.files {
+		rest, ok := strings.CutPrefix(name, dir+"/")
+		if !ok {
+			continue
+		}
+		entry, _, _ := strings.Cut(rest, "/")
+		if !seen[entry] {
+			seen[entry] = true
+			names = append(names, entry)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,137 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// PrefixedStore keeps every key of the store it wraps under prefix, so
+// several deployments can share one bucket. Callers see keys without the
+// prefix, which is also how they are recorded in the metadata tables.
+type PrefixedStore struct {
+	store  BlobStore
+	prefix string
+}
+
+func WithKeyPrefix(store BlobStore, prefix string) *PrefixedStore {
+	return &PrefixedStore{store: store, prefix: prefix}
+}
+
+// Unwrap returns the store that holds the prefixed keys.
+func (s *PrefixedStore) Unwrap() BlobStore {
+	return s.store
+}
+
+// Unwrap peels wrappers such as PrefixedStore off store and returns the
+// driver underneath.
+func Unwrap(store BlobStore) BlobStore {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() BlobStore })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
+
+func (s *PrefixedStore) key(key string) string {
+	return s.prefix + key
+}
+
+func (s *PrefixedStore) info(info *ObjectInfo) *ObjectInfo {
+	if info != nil {
+		info.Key = strings.TrimPrefix(info.Key, s.prefix)
+	}
+	return info
+}
+
+func (s *PrefixedStore) Put(ctx context.Context, key string, body io.Reader, options PutOptions) (*ObjectInfo, error) {
+	info, err := s.store.Put(ctx, s.key(key), body, options)
+	return s.info(info), err
+}
+
+func (s *PrefixedStore) Get(ctx context.Context, key string, options GetOptions) (*Object, error) {
+	object, err := s.store.Get(ctx, s.key(key), options)
+	if object != nil {
+		s.info(&object.ObjectInfo)
+	}
+	return object, err
+}
+
+func (s *PrefixedStore) Head(ctx context.Context, key string, versionID string) (*ObjectInfo, error) {
+	info, err := s.store.Head(ctx, s.key(key), versionID)
+	return s.info(info), err
+}
+
+func (s *PrefixedStore) Copy(ctx context.Context, srcKey string, srcVersionID string, dstKey string) (*ObjectInfo, error) {
+	info, err := s.store.Copy(ctx, s.key(srcKey), srcVersionID, s.key(dstKey))
+	return s.info(info), err
+}
+
+func (s *PrefixedStore) Delete(ctx context.Context, key string, versionID string) error {
+	return s.store.Delete(ctx, s.key(key), versionID)
+}
+
+func (s *PrefixedStore) DeleteAllVersions(ctx context.Context, key string) error {
+	return s.store.DeleteAllVersions(ctx, s.key(key))
+}
+
+func (s *PrefixedStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := s.store.List(ctx, s.key(prefix))
+	for i := range objects {
+		s.info(&objects[i])
+	}
+	return objects, err
+}
+
+func (s *PrefixedStore) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	return s.store.ListPrefixes(ctx, s.key(prefix))
+}
+
+func (s *PrefixedStore) PresignGet(ctx context.Context, key string, options PresignGetOptions) (string, error) {
+	return s.store.PresignGet(ctx, s.key(key), options)
+}
+
+func (s *PrefixedStore) PresignPut(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error) {
+	return s.store.PresignPut(ctx, s.key(key), options)
+}
+
+func (s *PrefixedStore) PresignPost(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error) {
+	return s.store.PresignPost(ctx, s.key(key), options)
+}
+
+func (s *PrefixedStore) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	return s.store.CreateMultipartUpload(ctx, s.key(key), contentType)
+}
+
+func (s *PrefixedStore) PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, expiresIn time.Duration) (string, error) {
+	return s.store.PresignUploadPart(ctx, s.key(key), uploadID, partNumber, size, expiresIn)
+}
+
+func (s *PrefixedStore) UploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, body io.Reader) (*Part, error) {
+	return s.store.UploadPart(ctx, s.key(key), uploadID, partNumber, size, body)
+}
+
+func (s *PrefixedStore) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	return s.store.ListParts(ctx, s.key(key), uploadID)
+}
+
+func (s *PrefixedStore) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part) error {
+	return s.store.CompleteMultipartUpload(ctx, s.key(key), uploadID, parts)
+}
+
+func (s *PrefixedStore) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	return s.store.AbortMultipartUpload(ctx, s.key(key), uploadID)
+}
+
+func (s *PrefixedStore) ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error) {
+	uploads, err := s.store.ListMultipartUploads(ctx, s.key(prefix))
+	for i := range uploads {
+		uploads[i].Key = strings.TrimPrefix(uploads[i].Key, s.prefix)
+	}
+	return uploads, err
+}
+
+var _ BlobStore = (*PrefixedStore)(nil)
@@ -0,0 +1,100 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func keys(objects []ObjectInfo) []string {
+	keys := []string{}
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPrefixedStore(t *testing.T) {
+	ctx := context.Background()
+	bucket := NewMemoryStore(nil)
+	staging := WithKeyPrefix(bucket, "staging/")
+	prod := WithKeyPrefix(bucket, "prod/")
+
+	put := func(store BlobStore, key string, content string) *ObjectInfo {
+		t.Helper()
+		info, err := store.Put(ctx, key, strings.NewReader(content), PutOptions{ContentType: "text/plain"})
+		if err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+		return info
+	}
+
+	if info := put(staging, "users/user-1/a.txt", "staging"); info.Key != "users/user-1/a.txt" {
+		t.Errorf("Put returned key %q, want it without the prefix", info.Key)
+	}
+	put(prod, "users/user-1/a.txt", "prod")
+	if _, err := staging.Copy(ctx, "users/user-1/a.txt", "", "users/user-1/b.txt"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	uploadID, err := staging.CreateMultipartUpload(ctx, "users/user-1/c.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+
+	all, err := bucket.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := keys(all), []string{"prod/users/user-1/a.txt", "staging/users/user-1/a.txt", "staging/users/user-1/b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bucket holds %v, want %v", got, want)
+	}
+
+	// Each deployment sees its own keys, without the prefix.
+	listed, err := staging.List(ctx, "users/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if got, want := keys(listed), []string{"users/user-1/a.txt", "users/user-1/b.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("staging lists %v, want %v", got, want)
+	}
+	prefixes, err := prod.ListPrefixes(ctx, "users/")
+	if err != nil || !reflect.DeepEqual(prefixes, []string{"user-1"}) {
+		t.Errorf("prod lists prefixes %v (%v), want [user-1]", prefixes, err)
+	}
+	uploads, err := staging.ListMultipartUploads(ctx, "")
+	if err != nil || len(uploads) != 1 || uploads[0].Key != "users/user-1/c.txt" || uploads[0].UploadID != uploadID {
+		t.Errorf("staging lists multipart uploads %+v (%v), want users/user-1/c.txt", uploads, err)
+	}
+	if uploads, err := prod.ListMultipartUploads(ctx, ""); err != nil || len(uploads) != 0 {
+		t.Errorf("prod lists multipart uploads %+v (%v), want none", uploads, err)
+	}
+
+	object, err := prod.Get(ctx, "users/user-1/a.txt", GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	content, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	if string(content) != "prod" || object.Key != "users/user-1/a.txt" {
+		t.Errorf("prod reads %q from %q, want its own copy", content, object.Key)
+	}
+
+	if err := staging.DeleteAllVersions(ctx, "users/user-1/a.txt"); err != nil {
+		t.Fatalf("DeleteAllVersions: %v", err)
+	}
+	if _, err := staging.Head(ctx, "users/user-1/a.txt", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Head in staging after the delete: %v, want ErrNotFound", err)
+	}
+	if info, err := prod.Head(ctx, "users/user-1/a.txt", ""); err != nil || info.Key != "users/user-1/a.txt" {
+		t.Errorf("Head in prod after the staging delete: %+v, %v", info, err)
+	}
+
+	if Unwrap(staging) != BlobStore(bucket) {
+		t.Error("Unwrap did not return the bucket under the prefix")
+	}
+}
@@ -0,0 +1,582 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 keeps one checksum per object, so the CRC32C travels with the object as
+// user metadata (x-amz-meta-crc32c) next to the SHA-256 S3 verifies itself.
+const crc32cMetadataKey = "crc32c"
+
+// S3Store keeps objects in one versioned S3 bucket.
+type S3Store struct {
+	client *s3.Client
+	bucket string
+}
+
+func NewS3Store(client *s3.Client, bucket string) *S3Store {
+	return &S3Store{
+		client: client,
+		bucket: bucket,
+	}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, options PutOptions) (*ObjectInfo, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(options.ContentType),
+		// Without a checksum to check, the SDK sends one it computes as the
+		// body streams, so S3 keeps a SHA-256 either way.
+		ChecksumAlgorithm: s3types.ChecksumAlgorithmSha256,
+	}
+	if options.Size > 0 {
+		input.ContentLength = aws.Int64(options.Size)
+	}
+	if options.ChecksumSHA256 != "" {
+		input.ChecksumSHA256 = aws.String(options.ChecksumSHA256)
+	}
+	if options.ChecksumCRC32C != "" {
+		input.Metadata = map[string]string{crc32cMetadataKey: options.ChecksumCRC32C}
+	}
+	if options.IfMatch != "" {
+		input.IfMatch = aws.String(options.IfMatch)
+	}
+
+	output, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		if statusCode(err) == http.StatusPreconditionFailed {
+			return nil, ErrPreconditionFailed
+		}
+		log.Printf("Failed to upload %s to S3: %v", key, err)
+		return nil, err
+	}
+
+	return &ObjectInfo{
+		Key:            key,
+		VersionID:      aws.ToString(output.VersionId),
+		ETag:           aws.ToString(output.ETag),
+		ContentType:    options.ContentType,
+		ChecksumSHA256: aws.ToString(output.ChecksumSHA256),
+		ChecksumCRC32C: options.ChecksumCRC32C,
+	}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string, options GetOptions) (*Object, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if options.VersionID != "" {
+		input.VersionId = aws.String(options.VersionID)
+	}
+	if options.Range != "" {
+		input.Range = aws.String(options.Range)
+	}
+	if options.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(options.IfNoneMatch)
+	}
+
+	output, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		switch statusCode(err) {
+		case http.StatusNotModified:
+			return nil, ErrNotModified
+		case http.StatusRequestedRangeNotSatisfiable:
+			return nil, ErrInvalidRange
+		case http.StatusNotFound:
+			return nil, ErrNotFound
+		}
+		log.Printf("S3 download error for %s: %v", key, err)
+		return nil, err
+	}
+
+	return &Object{
+		ObjectInfo: ObjectInfo{
+			Key:          key,
+			VersionID:    aws.ToString(output.VersionId),
+			ETag:         aws.ToString(output.ETag),
+			ContentType:  aws.ToString(output.ContentType),
+			LastModified: aws.ToTime(output.LastModified),
+		},
+		Body:          output.Body,
+		ContentLength: aws.ToInt64(output.ContentLength),
+		ContentRange:  aws.ToString(output.ContentRange),
+	}, nil
+}
+
+func (s *S3Store) Head(ctx context.Context, key string, versionID string) (*ObjectInfo, error) {
+	input := &s3.HeadObjectInput{
+		Bucket:       aws.String(s.bucket),
+		Key:          aws.String(key),
+		ChecksumMode: s3types.ChecksumModeEnabled,
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	head, err := s.client.HeadObject(ctx, input)
+	if err != nil {
+		if statusCode(err) == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		log.Printf("S3 head error for %s: %v", key, err)
+		return nil, err
+	}
+
+	info := &ObjectInfo{
+		Key:          key,
+		VersionID:    aws.ToString(head.VersionId),
+		ETag:         aws.ToString(head.ETag),
+		ContentType:  aws.ToString(head.ContentType),
+		Size:         aws.ToInt64(head.ContentLength),
+		LastModified: aws.ToTime(head.LastModified),
+	}
+	info.ChecksumSHA256, info.ChecksumCRC32C = fullObjectChecksums(head)
+
+	return info, nil
+}
+
+// fullObjectChecksums returns the SHA-256 and CRC32C S3 reports for an object,
+// leaving out composite checksums, which only cover the parts of a multipart
+// upload. A CRC32C the server stored in the object metadata counts too.
+func fullObjectChecksums(head *s3.HeadObjectOutput) (checksumSHA256 string, checksumCRC32C string) {
+	if head.ChecksumType != s3types.ChecksumTypeComposite {
+		checksumSHA256 = aws.ToString(head.ChecksumSHA256)
+		checksumCRC32C = aws.ToString(head.ChecksumCRC32C)
+	}
+	if !isFullSHA256(checksumSHA256) {
+		checksumSHA256 = ""
+	}
+	if checksumCRC32C == "" || strings.Contains(checksumCRC32C, "-") {
+		checksumCRC32C = head.Metadata[crc32cMetadataKey]
+	}
+	return checksumSHA256, checksumCRC32C
+}
+
+// copySource is the CopySource header for a key. S3 URL-decodes the header,
+// so each segment of the key is escaped; the slashes between them stay. "+"
+// is escaped too, as some S3 implementations decode it to a space.
+func copySource(bucket string, key string, versionID string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+	}
+
+	source := bucket + "/" + strings.Join(segments, "/")
+	if versionID != "" {
+		source += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return source
+}
+
+func (s *S3Store) Copy(ctx context.Context, srcKey string, srcVersionID string, dstKey string) (*ObjectInfo, error) {
+	output, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(dstKey),
+		CopySource: aws.String(copySource(s.bucket, srcKey, srcVersionID)),
+		// Copies get no checksum unless asked for one.
+		ChecksumAlgorithm: s3types.ChecksumAlgorithmSha256,
+	})
+
+	if err != nil {
+		log.Printf("Failed to copy %s to %s: %v", srcKey, dstKey, err)
+		return nil, err
+	}
+
+	info := &ObjectInfo{
+		Key:       dstKey,
+		VersionID: aws.ToString(output.VersionId),
+	}
+	if output.CopyObjectResult != nil {
+		info.ETag = aws.ToString(output.CopyObjectResult.ETag)
+	}
+
+	return info, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string, versionID string) error {
+	input := &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	if _, err := s.client.DeleteObject(ctx, input); err != nil {
+		log.Printf("Failed to delete %s from S3: %v", key, err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAllVersions also removes delete markers, so a permanent delete does
+// not leave anything behind in the bucket.
+func (s *S3Store) DeleteAllVersions(ctx context.Context, key string) error {
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(key),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		var objects []s3types.ObjectIdentifier
+		for _, version := range page.Versions {
+			if aws.ToString(version.Key) == key {
+				objects = append(objects, s3types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+			}
+		}
+		for _, marker := range page.DeleteMarkers {
+			if aws.ToString(marker.Key) == key {
+				objects = append(objects, s3types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+			}
+		}
+
+		if len(objects) == 0 {
+			continue
+		}
+
+		_, err = s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list objects under %s: %v", prefix, err)
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(object.Key),
+				ETag:         aws.ToString(object.ETag),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *S3Store) ListPrefixes(ctx context.Context, prefix string) ([]string, error) {
+	prefixes := []string{}
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list prefixes under %s: %v", prefix, err)
+			return nil, err
+		}
+
+		for _, common := range page.CommonPrefixes {
+			segment := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(common.Prefix), prefix), "/")
+			if segment != "" {
+				prefixes = append(prefixes, segment)
+			}
+		}
+	}
+
+	return prefixes, nil
+}
+
+func (s *S3Store) PresignGet(ctx context.Context, key string, options PresignGetOptions) (string, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if options.ContentType != "" {
+		input.ResponseContentType = aws.String(options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(options.ContentDisposition)
+	}
+
+	request, err := s3.NewPresignClient(s.client).PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = options.ExpiresIn
+	})
+
+	if err != nil {
+		log.Printf("Failed to generate presigned URL: %v", err)
+		return "", err
+	}
+
+	return request.URL, nil
+}
+
+func (s *S3Store) PresignPut(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error) {
+	request, err := s3.NewPresignClient(s.client).PresignPutObject(ctx, s.uploadInput(key, options), func(opts *s3.PresignOptions) {
+		opts.Expires = options.ExpiresIn
+	})
+	if err != nil {
+		log.Printf("Failed to generate presigned PUT URL: %v", err)
+		return nil, err
+	}
+
+	// The client has to send the signed headers verbatim or S3 rejects the PUT.
+	headers := map[string]string{}
+	for name := range request.SignedHeader {
+		if name == "Host" {
+			continue
+		}
+		headers[name] = request.SignedHeader.Get(name)
+	}
+
+	return &PresignedRequest{URL: request.URL, Headers: headers}, nil
+}
+
+func (s *S3Store) PresignPost(ctx context.Context, key string, options PresignUploadOptions) (*PresignedRequest, error) {
+	request, err := s3.NewPresignClient(s.client).PresignPostObject(ctx, s.uploadInput(key, options), func(opts *s3.PresignPostOptions) {
+		opts.Expires = options.ExpiresIn
+		opts.Conditions = []interface{}{
+			[]interface{}{"content-length-range", options.Size, options.Size},
+			map[string]string{"Content-Type": options.ContentType},
+		}
+	})
+	if err != nil {
+		log.Printf("Failed to generate presigned POST policy: %v", err)
+		return nil, err
+	}
+
+	request.Values["Content-Type"] = options.ContentType
+	return &PresignedRequest{URL: request.URL, Fields: request.Values}, nil
+}
+
+func (s *S3Store) uploadInput(key string, options PresignUploadOptions) *s3.PutObjectInput {
+	return &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(options.ContentType),
+		ContentLength: aws.Int64(options.Size),
+	}
+}
+
+func (s *S3Store) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	output, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		log.Printf("Failed to create multipart upload for %s: %v", key, err)
+		return "", err
+	}
+
+	return aws.ToString(output.UploadId), nil
+}
+
+func (s *S3Store) PresignUploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, expiresIn time.Duration) (string, error) {
+	request, err := s3.NewPresignClient(s.client).PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		ContentLength: aws.Int64(size),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expiresIn
+	})
+
+	if err != nil {
+		log.Printf("Failed to presign part %d of %s: %v", partNumber, key, err)
+		return "", err
+	}
+
+	return request.URL, nil
+}
+
+func (s *S3Store) UploadPart(ctx context.Context, key string, uploadID string, partNumber int32, size int64, body io.Reader) (*Part, error) {
+	output, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		ContentLength: aws.Int64(size),
+		Body:          body,
+	})
+
+	if err != nil {
+		log.Printf("Failed to upload part %d of %s: %v", partNumber, key, err)
+		return nil, err
+	}
+
+	return &Part{
+		PartNumber: partNumber,
+		ETag:       aws.ToString(output.ETag),
+		Size:       size,
+	}, nil
+}
+
+func (s *S3Store) ListParts(ctx context.Context, key string, uploadID string) ([]Part, error) {
+	parts := []Part{}
+
+	paginator := s3.NewListPartsPaginator(s.client, &s3.ListPartsInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var noSuchUpload *s3types.NoSuchUpload
+			if errors.As(err, &noSuchUpload) {
+				return nil, ErrUploadNotFound
+			}
+			log.Printf("Failed to list parts of %s: %v", key, err)
+			return nil, err
+		}
+
+		for _, part := range page.Parts {
+			parts = append(parts, Part{
+				PartNumber: aws.ToInt32(part.PartNumber),
+				ETag:       aws.ToString(part.ETag),
+				Size:       aws.ToInt64(part.Size),
+			})
+		}
+	}
+
+	return parts, nil
+}
+
+func (s *S3Store) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []Part) error {
+	completed := make([]s3types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, s3types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.PartNumber),
+		})
+	}
+
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+		MultipartUpload: &s3types.CompletedMultipartUpload{
+			Parts: completed,
+		},
+	})
+
+	if err != nil {
+		log.Printf("Failed to complete multipart upload of %s: %v", key, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3Store) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+
+	if err != nil {
+		var noSuchUpload *s3types.NoSuchUpload
+		if errors.As(err, &noSuchUpload) {
+			return nil
+		}
+		log.Printf("Failed to abort multipart upload %s: %v", uploadID, err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *S3Store) ListMultipartUploads(ctx context.Context, prefix string) ([]MultipartUpload, error) {
+	uploads := []MultipartUpload{}
+
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	for {
+		output, err := s.client.ListMultipartUploads(ctx, input)
+		if err != nil {
+			log.Printf("Failed to list multipart uploads: %v", err)
+			return nil, err
+		}
+
+		for _, upload := range output.Uploads {
+			uploads = append(uploads, MultipartUpload{
+				Key:       aws.ToString(upload.Key),
+				UploadID:  aws.ToString(upload.UploadId),
+				Initiated: aws.ToTime(upload.Initiated),
+			})
+		}
+
+		if !aws.ToBool(output.IsTruncated) {
+			break
+		}
+		input.KeyMarker = output.NextKeyMarker
+		input.UploadIdMarker = output.NextUploadIdMarker
+	}
+
+	return uploads, nil
+}
+
+// isFullSHA256 reports whether checksum is a base64 SHA-256 of a whole
+// object. Composite checksums of multipart uploads look like "...-N".
+func isFullSHA256(checksum string) bool {
+	sum, err := base64.StdEncoding.DecodeString(checksum)
+	return err == nil && len(sum) == sha256.Size
+}
+
+func statusCode(err error) int {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode()
+	}
+	return 0
+}
+
+var _ BlobStore = (*S3Store)(nil)
@@ -0,0 +1,40 @@
+package blobstore
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCopySource(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		versionID string
+		want      string
+	}{
+		{name: "plain", key: "users/u1/abc", want: "bucket/users/u1/abc"},
+		{name: "space", key: "users/u1/my file.txt", want: "bucket/users/u1/my%20file.txt"},
+		{name: "plus", key: "users/u1/a+b.txt", want: "bucket/users/u1/a%2Bb.txt"},
+		{name: "percent", key: "users/u1/100%.txt", want: "bucket/users/u1/100%25.txt"},
+		{name: "non-ascii", key: "users/u1/résumé.pdf", want: "bucket/users/u1/r%C3%A9sum%C3%A9.pdf"},
+		{name: "question mark", key: "users/u1/why?.txt", want: "bucket/users/u1/why%3F.txt"},
+		{name: "version", key: "users/u1/a b", versionID: "v+1/2", want: "bucket/users/u1/a%20b?versionId=v%2B1%2F2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := copySource("bucket", tt.key, tt.versionID)
+			if got != tt.want {
+				t.Fatalf("copySource(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+
+			// S3 decodes the header back to the bucket and the original key.
+			path, _, _ := strings.Cut(got, "?")
+			decoded, err := url.PathUnescape(path)
+			if err != nil || decoded != "bucket/"+tt.key {
+				t.Fatalf("decoded %q (%v), want %q", decoded, err, "bucket/"+tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Command migrate applies the schema migrations of the metadata tables.
+//
+//	go run ./cmd/migrate up      apply the pending migrations
+//	go run ./cmd/migrate status  list the migrations and whether they are applied
+//
+// The server refuses to start until every migration has been applied.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/metastore"
+	"github.com/berkkaradalan/AwsGo-Storage/migrations"
+)
+
+func main() {
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] up|status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || (flag.Arg(0) != "up" && flag.Arg(0) != "status") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.MetadataStore.Driver == metastore.DriverBolt {
+		log.Fatalf("Schema migrations only apply to the dynamodb metadata store")
+	}
+
+	dbService := config.ConnectDatabase(cfg)
+	migrator := migrations.NewMigrator(dbService, migrations.All)
+	ctx := context.Background()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		log.Printf("Applied %d migrations", applied)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT\tDESCRIPTION")
+		for _, status := range statuses {
+			state, appliedAt := "pending", "-"
+			if status.Applied {
+				state, appliedAt = "applied", status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			if !status.Known {
+				state = "unknown"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, state, appliedAt, status.Description)
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,46 @@
+// Command rebuild-usage recomputes the per-month usage aggregates behind the
+// dashboard and the quota usage of every user from the storage table. Run it
+// once after upgrading, or whenever the numbers are suspected to be off, while
+// the server is stopped.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func main() {
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	blobStore := config.ConnectBlobStore(cfg)
+
+	stores, err := repositories.OpenStores(cfg, blobStore)
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+	defer stores.Close()
+
+	users, err := stores.Storage.RebuildUsage(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to rebuild usage aggregates: %v", err)
+	}
+
+	log.Printf("Rebuilt usage aggregates of %d users", users)
+
+	users, err = stores.Users.RebuildUsedBytes(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to rebuild quota usage: %v", err)
+	}
+
+	log.Printf("Rebuilt quota usage of %d users", users)
+}
@@ -0,0 +1,61 @@
+// Command reconcile compares the objects under users/<id>/ and blobs/ with the
+// storage and blob tables and prints the orphaned objects and dangling
+// records it finds as JSON. By default it is a dry run; with -fix orphans are
+// deleted and dangling records removed. It is safe to run while the server is
+// up.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "delete orphaned objects and remove dangling records")
+	minAge := flag.Duration("min-age", services.DefaultReconcileMinAge, "leave objects younger than this alone")
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	blobStore := config.ConnectBlobStore(cfg)
+
+	authConfig := config.NewAuthConfig(cfg)
+	storageConfig := config.NewStorageConfig(cfg)
+
+	stores, err := repositories.OpenStores(cfg, blobStore)
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+	defer stores.Close()
+
+	storageService := services.NewStorageService(stores.Storage, stores.Folders, stores.Users, stores.Grants, stores.Notifications, stores.FileRequests, authConfig, storageConfig)
+
+	report, err := storageService.Reconcile(context.Background(), models.ReconcileOptions{
+		Fix:    *fix,
+		MinAge: *minAge,
+	})
+	if err != nil {
+		log.Fatalf("Failed to reconcile storage: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	log.Printf("Checked %d users, %d objects and %d records: %d orphaned objects, %d dangling records",
+		report.Users, report.ObjectsScanned, report.RecordsScanned, len(report.OrphanObjects), len(report.DanglingRecords))
+}
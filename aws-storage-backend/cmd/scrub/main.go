@@ -0,0 +1,56 @@
+// Command scrub checks every stored file against its metadata: that its
+// object exists and has the recorded size and checksums. It prints the
+// findings as JSON and exits non-zero if there were any. It is safe to run
+// while the server is up.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func main() {
+	deep := flag.Bool("deep", false, "download every object and recompute its checksums")
+	quarantine := flag.Bool("quarantine", false, "block downloads of files that fail")
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	blobStore := config.ConnectBlobStore(cfg)
+
+	stores, err := repositories.OpenStores(cfg, blobStore)
+	if err != nil {
+		log.Fatalf("Failed to open metadata store: %v", err)
+	}
+	defer stores.Close()
+
+	report, err := stores.Storage.ScrubFiles(context.Background(), models.ScrubOptions{
+		Deep:       *deep,
+		Quarantine: *quarantine,
+	})
+	if err != nil {
+		log.Fatalf("Failed to scrub files: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	log.Printf("Checked %d files, skipped %d, found %d problems", report.Checked, report.Skipped, len(report.Findings))
+	if len(report.Findings) > 0 {
+		os.Exit(1)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -11,45 +13,86 @@ import (
 
 	"github.com/berkkaradalan/AwsGo-Storage/config"
 	"github.com/berkkaradalan/AwsGo-Storage/handlers"
+	"github.com/berkkaradalan/AwsGo-Storage/metastore"
+	"github.com/berkkaradalan/AwsGo-Storage/migrations"
 	"github.com/berkkaradalan/AwsGo-Storage/repositories"
 	"github.com/berkkaradalan/AwsGo-Storage/routers"
 	"github.com/berkkaradalan/AwsGo-Storage/services"
 )
 
 func main() {
-	env := config.LoadEnv()
-	log.Println("Env loaded successfully")
+	loader := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-	dbService := config.ConnectDatabase()
-	log.Println("Database connected successfully")
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	log.Println("Config loaded successfully")
 
-	s3Service := config.ConnectS3Bucket(env)
-	log.Println("S3 connected successfully")
+	blobStore := config.ConnectBlobStore(cfg)
+	log.Println("Blob store connected successfully")
+
+	var stores *repositories.Stores
+	switch cfg.MetadataStore.Driver {
+	case metastore.DriverBolt:
+		stores, err = repositories.OpenBoltStores(cfg.MetadataStore.Path, blobStore, cfg)
+		if err != nil {
+			log.Fatalf("Failed to open metadata store: %v", err)
+		}
+		defer stores.Close()
+	default:
+		dbService := config.ConnectDatabase(cfg)
+		if err := migrations.NewMigrator(dbService, migrations.All).Check(context.Background()); err != nil {
+			log.Fatalf("Refusing to start: %v", err)
+		}
+		stores = repositories.NewDynamoDBStores(dbService, blobStore, cfg)
+	}
+	log.Println("Database connected successfully")
 
 
-	authConfig := config.NewAuthConfig(*env)
+	authConfig := config.NewAuthConfig(cfg)
+	storageConfig := config.NewStorageConfig(cfg)
 
-	userRepo := repositories.NewUserRepository(dbService, s3Service)
-	userService := services.NewUserService(userRepo, authConfig)
+	userService := services.NewUserService(stores.Users, authConfig)
 	userHandler := handlers.NewUserHandler(userService)
 
-	storageRepo := repositories.NewStorageRepository(dbService, s3Service)
-	storageService := services.NewStorageService(storageRepo, authConfig)
+	storageService := services.NewStorageService(stores.Storage, stores.Folders, stores.Users, stores.Grants, stores.Notifications, stores.FileRequests, authConfig, storageConfig)
 	storageHandler := handlers.NewStorageHandler(storageService)
 
+	shareService := services.NewShareService(stores.Shares, stores.Storage)
+	shareHandler := handlers.NewShareHandler(shareService)
+
+	notificationService := services.NewNotificationService(stores.Notifications)
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+
+	blobHandler := handlers.NewBlobHandler(blobStore)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	storageService.StartUploadSweeper(jobsCtx, 10*time.Minute)
+	storageService.StartTrashPurger(jobsCtx, time.Hour)
+	storageService.StartOutboxWorker(jobsCtx, time.Minute)
+	if storageConfig.ScrubInterval > 0 {
+		storageService.StartScrubber(jobsCtx, storageConfig.ScrubInterval)
+	}
+	if storageConfig.ReconcileInterval > 0 {
+		storageService.StartReconciler(jobsCtx, storageConfig.ReconcileInterval)
+	}
+
 
-	router := routers.SetupRouter(userHandler, storageHandler, *env, authConfig)
+	router := routers.SetupRouter(userHandler, storageHandler, shareHandler, notificationHandler, blobHandler, cfg, authConfig)
 
 	srv := &http.Server{
-		Addr:    ":8080",
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler: router,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20, // 1 MB
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
 	}
 
 	go func() {
-		log.Println("Starting server on :8080")
+		log.Printf("Starting server on %s", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
@@ -60,8 +103,9 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopJobs()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
@@ -69,6 +113,4 @@ func main() {
 	}
 
 	log.Println("Server exited")
-	
-	_ = dbService
 }
\ No newline at end of file
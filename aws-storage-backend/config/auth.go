@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,6 +11,7 @@ import (
 type AuthConfig struct {
 	JWTSecret      string
 	JWTExpireHours int
+	AdminUserIDs   map[string]bool
 }
 
 type JWTClaims struct {
@@ -21,13 +23,32 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
-func NewAuthConfig(env Env) *AuthConfig {
+func NewAuthConfig(cfg *Config) *AuthConfig {
 	return &AuthConfig{
-		JWTSecret:      env.JWT_SECRET_KEY,
-		JWTExpireHours: env.JWT_EXPIRE_HOURS,
+		JWTSecret:      cfg.Auth.JWTSecret,
+		JWTExpireHours: cfg.Auth.JWTExpireHours,
+		AdminUserIDs:   parseAdminUserIDs(cfg.Auth.AdminUserIDs),
 	}
 }
 
+// IsAdmin reports whether the user is listed in auth.admin_user_ids. Admins
+// are named by user ID because the server assigns those; anyone can register
+// with any email.
+func (a *AuthConfig) IsAdmin(userID string) bool {
+	return a.AdminUserIDs[strings.TrimSpace(userID)]
+}
+
+func parseAdminUserIDs(values []string) map[string]bool {
+	userIDs := map[string]bool{}
+	for _, userID := range values {
+		userID = strings.TrimSpace(userID)
+		if userID != "" {
+			userIDs[userID] = true
+		}
+	}
+	return userIDs
+}
+
 func (a *AuthConfig) GenerateToken(userID string, userName string, userEmail string, UserCreatedAt int64, UserUpdatedAt int64) (string, error) {
 	claims := JWTClaims{
 		UserID: userID,
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// LoadAWSConfig loads the shared AWS config. The region and static
+// credentials from cfg override the default chain when they are set.
+func LoadAWSConfig(ctx context.Context, cfg *Config) (aws.Config, error) {
+	options := []func(*config.LoadOptions) error{}
+
+	if cfg.AWS.Region != "" {
+		options = append(options, config.WithRegion(cfg.AWS.Region))
+	}
+
+	if cfg.AWS.AccessKeyID != "" {
+		options = append(options, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, cfg.AWS.SessionToken),
+		))
+	}
+
+	return config.LoadDefaultConfig(ctx, options...)
+}
+
+// dynamoDBOptions points the client at aws.dynamodb_endpoint, e.g. DynamoDB
+// Local or LocalStack.
+func dynamoDBOptions(cfg *Config) func(*dynamodb.Options) {
+	return func(o *dynamodb.Options) {
+		if cfg.AWS.DynamoDBEndpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.AWS.DynamoDBEndpoint)
+		}
+	}
+}
+
+// s3Options points the client at aws.s3_endpoint, e.g. MinIO or LocalStack.
+// Presigned URLs are built from the same options, so they use that endpoint
+// too.
+func s3Options(cfg *Config) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if cfg.AWS.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.AWS.S3Endpoint)
+		}
+		o.UsePathStyle = cfg.AWS.S3ForcePathStyle
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestAWSEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoint  string
+		pathStyle bool
+		wantURL   string
+	}{
+		{name: "AWS", wantURL: "https://bucket.s3.eu-west-1.amazonaws.com/users/a.txt?"},
+		{name: "MinIO", endpoint: "http://localhost:9000", pathStyle: true, wantURL: "http://localhost:9000/bucket/users/a.txt?"},
+		{name: "virtual-hosted custom endpoint", endpoint: "https://storage.example.com", wantURL: "https://bucket.storage.example.com/users/a.txt?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Keep the developer's own AWS setup out of the test.
+			t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+			t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+			t.Setenv("AWS_PROFILE", "")
+			t.Setenv("AWS_ENDPOINT_URL", "")
+			t.Setenv("AWS_ENDPOINT_URL_S3", "")
+
+			cfg := Defaults()
+			cfg.AWS.Region = "eu-west-1"
+			cfg.AWS.AccessKeyID = "minio"
+			cfg.AWS.SecretAccessKey = "minio-secret"
+			cfg.AWS.S3Endpoint = tt.endpoint
+			cfg.AWS.DynamoDBEndpoint = tt.endpoint
+			cfg.AWS.S3ForcePathStyle = tt.pathStyle
+
+			awsConfig, err := LoadAWSConfig(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("LoadAWSConfig: %v", err)
+			}
+			credentials, err := awsConfig.Credentials.Retrieve(context.Background())
+			if err != nil || credentials.AccessKeyID != "minio" || credentials.SecretAccessKey != "minio-secret" {
+				t.Errorf("credentials = %+v (%v), want the static ones", credentials, err)
+			}
+
+			var dynamoDB dynamodb.Options
+			dynamoDBOptions(cfg)(&dynamoDB)
+			if aws.ToString(dynamoDB.BaseEndpoint) != tt.endpoint {
+				t.Errorf("DynamoDB endpoint = %q, want %q", aws.ToString(dynamoDB.BaseEndpoint), tt.endpoint)
+			}
+
+			// Presigned URLs are what clients see of the endpoint.
+			presigner := s3.NewPresignClient(s3.NewFromConfig(awsConfig, s3Options(cfg)))
+			request, err := presigner.PresignGetObject(context.Background(), &s3.GetObjectInput{
+				Bucket: aws.String("bucket"),
+				Key:    aws.String("users/a.txt"),
+			}, s3.WithPresignExpires(time.Minute))
+			if err != nil {
+				t.Fatalf("PresignGetObject: %v", err)
+			}
+			if !strings.HasPrefix(request.URL, tt.wantURL) {
+				t.Errorf("URL = %s, want it to start with %s", request.URL, tt.wantURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"log"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+)
+
+// ConnectBlobStore opens the blob store named by blob_store.driver. The local
+// and memory drivers answer their own signed URLs under
+// blob_store.public_url/blobs/. Every key gets the namespace key prefix.
+func ConnectBlobStore(cfg *Config) blobstore.BlobStore {
+	store := connectBlobDriver(cfg)
+	if cfg.Namespace.KeyPrefix == "" {
+		return store
+	}
+	return blobstore.WithKeyPrefix(store, cfg.Namespace.KeyPrefix)
+}
+
+func connectBlobDriver(cfg *Config) blobstore.BlobStore {
+	switch cfg.BlobStore.Driver {
+	case blobstore.DriverLocal:
+		signer := blobstore.NewURLSigner(cfg.BlobStore.URLSecret, cfg.BlobStore.PublicURL)
+		store, err := blobstore.NewLocalStore(cfg.BlobStore.Path, signer)
+		if err != nil {
+			log.Printf("Couldn't open blob store at %v. Here's why: %v\n", cfg.BlobStore.Path, err)
+			panic(err)
+		}
+		return store
+	case blobstore.DriverMemory:
+		log.Println("Using in-memory blob store, stored files are lost on restart")
+		signer := blobstore.NewURLSigner(cfg.BlobStore.URLSecret, cfg.BlobStore.PublicURL)
+		return blobstore.NewMemoryStore(signer)
+	default:
+		s3Service := ConnectS3Bucket(cfg)
+		return blobstore.NewS3Store(s3Service.Client, cfg.AWS.S3Bucket)
+	}
+}
@@ -7,17 +7,17 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	dynamotypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"github.com/berkkaradalan/AwsGo-Storage/metastore"
 )
 
 type DynamoDBService struct {
-	Client *dynamodb.Client
+	Client metastore.Client
 }
 
 type S3BucketService struct {
@@ -79,6 +79,10 @@ func CreateUserTableInput() dynamodb.CreateTableInput {
 				AttributeName: aws.String("UserEmail"),
 				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
 			},
+			{
+				AttributeName: aws.String("UserName"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
 		},
 		BillingMode: dynamotypes.BillingModePayPerRequest,
 		GlobalSecondaryIndexes: []dynamotypes.GlobalSecondaryIndex{
@@ -94,6 +98,18 @@ func CreateUserTableInput() dynamodb.CreateTableInput {
 					ProjectionType: dynamotypes.ProjectionTypeAll,
 				},
 			},
+			{
+				IndexName: aws.String("UserNameIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserName"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
 		},
 	}
 }
@@ -120,6 +136,30 @@ func CreateStorageTableInput() dynamodb.CreateTableInput {
 				AttributeName: aws.String("UploadedAt"),
 				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (ISO 8601 format)
 			},
+			{
+				AttributeName: aws.String("FileName"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("FileSize"),
+				AttributeType: dynamotypes.ScalarAttributeTypeN, // Number
+			},
+			{
+				AttributeName: aws.String("Status"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("ExpiresAt"),
+				AttributeType: dynamotypes.ScalarAttributeTypeN, // Number (unix seconds)
+			},
+			{
+				AttributeName: aws.String("ParentID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("ContentHash"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (hex SHA-256)
+			},
 		},
 		BillingMode: dynamotypes.BillingModePayPerRequest,
 		GlobalSecondaryIndexes: []dynamotypes.GlobalSecondaryIndex{
@@ -151,49 +191,494 @@ func CreateStorageTableInput() dynamodb.CreateTableInput {
 					ProjectionType: dynamotypes.ProjectionTypeAll,
 				},
 			},
+			{
+				IndexName: aws.String("UserIDFileNameIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("FileName"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+			{
+				IndexName: aws.String("UserIDFileSizeIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("FileSize"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+			{
+				IndexName: aws.String("UserIDParentIDIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("ParentID"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+			{
+				IndexName: aws.String("UserIDContentHashIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("ContentHash"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+			{
+				// Sparse index: only items that can lapse (pending uploads) carry ExpiresAt.
+				IndexName: aws.String("StatusExpiresAtIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("Status"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("ExpiresAt"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
 		},
 	}
 }
 
-func ConnectDatabase() *DynamoDBService {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+func CreateMultipartUploadTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("multipart_upload"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("ObjectID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("ObjectID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+	}
+}
 
-	if err != nil {
-		log.Printf("Failed to connect to database %v", err)
-		panic(err)
+func CreateFileVersionTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("file_version"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("ObjectID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+			{
+				AttributeName: aws.String("VersionID"),
+				KeyType:       dynamotypes.KeyTypeRange, // Sort key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("ObjectID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("VersionID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (S3 version ID)
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
 	}
+}
 
-	client := dynamodb.NewFromConfig(cfg)
-	service := &DynamoDBService{Client: client}
+func CreateShareLinkTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("share_link"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("Token"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("Token"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("ObjectID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: []dynamotypes.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("ObjectIDIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("ObjectID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+		},
+	}
+}
 
-	userTableCheck, err := service.TableExists(context.TODO(), "user")
+func CreateAccessGrantTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("access_grant"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("ResourceID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+			{
+				AttributeName: aws.String("GranteeID"),
+				KeyType:       dynamotypes.KeyTypeRange, // Sort key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("ResourceID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("GranteeID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("CreatedAt"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (ISO 8601 format)
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: []dynamotypes.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("GranteeIDCreatedAtIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("GranteeID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("CreatedAt"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+		},
+	}
+}
 
-	if err != nil {
-		panic(err)
+func CreateNotificationTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("notification"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("UserID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+			{
+				AttributeName: aws.String("NotificationID"),
+				KeyType:       dynamotypes.KeyTypeRange, // Sort key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("UserID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("NotificationID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (time-prefixed)
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
 	}
+}
 
-	if !userTableCheck {
-		log.Println("Creating user table")
-		userTableInput := CreateUserTableInput()
-		_, err := service.CreateTable(context.Background(), userTableInput, "user")
-		if err != nil {
-			panic(err)
-		}
+func CreateFileRequestTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("file_request"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("RequestID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("RequestID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("Token"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("UserID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: []dynamotypes.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("TokenIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("Token"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+			{
+				IndexName: aws.String("UserIDIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+		},
 	}
+}
 
-	storageTableCheck, err := service.TableExists(context.TODO(), "storage")
+func CreateFolderTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("folder"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("FolderID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("FolderID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("UserID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("ParentID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: []dynamotypes.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String("UserIDParentIDIndex"),
+				KeySchema: []dynamotypes.KeySchemaElement{
+					{
+						AttributeName: aws.String("UserID"),
+						KeyType:       dynamotypes.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("ParentID"),
+						KeyType:       dynamotypes.KeyTypeRange, // Sort key
+					},
+				},
+				Projection: &dynamotypes.Projection{
+					ProjectionType: dynamotypes.ProjectionTypeAll,
+				},
+			},
+		},
+	}
+}
 
-	if err != nil {
-		panic(err)
+func CreateBlobTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("blob"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("BlobKey"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("BlobKey"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (S3 key)
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+	}
+}
+
+func CreateOutboxTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("outbox"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("OutboxID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("OutboxID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+	}
+}
+
+func CreateUsageAggregateTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("usage_aggregate"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("UserID"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+			{
+				AttributeName: aws.String("UsageKey"),
+				KeyType:       dynamotypes.KeyTypeRange, // Sort key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("UserID"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String
+			},
+			{
+				AttributeName: aws.String("UsageKey"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (month, or month#contentType)
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+	}
+}
+
+// CreateUserUniqueTableInput holds one item per email and per username in use,
+// written in the same transaction as the user that claims it.
+func CreateUserUniqueTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("user_unique"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("UniqueKey"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("UniqueKey"),
+				AttributeType: dynamotypes.ScalarAttributeTypeS, // String (email#... or username#...)
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
+	}
+}
+
+// CreateSchemaMigrationTableInput is the table the migrations package records
+// applied schema migrations in.
+func CreateSchemaMigrationTableInput() dynamodb.CreateTableInput {
+	return dynamodb.CreateTableInput{
+		TableName: aws.String("schema_migration"),
+		KeySchema: []dynamotypes.KeySchemaElement{
+			{
+				AttributeName: aws.String("Version"),
+				KeyType:       dynamotypes.KeyTypeHash, // Partition key
+			},
+		},
+		AttributeDefinitions: []dynamotypes.AttributeDefinition{
+			{
+				AttributeName: aws.String("Version"),
+				AttributeType: dynamotypes.ScalarAttributeTypeN, // Number
+			},
+		},
+		BillingMode: dynamotypes.BillingModePayPerRequest,
 	}
+}
+
+// TableInputs lists every table the service uses, as ConnectDatabase creates
+// it when it is missing.
+func TableInputs() []dynamodb.CreateTableInput {
+	return []dynamodb.CreateTableInput{
+		CreateUserTableInput(),
+		CreateStorageTableInput(),
+		CreateMultipartUploadTableInput(),
+		CreateFolderTableInput(),
+		CreateFileVersionTableInput(),
+		CreateShareLinkTableInput(),
+		CreateAccessGrantTableInput(),
+		CreateNotificationTableInput(),
+		CreateFileRequestTableInput(),
+		CreateUsageAggregateTableInput(),
+		CreateBlobTableInput(),
+		CreateOutboxTableInput(),
+		CreateUserUniqueTableInput(),
+		CreateSchemaMigrationTableInput(),
+	}
+}
+
+func ConnectDatabase(cfg *Config) *DynamoDBService {
+	service := &DynamoDBService{Client: ConnectMetadataStore(cfg)}
+
+	for _, tableInput := range TableInputs() {
+		tableName := aws.ToString(tableInput.TableName)
+		tableCheck, err := service.TableExists(context.TODO(), tableName)
 
-	if !storageTableCheck {
-		log.Println("Creating storage table")
-		storageTableInput := CreateStorageTableInput()
-		_, err := service.CreateTable(context.Background(), storageTableInput, "storage")
 		if err != nil {
 			panic(err)
 		}
+
+		if !tableCheck {
+			log.Printf("Creating %s table", cfg.TableName(tableName))
+			_, err := service.CreateTable(context.Background(), tableInput, tableName)
+			if err != nil {
+				panic(err)
+			}
+		}
 	}
 
 	return service
@@ -266,35 +751,94 @@ func (client *S3BucketService) CreateBucket(ctx context.Context, bucketName stri
 	return err
 }
 
-func ConnectS3Bucket(env *Env) *S3BucketService {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+// ConfigureCORS lets browsers PUT/POST straight to the bucket with presigned
+// requests from the given origins.
+func (client *S3BucketService) ConfigureCORS(ctx context.Context, bucketName string, origins []string) error {
+	_, err := client.Client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket: aws.String(bucketName),
+		CORSConfiguration: &s3types.CORSConfiguration{
+			CORSRules: []s3types.CORSRule{
+				{
+					AllowedOrigins: origins,
+					AllowedMethods: []string{"GET", "HEAD", "PUT", "POST"},
+					AllowedHeaders: []string{"*"},
+					ExposeHeaders:  []string{"ETag"},
+					MaxAgeSeconds:  aws.Int32(3600),
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		// MinIO and some other S3-compatible stores have no bucket CORS API
+		// and set their CORS rules server-wide instead.
+		var apiError smithy.APIError
+		if errors.As(err, &apiError) && apiError.ErrorCode() == "NotImplemented" {
+			log.Printf("Bucket %v does not support CORS configuration, skipping. Configure CORS on the server instead.\n", bucketName)
+			return nil
+		}
+		log.Printf("Couldn't configure CORS for bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return err
+}
+
+// EnableVersioning keeps every overwritten object as a noncurrent version, so
+// replaced file content can be listed and restored.
+func (client *S3BucketService) EnableVersioning(ctx context.Context, bucketName string) error {
+	_, err := client.Client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+		VersioningConfiguration: &s3types.VersioningConfiguration{
+			Status: s3types.BucketVersioningStatusEnabled,
+		},
+	})
+
+	if err != nil {
+		log.Printf("Couldn't enable versioning for bucket %v. Here's why: %v\n", bucketName, err)
+	}
+	return err
+}
+
+func ConnectS3Bucket(cfg *Config) *S3BucketService {
+	awsConfig, err := LoadAWSConfig(context.TODO(), cfg)
 
 	if err != nil {
 		log.Println(err)
 		panic(err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	client := s3.NewFromConfig(awsConfig, s3Options(cfg))
 
 	service := &S3BucketService{
 		Client: client,
 	}
 
-	userStorageBucket, err := service.BucketExists(context.Background(), env.S3_BUCKET_NAME)
+	userStorageBucket, err := service.BucketExists(context.Background(), cfg.AWS.S3Bucket)
 
 	if err != nil {
 		panic(err)
 	}
 
 	if !userStorageBucket {
-		log.Printf("Creating user bucket with name : %v ", env.S3_BUCKET_NAME)
-		log.Println(cfg.Region)
-		err = service.CreateBucket(context.Background(), env.S3_BUCKET_NAME, cfg.Region)
+		log.Printf("Creating user bucket with name : %v ", cfg.AWS.S3Bucket)
+		log.Println(awsConfig.Region)
+		err = service.CreateBucket(context.Background(), cfg.AWS.S3Bucket, awsConfig.Region)
 
 		if err != nil {
 			panic(err)
 		}
 	}
 
+	err = service.EnableVersioning(context.Background(), cfg.AWS.S3Bucket)
+
+	if err != nil {
+		panic(err)
+	}
+
+	err = service.ConfigureCORS(context.Background(), cfg.AWS.S3Bucket, cfg.Server.CORSOrigins)
+
+	if err != nil {
+		panic(err)
+	}
+
 	return service
 }
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// setting is one leaf field of Config.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// settingsOf lists the settings of cfg in declaration order, with values that
+// write straight into cfg.
+func settingsOf(cfg *Config) []setting {
+	var settings []setting
+	var walk func(prefix string, value reflect.Value)
+	walk = func(prefix string, value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			key := prefix + field.Tag.Get("key")
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(key+".", value.Field(i))
+				continue
+			}
+			settings = append(settings, setting{
+				key:   key,
+				env:   field.Tag.Get("env"),
+				usage: field.Tag.Get("usage"),
+				value: value.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return settings
+}
+
+// settingName is how errors refer to a setting: its key and its environment
+// variable.
+func settingName(key string) string {
+	for _, s := range settingsOf(&Config{}) {
+		if s.key == key {
+			return fmt.Sprintf("%s (%s)", s.key, s.env)
+		}
+	}
+	return key
+}
+
+// set parses raw into the setting. raw is a string from the environment or a
+// flag, or whatever a config file decoded to.
+func (s setting) set(raw any) error {
+	if list, ok := raw.([]any); ok {
+		if s.value.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		values := make([]string, 0, len(list))
+		for _, item := range list {
+			values = append(values, fmt.Sprint(item))
+		}
+		s.value.Set(reflect.ValueOf(values))
+		return nil
+	}
+
+	text := strings.TrimSpace(fmt.Sprint(raw))
+	switch s.value.Interface().(type) {
+	case time.Duration:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("expected a duration like 30s or 5m, got %q", text)
+		}
+		s.value.SetInt(int64(duration))
+	case int, int64:
+		number, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || s.value.OverflowInt(number) {
+			return fmt.Errorf("expected a whole number, got %q", text)
+		}
+		s.value.SetInt(number)
+	case bool:
+		enabled, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", text)
+		}
+		s.value.SetBool(enabled)
+	case []string:
+		values := []string{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(values))
+	case string:
+		s.value.SetString(text)
+	}
+	return nil
+}
+
+// Loader remembers the flags a command was started with until Load applies
+// them.
+type Loader struct {
+	configFile string
+	flags      map[string]string
+}
+
+// RegisterFlags adds -config and one flag per setting, named by its key, to
+// flags. Call it before flags.Parse.
+func RegisterFlags(flags *flag.FlagSet) *Loader {
+	loader := &Loader{flags: map[string]string{}}
+
+	flags.StringVar(&loader.configFile, "config", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	for _, s := range settingsOf(&Config{}) {
+		key := s.key
+		flags.Func(key, fmt.Sprintf("%s ($%s)", s.usage, s.env), func(value string) error {
+			loader.flags[key] = value
+			return nil
+		})
+	}
+
+	return loader
+}
+
+// Load builds the configuration. Later sources override earlier ones:
+// defaults, the config file from -config or CONFIG_FILE, the environment
+// (including a .env file in the working directory, if there is one), then
+// flags.
+func (l *Loader) Load() (*Config, error) {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("couldn't read .env: %w", err)
+	}
+
+	cfg := Defaults()
+	settings := map[string]setting{}
+	for _, s := range settingsOf(cfg) {
+		settings[s.key] = s
+	}
+
+	configFile := l.configFile
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if configFile != "" {
+		values, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s, ok := settings[key]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown setting %q", configFile, key)
+			}
+			if err := s.set(values[key]); err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", configFile, key, err)
+			}
+		}
+	}
+
+	for _, s := range settingsOf(cfg) {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("environment variable %s: %w", s.env, err)
+			}
+		}
+	}
+
+	for key, value := range l.flags {
+		if err := settings[key].set(value); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", key, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readConfigFile decodes a YAML or TOML file into a flat map from setting
+// keys to values.
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read config file: %w", err)
+	}
+
+	document := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse config file %s: %w", path, err)
+	}
+
+	values := map[string]any{}
+	var flatten func(prefix string, section map[string]any) error
+	flatten = func(prefix string, section map[string]any) error {
+		for name, value := range section {
+			key := prefix + name
+			if nested, ok := value.(map[string]any); ok {
+				if err := flatten(key+".", nested); err != nil {
+					return err
+				}
+				continue
+			}
+			if value == nil {
+				return fmt.Errorf("%s: %s has no value", path, key)
+			}
+			values[key] = value
+		}
+		return nil
+	}
+	if err := flatten("", document); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      string
+		flag     string
+		wantPort int
+	}{
+		{name: "defaults", wantPort: 8080},
+		{name: "file over defaults", file: "1001", wantPort: 1001},
+		{name: "environment over file", file: "1001", env: "1002", wantPort: 1002},
+		{name: "flag over environment", file: "1001", env: "1002", flag: "1003", wantPort: 1003},
+		{name: "flag without file", env: "1002", flag: "1003", wantPort: 1003},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET_KEY", "jwt-secret")
+			t.Setenv("S3_BUCKET_NAME", "bucket")
+			t.Setenv("PORT", tt.env)
+			t.Setenv("CONFIG_FILE", "")
+
+			var args []string
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte("server:\n  port: "+tt.file+"\n"), 0o600); err != nil {
+					t.Fatalf("write config file: %v", err)
+				}
+				args = append(args, "-config", path)
+			}
+			if tt.flag != "" {
+				args = append(args, "-server.port", tt.flag)
+			}
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			loader := RegisterFlags(flags)
+			if err := flags.Parse(args); err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			cfg, err := loader.Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.Port != tt.wantPort {
+				t.Errorf("server.port = %d, want %d", cfg.Server.Port, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown setting in file", file: "server:\n  prot: 80\n", wantErr: `unknown setting "server.prot"`},
+		{name: "bad value in file", file: "storage:\n  trash_retention_days: soon\n", wantErr: "storage.trash_retention_days: expected a whole number"},
+		{name: "bad value in environment", env: map[string]string{"SERVER_READ_TIMEOUT": "10"}, wantErr: "SERVER_READ_TIMEOUT: expected a duration"},
+		{name: "missing JWT secret", env: map[string]string{"JWT_SECRET_KEY": ""}, wantErr: "auth.jwt_secret (JWT_SECRET_KEY) is required"},
+		// The URL secret used to fall back to the JWT secret.
+		{name: "local store without URL secret", env: map[string]string{"BLOB_STORE": "local"}, wantErr: "blob_store.url_secret (BLOB_URL_SECRET) is required by the local blob store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET_KEY", "jwt-secret")
+			t.Setenv("S3_BUCKET_NAME", "bucket")
+			t.Setenv("CONFIG_FILE", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatalf("write config file: %v", err)
+				}
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			loader := RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+			_, err := loader.Load()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load: %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/berkkaradalan/AwsGo-Storage/metastore"
+)
+
+// ConnectMetadataStore returns the DynamoDB client of the dynamodb metadata
+// store. Table names get the namespace prefix and suffix. The bolt store is
+// opened by repositories.OpenBoltStores instead.
+func ConnectMetadataStore(cfg *Config) metastore.Client {
+	awsConfig, err := LoadAWSConfig(context.TODO(), cfg)
+
+	if err != nil {
+		log.Printf("Failed to connect to database %v", err)
+		panic(err)
+	}
+
+	client := metastore.Client(dynamodb.NewFromConfig(awsConfig, dynamoDBOptions(cfg)))
+	if cfg.Namespace.TablePrefix == "" && cfg.Namespace.TableSuffix == "" {
+		return client
+	}
+	return metastore.WithTableNames(client, cfg.TableName)
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config is the whole service configuration. Every setting has a key, which
+// is its name in a config file and as a flag (server.port, -server.port), and
+// an environment variable. Load fills it from defaults, a config file, the
+// environment and flags, in that order, then validates it.
+type Config struct {
+	Server        ServerSettings        `key:"server"`
+	Auth          AuthSettings          `key:"auth"`
+	Storage       StorageSettings       `key:"storage"`
+	Scrub         ScrubSettings         `key:"scrub"`
+	Reconcile     ReconcileSettings     `key:"reconcile"`
+	AWS           AWSSettings           `key:"aws"`
+	BlobStore     BlobStoreSettings     `key:"blob_store"`
+	MetadataStore MetadataStoreSettings `key:"metadata_store"`
+	Namespace     NamespaceSettings     `key:"namespace"`
+}
+
+type ServerSettings struct {
+	Port            int           `key:"port" env:"PORT" usage:"port the HTTP server listens on"`
+	ReadTimeout     time.Duration `key:"read_timeout" env:"SERVER_READ_TIMEOUT" usage:"how long a request may take to arrive"`
+	WriteTimeout    time.Duration `key:"write_timeout" env:"SERVER_WRITE_TIMEOUT" usage:"how long a response may take to send"`
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" usage:"how long in-flight requests get on shutdown"`
+	MaxHeaderBytes  int           `key:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" usage:"largest accepted request header"`
+	CORSOrigins     []string      `key:"cors_origins" env:"CORS_ORIGINS" usage:"comma-separated origins the frontend is served from"`
+}
+
+type AuthSettings struct {
+	JWTSecret      string   `key:"jwt_secret" env:"JWT_SECRET_KEY" usage:"secret that signs login tokens"`
+	JWTExpireHours int      `key:"jwt_expire_hours" env:"JWT_EXPIRE_HOURS" usage:"how long login tokens are valid"`
+	AdminUserIDs   []string `key:"admin_user_ids" env:"ADMIN_USER_IDS" usage:"comma-separated IDs of admin users"`
+}
+
+type StorageSettings struct {
+	MaxUploadSizeMB          int64    `key:"max_upload_size_mb" env:"MAX_UPLOAD_SIZE_MB" usage:"largest file accepted in one request"`
+	MaxMultipartUploadSizeMB int64    `key:"max_multipart_upload_size_mb" env:"MAX_MULTIPART_UPLOAD_SIZE_MB" usage:"largest file accepted as a multipart upload"`
+	AllowedContentTypes      []string `key:"allowed_content_types" env:"ALLOWED_CONTENT_TYPES" usage:"comma-separated MIME types users may upload"`
+	TrashRetentionDays       int      `key:"trash_retention_days" env:"TRASH_RETENTION_DAYS" usage:"days before trashed files are purged"`
+	MaxFileVersions          int      `key:"max_file_versions" env:"MAX_FILE_VERSIONS" usage:"versions kept per file unless a user sets their own"`
+	DefaultQuotaMB           int64    `key:"default_quota_mb" env:"DEFAULT_STORAGE_QUOTA_MB" usage:"storage quota of users without one of their own"`
+	DedupScope               string   `key:"dedup_scope" env:"DEDUP_SCOPE" usage:"share identical content per user or globally (user|global)"`
+}
+
+type ScrubSettings struct {
+	IntervalHours int    `key:"interval_hours" env:"SCRUB_INTERVAL_HOURS" usage:"hours between integrity scrubs, 0 to turn them off"`
+	Action        string `key:"action" env:"SCRUB_ACTION" usage:"what scrubs do with failing files (report|quarantine)"`
+}
+
+type ReconcileSettings struct {
+	IntervalHours int    `key:"interval_hours" env:"RECONCILE_INTERVAL_HOURS" usage:"hours between reconciliations, 0 to turn them off"`
+	Mode          string `key:"mode" env:"RECONCILE_MODE" usage:"whether reconciliations repair what they find (dry-run|fix)"`
+}
+
+type AWSSettings struct {
+	Region           string `key:"region" env:"AWS_REGION" usage:"AWS region, defaults to the shared AWS config"`
+	AccessKeyID      string `key:"access_key_id" env:"AWS_ACCESS_KEY_ID" usage:"static access key, defaults to the AWS credential chain"`
+	SecretAccessKey  string `key:"secret_access_key" env:"AWS_SECRET_ACCESS_KEY" usage:"static secret key"`
+	SessionToken     string `key:"session_token" env:"AWS_SESSION_TOKEN" usage:"static session token"`
+	DynamoDBEndpoint string `key:"dynamodb_endpoint" env:"DYNAMODB_ENDPOINT" usage:"custom DynamoDB endpoint URL"`
+	S3Endpoint       string `key:"s3_endpoint" env:"S3_ENDPOINT" usage:"custom S3 endpoint URL"`
+	S3ForcePathStyle bool   `key:"s3_force_path_style" env:"S3_FORCE_PATH_STYLE" usage:"address buckets as endpoint/bucket/key"`
+	S3Bucket         string `key:"s3_bucket" env:"S3_BUCKET_NAME" usage:"bucket that holds stored files"`
+}
+
+type BlobStoreSettings struct {
+	Driver    string `key:"driver" env:"BLOB_STORE" usage:"where file bytes are stored (s3|local|memory)"`
+	Path      string `key:"path" env:"BLOB_STORE_PATH" usage:"directory of the local driver"`
+	URLSecret string `key:"url_secret" env:"BLOB_URL_SECRET" usage:"secret that signs local and memory URLs, distinct from auth.jwt_secret"`
+	PublicURL string `key:"public_url" env:"BLOB_PUBLIC_URL" usage:"base URL of this server in local and memory URLs"`
+}
+
+type MetadataStoreSettings struct {
+	Driver string `key:"driver" env:"METADATA_STORE" usage:"where metadata tables live (dynamodb|bolt)"`
+	Path   string `key:"path" env:"METADATA_STORE_PATH" usage:"database file of the bolt driver"`
+}
+
+// NamespaceSettings keep deployments that share an AWS account, such as dev,
+// staging and prod, out of each other's tables and objects.
+type NamespaceSettings struct {
+	TablePrefix string `key:"table_prefix" env:"TABLE_PREFIX" usage:"prepended to every table name, e.g. staging_"`
+	TableSuffix string `key:"table_suffix" env:"TABLE_SUFFIX" usage:"appended to every table name"`
+	KeyPrefix   string `key:"key_prefix" env:"BLOB_KEY_PREFIX" usage:"prepended to every stored object key, e.g. staging/"`
+}
+
+// Defaults is the configuration before any source is applied. The JWT secret
+// has no default and must always be set.
+func Defaults() *Config {
+	return &Config{
+		Server: ServerSettings{
+			Port:            8080,
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+			MaxHeaderBytes:  1 << 20, // 1 MB
+			CORSOrigins:     []string{"http://localhost:3000"},
+		},
+		Auth: AuthSettings{
+			JWTExpireHours: 24,
+		},
+		Storage: StorageSettings{
+			MaxUploadSizeMB:          50,
+			MaxMultipartUploadSizeMB: 1024 * 1024, // 1 TB
+			AllowedContentTypes: []string{
+				"image/jpeg",
+				"image/png",
+				"image/gif",
+				"image/webp",
+				"application/pdf",
+				"application/zip",
+				"application/x-tar",
+				"application/gzip",
+				"application/x-7z-compressed",
+			},
+			TrashRetentionDays: 30,
+			MaxFileVersions:    10,
+			DefaultQuotaMB:     5120,
+			DedupScope:         "user",
+		},
+		Scrub: ScrubSettings{
+			IntervalHours: 24,
+			Action:        "report",
+		},
+		Reconcile: ReconcileSettings{
+			IntervalHours: 24,
+			Mode:          "dry-run",
+		},
+		BlobStore: BlobStoreSettings{
+			Driver:    "s3",
+			Path:      "./data/blobs",
+			PublicURL: "http://localhost:8080",
+		},
+		MetadataStore: MetadataStoreSettings{
+			Driver: "dynamodb",
+			Path:   "./data/metadata.db",
+		},
+	}
+}
+
+// maxObjectSizeMB is the largest object S3 stores.
+const maxObjectSizeMB = 5 * 1024 * 1024
+
+// Validate checks every setting and reports all problems at once, each
+// named by its key and environment variable.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, key string, format string, args ...any) {
+		if !ok {
+			problems = append(problems, settingName(key)+" "+fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(key string, value string, allowed ...string) {
+		for _, option := range allowed {
+			if value == option {
+				return
+			}
+		}
+		check(false, key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive, got %v", c.Server.ReadTimeout)
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive, got %v", c.Server.WriteTimeout)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive, got %v", c.Server.ShutdownTimeout)
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes", "must be positive, got %d", c.Server.MaxHeaderBytes)
+	check(len(c.Server.CORSOrigins) > 0, "server.cors_origins", "must list at least one origin")
+	for _, origin := range c.Server.CORSOrigins {
+		check(validOrigin(origin), "server.cors_origins", "must hold origins like https://example.com, got %q", origin)
+	}
+
+	check(c.Auth.JWTSecret != "", "auth.jwt_secret", "is required")
+	check(c.Auth.JWTExpireHours > 0, "auth.jwt_expire_hours", "must be positive, got %d", c.Auth.JWTExpireHours)
+
+	check(c.Storage.MaxUploadSizeMB > 0, "storage.max_upload_size_mb", "must be positive, got %d", c.Storage.MaxUploadSizeMB)
+	check(c.Storage.MaxMultipartUploadSizeMB > 0 && c.Storage.MaxMultipartUploadSizeMB <= maxObjectSizeMB, "storage.max_multipart_upload_size_mb", "must be between 1 and %d, got %d", maxObjectSizeMB, c.Storage.MaxMultipartUploadSizeMB)
+	check(len(c.Storage.AllowedContentTypes) > 0, "storage.allowed_content_types", "must list at least one MIME type")
+	for _, contentType := range c.Storage.AllowedContentTypes {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		check(err == nil && len(params) == 0 && mediaType == contentType, "storage.allowed_content_types", "must hold lowercase MIME types like image/png, got %q", contentType)
+	}
+	check(c.Storage.TrashRetentionDays > 0, "storage.trash_retention_days", "must be positive, got %d", c.Storage.TrashRetentionDays)
+	check(c.Storage.MaxFileVersions >= 1 && c.Storage.MaxFileVersions <= 100, "storage.max_file_versions", "must be between 1 and 100, got %d", c.Storage.MaxFileVersions)
+	check(c.Storage.DefaultQuotaMB > 0, "storage.default_quota_mb", "must be positive, got %d", c.Storage.DefaultQuotaMB)
+	oneOf("storage.dedup_scope", c.Storage.DedupScope, "user", "global")
+
+	check(c.Scrub.IntervalHours >= 0, "scrub.interval_hours", "must not be negative, got %d", c.Scrub.IntervalHours)
+	oneOf("scrub.action", c.Scrub.Action, "report", "quarantine")
+	check(c.Reconcile.IntervalHours >= 0, "reconcile.interval_hours", "must not be negative, got %d", c.Reconcile.IntervalHours)
+	oneOf("reconcile.mode", c.Reconcile.Mode, "dry-run", "fix")
+
+	check((c.AWS.AccessKeyID == "") == (c.AWS.SecretAccessKey == ""), "aws.access_key_id", "and %s must be set together", settingName("aws.secret_access_key"))
+	check(validEndpoint(c.AWS.DynamoDBEndpoint), "aws.dynamodb_endpoint", "must be an http or https URL, got %q", c.AWS.DynamoDBEndpoint)
+	check(validEndpoint(c.AWS.S3Endpoint), "aws.s3_endpoint", "must be an http or https URL, got %q", c.AWS.S3Endpoint)
+
+	oneOf("blob_store.driver", c.BlobStore.Driver, "s3", "local", "memory")
+	switch c.BlobStore.Driver {
+	case "s3":
+		check(c.AWS.S3Bucket != "", "aws.s3_bucket", "is required by the s3 blob store")
+	case "local":
+		check(c.BlobStore.Path != "", "blob_store.path", "is required by the local blob store")
+	}
+	if c.BlobStore.Driver != "s3" {
+		check(c.BlobStore.URLSecret != "", "blob_store.url_secret", "is required by the %s blob store", c.BlobStore.Driver)
+		// A signed URL must not double as a login token, or the other way round.
+		check(c.BlobStore.URLSecret == "" || c.BlobStore.URLSecret != c.Auth.JWTSecret, "blob_store.url_secret", "must differ from %s", settingName("auth.jwt_secret"))
+		check(validEndpoint(c.BlobStore.PublicURL) && c.BlobStore.PublicURL != "", "blob_store.public_url", "must be an http or https URL, got %q", c.BlobStore.PublicURL)
+	}
+
+	oneOf("metadata_store.driver", c.MetadataStore.Driver, "dynamodb", "bolt")
+	if c.MetadataStore.Driver == "bolt" {
+		check(c.MetadataStore.Path != "", "metadata_store.path", "is required by the bolt metadata store")
+	}
+
+	check(validTableNamePart(c.Namespace.TablePrefix), "namespace.table_prefix", "may only hold letters, digits, _, - and ., got %q", c.Namespace.TablePrefix)
+	check(validTableNamePart(c.Namespace.TableSuffix), "namespace.table_suffix", "may only hold letters, digits, _, - and ., got %q", c.Namespace.TableSuffix)
+	check(len(c.TableName(longestTableName)) <= maxTableNameLength, "namespace.table_prefix", "and %s make table names longer than %d characters", settingName("namespace.table_suffix"), maxTableNameLength)
+	check(c.Namespace.KeyPrefix == "" || (strings.HasSuffix(c.Namespace.KeyPrefix, "/") && !strings.HasPrefix(c.Namespace.KeyPrefix, "/")), "namespace.key_prefix", "must end with / and not start with one, got %q", c.Namespace.KeyPrefix)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// TableName is the name the table called name has in this deployment.
+func (c *Config) TableName(name string) string {
+	return c.Namespace.TablePrefix + name + c.Namespace.TableSuffix
+}
+
+const (
+	maxTableNameLength = 255
+	longestTableName   = "multipart_upload"
+)
+
+func validTableNamePart(value string) bool {
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// validEndpoint accepts an empty value, which means the AWS default, or an
+// absolute http(s) URL.
+func validEndpoint(value string) bool {
+	if value == "" {
+		return true
+	}
+
+	endpoint, err := url.Parse(value)
+	return err == nil && (endpoint.Scheme == "http" || endpoint.Scheme == "https") && endpoint.Host != ""
+}
+
+func validOrigin(value string) bool {
+	origin, err := url.Parse(value)
+	return err == nil && (origin.Scheme == "http" || origin.Scheme == "https") && origin.Host != "" && origin.Path == "" && origin.RawQuery == ""
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(cfg *Config)
+		wantErr string
+	}{
+		{name: "defaults with secrets", change: func(cfg *Config) {}},
+		{name: "port out of range", change: func(cfg *Config) { cfg.Server.Port = 70000 }, wantErr: "server.port (PORT) must be between 1 and 65535"},
+		{name: "origin with a path", change: func(cfg *Config) { cfg.Server.CORSOrigins = []string{"https://example.com/app"} }, wantErr: "server.cors_origins"},
+		{name: "MIME type with parameters", change: func(cfg *Config) { cfg.Storage.AllowedContentTypes = []string{"text/plain; charset=utf-8"} }, wantErr: "storage.allowed_content_types"},
+		{name: "unknown dedup scope", change: func(cfg *Config) { cfg.Storage.DedupScope = "team" }, wantErr: `storage.dedup_scope (DEDUP_SCOPE) must be one of user, global, got "team"`},
+		{name: "access key without secret", change: func(cfg *Config) { cfg.AWS.AccessKeyID = "AKIA" }, wantErr: "aws.access_key_id (AWS_ACCESS_KEY_ID) and aws.secret_access_key (AWS_SECRET_ACCESS_KEY) must be set together"},
+		{name: "s3 without bucket", change: func(cfg *Config) { cfg.AWS.S3Bucket = "" }, wantErr: "aws.s3_bucket (S3_BUCKET_NAME) is required by the s3 blob store"},
+		{name: "memory store with URL secret", change: func(cfg *Config) {
+			cfg.BlobStore.Driver = "memory"
+			cfg.BlobStore.URLSecret = "url-secret"
+		}},
+		{name: "memory store without URL secret", change: func(cfg *Config) { cfg.BlobStore.Driver = "memory" }, wantErr: "blob_store.url_secret (BLOB_URL_SECRET) is required by the memory blob store"},
+		{name: "URL secret reusing the JWT secret", change: func(cfg *Config) {
+			cfg.BlobStore.Driver = "local"
+			cfg.BlobStore.URLSecret = cfg.Auth.JWTSecret
+		}, wantErr: "blob_store.url_secret (BLOB_URL_SECRET) must differ from auth.jwt_secret (JWT_SECRET_KEY)"},
+		{name: "bolt without path", change: func(cfg *Config) {
+			cfg.MetadataStore.Driver = "bolt"
+			cfg.MetadataStore.Path = ""
+		}, wantErr: "metadata_store.path (METADATA_STORE_PATH) is required by the bolt metadata store"},
+		{name: "table prefix with a slash", change: func(cfg *Config) { cfg.Namespace.TablePrefix = "staging/" }, wantErr: "namespace.table_prefix (TABLE_PREFIX) may only hold"},
+		{name: "key prefix without a slash", change: func(cfg *Config) { cfg.Namespace.KeyPrefix = "staging" }, wantErr: "namespace.key_prefix (BLOB_KEY_PREFIX) must end with /"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Defaults()
+			cfg.Auth.JWTSecret = "jwt-secret"
+			cfg.AWS.S3Bucket = "bucket"
+			tt.change(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate: %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
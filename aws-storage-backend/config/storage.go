@@ -0,0 +1,56 @@
+package config
+
+import (
+	"slices"
+	"time"
+)
+
+type StorageConfig struct {
+	// MaxUploadSize is the largest file, in bytes, accepted in one request or
+	// upload session.
+	MaxUploadSize int64
+	// MaxMultipartUploadSize is the largest file, in bytes, accepted as a
+	// multipart upload.
+	MaxMultipartUploadSize int64
+	// AllowedContentTypes are the MIME types users may upload.
+	AllowedContentTypes []string
+	TrashRetention      time.Duration
+	// MaxFileVersions is how many versions of a file are kept for users who
+	// have not set their own limit.
+	MaxFileVersions int
+	// DefaultStorageQuota is how many bytes a user may store unless an admin
+	// set a quota of their own.
+	DefaultStorageQuota int64
+	// ScrubInterval is how often stored files are checked against their
+	// metadata; zero turns the scrubber off.
+	ScrubInterval time.Duration
+	// ScrubQuarantine makes the scrubber block downloads of files that fail
+	// instead of only reporting them.
+	ScrubQuarantine bool
+	// ReconcileInterval is how often S3 is compared with the storage table for
+	// orphaned objects and dangling records; zero turns it off.
+	ReconcileInterval time.Duration
+	// ReconcileFix makes the scheduled reconciliation repair what it finds
+	// instead of only reporting it.
+	ReconcileFix bool
+}
+
+func NewStorageConfig(cfg *Config) *StorageConfig {
+	return &StorageConfig{
+		MaxUploadSize:          cfg.Storage.MaxUploadSizeMB * 1024 * 1024,
+		MaxMultipartUploadSize: cfg.Storage.MaxMultipartUploadSizeMB * 1024 * 1024,
+		AllowedContentTypes:    cfg.Storage.AllowedContentTypes,
+		TrashRetention:         time.Duration(cfg.Storage.TrashRetentionDays) * 24 * time.Hour,
+		MaxFileVersions:        cfg.Storage.MaxFileVersions,
+		DefaultStorageQuota:    cfg.Storage.DefaultQuotaMB * 1024 * 1024,
+		ScrubInterval:          time.Duration(cfg.Scrub.IntervalHours) * time.Hour,
+		ScrubQuarantine:        cfg.Scrub.Action == "quarantine",
+		ReconcileInterval:      time.Duration(cfg.Reconcile.IntervalHours) * time.Hour,
+		ReconcileFix:           cfg.Reconcile.Mode == "fix",
+	}
+}
+
+// AllowsContentType reports whether users may upload files of contentType.
+func (c *StorageConfig) AllowsContentType(contentType string) bool {
+	return slices.Contains(c.AllowedContentTypes, contentType)
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/gin-gonic/gin"
+)
+
+// urlVerifier is implemented by the blob stores that sign their own URLs
+// instead of handing out S3 presigned ones.
+type urlVerifier interface {
+	VerifyURL(method string, key string, query url.Values) error
+}
+
+// BlobHandler answers the signed URLs of the local and in-memory blob stores,
+// playing the part S3 plays for presigned downloads and direct uploads.
+type BlobHandler struct {
+	blobStore blobstore.BlobStore
+	verifier  urlVerifier
+}
+
+// NewBlobHandler returns nil for stores whose URLs point elsewhere, such as
+// S3, since there is nothing for the server to answer. Signed URLs carry the
+// keys the driver itself stores, so any key prefix is already in them and the
+// handler talks to the driver directly.
+func NewBlobHandler(blobStore blobstore.BlobStore) *BlobHandler {
+	blobStore = blobstore.Unwrap(blobStore)
+	verifier, ok := blobStore.(urlVerifier)
+	if !ok {
+		return nil
+	}
+
+	return &BlobHandler{
+		blobStore: blobStore,
+		verifier:  verifier,
+	}
+}
+
+func (h *BlobHandler) GetBlob(c *gin.Context) {
+	key, ok := h.verify(c)
+	if !ok {
+		return
+	}
+
+	object, err := h.blobStore.Get(c.Request.Context(), key, blobstore.GetOptions{
+		Range:       c.GetHeader("Range"),
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+	})
+
+	switch {
+	case errors.Is(err, blobstore.ErrNotModified):
+		c.Status(http.StatusNotModified)
+		return
+	case errors.Is(err, blobstore.ErrInvalidRange):
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, blobstore.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to read blob %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while reading blob."})
+		return
+	}
+	defer object.Body.Close()
+
+	// Large bodies can take longer than the server-wide WriteTimeout to send.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear write deadline for blob %s: %v", key, err)
+	}
+
+	query := c.Request.URL.Query()
+	contentType := object.ContentType
+	if value := query.Get("response-content-type"); value != "" {
+		contentType = value
+	}
+
+	status := http.StatusOK
+	extraHeaders := map[string]string{
+		"ETag":          object.ETag,
+		"Accept-Ranges": "bytes",
+	}
+	if value := query.Get("response-content-disposition"); value != "" {
+		extraHeaders["Content-Disposition"] = value
+	}
+	if object.ContentRange != "" {
+		status = http.StatusPartialContent
+		extraHeaders["Content-Range"] = object.ContentRange
+	}
+
+	c.DataFromReader(status, object.ContentLength, contentType, object.Body, extraHeaders)
+}
+
+// PutBlob takes either a whole object or, with uploadId, one part of a
+// multipart upload.
+func (h *BlobHandler) PutBlob(c *gin.Context) {
+	key, ok := h.verify(c)
+	if !ok {
+		return
+	}
+	query := c.Request.URL.Query()
+
+	size, err := strconv.ParseInt(query.Get("content-length"), 10, 64)
+	if err != nil || c.Request.ContentLength != size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Length does not match the signed size"})
+		return
+	}
+
+	// Large bodies can take longer than the server-wide ReadTimeout to arrive.
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear read deadline for blob %s: %v", key, err)
+	}
+
+	if uploadID := query.Get("uploadId"); uploadID != "" {
+		partNumber, err := strconv.ParseInt(query.Get("partNumber"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid part number"})
+			return
+		}
+
+		part, err := h.blobStore.UploadPart(c.Request.Context(), key, uploadID, int32(partNumber), size, c.Request.Body)
+		if err != nil {
+			c.JSON(blobErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("ETag", part.ETag)
+		c.Status(http.StatusOK)
+		return
+	}
+
+	contentType := query.Get("content-type")
+	if c.GetHeader("Content-Type") != contentType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type does not match the signed type"})
+		return
+	}
+
+	info, err := h.blobStore.Put(c.Request.Context(), key, c.Request.Body, blobstore.PutOptions{ContentType: contentType})
+	if err != nil {
+		log.Printf("Failed to store blob %s: %v", key, err)
+		c.JSON(blobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", info.ETag)
+	c.Status(http.StatusOK)
+}
+
+// PostBlob takes a browser form upload, the counterpart of an S3 POST policy.
+func (h *BlobHandler) PostBlob(c *gin.Context) {
+	key, ok := h.verify(c)
+	if !ok {
+		return
+	}
+	query := c.Request.URL.Query()
+
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear read deadline for blob %s: %v", key, err)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+
+	contentType := query.Get("content-type")
+	if c.PostForm("Content-Type") != contentType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content-Type does not match the signed type"})
+		return
+	}
+	if strconv.FormatInt(file.Size, 10) != query.Get("content-length") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file size does not match the signed size"})
+		return
+	}
+
+	fileData, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer fileData.Close()
+
+	info, err := h.blobStore.Put(c.Request.Context(), key, fileData, blobstore.PutOptions{ContentType: contentType})
+	if err != nil {
+		log.Printf("Failed to store blob %s: %v", key, err)
+		c.JSON(blobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", info.ETag)
+	c.Status(http.StatusNoContent)
+}
+
+// verify checks the signature of the request URL and returns the key it is
+// for. It has answered the request if ok is false.
+func (h *BlobHandler) verify(c *gin.Context) (key string, ok bool) {
+	key = strings.TrimPrefix(c.Param("key"), "/")
+
+	err := h.verifier.VerifyURL(c.Request.Method, key, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	return key, true
+}
+
+func blobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, blobstore.ErrNotFound), errors.Is(err, blobstore.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, blobstore.ErrChecksumMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, blobstore.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusInternalServerError
+	}
+}
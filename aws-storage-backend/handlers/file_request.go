@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *StorageHandler) CreateFileRequest(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.CreateFileRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.storageService.CreateFileRequest(c.Request.Context(), userID, req)
+	if err != nil {
+		c.JSON(fileRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+func (h *StorageHandler) ListFileRequests(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	requests, err := h.storageService.ListFileRequests(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func (h *StorageHandler) ListFileRequestUploads(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	uploads, err := h.storageService.ListFileRequestUploads(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(fileRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, uploads)
+}
+
+func (h *StorageHandler) RevokeFileRequest(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	message, err := h.storageService.RevokeFileRequest(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(fileRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func (h *StorageHandler) GetPublicFileRequest(c *gin.Context) {
+	request, err := h.storageService.GetPublicFileRequest(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		c.JSON(fileRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+func (h *StorageHandler) UploadToFileRequest(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	var uploader models.Uploader
+	if err := c.ShouldBind(&uploader); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.storageService.UploadToFileRequest(c.Request.Context(), c.Param("token"), file, &uploader)
+	if err != nil {
+		c.JSON(fileRequestErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func fileRequestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrFileRequestNotFound), errors.Is(err, repositories.ErrFolderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrFileRequestUnavailable):
+		return http.StatusGone
+	case errors.Is(err, repositories.ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, repositories.ErrOutboxPending):
+		return http.StatusAccepted
+	case errors.Is(err, repositories.ErrUploadRolledBack):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *StorageHandler) CreateFolder(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.CreateFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.storageService.CreateFolder(c.Request.Context(), userID, req)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+func (h *StorageHandler) GetFolder(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	folder, err := h.storageService.GetFolder(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *StorageHandler) GetFolderPath(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	path, err := h.storageService.GetFolderPath(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"path": path})
+}
+
+func (h *StorageHandler) RenameFolder(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.RenameFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.storageService.RenameFolder(c.Request.Context(), userID, c.Param("id"), req.Name)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *StorageHandler) MoveFolder(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder, err := h.storageService.MoveFolder(c.Request.Context(), userID, c.Param("id"), req.ParentID)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *StorageHandler) DeleteFolder(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	deleteMessage, err := h.storageService.DeleteFolder(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": deleteMessage})
+}
+
+func (h *StorageHandler) MoveFile(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.MoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := h.storageService.MoveFile(c.Request.Context(), userID, c.Param("id"), req.ParentID)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func folderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrFolderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrFolderAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrFolderCycle), errors.Is(err, services.ErrFolderTooDeep), errors.Is(err, services.ErrRootFolderEdit):
+		return http.StatusBadRequest
+	default:
+		return fileErrorStatus(err)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *StorageHandler) GrantFileAccess(c *gin.Context) {
+	h.grantAccess(c, models.ResourceTypeFile)
+}
+
+func (h *StorageHandler) GrantFolderAccess(c *gin.Context) {
+	h.grantAccess(c, models.ResourceTypeFolder)
+}
+
+func (h *StorageHandler) ListFileGrants(c *gin.Context) {
+	h.listGrants(c, models.ResourceTypeFile)
+}
+
+func (h *StorageHandler) ListFolderGrants(c *gin.Context) {
+	h.listGrants(c, models.ResourceTypeFolder)
+}
+
+func (h *StorageHandler) RevokeFileAccess(c *gin.Context) {
+	h.revokeAccess(c, models.ResourceTypeFile)
+}
+
+func (h *StorageHandler) RevokeFolderAccess(c *gin.Context) {
+	h.revokeAccess(c, models.ResourceTypeFolder)
+}
+
+func (h *StorageHandler) ListSharedWithMe(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	shared, err := h.storageService.ListSharedWithMe(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shared)
+}
+
+func (h *StorageHandler) grantAccess(c *gin.Context, resourceType string) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.GrantAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grant, err := h.storageService.GrantAccess(c.Request.Context(), userID, resourceType, c.Param("id"), req)
+	if err != nil {
+		c.JSON(grantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grant)
+}
+
+func (h *StorageHandler) listGrants(c *gin.Context, resourceType string) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	grants, err := h.storageService.ListGrants(c.Request.Context(), userID, resourceType, c.Param("id"))
+	if err != nil {
+		c.JSON(grantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+func (h *StorageHandler) revokeAccess(c *gin.Context, resourceType string) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	message, err := h.storageService.RevokeAccess(c.Request.Context(), userID, resourceType, c.Param("id"), c.Param("userId"))
+	if err != nil {
+		c.JSON(grantErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func grantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrGrantNotFound), errors.Is(err, services.ErrGranteeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrGrantToSelf):
+		return http.StatusBadRequest
+	default:
+		return folderErrorStatus(err)
+	}
+}
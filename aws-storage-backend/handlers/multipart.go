@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *StorageHandler) InitiateMultipartUpload(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.InitiateMultipartUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	upload, err := h.storageService.InitiateMultipartUpload(c.Request.Context(), userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+func (h *StorageHandler) GetMultipartUpload(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	upload, err := h.storageService.GetMultipartUpload(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(multipartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, upload)
+}
+
+func (h *StorageHandler) GetPartURL(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	partNumber, ok := partNumberParam(c)
+	if !ok {
+		return
+	}
+
+	partURL, err := h.storageService.GetPartURL(c.Request.Context(), userID, c.Param("id"), partNumber)
+	if err != nil {
+		c.JSON(multipartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, partURL)
+}
+
+func (h *StorageHandler) UploadPart(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	partNumber, ok := partNumberParam(c)
+	if !ok {
+		return
+	}
+
+	// A part can be far bigger than what fits in the server-wide ReadTimeout.
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear read deadline for part upload: %v", err)
+	}
+
+	part, err := h.storageService.UploadPart(c.Request.Context(), userID, c.Param("id"), partNumber, c.Request.ContentLength, c.Request.Body)
+	if err != nil {
+		c.JSON(multipartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, part)
+}
+
+func (h *StorageHandler) AcknowledgePart(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	partNumber, ok := partNumberParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.AcknowledgePartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	part, err := h.storageService.AcknowledgePart(c.Request.Context(), userID, c.Param("id"), partNumber, req.ETag)
+	if err != nil {
+		c.JSON(multipartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, part)
+}
+
+func (h *StorageHandler) CompleteMultipartUpload(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	response, err := h.storageService.CompleteMultipartUpload(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(multipartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *StorageHandler) AbortMultipartUpload(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	if err := h.storageService.AbortMultipartUpload(c.Request.Context(), userID, c.Param("id")); err != nil {
+		c.JSON(multipartErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Multipart upload aborted"})
+}
+
+func partNumberParam(c *gin.Context) (int32, bool) {
+	partNumber, err := strconv.ParseInt(c.Param("partNumber"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidPartNumber.Error()})
+		return 0, false
+	}
+	return int32(partNumber), true
+}
+
+func multipartErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPartNumber), errors.Is(err, services.ErrInvalidPartSize):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrIncompleteUpload), errors.Is(err, repositories.ErrUploadSessionNotPending):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUploadSessionExpired):
+		return http.StatusGone
+	case errors.Is(err, repositories.ErrUploadMismatch), errors.Is(err, services.ErrPartMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repositories.ErrMultipartUploadNotFound):
+		return http.StatusNotFound
+	default:
+		return fileErrorStatus(err)
+	}
+}
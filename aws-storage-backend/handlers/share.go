@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ShareHandler struct {
+	shareService *services.ShareService
+}
+
+func NewShareHandler(shareService *services.ShareService) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+	}
+}
+
+func (h *ShareHandler) CreateShareLink(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var req models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, err := h.shareService.CreateShareLink(c.Request.Context(), userID, c.Param("id"), req)
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+func (h *ShareHandler) ListShareLinks(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	links, err := h.shareService.ListShareLinks(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+func (h *ShareHandler) RevokeShareLink(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	message, err := h.shareService.RevokeShareLink(c.Request.Context(), userID, c.Param("token"))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+func (h *ShareHandler) GetSharedFile(c *gin.Context) {
+	info, err := h.shareService.GetSharedFile(c.Request.Context(), c.Param("token"), sharePasswordFrom(c))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *ShareHandler) DownloadSharedFile(c *gin.Context) {
+	token := c.Param("token")
+
+	download, err := h.shareService.DownloadSharedFile(c.Request.Context(), token, sharePasswordFrom(c), downloadRequestFrom(c))
+
+	if errors.Is(err, repositories.ErrInvalidRange) {
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	writeDownload(c, download.ObjectID, download)
+}
+
+// sharePasswordFrom reads the link password from a header so it stays out of
+// access logs, with a query parameter fallback for plain browser links.
+func sharePasswordFrom(c *gin.Context) string {
+	if password := c.GetHeader("X-Share-Password"); password != "" {
+		return password
+	}
+	return c.Query("password")
+}
+
+func shareErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrShareLinkNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrShareLinkUnavailable):
+		return http.StatusGone
+	case errors.Is(err, services.ErrSharePasswordRequired), errors.Is(err, services.ErrSharePasswordInvalid):
+		return http.StatusUnauthorized
+	default:
+		return fileErrorStatus(err)
+	}
+}
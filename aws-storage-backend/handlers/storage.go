@@ -1,10 +1,16 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
+	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
 	"github.com/berkkaradalan/AwsGo-Storage/services"
 	"github.com/gin-gonic/gin"
 )
@@ -40,9 +46,18 @@ func (h *StorageHandler) UploadFile(c *gin.Context) {
 		descPtr = &description
 	}
 
-	response, err := h.storageService.UploadFile(c.Request.Context(), userID, file, descPtr)
+	response, err := h.storageService.UploadFile(c.Request.Context(), userID, file, descPtr, c.PostForm("folderId"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, repositories.ErrQuotaExceeded):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, repositories.ErrOutboxPending):
+			status = http.StatusAccepted
+		case errors.Is(err, repositories.ErrUploadRolledBack):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -53,7 +68,23 @@ func (h *StorageHandler) ListFiles(c *gin.Context) {
 	userData := middleware.GetCurrentClaims(c)
 	userID := userData.UserID
 
-	files, err := h.storageService.ListFiles(c, userID)
+	var query models.ListFilesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := h.storageService.ListFiles(c.Request.Context(), userID, query)
+
+	if errors.Is(err, services.ErrInvalidListQuery) || errors.Is(err, repositories.ErrInvalidNextToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, repositories.ErrFolderNotFound) || errors.Is(err, repositories.ErrFolderAccessDenied) {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -69,31 +100,174 @@ func (h *StorageHandler) DownloadFile(c *gin.Context) {
 	userData := middleware.GetCurrentClaims(c)
 	userID := userData.UserID
 	fileID := c.Param("id")
-	
-	fileData, err := h.storageService.DownloadFile(c.Request.Context(), fileID, userID)
+
+	download, err := h.storageService.DownloadFile(c.Request.Context(), fileID, userID, downloadRequestFrom(c))
+
+	if errors.Is(err, repositories.ErrInvalidRange) {
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+		return
+	}
 
 	if err != nil {
-		c.JSON(http.StatusExpectationFailed, gin.H{"error" : "Error while downloading file."})
+		c.JSON(fileErrorStatus(err), gin.H{"error" : "Error while downloading file."})
 		return 
 	}
 
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileID))
-    c.Header("Content-Type", "application/octet-stream")
-    c.Header("Content-Length", fmt.Sprintf("%d", len(fileData)))
-    
-    c.Data(http.StatusOK, "application/octet-stream", fileData)
+	writeDownload(c, fileID, download)
+}
+
+// writeDownload streams a file body, or answers 304 for a conditional hit.
+func writeDownload(c *gin.Context, fileID string, download *models.FileDownload) {
+	setFileHeaders(c, download.FileInfo)
+
+	if download.NotModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	defer download.Body.Close()
+
+	// Large bodies can take longer than the server-wide WriteTimeout to send.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Failed to clear write deadline for download %s: %v", fileID, err)
+	}
+
+	status := http.StatusOK
+	extraHeaders := map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": download.FileName}),
+	}
+	if download.ContentRange != "" {
+		status = http.StatusPartialContent
+		extraHeaders["Content-Range"] = download.ContentRange
+	}
 
+	c.DataFromReader(status, download.ContentLength, download.ContentType, download.Body, extraHeaders)
 }
 
-func (h *StorageHandler) DeleteFile(c *gin.Context) {
+func (h *StorageHandler) HeadFile(c *gin.Context) {
 	userData := middleware.GetCurrentClaims(c)
 	userID := userData.UserID
 	fileID := c.Param("id")
 
-	deleteMessage, err := h.storageService.DeleteFile(c, userID, fileID)
+	info, notModified, err := h.storageService.HeadFile(c.Request.Context(), fileID, userID, downloadRequestFrom(c))
+	if err != nil {
+		c.Status(fileErrorStatus(err))
+		return
+	}
 
-	if err != nil ||  deleteMessage == nil{
-		c.JSON(http.StatusExpectationFailed, gin.H{"error": "Error while deleting file"})
+	setFileHeaders(c, *info)
+
+	if notModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+}
+
+func downloadRequestFrom(c *gin.Context) models.DownloadRequest {
+	req := models.DownloadRequest{
+		Range:       c.GetHeader("Range"),
+		IfNoneMatch: c.GetHeader("If-None-Match"),
+	}
+
+	if since := c.GetHeader("If-Modified-Since"); since != "" {
+		if parsed, err := http.ParseTime(since); err == nil {
+			req.IfModifiedSince = &parsed
+		}
+	}
+
+	return req
+}
+
+func setFileHeaders(c *gin.Context, info models.FileInfo) {
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	if info.ETag != "" {
+		c.Header("ETag", info.ETag)
+	}
+}
+
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrFileAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, repositories.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, repositories.ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, repositories.ErrFileQuarantined):
+		return http.StatusLocked
+	case errors.Is(err, repositories.ErrOutboxPending):
+		return http.StatusAccepted
+	case errors.Is(err, repositories.ErrUploadRolledBack):
+		return http.StatusConflict
+	default:
+		return http.StatusExpectationFailed
+	}
+}
+
+func (h *StorageHandler) UpdateFileMetadata(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+	fileID := c.Param("id")
+
+	var req models.UpdateFileMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := h.storageService.UpdateFileMetadata(c.Request.Context(), userID, fileID, req)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *StorageHandler) ReplaceFileContent(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+	fileID := c.Param("id")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	var expectedVersion *int64
+	if version := c.PostForm("version"); version != "" {
+		parsed, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+			return
+		}
+		expectedVersion = &parsed
+	}
+
+	updated, err := h.storageService.ReplaceFileContent(c.Request.Context(), userID, fileID, file, expectedVersion)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *StorageHandler) DeleteFile(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+	fileID := c.Param("id")
+
+	deleteMessage, err := h.storageService.DeleteFile(c.Request.Context(), userID, fileID)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -104,11 +278,35 @@ func (h *StorageHandler) GetDashboardMetrics(c *gin.Context) {
 	userData := middleware.GetCurrentClaims(c)
 	userID := userData.UserID
 
-	dashboardMetrics, err := h.storageService.GetDashboardMetrics(c, userID)
+	var query models.DashboardQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dashboardMetrics, err := h.storageService.GetDashboardMetrics(c.Request.Context(), userID, query)
 
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidDashboardRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Error while getting dashboard metrics"})
+		return
 	}
 
 	c.JSON(http.StatusOK, dashboardMetrics)
-}
\ No newline at end of file
+}
+
+func (h *StorageHandler) FindDuplicates(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	duplicates, err := h.storageService.FindDuplicates(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, duplicates)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *StorageHandler) ListTrash(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	var query models.ListFilesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := h.storageService.ListTrash(c.Request.Context(), userID, query)
+
+	if errors.Is(err, services.ErrInvalidListQuery) || errors.Is(err, repositories.ErrInvalidNextToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+func (h *StorageHandler) RestoreFile(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	file, err := h.storageService.RestoreFile(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(trashErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *StorageHandler) DeleteTrashedFile(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	deleteMessage, err := h.storageService.DeleteTrashedFile(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(trashErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": deleteMessage})
+}
+
+func (h *StorageHandler) EmptyTrash(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	deleteMessage, err := h.storageService.EmptyTrash(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": deleteMessage})
+}
+
+func trashErrorStatus(err error) int {
+	if errors.Is(err, services.ErrFileNotInTrash) {
+		return http.StatusConflict
+	}
+	return fileErrorStatus(err)
+}
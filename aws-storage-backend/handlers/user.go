@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/berkkaradalan/AwsGo-Storage/middleware"
 	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
 	"github.com/berkkaradalan/AwsGo-Storage/services"
 	"github.com/gin-gonic/gin"
 )
@@ -48,6 +50,13 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	user, err := h.userService.CreateUser(c.Request.Context(), req)
 
+	if errors.Is(err, repositories.ErrEmailInUse) || errors.Is(err, repositories.ErrUserNameInUse) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":err.Error(),
@@ -91,4 +100,41 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"user":userData,
 	})
-}
\ No newline at end of file
+}
+func (h *UserHandler) SetVersionPolicy(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+
+	var req models.VersionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.SetVersionPolicy(c.Request.Context(), userData.UserID, req.MaxFileVersions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user.ToResponse(),
+	})
+}
+
+func (h *UserHandler) SetStorageQuota(c *gin.Context) {
+	var req models.StorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.userService.SetStorageQuota(c.Request.Context(), c.Param("id"), req.StorageQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user.ToResponse(),
+	})
+}
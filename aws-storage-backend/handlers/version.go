@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/berkkaradalan/AwsGo-Storage/middleware"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/berkkaradalan/AwsGo-Storage/services"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *StorageHandler) ListFileVersions(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	versions, err := h.storageService.ListFileVersions(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+func (h *StorageHandler) DownloadFileVersion(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+	fileID := c.Param("id")
+
+	download, err := h.storageService.DownloadFileVersion(c.Request.Context(), userID, fileID, c.Param("versionId"), downloadRequestFrom(c))
+
+	if errors.Is(err, repositories.ErrInvalidRange) {
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": "Error while downloading file version."})
+		return
+	}
+
+	writeDownload(c, fileID, download)
+}
+
+func (h *StorageHandler) RestoreFileVersion(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	file, err := h.storageService.RestoreFileVersion(c.Request.Context(), userID, c.Param("id"), c.Param("versionId"))
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+func (h *StorageHandler) DeleteFileVersion(c *gin.Context) {
+	userData := middleware.GetCurrentClaims(c)
+	userID := userData.UserID
+
+	deleteMessage, err := h.storageService.DeleteFileVersion(c.Request.Context(), userID, c.Param("id"), c.Param("versionId"))
+	if err != nil {
+		c.JSON(versionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": deleteMessage})
+}
+
+func versionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrFileVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCurrentVersion):
+		return http.StatusConflict
+	default:
+		return fileErrorStatus(err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package testdb gives tests a DynamoDB metadata store of their own: every
+// table the test creates gets a prefix no other test uses, and is deleted when
+// the test ends. It talks to the endpoint in METASTORE_TEST_DYNAMODB_ENDPOINT,
+// usually DynamoDB Local, and skips the test when that is unset.
+package testdb
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/metastore"
+	"github.com/google/uuid"
+)
+
+// EndpointEnv names the variable that holds the DynamoDB endpoint tests use.
+const EndpointEnv = "METASTORE_TEST_DYNAMODB_ENDPOINT"
+
+// New returns a service with every table of config.TableInputs.
+func New(t testing.TB) *config.DynamoDBService {
+	t.Helper()
+
+	service := Empty(t)
+	for _, input := range config.TableInputs() {
+		CreateTable(t, service, input)
+	}
+	return service
+}
+
+// Empty returns a service without any tables.
+func Empty(t testing.TB) *config.DynamoDBService {
+	t.Helper()
+
+	endpoint := os.Getenv(EndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s is not set", EndpointEnv)
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       region,
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+	})
+
+	prefix := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "") + "_"
+	rename := func(name string) string { return prefix + name }
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		pages := dynamodb.NewListTablesPaginator(client, &dynamodb.ListTablesInput{})
+		for pages.HasMorePages() {
+			page, err := pages.NextPage(ctx)
+			if err != nil {
+				t.Logf("list tables: %v", err)
+				return
+			}
+			for _, name := range page.TableNames {
+				if !strings.HasPrefix(name, prefix) {
+					continue
+				}
+				if _, err := client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(name)}); err != nil {
+					t.Logf("delete table %s: %v", name, err)
+				}
+			}
+		}
+	})
+
+	return &config.DynamoDBService{Client: metastore.WithTableNames(client, rename)}
+}
+
+// CreateTable creates one table in service and waits until it is active.
+func CreateTable(t testing.TB, service *config.DynamoDBService, input dynamodb.CreateTableInput) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := service.Client.CreateTable(ctx, &input); err != nil {
+		t.Fatalf("create table %s: %v", aws.ToString(input.TableName), err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(service.Client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: input.TableName}, time.Minute); err != nil {
+		t.Fatalf("wait for table %s: %v", aws.ToString(input.TableName), err)
+	}
+}
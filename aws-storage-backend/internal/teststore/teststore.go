@@ -0,0 +1,54 @@
+// Package teststore runs tests against every implementation of the stores in
+// package repositories, so each backend is held to the same behaviour.
+package teststore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/testdb"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// Driver opens empty stores on one backend. They are closed when the test
+// ends.
+type Driver struct {
+	Name string
+	Open func(t testing.TB, blobStore blobstore.BlobStore, cfg *config.Config) *repositories.Stores
+}
+
+// Drivers lists the backends tests run against. The dynamodb driver skips the
+// test unless testdb.EndpointEnv is set.
+func Drivers() []Driver {
+	return []Driver{
+		{Name: "dynamodb", Open: func(t testing.TB, blobStore blobstore.BlobStore, cfg *config.Config) *repositories.Stores {
+			return repositories.NewDynamoDBStores(testdb.New(t), blobStore, cfg)
+		}},
+		{Name: "bolt", Open: func(t testing.TB, blobStore blobstore.BlobStore, cfg *config.Config) *repositories.Stores {
+			stores, err := repositories.OpenBoltStores(filepath.Join(t.TempDir(), "metadata.db"), blobStore, cfg)
+			if err != nil {
+				t.Fatalf("open bolt stores: %v", err)
+			}
+			t.Cleanup(func() { stores.Close() })
+			return stores
+		}},
+	}
+}
+
+// Run runs test once per driver, as a subtest named after it.
+func Run(t *testing.T, test func(t *testing.T, driver Driver)) {
+	for _, driver := range Drivers() {
+		t.Run(driver.Name, func(t *testing.T) {
+			test(t, driver)
+		})
+	}
+}
+
+// OpenDefault returns empty stores with the default configuration and a memory blob
+// store.
+func (d Driver) OpenDefault(t testing.TB) *repositories.Stores {
+	t.Helper()
+	return d.Open(t, blobstore.NewMemoryStore(nil), config.Defaults())
+}
@@ -0,0 +1,114 @@
+package metastore
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// renamedClient maps the table name of every request through rename, so
+// several deployments can keep their tables side by side in one account.
+// Inputs are copied before they are renamed: callers reuse them for retries
+// and pages.
+type renamedClient struct {
+	client Client
+	rename func(string) string
+}
+
+// WithTableNames returns a Client that sends every request to the table
+// rename(name) instead of name.
+func WithTableNames(client Client, rename func(string) string) Client {
+	return &renamedClient{client: client, rename: rename}
+}
+
+func (c *renamedClient) table(name *string) *string {
+	if name == nil {
+		return nil
+	}
+	return aws.String(c.rename(*name))
+}
+
+func (c *renamedClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.GetItem(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.PutItem(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.UpdateItem(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.DeleteItem(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.Query(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.Scan(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	input := *params
+	input.TransactItems = make([]types.TransactWriteItem, len(params.TransactItems))
+	for i, item := range params.TransactItems {
+		if item.Put != nil {
+			put := *item.Put
+			put.TableName = c.table(put.TableName)
+			item.Put = &put
+		}
+		if item.Update != nil {
+			update := *item.Update
+			update.TableName = c.table(update.TableName)
+			item.Update = &update
+		}
+		if item.Delete != nil {
+			del := *item.Delete
+			del.TableName = c.table(del.TableName)
+			item.Delete = &del
+		}
+		if item.ConditionCheck != nil {
+			check := *item.ConditionCheck
+			check.TableName = c.table(check.TableName)
+			item.ConditionCheck = &check
+		}
+		input.TransactItems[i] = item
+	}
+	return c.client.TransactWriteItems(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.CreateTable(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.DescribeTable(ctx, &input, optFns...)
+}
+
+func (c *renamedClient) UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	input := *params
+	input.TableName = c.table(params.TableName)
+	return c.client.UpdateTable(ctx, &input, optFns...)
+}
@@ -0,0 +1,122 @@
+package metastore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// tableRecorder records the tables requests are sent to.
+type tableRecorder struct {
+	Client
+	tables []string
+}
+
+func (c *tableRecorder) record(names ...*string) {
+	for _, name := range names {
+		c.tables = append(c.tables, aws.ToString(name))
+	}
+}
+
+func (c *tableRecorder) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.record(params.TableName)
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (c *tableRecorder) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.record(params.TableName)
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (c *tableRecorder) CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	c.record(params.TableName)
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (c *tableRecorder) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	for _, item := range params.TransactItems {
+		switch {
+		case item.Put != nil:
+			c.record(item.Put.TableName)
+		case item.Update != nil:
+			c.record(item.Update.TableName)
+		case item.Delete != nil:
+			c.record(item.Delete.TableName)
+		case item.ConditionCheck != nil:
+			c.record(item.ConditionCheck.TableName)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func TestWithTableNames(t *testing.T) {
+	ctx := context.Background()
+	rename := func(name string) string { return "staging_" + name + "_v2" }
+
+	tests := []struct {
+		name       string
+		send       func(client Client) error
+		wantTables []string
+	}{
+		{name: "get", wantTables: []string{"staging_users_v2"}, send: func(client Client) error {
+			_, err := client.GetItem(ctx, &dynamodb.GetItemInput{TableName: aws.String("users")})
+			return err
+		}},
+		{name: "query", wantTables: []string{"staging_storage_v2"}, send: func(client Client) error {
+			_, err := client.Query(ctx, &dynamodb.QueryInput{TableName: aws.String("storage"), IndexName: aws.String("UserIDIndex")})
+			return err
+		}},
+		{name: "create table", wantTables: []string{"staging_outbox_v2"}, send: func(client Client) error {
+			_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{TableName: aws.String("outbox")})
+			return err
+		}},
+		{name: "transaction", wantTables: []string{"staging_storage_v2", "staging_usage_aggregate_v2", "staging_outbox_v2", "staging_users_v2"}, send: func(client Client) error {
+			_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+				{Put: &types.Put{TableName: aws.String("storage")}},
+				{Update: &types.Update{TableName: aws.String("usage_aggregate")}},
+				{Delete: &types.Delete{TableName: aws.String("outbox")}},
+				{ConditionCheck: &types.ConditionCheck{TableName: aws.String("users")}},
+			}})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &tableRecorder{}
+			if err := tt.send(WithTableNames(recorder, rename)); err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if !reflect.DeepEqual(recorder.tables, tt.wantTables) {
+				t.Errorf("sent to %v, want %v", recorder.tables, tt.wantTables)
+			}
+		})
+	}
+}
+
+// Paginators and retries send the same input again, so it must come back
+// with the names the caller gave it.
+func TestWithTableNamesLeavesInputAlone(t *testing.T) {
+	client := WithTableNames(&tableRecorder{}, func(name string) string { return "staging_" + name })
+
+	query := &dynamodb.QueryInput{TableName: aws.String("storage")}
+	transaction := &dynamodb.TransactWriteItemsInput{TransactItems: []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String("storage")}},
+	}}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Query(context.Background(), query); err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		if _, err := client.TransactWriteItems(context.Background(), transaction); err != nil {
+			t.Fatalf("TransactWriteItems: %v", err)
+		}
+	}
+
+	if aws.ToString(query.TableName) != "storage" || aws.ToString(transaction.TransactItems[0].Put.TableName) != "storage" {
+		t.Errorf("inputs now name %q and %q, want storage", aws.ToString(query.TableName), aws.ToString(transaction.TransactItems[0].Put.TableName))
+	}
+}
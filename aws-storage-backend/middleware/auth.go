@@ -38,6 +38,21 @@ func AuthMiddleware(authConfig *config.AuthConfig) gin.HandlerFunc {
 	}
 }
 
+// AdminMiddleware lets through only users listed in ADMIN_USER_IDS. It must
+// run after AuthMiddleware.
+func AdminMiddleware(authConfig *config.AuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetCurrentClaims(c)
+		if claims == nil || !authConfig.IsAdmin(claims.UserID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func GetCurrentClaims(c *gin.Context) *config.JWTClaims {
 	claims, exists := c.Get("claims")
 	if !exists {
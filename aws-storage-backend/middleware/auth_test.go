@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Defaults()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Auth.AdminUserIDs = []string{"admin-id", " spaced-id "}
+	authConfig := config.NewAuthConfig(cfg)
+
+	tests := []struct {
+		name   string
+		userID string
+		email  string
+		want   int
+	}{
+		{name: "listed user", userID: "admin-id", email: "someone@example.com", want: http.StatusOK},
+		{name: "listed with spaces", userID: "spaced-id", email: "someone@example.com", want: http.StatusOK},
+		{name: "unlisted user", userID: "other-id", email: "someone@example.com", want: http.StatusForbidden},
+		// Emails are not verified, so an email that looks like an admin's
+		// grants nothing.
+		{name: "email of an admin", userID: "other-id", email: "admin-id", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := authConfig.GenerateToken(tt.userID, "name", tt.email, 0, 0)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+
+			router := gin.New()
+			router.GET("/admin", AuthMiddleware(authConfig), AdminMiddleware(authConfig), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
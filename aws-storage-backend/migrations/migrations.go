@@ -0,0 +1,265 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// All is the schema this build expects, oldest first. The Create*TableInput
+// functions in config describe the current tables, so a fresh install starts
+// with every index and the index migrations have nothing to do; a table
+// created by an older build is brought up to date here. Never edit or
+// renumber a migration that has been released.
+var All = []Migration{
+	{
+		Version:     1,
+		Description: "Add UserNameIndex to the user table",
+		Up:          addIndex(config.CreateUserTableInput(), "UserNameIndex"),
+	},
+	{
+		Version:     2,
+		Description: "Reserve the emails and usernames of existing users and normalize their emails",
+		Up:          reserveUserUniqueKeys,
+	},
+	{
+		Version:     3,
+		Description: "Put files without a folder or status in the root folder and mark them active",
+		Up:          backfillFileParentAndStatus,
+	},
+	{
+		Version:     4,
+		Description: "Add UserIDFileNameIndex to the storage table",
+		Up:          addIndex(config.CreateStorageTableInput(), "UserIDFileNameIndex"),
+	},
+	{
+		Version:     5,
+		Description: "Add UserIDFileSizeIndex to the storage table",
+		Up:          addIndex(config.CreateStorageTableInput(), "UserIDFileSizeIndex"),
+	},
+	{
+		Version:     6,
+		Description: "Add UserIDParentIDIndex to the storage table",
+		Up:          addIndex(config.CreateStorageTableInput(), "UserIDParentIDIndex"),
+	},
+	{
+		Version:     7,
+		Description: "Add UserIDContentHashIndex to the storage table",
+		Up:          addIndex(config.CreateStorageTableInput(), "UserIDContentHashIndex"),
+	},
+	{
+		Version:     8,
+		Description: "Add StatusExpiresAtIndex to the storage table",
+		Up:          addIndex(config.CreateStorageTableInput(), "StatusExpiresAtIndex"),
+	},
+	{
+		Version:     9,
+		Description: "Count the files users already store into their quota usage",
+		Up:          rebuildUsedBytes,
+	},
+	{
+		Version:     10,
+		Description: "Store the upload and update times of files in UTC",
+		Up:          normalizeFileTimes,
+	},
+}
+
+// addIndex adds the global secondary index indexName of table as config
+// defines it, with the definitions of its key attributes. DynamoDB fills a
+// new index from the existing items.
+func addIndex(table dynamodb.CreateTableInput, indexName string) func(ctx context.Context, m *Migrator) error {
+	return func(ctx context.Context, m *Migrator) error {
+		for _, index := range table.GlobalSecondaryIndexes {
+			if aws.ToString(index.IndexName) != indexName {
+				continue
+			}
+
+			var attributes []types.AttributeDefinition
+			for _, key := range index.KeySchema {
+				for _, definition := range table.AttributeDefinitions {
+					if aws.ToString(definition.AttributeName) == aws.ToString(key.AttributeName) {
+						attributes = append(attributes, definition)
+					}
+				}
+			}
+			return m.AddGlobalSecondaryIndex(ctx, aws.ToString(table.TableName), index, attributes)
+		}
+		return fmt.Errorf("table %s has no index %s", aws.ToString(table.TableName), indexName)
+	}
+}
+
+// reserveUserUniqueKeys writes the email and username reservations that
+// UserRepository.CreateUser now writes with every user, then stores the
+// emails normalized. Users that clash, such as "Bob@x.io" and "bob@x.io",
+// stop the migration before any email is changed; resolve them by hand and
+// run it again.
+func reserveUserUniqueKeys(ctx context.Context, m *Migrator) error {
+	userRepo := repositories.NewUserRepository(m.Service())
+
+	var conflicts []string
+	paginator := dynamodb.NewScanPaginator(m.Service().Client, &dynamodb.ScanInput{
+		TableName: aws.String(repositories.UsersTable),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan users: %v", err)
+			return err
+		}
+
+		var users []models.User
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &users); err != nil {
+			log.Printf("Failed to unmarshal users: %v", err)
+			return err
+		}
+
+		for i := range users {
+			err := userRepo.ReserveUniqueKeys(ctx, &users[i])
+			switch {
+			case errors.Is(err, repositories.ErrEmailInUse):
+				conflicts = append(conflicts, fmt.Sprintf("user %s: email %s", users[i].UserID, models.NormalizeEmail(users[i].UserEmail)))
+			case errors.Is(err, repositories.ErrUserNameInUse):
+				conflicts = append(conflicts, fmt.Sprintf("user %s: username %s", users[i].UserID, users[i].UserName))
+			case err != nil:
+				return err
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%d users share an email or username with another user:\n  %s", len(conflicts), strings.Join(conflicts, "\n  "))
+	}
+
+	normalized, err := m.Backfill(ctx, repositories.UsersTable, func(item map[string]types.AttributeValue) *dynamodb.UpdateItemInput {
+		email, ok := item["UserEmail"].(*types.AttributeValueMemberS)
+		if !ok || email.Value == models.NormalizeEmail(email.Value) {
+			return nil
+		}
+		return &dynamodb.UpdateItemInput{
+			UpdateExpression:    aws.String("SET UserEmail = :email"),
+			ConditionExpression: aws.String("UserEmail = :current"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":email":   &types.AttributeValueMemberS{Value: models.NormalizeEmail(email.Value)},
+				":current": email,
+			},
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Normalized the emails of %d users", normalized)
+	return nil
+}
+
+// backfillFileParentAndStatus gives files written before folders and the
+// trash existed the ParentID and Status that newer files always carry. Items
+// without them are left out of UserIDParentIDIndex, so their folder listing
+// would miss them.
+func backfillFileParentAndStatus(ctx context.Context, m *Migrator) error {
+	updated, err := m.Backfill(ctx, repositories.StorageTable, func(item map[string]types.AttributeValue) *dynamodb.UpdateItemInput {
+		var set []string
+		values := map[string]types.AttributeValue{}
+		if _, ok := item["ParentID"]; !ok {
+			set = append(set, "ParentID = if_not_exists(ParentID, :root)")
+			values[":root"] = &types.AttributeValueMemberS{Value: models.RootFolderID}
+		}
+		if _, ok := item["Status"]; !ok {
+			set = append(set, "#status = if_not_exists(#status, :active)")
+			values[":active"] = &types.AttributeValueMemberS{Value: models.StatusActive}
+		}
+		if len(set) == 0 {
+			return nil
+		}
+
+		input := &dynamodb.UpdateItemInput{
+			UpdateExpression:          aws.String("SET " + strings.Join(set, ", ")),
+			ConditionExpression:       aws.String("attribute_exists(ObjectID)"),
+			ExpressionAttributeValues: values,
+		}
+		if _, ok := values[":active"]; ok {
+			input.ExpressionAttributeNames = map[string]string{"#status": "Status"}
+		}
+		return input
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Gave %d legacy files a folder or status", updated)
+	return nil
+}
+
+// rebuildUsedBytes starts quota enforcement from what users already store.
+// Without it, files uploaded before quotas existed would not count, and
+// deleting them would find nothing to release.
+func rebuildUsedBytes(ctx context.Context, m *Migrator) error {
+	users, err := repositories.NewUserRepository(m.Service()).RebuildUsedBytes(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Recounted the quota usage of %d users", users)
+	return nil
+}
+
+// normalizeFileTimes rewrites UploadedAt and UpdatedAt of files written
+// before uploads were timestamped in UTC. They carry the server's offset, so
+// they compare wrongly as strings against the UTC bounds of date filters and
+// sort out of order in UserIDUploadedAtIndex. Values that don't parse are
+// left alone.
+func normalizeFileTimes(ctx context.Context, m *Migrator) error {
+	updated, err := m.Backfill(ctx, repositories.StorageTable, func(item map[string]types.AttributeValue) *dynamodb.UpdateItemInput {
+		var set, conditions []string
+		values := map[string]types.AttributeValue{}
+		for _, attribute := range []string{"UploadedAt", "UpdatedAt"} {
+			current, ok := item[attribute].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			utc, ok := utcTimestamp(current.Value)
+			if !ok || utc == current.Value {
+				continue
+			}
+			set = append(set, fmt.Sprintf("%s = :%s", attribute, attribute))
+			conditions = append(conditions, fmt.Sprintf("%s = :current%s", attribute, attribute))
+			values[":"+attribute] = &types.AttributeValueMemberS{Value: utc}
+			values[":current"+attribute] = current
+		}
+		if len(set) == 0 {
+			return nil
+		}
+
+		return &dynamodb.UpdateItemInput{
+			UpdateExpression:          aws.String("SET " + strings.Join(set, ", ")),
+			ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+			ExpressionAttributeValues: values,
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Stored the times of %d files in UTC", updated)
+	return nil
+}
+
+// utcTimestamp returns value in the UTC form time.Time is stored in now.
+func utcTimestamp(value string) (string, bool) {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return "", false
+	}
+	return parsed.UTC().Format(time.RFC3339Nano), true
+}
@@ -0,0 +1,218 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/testdb"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func noop(context.Context, *Migrator) error { return nil }
+
+func TestCheck(t *testing.T) {
+	known := []Migration{
+		{Version: 1, Description: "one", Up: noop},
+		{Version: 2, Description: "two", Up: noop},
+	}
+
+	tests := []struct {
+		name    string
+		applied []Migration
+		wantErr string
+	}{
+		{name: "nothing applied", applied: nil, wantErr: "missing migrations [1 2]"},
+		{name: "partly applied", applied: known[:1], wantErr: "missing migrations [2]"},
+		{name: "up to date", applied: known},
+		{name: "newer database", applied: append(known, Migration{Version: 3, Description: "three", Up: noop}), wantErr: "doesn't know about"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := testdb.New(t)
+
+			if _, err := NewMigrator(service, tt.applied).Up(ctx); err != nil {
+				t.Fatalf("Up: %v", err)
+			}
+
+			err := NewMigrator(service, known).Check(ctx)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Check: unexpected error %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Check: got %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	service := testdb.New(t)
+
+	failed := false
+	migrations := []Migration{
+		{Version: 1, Description: "one", Up: noop},
+		{Version: 2, Description: "two", Up: func(context.Context, *Migrator) error {
+			failed = true
+			return context.DeadlineExceeded
+		}},
+		{Version: 3, Description: "three", Up: noop},
+	}
+
+	applied, err := NewMigrator(service, migrations).Up(ctx)
+	if err == nil || !failed || applied != 1 {
+		t.Fatalf("Up: applied %d, err %v; want 1 applied and the error of migration 2", applied, err)
+	}
+
+	statuses, err := NewMigrator(service, migrations).Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Applied != (status.Version == 1) {
+			t.Errorf("migration %d: applied %v", status.Version, status.Applied)
+		}
+	}
+}
+
+// legacyTable is input without the indexes that migrations add.
+func legacyTable(input dynamodb.CreateTableInput, added ...string) dynamodb.CreateTableInput {
+	var indexes []types.GlobalSecondaryIndex
+	for _, index := range input.GlobalSecondaryIndexes {
+		keep := true
+		for _, name := range added {
+			keep = keep && aws.ToString(index.IndexName) != name
+		}
+		if keep {
+			indexes = append(indexes, index)
+		}
+	}
+	input.GlobalSecondaryIndexes = indexes
+	return input
+}
+
+func TestAllUpgradesLegacyTables(t *testing.T) {
+	ctx := context.Background()
+	service := testdb.Empty(t)
+
+	for _, input := range config.TableInputs() {
+		switch aws.ToString(input.TableName) {
+		case repositories.UsersTable:
+			input = legacyTable(input, "UserNameIndex")
+		case repositories.StorageTable:
+			input = legacyTable(input, "UserIDFileNameIndex", "UserIDFileSizeIndex", "UserIDParentIDIndex", "UserIDContentHashIndex", "StatusExpiresAtIndex")
+		}
+		testdb.CreateTable(t, service, input)
+	}
+
+	// A file stored before folders and the trash existed.
+	_, err := service.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(repositories.StorageTable),
+		Item: map[string]types.AttributeValue{
+			"ObjectID":   &types.AttributeValueMemberS{Value: "legacy"},
+			"UserID":     &types.AttributeValueMemberS{Value: "user-1"},
+			"FileName":   &types.AttributeValueMemberS{Value: "old.txt"},
+			"FileSize":   &types.AttributeValueMemberN{Value: "3"},
+			"UploadedAt": &types.AttributeValueMemberS{Value: "2024-01-02T03:04:05Z"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+
+	migrator := NewMigrator(service, All)
+	if err := migrator.Check(ctx); err == nil {
+		t.Fatal("Check passed before migrating")
+	}
+	if applied, err := migrator.Up(ctx); err != nil || applied != len(All) {
+		t.Fatalf("Up: applied %d, err %v", applied, err)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+
+	for _, table := range []dynamodb.CreateTableInput{config.CreateUserTableInput(), config.CreateStorageTableInput()} {
+		description, err := service.Client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: table.TableName})
+		if err != nil {
+			t.Fatalf("DescribeTable: %v", err)
+		}
+		for _, index := range table.GlobalSecondaryIndexes {
+			if findIndex(description.Table, aws.ToString(index.IndexName)) == nil {
+				t.Errorf("table %s has no index %s", aws.ToString(table.TableName), aws.ToString(index.IndexName))
+			}
+		}
+	}
+
+	storageRepo := repositories.NewStorageRepository(service, nil, config.Defaults())
+	files, err := storageRepo.ListChildFiles(ctx, "user-1", models.RootFolderID)
+	if err != nil {
+		t.Fatalf("ListChildFiles: %v", err)
+	}
+	if len(files) != 1 || files[0].Status != models.StatusActive {
+		t.Fatalf("root folder holds %+v, want the legacy file marked active", files)
+	}
+
+	if applied, err := NewMigrator(service, All).Up(ctx); err != nil || applied != 0 {
+		t.Fatalf("second Up: applied %d, err %v", applied, err)
+	}
+}
+
+func TestNormalizeFileTimes(t *testing.T) {
+	tests := []struct {
+		name       string
+		uploadedAt string
+		want       string
+	}{
+		{name: "local time", uploadedAt: "2024-01-02T05:04:05.123+02:00", want: "2024-01-02T03:04:05.123Z"},
+		{name: "west of UTC", uploadedAt: "2024-01-01T22:04:05-05:00", want: "2024-01-02T03:04:05Z"},
+		{name: "already UTC", uploadedAt: "2024-01-02T03:04:05Z", want: "2024-01-02T03:04:05Z"},
+		{name: "unparseable", uploadedAt: "yesterday", want: "yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := testdb.New(t)
+
+			_, err := service.Client.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName: aws.String(repositories.StorageTable),
+				Item: map[string]types.AttributeValue{
+					"ObjectID":   &types.AttributeValueMemberS{Value: "legacy"},
+					"UserID":     &types.AttributeValueMemberS{Value: "user-1"},
+					"FileName":   &types.AttributeValueMemberS{Value: "old.txt"},
+					"FileSize":   &types.AttributeValueMemberN{Value: "3"},
+					"UploadedAt": &types.AttributeValueMemberS{Value: tt.uploadedAt},
+					"UpdatedAt":  &types.AttributeValueMemberS{Value: tt.uploadedAt},
+				},
+			})
+			if err != nil {
+				t.Fatalf("PutItem: %v", err)
+			}
+
+			if err := normalizeFileTimes(ctx, NewMigrator(service, nil)); err != nil {
+				t.Fatalf("normalizeFileTimes: %v", err)
+			}
+
+			result, err := service.Client.GetItem(ctx, &dynamodb.GetItemInput{
+				TableName: aws.String(repositories.StorageTable),
+				Key:       map[string]types.AttributeValue{"ObjectID": &types.AttributeValueMemberS{Value: "legacy"}},
+			})
+			if err != nil {
+				t.Fatalf("GetItem: %v", err)
+			}
+			for _, attribute := range []string{"UploadedAt", "UpdatedAt"} {
+				if got := result.Item[attribute].(*types.AttributeValueMemberS).Value; got != tt.want {
+					t.Errorf("%s = %q, want %q", attribute, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+// Package migrations evolves the metadata tables after ConnectDatabase has
+// created them. Each migration has a version; the versions that were applied
+// are recorded in the schema_migration table.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+)
+
+const SchemaMigrationTable = "schema_migration"
+
+// indexPollInterval is how often AddGlobalSecondaryIndex checks whether a new
+// index has finished building.
+const indexPollInterval = 5 * time.Second
+
+// Migration is one step of the schema. Up must be safe to run again when it
+// was interrupted halfway: a migration is only recorded once Up returns.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, m *Migrator) error
+}
+
+// AppliedMigration is the record of a migration in the schema_migration
+// table.
+type AppliedMigration struct {
+	Version     int    `dynamodbav:"Version"`
+	Description string `dynamodbav:"Description"`
+	AppliedAt   int64  `dynamodbav:"AppliedAt"`
+}
+
+// MigrationStatus is one line of Status. A migration that is Applied but not
+// Known was applied by a newer build.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Known       bool
+	Applied     bool
+	AppliedAt   time.Time
+}
+
+type Migrator struct {
+	service    *config.DynamoDBService
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for migrations, which are sorted by version.
+func NewMigrator(service *config.DynamoDBService, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{service: service, migrations: sorted}
+}
+
+// Service is the metadata store the migrations run against.
+func (m *Migrator) Service() *config.DynamoDBService {
+	return m.service
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]AppliedMigration, error) {
+	applied := map[int]AppliedMigration{}
+
+	paginator := dynamodb.NewScanPaginator(m.service.Client, &dynamodb.ScanInput{
+		TableName:      aws.String(SchemaMigrationTable),
+		ConsistentRead: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan applied migrations: %v", err)
+			return nil, err
+		}
+
+		var records []AppliedMigration
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &records); err != nil {
+			log.Printf("Failed to unmarshal applied migrations: %v", err)
+			return nil, err
+		}
+		for _, record := range records {
+			applied[record.Version] = record
+		}
+	}
+
+	return applied, nil
+}
+
+// Status lists every known migration and every applied one, by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Description: migration.Description, Known: true}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = time.Unix(record.AppliedAt, 0)
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version:     record.Version,
+			Description: record.Description,
+			Applied:     true,
+			AppliedAt:   time.Unix(record.AppliedAt, 0),
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Up applies the pending migrations in order and returns how many it applied.
+// It stops at the first one that fails.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %d: %s", migration.Version, migration.Description)
+		if err := migration.Up(ctx, m); err != nil {
+			return count, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		if err := m.record(ctx, migration); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *Migrator) record(ctx context.Context, migration Migration) error {
+	item, err := attributevalue.MarshalMap(AppliedMigration{
+		Version:     migration.Version,
+		Description: migration.Description,
+		AppliedAt:   time.Now().Unix(),
+	})
+	if err != nil {
+		log.Printf("Failed to marshal migration record: %v", err)
+		return err
+	}
+
+	_, err = m.service.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(SchemaMigrationTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(Version)"),
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			// Another run applied the same migration in the meantime.
+			log.Printf("Migration %d was already recorded", migration.Version)
+			return nil
+		}
+		log.Printf("Failed to record migration %d: %v", migration.Version, err)
+		return err
+	}
+	return nil
+}
+
+// Check returns an error unless exactly the known migrations have been
+// applied, so that a server never runs against a schema it wasn't built for.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending, unknown []string
+	for _, status := range statuses {
+		switch {
+		case !status.Applied:
+			pending = append(pending, strconv.Itoa(status.Version))
+		case !status.Known:
+			unknown = append(unknown, strconv.Itoa(status.Version))
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("the database has migrations %v that this build doesn't know about; deploy a newer build", unknown)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("the database is missing migrations %v; run `go run ./cmd/migrate up` first", pending)
+	}
+	return nil
+}
+
+// AddGlobalSecondaryIndex adds index to table unless the table already has
+// it, and waits until the index is active. attributes defines the types of
+// the index's key attributes.
+func (m *Migrator) AddGlobalSecondaryIndex(ctx context.Context, table string, index types.GlobalSecondaryIndex, attributes []types.AttributeDefinition) error {
+	indexName := aws.ToString(index.IndexName)
+
+	description, err := m.service.Client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		log.Printf("Failed to describe table %s: %v", table, err)
+		return err
+	}
+
+	if findIndex(description.Table, indexName) == nil {
+		log.Printf("Creating index %s on table %s", indexName, table)
+		create := &types.CreateGlobalSecondaryIndexAction{
+			IndexName:             index.IndexName,
+			KeySchema:             index.KeySchema,
+			Projection:            index.Projection,
+			ProvisionedThroughput: index.ProvisionedThroughput,
+		}
+		_, err := m.service.Client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:                   aws.String(table),
+			AttributeDefinitions:        attributes,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: create}},
+		})
+		if err != nil {
+			log.Printf("Failed to create index %s on table %s: %v", indexName, table, err)
+			return err
+		}
+	}
+
+	return m.waitForIndex(ctx, table, indexName)
+}
+
+func (m *Migrator) waitForIndex(ctx context.Context, table string, indexName string) error {
+	ticker := time.NewTicker(indexPollInterval)
+	defer ticker.Stop()
+
+	for {
+		description, err := m.service.Client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			log.Printf("Failed to describe table %s: %v", table, err)
+			return err
+		}
+
+		index := findIndex(description.Table, indexName)
+		if index == nil {
+			return fmt.Errorf("index %s of table %s disappeared while it was being built", indexName, table)
+		}
+		if index.IndexStatus == types.IndexStatusActive {
+			return nil
+		}
+		if aws.ToBool(index.Backfilling) {
+			log.Printf("Index %s of table %s is backfilling", indexName, table)
+		} else {
+			log.Printf("Index %s of table %s is %s", indexName, table, index.IndexStatus)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func findIndex(table *types.TableDescription, indexName string) *types.GlobalSecondaryIndexDescription {
+	if table == nil {
+		return nil
+	}
+	for i := range table.GlobalSecondaryIndexes {
+		if aws.ToString(table.GlobalSecondaryIndexes[i].IndexName) == indexName {
+			return &table.GlobalSecondaryIndexes[i]
+		}
+	}
+	return nil
+}
+
+// Backfill scans table and applies the update that change returns for each
+// item; change returns nil to leave an item alone. TableName and Key of the
+// update are filled in. An update whose condition fails is skipped, since the
+// item changed under the scan. Backfill returns how many items it updated.
+func (m *Migrator) Backfill(ctx context.Context, table string, change func(item map[string]types.AttributeValue) *dynamodb.UpdateItemInput) (int, error) {
+	description, err := m.service.Client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		log.Printf("Failed to describe table %s: %v", table, err)
+		return 0, err
+	}
+
+	updated := 0
+	paginator := dynamodb.NewScanPaginator(m.service.Client, &dynamodb.ScanInput{
+		TableName: aws.String(table),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan table %s: %v", table, err)
+			return updated, err
+		}
+
+		for _, item := range page.Items {
+			update := change(item)
+			if update == nil {
+				continue
+			}
+
+			input := *update
+			input.TableName = aws.String(table)
+			if input.Key == nil {
+				input.Key = map[string]types.AttributeValue{}
+				for _, key := range description.Table.KeySchema {
+					name := aws.ToString(key.AttributeName)
+					input.Key[name] = item[name]
+				}
+			}
+
+			if _, err := m.service.Client.UpdateItem(ctx, &input); err != nil {
+				var conditionFailed *types.ConditionalCheckFailedException
+				if errors.As(err, &conditionFailed) {
+					continue
+				}
+				log.Printf("Failed to backfill an item of table %s: %v", table, err)
+				return updated, err
+			}
+			updated++
+		}
+
+		log.Printf("Backfilled %d items of table %s so far", updated, table)
+	}
+
+	return updated, nil
+}
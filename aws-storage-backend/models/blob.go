@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Blob is deduplicated content in S3, stored once under a key derived from
+// its SHA-256 and shared by every StorageObject with that content. VersionID
+// stays empty until the bytes are in S3.
+type Blob struct {
+	BlobKey        string    `dynamodbav:"BlobKey"`
+	RefCount       int64     `dynamodbav:"RefCount"`
+	FileSize       int64     `dynamodbav:"FileSize"`
+	ETag           string    `dynamodbav:"ETag,omitempty"`
+	VersionID      string    `dynamodbav:"VersionID,omitempty"`
+	ChecksumSHA256 string    `dynamodbav:"ChecksumSHA256,omitempty"`
+	ChecksumCRC32C string    `dynamodbav:"ChecksumCRC32C,omitempty"`
+	CreatedAt      time.Time `dynamodbav:"CreatedAt"`
+}
+
+// DuplicateGroup is a set of a user's files with identical content.
+type DuplicateGroup struct {
+	ContentHash string          `json:"contentHash"`
+	FileSize    int64           `json:"fileSize"`
+	Count       int             `json:"count"`
+	Files       []StorageObject `json:"files"`
+}
+
+type DuplicatesResponse struct {
+	Success bool             `json:"success"`
+	Message string           `json:"message"`
+	Groups  []DuplicateGroup `json:"groups"`
+	Count   int              `json:"count"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// UploadedByGuest marks file versions that came in through a file request.
+const UploadedByGuest = "guest"
+
+// FileRequest lets people without an account upload files into one of the
+// owner's folders through a link carrying Token.
+type FileRequest struct {
+	RequestID           string     `dynamodbav:"RequestID" json:"requestId"`
+	Token               string     `dynamodbav:"Token" json:"token"`
+	UserID              string     `dynamodbav:"UserID" json:"-"`
+	FolderID            string     `dynamodbav:"FolderID" json:"folderId"`
+	Title               string     `dynamodbav:"Title" json:"title"`
+	ExpiresAt           int64      `dynamodbav:"ExpiresAt,omitempty" json:"expiresAt,omitempty"`
+	MaxFiles            int64      `dynamodbav:"MaxFiles,omitempty" json:"maxFiles,omitempty"`
+	MaxFileSize         int64      `dynamodbav:"MaxFileSize" json:"maxFileSize"`
+	AllowedContentTypes []string   `dynamodbav:"AllowedContentTypes,omitempty" json:"allowedContentTypes,omitempty"`
+	UploadCount         int64      `dynamodbav:"UploadCount" json:"uploadCount"`
+	RevokedAt           *time.Time `dynamodbav:"RevokedAt,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt           time.Time  `dynamodbav:"CreatedAt" json:"createdAt"`
+}
+
+type CreateFileRequestRequest struct {
+	Title               string   `json:"title" binding:"required,min=1,max=255"`
+	FolderID            string   `json:"folderId"`
+	ExpiresInHours      int      `json:"expiresInHours" binding:"omitempty,min=1,max=8760"`
+	MaxFiles            int64    `json:"maxFiles" binding:"omitempty,min=1,max=1000"`
+	MaxFileSize         int64    `json:"maxFileSize" binding:"omitempty,min=1"`
+	AllowedContentTypes []string `json:"allowedContentTypes"`
+}
+
+type FileRequestsResponse struct {
+	Success  bool          `json:"success"`
+	Message  string        `json:"message"`
+	Requests []FileRequest `json:"requests"`
+}
+
+// PublicFileRequest is what a guest sees before uploading.
+type PublicFileRequest struct {
+	Title               string   `json:"title"`
+	ExpiresAt           int64    `json:"expiresAt,omitempty"`
+	MaxFileSize         int64    `json:"maxFileSize"`
+	AllowedContentTypes []string `json:"allowedContentTypes,omitempty"`
+	FilesLeft           *int64   `json:"filesLeft,omitempty"`
+}
+
+type FileRequestUploadsResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Files   []StorageObject `json:"files"`
+}
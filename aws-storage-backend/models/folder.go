@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// RootFolderID is the ParentID of everything at the top of a user's tree.
+// There is no folder item for it.
+const RootFolderID = "root"
+
+type Folder struct {
+	FolderID  string    `dynamodbav:"FolderID" json:"folderId"`
+	UserID    string    `dynamodbav:"UserID" json:"userId"`
+	Name      string    `dynamodbav:"Name" json:"name"`
+	ParentID  string    `dynamodbav:"ParentID" json:"parentId"`
+	CreatedAt time.Time `dynamodbav:"CreatedAt" json:"createdAt"`
+	UpdatedAt time.Time `dynamodbav:"UpdatedAt" json:"updatedAt"`
+}
+
+type CreateFolderRequest struct {
+	Name     string `json:"name" binding:"required,min=1,max=255"`
+	ParentID string `json:"parentId"`
+}
+
+type RenameFolderRequest struct {
+	Name string `json:"name" binding:"required,min=1,max=255"`
+}
+
+type MoveRequest struct {
+	ParentID string `json:"parentId" binding:"required"`
+}
+
+type FolderPathEntry struct {
+	FolderID string `json:"folderId"`
+	Name     string `json:"name"`
+}
+
+type FolderResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message"`
+	Folder  *Folder           `json:"folder,omitempty"`
+	Folders []Folder          `json:"folders"`
+	Path    []FolderPathEntry `json:"path"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+const (
+	ResourceTypeFile   = "file"
+	ResourceTypeFolder = "folder"
+)
+
+// RoleRank orders roles so that a stronger role satisfies a weaker check.
+// Unknown roles, including "", rank below viewer.
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Grant gives another registered user a role on a file or a folder. A grant on
+// a folder covers everything below it.
+type Grant struct {
+	ResourceID   string    `dynamodbav:"ResourceID" json:"resourceId"`
+	GranteeID    string    `dynamodbav:"GranteeID" json:"granteeId"`
+	GranteeEmail string    `dynamodbav:"GranteeEmail" json:"granteeEmail"`
+	ResourceType string    `dynamodbav:"ResourceType" json:"resourceType"`
+	OwnerID      string    `dynamodbav:"OwnerID" json:"ownerId"`
+	Role         string    `dynamodbav:"Role" json:"role"`
+	CreatedAt    time.Time `dynamodbav:"CreatedAt" json:"createdAt"`
+	UpdatedAt    time.Time `dynamodbav:"UpdatedAt" json:"updatedAt"`
+}
+
+type GrantAccessRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+type GrantsResponse struct {
+	Success bool    `json:"success"`
+	Message string  `json:"message"`
+	Grants  []Grant `json:"grants"`
+}
+
+type SharedItem struct {
+	Grant  Grant          `json:"grant"`
+	File   *StorageObject `json:"file,omitempty"`
+	Folder *Folder        `json:"folder,omitempty"`
+}
+
+type SharedWithMeResponse struct {
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Items   []SharedItem `json:"items"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+const (
+	NotificationGrantAdded   = "grant_added"
+	NotificationGrantRevoked = "grant_revoked"
+)
+
+// Notification is a message for one user. NotificationID starts with the
+// creation time, so sorting by it lists newest or oldest first.
+type Notification struct {
+	UserID         string    `dynamodbav:"UserID" json:"-"`
+	NotificationID string    `dynamodbav:"NotificationID" json:"notificationId"`
+	Type           string    `dynamodbav:"Type" json:"type"`
+	ActorID        string    `dynamodbav:"ActorID" json:"actorId"`
+	ResourceID     string    `dynamodbav:"ResourceID" json:"resourceId"`
+	ResourceType   string    `dynamodbav:"ResourceType" json:"resourceType"`
+	ResourceName   string    `dynamodbav:"ResourceName" json:"resourceName"`
+	Role           string    `dynamodbav:"Role,omitempty" json:"role,omitempty"`
+	Read           bool      `dynamodbav:"Read" json:"read"`
+	CreatedAt      time.Time `dynamodbav:"CreatedAt" json:"createdAt"`
+}
+
+type ListNotificationsQuery struct {
+	Limit      int32 `form:"limit" binding:"omitempty,min=1,max=100"`
+	UnreadOnly bool  `form:"unreadOnly"`
+}
+
+type NotificationsResponse struct {
+	Success       bool           `json:"success"`
+	Message       string         `json:"message"`
+	Notifications []Notification `json:"notifications"`
+}
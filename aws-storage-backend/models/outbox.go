@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	OutboxUpload = "upload"
+	OutboxPurge  = "purge"
+	// OutboxReplace is a new version of a file's content being written.
+	OutboxReplace = "replace"
+)
+
+// Outcomes of resuming an outbox entry.
+const (
+	OutboxFinalized  = "finalized"
+	OutboxRolledBack = "rolled_back"
+	OutboxDiscarded  = "discarded"
+	OutboxHeld       = "held"
+)
+
+// OutboxEntry is written in the same transaction that starts an upload or a
+// permanent delete, and removed once the S3 side and the final record are in
+// place. DueAt is when the lease of its holder runs out: an entry still around
+// after DueAt means the request did not get that far and stopped renewing it;
+// the outbox worker picks it up from there.
+//
+// FileSize is what the request reserved against the quota: the whole file for
+// an upload, the growth for a replacement. ETag and VersionID are the content
+// a replacement started from.
+type OutboxEntry struct {
+	OutboxID  string    `dynamodbav:"OutboxID"`
+	Kind      string    `dynamodbav:"Kind"`
+	ObjectID  string    `dynamodbav:"ObjectID"`
+	UserID    string    `dynamodbav:"UserID"`
+	FileSize  int64     `dynamodbav:"FileSize"`
+	BlobKey   string    `dynamodbav:"BlobKey,omitempty"`
+	ETag      string    `dynamodbav:"ETag,omitempty"`
+	VersionID string    `dynamodbav:"VersionID,omitempty"`
+	Attempts  int       `dynamodbav:"Attempts"`
+	LastError string    `dynamodbav:"LastError,omitempty"`
+	CreatedAt time.Time `dynamodbav:"CreatedAt"`
+	DueAt     int64     `dynamodbav:"DueAt"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// StoredObject is an S3 object as ListObjectsV2 reports it.
+type StoredObject struct {
+	Key          string
+	ETag         string
+	Size         int64
+	LastModified time.Time
+}
+
+// ReconcileOptions controls a reconciliation run. Without Fix nothing is
+// changed. Objects younger than MinAge are left alone, since their metadata
+// may still be on its way.
+type ReconcileOptions struct {
+	Fix    bool
+	MinAge time.Duration
+}
+
+// OrphanObject is an S3 object no storage record or blob points at.
+type OrphanObject struct {
+	Key          string    `json:"key"`
+	UserID       string    `json:"userId,omitempty"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+	Repaired     bool      `json:"repaired"`
+}
+
+// DanglingRecord is a storage record whose S3 object is gone.
+type DanglingRecord struct {
+	ObjectID string `json:"objectId"`
+	UserID   string `json:"userId"`
+	S3Key    string `json:"s3Key"`
+	Status   string `json:"status"`
+	FileSize int64  `json:"fileSize"`
+	Repaired bool   `json:"repaired"`
+}
+
+type ReconcileReport struct {
+	StartedAt       time.Time        `json:"startedAt"`
+	FinishedAt      time.Time        `json:"finishedAt"`
+	Fix             bool             `json:"fix"`
+	Users           int              `json:"users"`
+	ObjectsScanned  int              `json:"objectsScanned"`
+	RecordsScanned  int              `json:"recordsScanned"`
+	OrphanObjects   []OrphanObject   `json:"orphanObjects"`
+	DanglingRecords []DanglingRecord `json:"danglingRecords"`
+}
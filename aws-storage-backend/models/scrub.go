@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+const (
+	ScrubMissing          = "missing"
+	ScrubSizeMismatch     = "size_mismatch"
+	ScrubChecksumMissing  = "checksum_missing"
+	ScrubChecksumMismatch = "checksum_mismatch"
+)
+
+// ScrubOptions controls a scrub run. Deep downloads every object to recompute
+// its checksums instead of trusting what S3 reports; Quarantine blocks
+// downloads of files that fail.
+type ScrubOptions struct {
+	Deep       bool
+	Quarantine bool
+}
+
+// ScrubFinding is one way a file's stored bytes disagree with its metadata.
+type ScrubFinding struct {
+	ObjectID    string `json:"objectId"`
+	UserID      string `json:"userId"`
+	S3Key       string `json:"s3Key"`
+	Problem     string `json:"problem"`
+	Expected    string `json:"expected,omitempty"`
+	Actual      string `json:"actual,omitempty"`
+	Quarantined bool   `json:"quarantined"`
+}
+
+// ScrubReport sums up a scrub run. Skipped counts pending uploads and files
+// that were already quarantined.
+type ScrubReport struct {
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Checked    int            `json:"checked"`
+	Skipped    int            `json:"skipped"`
+	Findings   []ScrubFinding `json:"findings"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// ShareLink gives anyone holding Token read access to one file, within the
+// limits set by its owner.
+type ShareLink struct {
+	Token         string     `dynamodbav:"Token" json:"token"`
+	ObjectID      string     `dynamodbav:"ObjectID" json:"objectId"`
+	UserID        string     `dynamodbav:"UserID" json:"-"`
+	PasswordHash  string     `dynamodbav:"PasswordHash,omitempty" json:"-"`
+	ExpiresAt     int64      `dynamodbav:"ExpiresAt,omitempty" json:"expiresAt,omitempty"`
+	MaxDownloads  int64      `dynamodbav:"MaxDownloads,omitempty" json:"maxDownloads,omitempty"`
+	DownloadCount int64      `dynamodbav:"DownloadCount" json:"downloadCount"`
+	RevokedAt     *time.Time `dynamodbav:"RevokedAt,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt     time.Time  `dynamodbav:"CreatedAt" json:"createdAt"`
+	HasPassword   bool       `dynamodbav:"-" json:"hasPassword"`
+}
+
+type CreateShareLinkRequest struct {
+	ExpiresInHours int    `json:"expiresInHours" binding:"omitempty,min=1,max=8760"`
+	Password       string `json:"password" binding:"omitempty,min=4,max=72"`
+	MaxDownloads   int64  `json:"maxDownloads" binding:"omitempty,min=1"`
+}
+
+type ShareLinksResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Links   []ShareLink `json:"links"`
+}
+
+// SharedFileInfo is what a share link reveals before downloading.
+type SharedFileInfo struct {
+	Token         string `json:"token"`
+	FileName      string `json:"fileName"`
+	FileSize      int64  `json:"fileSize"`
+	ContentType   string `json:"contentType"`
+	ExpiresAt     int64  `json:"expiresAt,omitempty"`
+	DownloadsLeft *int64 `json:"downloadsLeft,omitempty"`
+}
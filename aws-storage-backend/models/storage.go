@@ -1,10 +1,14 @@
 package models
 
-import "time"
+import (
+    "io"
+    "time"
+)
 
 type StorageObject struct {
     ObjectID      string    `dynamodbav:"ObjectID"`
     UserID        string    `dynamodbav:"UserID"`
+    ParentID      string    `dynamodbav:"ParentID,omitempty"`
     FileName      string    `dynamodbav:"FileName"`
     FileSize      int64     `dynamodbav:"FileSize"`
     ContentType   string    `dynamodbav:"ContentType"`
@@ -13,11 +17,70 @@ type StorageObject struct {
     UploadedAt    time.Time `dynamodbav:"UploadedAt"`
     UpdatedAt     time.Time `dynamodbav:"UpdatedAt"`
     Description   *string    `dynamodbav:"Description"`
+    ETag          string    `dynamodbav:"ETag,omitempty"`
+    Status        string    `dynamodbav:"Status,omitempty" json:"status,omitempty"`
+    Version       int64     `dynamodbav:"Version" json:"version"`
+    VersionID     string    `dynamodbav:"VersionID,omitempty" json:"versionId,omitempty"`
+    DeletedAt     *time.Time `dynamodbav:"DeletedAt,omitempty" json:"deletedAt,omitempty"`
+    ExpiresAt     int64     `dynamodbav:"ExpiresAt,omitempty" json:"expiresAt,omitempty"`
+    FileRequestID string    `dynamodbav:"FileRequestID,omitempty" json:"fileRequestId,omitempty"`
+    Uploader      *Uploader `dynamodbav:"Uploader,omitempty" json:"uploader,omitempty"`
+    ContentHash   string    `dynamodbav:"ContentHash,omitempty" json:"contentHash,omitempty"`
+    BlobKey       string    `dynamodbav:"BlobKey,omitempty" json:"-"`
+    ChecksumSHA256 string   `dynamodbav:"ChecksumSHA256,omitempty" json:"checksumSha256,omitempty"`
+    ChecksumCRC32C string   `dynamodbav:"ChecksumCRC32C,omitempty" json:"checksumCrc32c,omitempty"`
+    QuarantinedAt *time.Time `dynamodbav:"QuarantinedAt,omitempty" json:"quarantinedAt,omitempty"`
+    QuarantineReason string `dynamodbav:"QuarantineReason,omitempty" json:"quarantineReason,omitempty"`
+    ReplaceID     string    `dynamodbav:"ReplaceID,omitempty" json:"-"`
     PreviewURL    string    `dynamodbav:"-" json:"previewUrl,omitempty"`
 }
 
+// Uploader is what a guest entered about themselves when uploading through a
+// file request link.
+type Uploader struct {
+    Name  string `dynamodbav:"Name,omitempty" json:"name,omitempty" form:"name" binding:"omitempty,max=100"`
+    Email string `dynamodbav:"Email,omitempty" json:"email,omitempty" form:"email" binding:"omitempty,email"`
+}
+
+const (
+    StatusActive  = "active"
+    StatusPending = "pending"
+    StatusTrashed = "trashed"
+    // StatusUploading and StatusDeleting mark files the server is moving into
+    // or out of S3. An outbox entry finishes the move if the request does not.
+    StatusUploading = "uploading"
+    StatusDeleting  = "deleting"
+)
+
+// SharesBlob reports whether the object's bytes live in a deduplicated blob
+// that other objects may point at too, rather than under its own S3 key.
+func (o *StorageObject) SharesBlob() bool {
+    return o.BlobKey != ""
+}
+
+// IsReplacing reports whether new content is being written for the object.
+// ReplaceID is the outbox entry of that replacement; other changes to the
+// file wait until it is finished or rolled back.
+func (o *StorageObject) IsReplacing() bool {
+    return o.ReplaceID != ""
+}
+
+// IsQuarantined reports whether the scrubber found the stored bytes no longer
+// match the metadata. Quarantined files cannot be downloaded until their
+// content is replaced.
+func (o *StorageObject) IsQuarantined() bool {
+    return o.QuarantinedAt != nil
+}
+
+// IsActive reports whether the object is visible to its owner. Items written
+// before the Status attribute existed have none and count as active.
+func (o *StorageObject) IsActive() bool {
+    return o.Status == "" || o.Status == StatusActive
+}
+
 type UploadFileRequest struct {
     Description *string `form:"description"`
+    FolderID    string  `form:"folderId"`
 }
 
 type UploadFileResponse struct {
@@ -27,15 +90,139 @@ type UploadFileResponse struct {
     ContentType string    `json:"contentType"`
     UploadedAt  time.Time `json:"uploadedAt"`
     Description *string   `json:"description,omitempty"`
+    ParentID    string    `json:"parentId,omitempty"`
+    ChecksumSHA256 string `json:"checksumSha256,omitempty"`
+    ChecksumCRC32C string `json:"checksumCrc32c,omitempty"`
     Message     string    `json:"message"`
 }
 
+// UpdateFileMetadataRequest changes only the fields that are set. Version is
+// the value the client last read; the write fails if the file moved on since.
+type UpdateFileMetadataRequest struct {
+    FileName    *string `json:"fileName" binding:"omitempty,min=1,max=255"`
+    Description *string `json:"description"`
+    Version     *int64  `json:"version"`
+}
+
+type CreateUploadSessionRequest struct {
+    FileName    string  `json:"fileName" binding:"required,max=255"`
+    ContentType string  `json:"contentType" binding:"required"`
+    FileSize    int64   `json:"fileSize" binding:"required,min=1"`
+    Description *string `json:"description"`
+    FolderID    string  `json:"folderId"`
+    Method      string  `json:"method" binding:"omitempty,oneof=put post"`
+}
+
+type UploadSessionResponse struct {
+    ObjectID  string            `json:"objectId"`
+    Method    string            `json:"method"`
+    URL       string            `json:"url"`
+    Headers   map[string]string `json:"headers,omitempty"`
+    Fields    map[string]string `json:"fields,omitempty"`
+    ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+type MultipartUpload struct {
+    ObjectID  string                  `dynamodbav:"ObjectID" json:"objectId"`
+    UserID    string                  `dynamodbav:"UserID" json:"-"`
+    UploadID  string                  `dynamodbav:"UploadID" json:"-"`
+    FileSize  int64                   `dynamodbav:"FileSize" json:"fileSize"`
+    PartSize  int64                   `dynamodbav:"PartSize" json:"partSize"`
+    PartCount int32                   `dynamodbav:"PartCount" json:"partCount"`
+    Parts     map[string]UploadedPart `dynamodbav:"Parts" json:"parts"`
+    CreatedAt time.Time               `dynamodbav:"CreatedAt" json:"createdAt"`
+    UpdatedAt time.Time               `dynamodbav:"UpdatedAt" json:"updatedAt"`
+}
+
+type UploadedPart struct {
+    PartNumber int32     `dynamodbav:"PartNumber" json:"partNumber"`
+    ETag       string    `dynamodbav:"ETag" json:"etag"`
+    Size       int64     `dynamodbav:"Size" json:"size"`
+    UploadedAt time.Time `dynamodbav:"UploadedAt" json:"uploadedAt"`
+}
+
+type InitiateMultipartUploadRequest struct {
+    FileName    string  `json:"fileName" binding:"required,max=255"`
+    ContentType string  `json:"contentType" binding:"required"`
+    FileSize    int64   `json:"fileSize" binding:"required,min=1"`
+    Description *string `json:"description"`
+    FolderID    string  `json:"folderId"`
+}
+
+type AcknowledgePartRequest struct {
+    ETag string `json:"etag" binding:"required"`
+}
+
+type MultipartUploadResponse struct {
+    ObjectID     string         `json:"objectId"`
+    FileName     string         `json:"fileName"`
+    FileSize     int64          `json:"fileSize"`
+    PartSize     int64          `json:"partSize"`
+    PartCount    int32          `json:"partCount"`
+    Parts        []UploadedPart `json:"parts"`
+    MissingParts []int32        `json:"missingParts"`
+    ExpiresAt    time.Time      `json:"expiresAt"`
+}
+
+type PartURLResponse struct {
+    PartNumber int32     `json:"partNumber"`
+    URL        string    `json:"url"`
+    Size       int64     `json:"size"`
+    ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type DownloadRequest struct {
+    VersionID       string
+    Range           string
+    IfNoneMatch     string
+    IfModifiedSince *time.Time
+}
+
+type FileInfo struct {
+    ObjectID     string
+    FileName     string
+    ContentType  string
+    Size         int64
+    ETag         string
+    LastModified time.Time
+}
+
+type FileDownload struct {
+    FileInfo
+    Body          io.ReadCloser
+    ContentLength int64
+    ContentRange  string
+    NotModified   bool
+}
+
 type ListStorageObjectsResponse struct {
     Success  bool             `json:"success"`
     Message  string           `json:"message"`
     Data     []StorageObject  `json:"data"`
     Count    int              `json:"count"`
-    // NextToken *string         `json:"nextToken,omitempty"`
+    NextToken *string         `json:"nextToken,omitempty"`
+}
+
+const (
+    SortByUploadedAt = "uploadedAt"
+    SortByFileName   = "fileName"
+    SortByFileSize   = "fileSize"
+
+    SortOrderAsc  = "asc"
+    SortOrderDesc = "desc"
+)
+
+type ListFilesQuery struct {
+    Limit          int32      `form:"limit" binding:"omitempty,min=1,max=100"`
+    NextToken      string     `form:"nextToken"`
+    SortBy         string     `form:"sortBy" binding:"omitempty,oneof=uploadedAt fileName fileSize"`
+    SortOrder      string     `form:"sortOrder" binding:"omitempty,oneof=asc desc"`
+    FolderID       string     `form:"folderId"`
+    ContentType    string     `form:"contentType"`
+    MinSize        *int64     `form:"minSize" binding:"omitempty,min=0"`
+    MaxSize        *int64     `form:"maxSize" binding:"omitempty,min=0"`
+    UploadedAfter  *time.Time `form:"uploadedAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+    UploadedBefore *time.Time `form:"uploadedBefore" time_format:"2006-01-02T15:04:05Z07:00"`
 }
 
 type MonthlyUsage struct {
@@ -47,12 +234,17 @@ type MonthlyUsage struct {
 }
 
 type DashboardData struct {
-	Months  []MonthlyUsage         `json:"months"`
-	Summary map[string]interface{} `json:"summary"`
+	Range        DashboardRange     `json:"range"`
+	Months       []MonthlyUsage     `json:"months"`
+	Series       []UsageBucket      `json:"series"`
+	ContentTypes []ContentTypeUsage `json:"contentTypes"`
+	Folders      []FolderUsage      `json:"folders"`
+	LargestFiles []LargestFile      `json:"largestFiles"`
+	Summary      DashboardSummary   `json:"summary"`
 }
 
 type DashboardResponse struct {
 	Success bool          `json:"success"`
 	Message string        `json:"message"`
 	Data    DashboardData `json:"data"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// UsageAggregate is a running total over some of a user's files. Which files
+// depends on the item: all files uploaded in Month, those of one ContentType
+// within Month, or the files directly inside FolderID.
+type UsageAggregate struct {
+	UserID       string `dynamodbav:"UserID"`
+	UsageKey     string `dynamodbav:"UsageKey"`
+	Month        string `dynamodbav:"Month,omitempty"`
+	ContentType  string `dynamodbav:"ContentType,omitempty"`
+	FolderID     string `dynamodbav:"FolderID,omitempty"`
+	Bytes        int64  `dynamodbav:"Bytes"`
+	FileCount    int64  `dynamodbav:"FileCount"`
+	TrashedBytes int64  `dynamodbav:"TrashedBytes"`
+	TrashedFiles int64  `dynamodbav:"TrashedFiles"`
+}
+
+// UsageActivity counts what happened to a user's files within one UTC hour.
+// NetBytes is how much the active files grew, replacements included.
+type UsageActivity struct {
+	UserID        string `dynamodbav:"UserID"`
+	UsageKey      string `dynamodbav:"UsageKey"`
+	Hour          string `dynamodbav:"Hour"`
+	Uploads       int64  `dynamodbav:"Uploads"`
+	UploadedBytes int64  `dynamodbav:"UploadedBytes"`
+	Deletions     int64  `dynamodbav:"Deletions"`
+	DeletedBytes  int64  `dynamodbav:"DeletedBytes"`
+	NetBytes      int64  `dynamodbav:"NetBytes"`
+}
+
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// DashboardQuery picks the period the dashboard covers. From and To are
+// dates (2006-01-02) in Timezone, both inclusive.
+type DashboardQuery struct {
+	From        string `form:"from"`
+	To          string `form:"to"`
+	Granularity string `form:"granularity" binding:"omitempty,oneof=day week month"`
+	Timezone    string `form:"timezone"`
+	Top         int    `form:"top" binding:"omitempty,min=1,max=50"`
+}
+
+type DashboardRange struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Granularity string    `json:"granularity"`
+	Timezone    string    `json:"timezone"`
+}
+
+// UsageBucket is one day, week or month of the dashboard range. StoredBytes
+// is the size of the active files at the end of the bucket.
+type UsageBucket struct {
+	Start         time.Time `json:"start"`
+	Label         string    `json:"label"`
+	Uploads       int64     `json:"uploads"`
+	UploadedBytes int64     `json:"uploadedBytes"`
+	Deletions     int64     `json:"deletions"`
+	DeletedBytes  int64     `json:"deletedBytes"`
+	NetBytes      int64     `json:"netBytes"`
+	StoredBytes   int64     `json:"storedBytes"`
+}
+
+type ContentTypeUsage struct {
+	ContentType string `json:"contentType"`
+	TotalSize   int64  `json:"totalSize"`
+	FileCount   int64  `json:"fileCount"`
+}
+
+type FolderUsage struct {
+	FolderID   string `json:"folderId"`
+	FolderName string `json:"folderName"`
+	TotalSize  int64  `json:"totalSize"`
+	FileCount  int64  `json:"fileCount"`
+}
+
+type LargestFile struct {
+	ObjectID    string    `json:"objectId"`
+	FileName    string    `json:"fileName"`
+	FileSize    int64     `json:"fileSize"`
+	ContentType string    `json:"contentType"`
+	ParentID    string    `json:"parentId,omitempty"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// DashboardSummary covers the files stored now, except for the upload,
+// deletion and growth figures, which cover the requested range. GrowthRate is
+// the growth in percent of what was stored when the range began, and is left
+// out if nothing was.
+type DashboardSummary struct {
+	TotalSizeInBytes int64    `json:"totalSizeInBytes"`
+	TotalSizeInMB    float64  `json:"totalSizeInMB"`
+	TotalSizeInGB    float64  `json:"totalSizeInGB"`
+	TotalFiles       int64    `json:"totalFiles"`
+	TrashSizeInBytes int64    `json:"trashSizeInBytes"`
+	TrashFiles       int64    `json:"trashFiles"`
+	QuotaInBytes     int64    `json:"quotaInBytes"`
+	UsedInBytes      int64    `json:"usedInBytes"`
+	RemainingInBytes int64    `json:"remainingInBytes"`
+	Uploads          int64    `json:"uploads"`
+	UploadedBytes    int64    `json:"uploadedBytes"`
+	Deletions        int64    `json:"deletions"`
+	DeletedBytes     int64    `json:"deletedBytes"`
+	GrowthInBytes    int64    `json:"growthInBytes"`
+	GrowthRate       *float64 `json:"growthRate,omitempty"`
+}
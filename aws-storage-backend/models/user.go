@@ -1,22 +1,31 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type User struct {
-	UserID       string `json:"user_id" dynamodbav:"UserID"`
-	UserName     string `json:"user_name" dynamodbav:"UserName"`
-	UserEmail    string `json:"user_email" dynamodbav:"UserEmail"`
-	UserPassword string `json:"user_password,omitempty" dynamodbav:"UserPassword"`
-	CreatedAt    int64  `json:"created_at" dynamodbav:"CreatedAt"`
-	UpdatedAt    int64  `json:"updated_at" dynamodbav:"UpdatedAt"`
+	UserID          string `json:"user_id" dynamodbav:"UserID"`
+	UserName        string `json:"user_name" dynamodbav:"UserName"`
+	UserEmail       string `json:"user_email" dynamodbav:"UserEmail"`
+	UserPassword    string `json:"user_password,omitempty" dynamodbav:"UserPassword"`
+	MaxFileVersions int    `json:"max_file_versions,omitempty" dynamodbav:"MaxFileVersions,omitempty"`
+	StorageQuota    *int64 `json:"storage_quota,omitempty" dynamodbav:"StorageQuota,omitempty"`
+	UsedBytes       int64  `json:"used_bytes" dynamodbav:"UsedBytes"`
+	CreatedAt       int64  `json:"created_at" dynamodbav:"CreatedAt"`
+	UpdatedAt       int64  `json:"updated_at" dynamodbav:"UpdatedAt"`
 }
 
 type UserResponse struct {
-	UserID    string `json:"user_id"`
-	UserName  string `json:"user_name"`
-	UserEmail string `json:"user_email"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
+	UserID          string `json:"user_id"`
+	UserName        string `json:"user_name"`
+	UserEmail       string `json:"user_email"`
+	MaxFileVersions int    `json:"max_file_versions,omitempty"`
+	StorageQuota    *int64 `json:"storage_quota,omitempty"`
+	UsedBytes       int64  `json:"used_bytes"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at"`
 }
 
 type LoginRequest struct {
@@ -30,16 +39,35 @@ type CreateUserRequest struct {
 	UserPassword string `json:"user_password" binding:"required,min=8,max=50"`
 }
 
+type VersionPolicyRequest struct {
+	MaxFileVersions int `json:"max_file_versions" binding:"required,min=1,max=100"`
+}
+
+// StorageQuotaRequest sets a user's quota in bytes. A null quota puts the user
+// back on the default from config.
+type StorageQuotaRequest struct {
+	StorageQuota *int64 `json:"storage_quota" binding:"omitempty,min=0"`
+}
+
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		UserID:    u.UserID,
-		UserName:  u.UserName,
-		UserEmail: u.UserEmail,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		UserID:          u.UserID,
+		UserName:        u.UserName,
+		UserEmail:       u.UserEmail,
+		MaxFileVersions: u.MaxFileVersions,
+		StorageQuota:    u.StorageQuota,
+		UsedBytes:       u.UsedBytes,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
 	}
 }
 
+// NormalizeEmail is the form emails are stored and looked up in, so that
+// " Alice@Example.com" and "alice@example.com" are the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func (u *User) SetTimestamps() {
 	now := time.Now().Unix()
 	if u.CreatedAt == 0 {
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// FileVersion records one S3 object version of a StorageObject.
+type FileVersion struct {
+    ObjectID    string    `dynamodbav:"ObjectID" json:"objectId"`
+    VersionID   string    `dynamodbav:"VersionID" json:"versionId"`
+    UserID      string    `dynamodbav:"UserID" json:"-"`
+    FileSize    int64     `dynamodbav:"FileSize" json:"fileSize"`
+    ContentType string    `dynamodbav:"ContentType" json:"contentType"`
+    ETag        string    `dynamodbav:"ETag,omitempty" json:"etag,omitempty"`
+    ChecksumSHA256 string `dynamodbav:"ChecksumSHA256,omitempty" json:"checksumSha256,omitempty"`
+    ChecksumCRC32C string `dynamodbav:"ChecksumCRC32C,omitempty" json:"checksumCrc32c,omitempty"`
+    UploadedBy  string    `dynamodbav:"UploadedBy" json:"uploadedBy"`
+    CreatedAt   time.Time `dynamodbav:"CreatedAt" json:"createdAt"`
+    IsCurrent   bool      `dynamodbav:"-" json:"isCurrent"`
+}
+
+type FileVersionsResponse struct {
+    Success  bool          `json:"success"`
+    Message  string        `json:"message"`
+    ObjectID string        `json:"objectId"`
+    Versions []FileVersion `json:"versions"`
+}
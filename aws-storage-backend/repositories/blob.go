@@ -0,0 +1,319 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const BlobTable = "blob"
+
+// blobKey is where content with the given hash is stored. With the user scope
+// every user has their own copy, so no one can learn what another user
+// stores; with the global scope identical content is kept once per bucket.
+func (r *fileBlobs) blobKey(userID string, hash string) string {
+	if r.dedupScope == "global" {
+		return fmt.Sprintf("blobs/%s", hash)
+	}
+	return fmt.Sprintf("users/%s/blobs/%s", userID, hash)
+}
+
+// acquireBlobWrite adds a reference to the blob, creating its record if this
+// is the first. It goes into the same transaction as the record that holds
+// the reference, so a reference is never taken or dropped twice. A record
+// without a VersionID has no bytes in S3 yet.
+func acquireBlobWrite(key string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(BlobTable),
+			Key: map[string]types.AttributeValue{
+				"BlobKey": &types.AttributeValueMemberS{Value: key},
+			},
+			UpdateExpression: aws.String("SET CreatedAt = if_not_exists(CreatedAt, :now) ADD RefCount :one"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+				":one": &types.AttributeValueMemberN{Value: "1"},
+			},
+		},
+	}
+}
+
+// releaseBlobWrite drops a reference to the blob, in the same transaction as
+// the record that held it. The blob is deleted afterwards by
+// deleteBlobIfUnused.
+func releaseBlobWrite(key string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(BlobTable),
+			Key: map[string]types.AttributeValue{
+				"BlobKey": &types.AttributeValueMemberS{Value: key},
+			},
+			UpdateExpression:    aws.String("ADD RefCount :minusOne"),
+			ConditionExpression: aws.String("attribute_exists(BlobKey)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":minusOne": &types.AttributeValueMemberN{Value: "-1"},
+			},
+		},
+	}
+}
+
+func (r *StorageRepository) getBlob(ctx context.Context, key string) (*models.Blob, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(BlobTable),
+		Key: map[string]types.AttributeValue{
+			"BlobKey": &types.AttributeValueMemberS{Value: key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for blob %s: %v", key, err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var blob models.Blob
+	if err := attributevalue.UnmarshalMap(result.Item, &blob); err != nil {
+		log.Printf("Blob unmarshal failed: %v", err)
+		return nil, err
+	}
+
+	return &blob, nil
+}
+
+// copyBlob makes the given version of srcKey the content of the blob and
+// records which version holds the bytes.
+func (r *StorageRepository) copyBlob(ctx context.Context, srcKey string, srcVersionID string, key string, fileSize int64, digest *contentDigest) (*models.Blob, error) {
+	stored, err := r.blobStore.Copy(ctx, srcKey, srcVersionID, key)
+	if err != nil {
+		log.Printf("Failed to copy %s to blob %s: %v", srcKey, key, err)
+		return nil, err
+	}
+
+	return r.recordBlob(ctx, key, stored, fileSize, digest)
+}
+
+// recordBlob records the version that holds the bytes of the blob. Two first
+// uploads of the same content can race; the loser removes its own S3 version
+// and uses the winner's. If the record is gone, the upload was rolled back
+// meanwhile: the bytes are removed again and ErrUploadRolledBack is returned.
+func (r *StorageRepository) recordBlob(ctx context.Context, key string, stored *blobstore.ObjectInfo, fileSize int64, digest *contentDigest) (*models.Blob, error) {
+	result, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(BlobTable),
+		Key: map[string]types.AttributeValue{
+			"BlobKey": &types.AttributeValueMemberS{Value: key},
+		},
+		UpdateExpression:    aws.String("SET VersionID = :versionID, ETag = :etag, FileSize = :fileSize, ChecksumSHA256 = :sha256, ChecksumCRC32C = :crc32c"),
+		ConditionExpression: aws.String("attribute_exists(BlobKey) AND attribute_not_exists(VersionID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":versionID": &types.AttributeValueMemberS{Value: stored.VersionID},
+			":etag":      &types.AttributeValueMemberS{Value: stored.ETag},
+			":fileSize":  &types.AttributeValueMemberN{Value: fmt.Sprint(fileSize)},
+			":sha256":    &types.AttributeValueMemberS{Value: digest.sha256},
+			":crc32c":    &types.AttributeValueMemberS{Value: digest.crc32c},
+		},
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+
+	var blob models.Blob
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			log.Printf("Failed to record blob %s: %v", key, err)
+			return nil, err
+		}
+
+		if conditionErr.Item == nil {
+			if err := r.blobStore.Delete(ctx, key, stored.VersionID); err != nil {
+				log.Printf("Failed to remove upload of released blob %s: %v", key, err)
+			}
+			return nil, ErrUploadRolledBack
+		}
+
+		if err := r.blobStore.Delete(ctx, key, stored.VersionID); err != nil {
+			log.Printf("Failed to remove duplicate upload of blob %s: %v", key, err)
+		}
+
+		if err := attributevalue.UnmarshalMap(conditionErr.Item, &blob); err != nil {
+			log.Printf("Blob unmarshal failed: %v", err)
+			return nil, err
+		}
+		return &blob, nil
+	}
+
+	if err := attributevalue.UnmarshalMap(result.Attributes, &blob); err != nil {
+		log.Printf("Blob unmarshal failed: %v", err)
+		return nil, err
+	}
+
+	return &blob, nil
+}
+
+// deleteBlobIfUnused deletes a blob nothing refers to any more. Only the S3
+// version the record names is deleted, so a new upload of the same content
+// that starts in the meantime keeps its bytes. It can be called any number of
+// times.
+func (r *StorageRepository) deleteBlobIfUnused(ctx context.Context, key string) error {
+	blob, err := r.getBlob(ctx, key)
+	if err != nil {
+		return err
+	}
+	if blob == nil || blob.RefCount > 0 {
+		return nil
+	}
+
+	condition := "RefCount <= :zero AND attribute_not_exists(VersionID)"
+	values := map[string]types.AttributeValue{
+		":zero": &types.AttributeValueMemberN{Value: "0"},
+	}
+	if blob.VersionID != "" {
+		condition = "RefCount <= :zero AND VersionID = :versionID"
+		values[":versionID"] = &types.AttributeValueMemberS{Value: blob.VersionID}
+	}
+
+	_, err = r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(BlobTable),
+		Key: map[string]types.AttributeValue{
+			"BlobKey": &types.AttributeValueMemberS{Value: key},
+		},
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			// Someone took a new reference in the meantime.
+			return nil
+		}
+		log.Printf("Failed to delete blob record %s: %v", key, err)
+		return err
+	}
+
+	if blob.VersionID == "" {
+		return nil
+	}
+
+	if err := r.blobStore.Delete(ctx, key, blob.VersionID); err != nil {
+		log.Printf("Failed to delete blob %s: %v", key, err)
+		return err
+	}
+
+	return nil
+}
+
+// DetachBlob gives a file that shares a blob a copy of the bytes under its
+// own key, so its content can be replaced and versioned on its own. The copy
+// becomes the file's first recorded version.
+func (r *StorageRepository) DetachBlob(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error) {
+	ownKey := fmt.Sprintf("users/%s/%s", storageObj.UserID, storageObj.ObjectID)
+
+	copied, err := r.blobStore.Copy(ctx, storageObj.BlobKey, "", ownKey)
+	if err != nil {
+		log.Printf("Failed to copy blob %s for file %s: %v", storageObj.BlobKey, storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	etag := storageObj.ETag
+	if copied.ETag != "" {
+		etag = copied.ETag
+	}
+
+	values := map[string]types.AttributeValue{
+		":s3Key":     &types.AttributeValueMemberS{Value: ownKey},
+		":s3Bucket":  &types.AttributeValueMemberS{Value: r.bucketName},
+		":etag":      &types.AttributeValueMemberS{Value: etag},
+		":versionID": &types.AttributeValueMemberS{Value: copied.VersionID},
+		":blobKey":   &types.AttributeValueMemberS{Value: storageObj.BlobKey},
+	}
+	condition := versionCondition(storageObj.UserID, storageObj.Version, values)
+	delete(values, ":nextVersion")
+
+	_, err = r.dynamoService.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String(StorageTable),
+					Key: map[string]types.AttributeValue{
+						"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+					},
+					UpdateExpression:                    aws.String("SET S3Key = :s3Key, S3Bucket = :s3Bucket, ETag = :etag, VersionID = :versionID REMOVE BlobKey"),
+					ConditionExpression:                 aws.String(condition + " AND BlobKey = :blobKey"),
+					ExpressionAttributeValues:           values,
+					ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+				},
+			},
+			releaseBlobWrite(storageObj.BlobKey),
+		},
+	})
+
+	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 0 && aws.ToString(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return nil, versionConflictError(cancelled.CancellationReasons[0].Item, storageObj.UserID)
+		}
+		log.Printf("Failed to detach file %s from blob %s: %v", storageObj.ObjectID, storageObj.BlobKey, err)
+		return nil, err
+	}
+
+	if err := r.deleteBlobIfUnused(ctx, storageObj.BlobKey); err != nil {
+		log.Printf("Failed to clean up blob %s of detached file %s: %v", storageObj.BlobKey, storageObj.ObjectID, err)
+	}
+
+	detached := *storageObj
+	detached.S3Key = ownKey
+	detached.S3Bucket = r.bucketName
+	detached.ETag = etag
+	detached.VersionID = copied.VersionID
+	detached.BlobKey = ""
+
+	return &detached, nil
+}
+
+// ListDuplicateCandidates returns the user's active files that have a
+// content hash, ordered by hash.
+func (r *StorageRepository) ListDuplicateCandidates(ctx context.Context, userID string) ([]models.StorageObject, error) {
+	files := []models.StorageObject{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:                aws.String(StorageTable),
+		IndexName:                aws.String("UserIDContentHashIndex"),
+		KeyConditionExpression:   aws.String("UserID = :userID"),
+		FilterExpression:         aws.String(statusFilter(models.StatusActive)),
+		ExpressionAttributeNames: statusAttributeNames,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+			":status": &types.AttributeValueMemberS{Value: models.StatusActive},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to query hashed files of user %s: %v", userID, err)
+			return nil, err
+		}
+
+		var items []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal files: %v", err)
+			return nil, err
+		}
+		files = append(files, items...)
+	}
+
+	return files, nil
+}
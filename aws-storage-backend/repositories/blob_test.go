@@ -0,0 +1,77 @@
+package repositories_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+func TestBlobRefCounts(t *testing.T) {
+	tests := []struct {
+		scope string
+		// wantObjects is the number of objects in the blob store after the
+		// uploads, then after each file is purged in upload order.
+		wantObjects []int
+	}{
+		{scope: "user", wantObjects: []int{2, 2, 1, 0}},
+		{scope: "global", wantObjects: []int{1, 1, 1, 0}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.scope, func(t *testing.T) {
+				ctx := context.Background()
+				cfg := config.Defaults()
+				cfg.Storage.DedupScope = tt.scope
+				blobStore := blobstore.NewMemoryStore(nil)
+				storage := driver.Open(t, blobStore, cfg).Storage
+
+				var files []*models.StorageObject
+				for _, userID := range []string{"user-1", "user-1", "user-2"} {
+					file, err := storage.UploadFile(ctx, userID, models.RootFolderID, "same.txt", 5, "text/plain", strings.NewReader("hello"), nil)
+					if err != nil {
+						t.Fatalf("UploadFile: %v", err)
+					}
+					files = append(files, file)
+				}
+
+				objects := []int{}
+				countObjects := func() {
+					listed, err := blobStore.List(ctx, "")
+					if err != nil {
+						t.Fatalf("List: %v", err)
+					}
+					objects = append(objects, len(listed))
+				}
+				countObjects()
+
+				for _, file := range files {
+					trashed, err := storage.TrashFile(ctx, file, time.Now())
+					if err != nil {
+						t.Fatalf("TrashFile: %v", err)
+					}
+					if err := storage.PurgeFile(ctx, trashed); err != nil {
+						t.Fatalf("PurgeFile: %v", err)
+					}
+					countObjects()
+				}
+
+				if !slices.Equal(objects, tt.wantObjects) {
+					t.Errorf("blob store held %v objects, want %v", objects, tt.wantObjects)
+				}
+				for _, file := range files {
+					if exists, err := storage.BlobExists(ctx, file.BlobKey); err != nil || exists {
+						t.Errorf("blob record %s survived the last file (%v)", file.BlobKey, err)
+					}
+				}
+			})
+		}
+	})
+}
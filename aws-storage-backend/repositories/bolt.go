@@ -0,0 +1,435 @@
+package repositories
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"go.etcd.io/bbolt"
+)
+
+// The bolt stores keep the metadata in one bbolt file, for running the service
+// without AWS. Every table is a bucket of gob encoded records under their
+// primary key, and every index a bucket of its own that maps the index key to
+// the primary key. A bolt transaction covers the whole file, so what DynamoDB
+// does with conditions and TransactWriteItems is done here by reading,
+// checking and writing in one update.
+
+// boltKeySeparator joins the parts of composite keys. It sorts before every
+// character IDs and names use, so all keys with the same first parts are
+// adjacent and in order of the next part.
+const boltKeySeparator = "\x00"
+
+func boltKey(parts ...string) string {
+	return strings.Join(parts, boltKeySeparator)
+}
+
+// boltSortableInt formats n so that keys holding it sort by n. Only
+// non-negative numbers are stored this way.
+func boltSortableInt(n int64) string {
+	return fmt.Sprintf("%020d", n)
+}
+
+// errBoltStop ends a scan early without an error.
+var errBoltStop = errors.New("stop scan")
+
+type boltDB struct {
+	db *bbolt.DB
+	// tableName is config.Config.TableName, so deployments sharing a file
+	// keep their tables apart the way they do in DynamoDB.
+	tableName func(string) string
+}
+
+type boltTx struct {
+	tx        *bbolt.Tx
+	tableName func(string) string
+}
+
+func (db *boltDB) view(fn func(tx *boltTx) error) error {
+	return db.db.View(func(tx *bbolt.Tx) error {
+		return fn(&boltTx{tx: tx, tableName: db.tableName})
+	})
+}
+
+func (db *boltDB) update(fn func(tx *boltTx) error) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&boltTx{tx: tx, tableName: db.tableName})
+	})
+}
+
+func (tx *boltTx) bucket(name string) *bbolt.Bucket {
+	return tx.tx.Bucket([]byte(tx.tableName(name)))
+}
+
+// boltTable describes how the records of one table are keyed and indexed.
+type boltTable[T any] struct {
+	name    string
+	key     func(item *T) string
+	indexes []boltIndex[T]
+	// transient clears the fields of a record that are computed for
+	// responses and not stored.
+	transient func(item *T)
+}
+
+type boltIndex[T any] struct {
+	name string
+	// key returns the key of item in the index, and false to leave it out,
+	// like a sparse DynamoDB index.
+	key func(item *T) (string, bool)
+}
+
+func (t *boltTable[T]) indexBucket(index string) string {
+	return t.name + "#" + index
+}
+
+func (t *boltTable[T]) buckets() []string {
+	buckets := []string{t.name}
+	for _, index := range t.indexes {
+		buckets = append(buckets, t.indexBucket(index.name))
+	}
+	return buckets
+}
+
+// get returns the record under key, or nil if there is none.
+func (t *boltTable[T]) get(tx *boltTx, key string) (*T, error) {
+	data := tx.bucket(t.name).Get([]byte(key))
+	if data == nil {
+		return nil, nil
+	}
+	return decodeBolt[T](data)
+}
+
+// put writes item under its key, replacing the record and the index entries
+// that were there.
+func (t *boltTable[T]) put(tx *boltTx, item *T) error {
+	key := t.key(item)
+	if err := t.unindex(tx, key); err != nil {
+		return err
+	}
+
+	stored := *item
+	if t.transient != nil {
+		t.transient(&stored)
+	}
+	data, err := encodeBolt(&stored)
+	if err != nil {
+		return err
+	}
+	if err := tx.bucket(t.name).Put([]byte(key), data); err != nil {
+		return err
+	}
+
+	for _, index := range t.indexes {
+		indexKey, ok := index.key(&stored)
+		if !ok {
+			continue
+		}
+		if err := tx.bucket(t.indexBucket(index.name)).Put([]byte(boltKey(indexKey, key)), []byte(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insert writes item only if nothing is stored under its key yet.
+func (t *boltTable[T]) insert(tx *boltTx, item *T) error {
+	key := t.key(item)
+	if tx.bucket(t.name).Get([]byte(key)) != nil {
+		return fmt.Errorf("%s %q already exists", t.name, key)
+	}
+	return t.put(tx, item)
+}
+
+func (t *boltTable[T]) delete(tx *boltTx, key string) error {
+	if err := t.unindex(tx, key); err != nil {
+		return err
+	}
+	return tx.bucket(t.name).Delete([]byte(key))
+}
+
+// unindex removes the index entries of the record under key.
+func (t *boltTable[T]) unindex(tx *boltTx, key string) error {
+	if len(t.indexes) == 0 {
+		return nil
+	}
+
+	old, err := t.get(tx, key)
+	if err != nil || old == nil {
+		return err
+	}
+
+	for _, index := range t.indexes {
+		indexKey, ok := index.key(old)
+		if !ok {
+			continue
+		}
+		if err := tx.bucket(t.indexBucket(index.name)).Delete([]byte(boltKey(indexKey, key))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scan calls fn with every record whose key starts with prefix, in key order,
+// until fn returns errBoltStop or another error.
+func (t *boltTable[T]) scan(tx *boltTx, prefix string, fn func(item *T) error) error {
+	cursor := tx.bucket(t.name).Cursor()
+	for key, data := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, data = cursor.Next() {
+		item, err := decodeBolt[T](data)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return stopBoltScan(err)
+		}
+	}
+	return nil
+}
+
+// scanReverse is scan from the last key down.
+func (t *boltTable[T]) scanReverse(tx *boltTx, prefix string, fn func(item *T) error) error {
+	cursor := tx.bucket(t.name).Cursor()
+
+	key, data := cursor.Seek([]byte(prefix + "\xff"))
+	if key == nil {
+		key, data = cursor.Last()
+	} else {
+		key, data = cursor.Prev()
+	}
+
+	for ; key != nil && bytes.HasPrefix(key, []byte(prefix)); key, data = cursor.Prev() {
+		item, err := decodeBolt[T](data)
+		if err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return stopBoltScan(err)
+		}
+	}
+	return nil
+}
+
+// scanIndex calls fn with every record whose key in the index starts with
+// prefix, in index order.
+func (t *boltTable[T]) scanIndex(tx *boltTx, index string, prefix string, fn func(item *T) error) error {
+	cursor := tx.bucket(t.indexBucket(index)).Cursor()
+	for key, primaryKey := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, primaryKey = cursor.Next() {
+		item, err := t.get(tx, string(primaryKey))
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			return fmt.Errorf("index %s of %s names missing record %q", index, t.name, primaryKey)
+		}
+		if err := fn(item); err != nil {
+			return stopBoltScan(err)
+		}
+	}
+	return nil
+}
+
+// list returns the records whose key starts with prefix.
+func (t *boltTable[T]) list(tx *boltTx, prefix string) ([]T, error) {
+	items := []T{}
+	err := t.scan(tx, prefix, func(item *T) error {
+		items = append(items, *item)
+		return nil
+	})
+	return items, err
+}
+
+// listIndex returns the records whose key in the index starts with prefix.
+func (t *boltTable[T]) listIndex(tx *boltTx, index string, prefix string) ([]T, error) {
+	items := []T{}
+	err := t.scanIndex(tx, index, prefix, func(item *T) error {
+		items = append(items, *item)
+		return nil
+	})
+	return items, err
+}
+
+func stopBoltScan(err error) error {
+	if errors.Is(err, errBoltStop) {
+		return nil
+	}
+	return err
+}
+
+func encodeBolt(item any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(item); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeBolt[T any](data []byte) (*T, error) {
+	item := new(T)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// boltUniqueBucket maps the keys of uniqueEmailKey and uniqueUserNameKey to
+// the user holding them, like UserUniqueTable.
+const boltUniqueBucket = UserUniqueTable
+
+// boltBuckets lists every bucket the stores use.
+func boltBuckets() []string {
+	buckets := []string{boltUniqueBucket}
+	for _, table := range []interface{ buckets() []string }{
+		&boltUserTable,
+		&boltFileTable,
+		&boltBlobTable,
+		&boltOutboxTable,
+		&boltUsageTable,
+		&boltVersionTable,
+		&boltMultipartTable,
+		&boltFolderTable,
+		&boltShareTable,
+		&boltGrantTable,
+		&boltNotificationTable,
+		&boltFileRequestTable,
+	} {
+		buckets = append(buckets, table.buckets()...)
+	}
+	return buckets
+}
+
+// OpenBoltStores returns the stores kept in the bbolt file at path, creating
+// the file and its buckets if needed. Close the stores to release the file.
+func OpenBoltStores(path string, blobStore blobstore.BlobStore, cfg *config.Config) (*Stores, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	store := &boltDB{db: db, tableName: cfg.TableName}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range boltBuckets() {
+			if _, err := tx.CreateBucketIfNotExists([]byte(cfg.TableName(name))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Stores{
+		Users:         &boltUserStore{db: store},
+		Storage:       &boltStorageStore{fileBlobs: newFileBlobs(blobStore, cfg), db: store},
+		Folders:       &boltFolderStore{db: store},
+		Shares:        &boltShareStore{db: store},
+		Grants:        &boltGrantStore{db: store},
+		Notifications: &boltNotificationStore{db: store},
+		FileRequests:  &boltFileRequestStore{db: store},
+		closer:        db,
+	}, nil
+}
+
+var (
+	_ UserStore         = (*boltUserStore)(nil)
+	_ StorageStore      = (*boltStorageStore)(nil)
+	_ FolderStore       = (*boltFolderStore)(nil)
+	_ ShareStore        = (*boltShareStore)(nil)
+	_ GrantStore        = (*boltGrantStore)(nil)
+	_ NotificationStore = (*boltNotificationStore)(nil)
+	_ FileRequestStore  = (*boltFileRequestStore)(nil)
+)
+
+// boltUsage is an aggregate item of UsageTable. Its labels and counters are
+// the attributes usageChanges sets and adds.
+type boltUsage struct {
+	UserID   string
+	UsageKey string
+	Labels   map[string]string
+	Counters map[string]int64
+}
+
+var boltUsageTable = boltTable[boltUsage]{
+	name: UsageTable,
+	key:  func(u *boltUsage) string { return boltKey(u.UserID, u.UsageKey) },
+}
+
+// addBoltUsage applies changes to the user's aggregate items, like
+// usageChanges.transactItems. Counters that do not move, and items with none
+// that do, are left out.
+func addBoltUsage(tx *boltTx, userID string, changes usageChanges) error {
+	for key, change := range changes {
+		moved := false
+		for _, value := range change.counters {
+			if value != 0 {
+				moved = true
+			}
+		}
+		if !moved {
+			continue
+		}
+
+		usage, err := boltUsageTable.get(tx, boltKey(userID, key))
+		if err != nil {
+			return err
+		}
+		if usage == nil {
+			usage = &boltUsage{UserID: userID, UsageKey: key}
+		}
+		if usage.Labels == nil {
+			usage.Labels = map[string]string{}
+		}
+		if usage.Counters == nil {
+			usage.Counters = map[string]int64{}
+		}
+
+		for name, value := range change.labels {
+			usage.Labels[name] = value
+		}
+		for name, value := range change.counters {
+			usage.Counters[name] += value
+		}
+
+		if err := boltUsageTable.put(tx, usage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *boltUsage) aggregate() models.UsageAggregate {
+	return models.UsageAggregate{
+		UserID:       u.UserID,
+		UsageKey:     u.UsageKey,
+		Month:        u.Labels["Month"],
+		ContentType:  u.Labels["ContentType"],
+		FolderID:     u.Labels["FolderID"],
+		Bytes:        u.Counters["Bytes"],
+		FileCount:    u.Counters["FileCount"],
+		TrashedBytes: u.Counters["TrashedBytes"],
+		TrashedFiles: u.Counters["TrashedFiles"],
+	}
+}
+
+func (u *boltUsage) activity() models.UsageActivity {
+	return models.UsageActivity{
+		UserID:        u.UserID,
+		UsageKey:      u.UsageKey,
+		Hour:          u.Labels["Hour"],
+		Uploads:       u.Counters["Uploads"],
+		UploadedBytes: u.Counters["UploadedBytes"],
+		Deletions:     u.Counters["Deletions"],
+		DeletedBytes:  u.Counters["DeletedBytes"],
+		NetBytes:      u.Counters["NetBytes"],
+	}
+}
@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltFileRequestTable = boltTable[models.FileRequest]{
+	name: FileRequestTable,
+	key:  func(r *models.FileRequest) string { return r.RequestID },
+	indexes: []boltIndex[models.FileRequest]{
+		{name: "token", key: func(r *models.FileRequest) (string, bool) { return r.Token, true }},
+		{name: "user", key: func(r *models.FileRequest) (string, bool) { return r.UserID, true }},
+	},
+}
+
+type boltFileRequestStore struct {
+	db *boltDB
+}
+
+func (r *boltFileRequestStore) CreateFileRequest(ctx context.Context, request *models.FileRequest) (*models.FileRequest, error) {
+	err := r.db.update(func(tx *boltTx) error {
+		return boltFileRequestTable.insert(tx, request)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save file request: %v", err)
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func (r *boltFileRequestStore) GetFileRequest(ctx context.Context, requestID string, userID string) (*models.FileRequest, error) {
+	var request *models.FileRequest
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		request, err = boltFileRequestTable.get(tx, requestID)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for file request %s: %v", requestID, err)
+		return nil, err
+	}
+
+	if request == nil || request.UserID != userID {
+		return nil, ErrFileRequestNotFound
+	}
+
+	return request, nil
+}
+
+func (r *boltFileRequestStore) GetFileRequestByToken(ctx context.Context, token string) (*models.FileRequest, error) {
+	var request *models.FileRequest
+	err := r.db.view(func(tx *boltTx) error {
+		return boltFileRequestTable.scanIndex(tx, "token", boltKey(token, ""), func(found *models.FileRequest) error {
+			request = found
+			return errBoltStop
+		})
+	})
+
+	if err != nil {
+		log.Printf("Get error for file request token: %v", err)
+		return nil, err
+	}
+
+	if request == nil {
+		return nil, ErrFileRequestNotFound
+	}
+
+	return request, nil
+}
+
+func (r *boltFileRequestStore) ListFileRequests(ctx context.Context, userID string) ([]models.FileRequest, error) {
+	var requests []models.FileRequest
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		requests, err = boltFileRequestTable.listIndex(tx, "user", boltKey(userID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list file requests of user %s: %v", userID, err)
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+func (r *boltFileRequestStore) RevokeFileRequest(ctx context.Context, requestID string, userID string) error {
+	return r.updateFileRequest(requestID, ErrFileRequestNotFound, func(request *models.FileRequest) bool {
+		if request.UserID != userID {
+			return false
+		}
+		if request.RevokedAt == nil {
+			now := time.Now().UTC()
+			request.RevokedAt = &now
+		}
+		return true
+	})
+}
+
+// ReserveUpload takes one of the request's upload slots before the bytes are
+// stored, so concurrent guests cannot go past MaxFiles. ReleaseUpload gives
+// the slot back if the upload then fails.
+func (r *boltFileRequestStore) ReserveUpload(ctx context.Context, requestID string, now time.Time) error {
+	return r.updateFileRequest(requestID, ErrFileRequestUnavailable, func(request *models.FileRequest) bool {
+		if request.RevokedAt != nil ||
+			(request.ExpiresAt != 0 && request.ExpiresAt <= now.Unix()) ||
+			(request.MaxFiles != 0 && request.UploadCount >= request.MaxFiles) {
+			return false
+		}
+		request.UploadCount++
+		return true
+	})
+}
+
+func (r *boltFileRequestStore) ReleaseUpload(ctx context.Context, requestID string) error {
+	errNoSlot := fmt.Errorf("file request %s has no upload slot to release", requestID)
+
+	err := r.updateFileRequest(requestID, errNoSlot, func(request *models.FileRequest) bool {
+		if request.UploadCount <= 0 {
+			return false
+		}
+		request.UploadCount--
+		return true
+	})
+
+	if errors.Is(err, errNoSlot) {
+		log.Printf("Failed to release upload slot of file request %s: %v", requestID, err)
+	}
+	return err
+}
+
+// updateFileRequest applies update to a request, or returns rejected if the
+// request is missing or update turns it down.
+func (r *boltFileRequestStore) updateFileRequest(requestID string, rejected error, update func(request *models.FileRequest) bool) error {
+	err := r.db.update(func(tx *boltTx) error {
+		request, err := boltFileRequestTable.get(tx, requestID)
+		if err != nil {
+			return err
+		}
+		if request == nil || !update(request) {
+			return rejected
+		}
+		return boltFileRequestTable.put(tx, request)
+	})
+
+	if errors.Is(err, rejected) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Update error for file request %s: %v", requestID, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+var boltFolderTable = boltTable[models.Folder]{
+	name: FolderTable,
+	key:  func(f *models.Folder) string { return f.FolderID },
+	indexes: []boltIndex[models.Folder]{
+		{name: "parent", key: func(f *models.Folder) (string, bool) {
+			return boltKey(f.UserID, f.ParentID), true
+		}},
+	},
+}
+
+type boltFolderStore struct {
+	db *boltDB
+}
+
+func (r *boltFolderStore) CreateFolder(ctx context.Context, userID string, name string, parentID string) (*models.Folder, error) {
+	now := time.Now().UTC()
+	folder := &models.Folder{
+		FolderID:  uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := r.db.update(func(tx *boltTx) error {
+		return boltFolderTable.insert(tx, folder)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save folder: %v", err)
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+func (r *boltFolderStore) GetFolder(ctx context.Context, folderID string, userID string) (*models.Folder, error) {
+	folder, err := r.GetFolderByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if folder.UserID != userID {
+		return nil, ErrFolderAccessDenied
+	}
+
+	return folder, nil
+}
+
+// GetFolderByID loads a folder without checking who owns it. Callers must
+// check access themselves.
+func (r *boltFolderStore) GetFolderByID(ctx context.Context, folderID string) (*models.Folder, error) {
+	var folder *models.Folder
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		folder, err = boltFolderTable.get(tx, folderID)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for folderID %s: %v", folderID, err)
+		return nil, err
+	}
+
+	if folder == nil {
+		return nil, ErrFolderNotFound
+	}
+
+	return folder, nil
+}
+
+func (r *boltFolderStore) ListChildFolders(ctx context.Context, userID string, parentID string) ([]models.Folder, error) {
+	var folders []models.Folder
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		folders, err = boltFolderTable.listIndex(tx, "parent", boltKey(userID, parentID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list child folders of %s: %v", parentID, err)
+		return nil, err
+	}
+
+	return folders, nil
+}
+
+func (r *boltFolderStore) RenameFolder(ctx context.Context, folderID string, userID string, name string) (*models.Folder, error) {
+	return r.updateFolder(folderID, userID, func(folder *models.Folder) {
+		folder.Name = name
+	})
+}
+
+func (r *boltFolderStore) MoveFolder(ctx context.Context, folderID string, userID string, parentID string) (*models.Folder, error) {
+	return r.updateFolder(folderID, userID, func(folder *models.Folder) {
+		folder.ParentID = parentID
+	})
+}
+
+func (r *boltFolderStore) DeleteFolder(ctx context.Context, folderID string, userID string) error {
+	err := r.db.update(func(tx *boltTx) error {
+		folder, err := boltFolderTable.get(tx, folderID)
+		if err != nil {
+			return err
+		}
+		if folder == nil || folder.UserID != userID {
+			return ErrFolderNotFound
+		}
+		return boltFolderTable.delete(tx, folderID)
+	})
+
+	if errors.Is(err, ErrFolderNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Delete error for folderID %s: %v", folderID, err)
+		return err
+	}
+
+	return nil
+}
+
+// updateFolder applies update to a folder the user owns and stamps UpdatedAt.
+func (r *boltFolderStore) updateFolder(folderID string, userID string, update func(folder *models.Folder)) (*models.Folder, error) {
+	var folder *models.Folder
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		folder, err = boltFolderTable.get(tx, folderID)
+		if err != nil {
+			return err
+		}
+		if folder == nil || folder.UserID != userID {
+			return ErrFolderNotFound
+		}
+
+		update(folder)
+		folder.UpdatedAt = time.Now().UTC()
+		return boltFolderTable.put(tx, folder)
+	})
+
+	if errors.Is(err, ErrFolderNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Update error for folderID %s: %v", folderID, err)
+		return nil, err
+	}
+
+	return folder, nil
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltGrantTable = boltTable[models.Grant]{
+	name: AccessGrantTable,
+	key:  func(g *models.Grant) string { return boltKey(g.ResourceID, g.GranteeID) },
+	indexes: []boltIndex[models.Grant]{
+		{name: "grantee", key: func(g *models.Grant) (string, bool) { return g.GranteeID, true }},
+	},
+}
+
+type boltGrantStore struct {
+	db *boltDB
+}
+
+// PutGrant creates a grant or changes the role of an existing one, keeping its
+// CreatedAt. It returns the previous grant, or nil if there was none.
+func (r *boltGrantStore) PutGrant(ctx context.Context, grant *models.Grant) (*models.Grant, error) {
+	var previous *models.Grant
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		previous, err = boltGrantTable.get(tx, boltKey(grant.ResourceID, grant.GranteeID))
+		if err != nil {
+			return err
+		}
+
+		stored := *grant
+		stored.CreatedAt = grant.UpdatedAt
+		if previous != nil {
+			stored.CreatedAt = previous.CreatedAt
+		}
+		return boltGrantTable.put(tx, &stored)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save grant on %s: %v", grant.ResourceID, err)
+		return nil, err
+	}
+
+	if previous == nil {
+		return nil, nil
+	}
+
+	grant.CreatedAt = previous.CreatedAt
+	return previous, nil
+}
+
+func (r *boltGrantStore) GetGrant(ctx context.Context, resourceID string, granteeID string) (*models.Grant, error) {
+	var grant *models.Grant
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		grant, err = boltGrantTable.get(tx, boltKey(resourceID, granteeID))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for grant on %s: %v", resourceID, err)
+		return nil, err
+	}
+
+	if grant == nil {
+		return nil, ErrGrantNotFound
+	}
+
+	return grant, nil
+}
+
+func (r *boltGrantStore) ListGrants(ctx context.Context, resourceID string) ([]models.Grant, error) {
+	var grants []models.Grant
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		grants, err = boltGrantTable.list(tx, boltKey(resourceID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list grants: %v", err)
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+// ListGrantsForGrantee returns everything shared with a user, newest first.
+func (r *boltGrantStore) ListGrantsForGrantee(ctx context.Context, granteeID string) ([]models.Grant, error) {
+	var grants []models.Grant
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		grants, err = boltGrantTable.listIndex(tx, "grantee", boltKey(granteeID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list grants: %v", err)
+		return nil, err
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].CreatedAt.After(grants[j].CreatedAt)
+	})
+
+	return grants, nil
+}
+
+// DeleteGrant removes a grant and returns it.
+func (r *boltGrantStore) DeleteGrant(ctx context.Context, resourceID string, granteeID string) (*models.Grant, error) {
+	var grant *models.Grant
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		grant, err = boltGrantTable.get(tx, boltKey(resourceID, granteeID))
+		if err != nil {
+			return err
+		}
+		if grant == nil {
+			return ErrGrantNotFound
+		}
+		return boltGrantTable.delete(tx, boltKey(resourceID, granteeID))
+	})
+
+	if errors.Is(err, ErrGrantNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Delete error for grant on %s: %v", resourceID, err)
+		return nil, err
+	}
+
+	return grant, nil
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+var boltNotificationTable = boltTable[models.Notification]{
+	name: NotificationTable,
+	key:  func(n *models.Notification) string { return boltKey(n.UserID, n.NotificationID) },
+}
+
+type boltNotificationStore struct {
+	db *boltDB
+}
+
+func (r *boltNotificationStore) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	notification.NotificationID = notification.CreatedAt.UTC().Format(notificationIDLayout) + "-" + uuid.New().String()
+
+	err := r.db.update(func(tx *boltTx) error {
+		return boltNotificationTable.put(tx, notification)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save notification for user %s: %v", notification.UserID, err)
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// ListNotifications returns up to limit notifications, newest first.
+func (r *boltNotificationStore) ListNotifications(ctx context.Context, userID string, limit int32, unreadOnly bool) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	err := r.db.view(func(tx *boltTx) error {
+		return boltNotificationTable.scanReverse(tx, boltKey(userID, ""), func(notification *models.Notification) error {
+			if int32(len(notifications)) >= limit {
+				return errBoltStop
+			}
+			if !unreadOnly || !notification.Read {
+				notifications = append(notifications, *notification)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to list notifications of user %s: %v", userID, err)
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *boltNotificationStore) MarkRead(ctx context.Context, userID string, notificationID string) error {
+	err := r.db.update(func(tx *boltTx) error {
+		notification, err := boltNotificationTable.get(tx, boltKey(userID, notificationID))
+		if err != nil {
+			return err
+		}
+		if notification == nil {
+			return ErrNotificationNotFound
+		}
+
+		notification.Read = true
+		return boltNotificationTable.put(tx, notification)
+	})
+
+	if errors.Is(err, ErrNotificationNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Update error for notification %s: %v", notificationID, err)
+		return err
+	}
+
+	return nil
+}
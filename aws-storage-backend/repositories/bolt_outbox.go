@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltOutboxTable = boltTable[models.OutboxEntry]{
+	name: OutboxTable,
+	key:  func(e *models.OutboxEntry) string { return e.OutboxID },
+}
+
+func (r *boltStorageStore) deleteOutboxEntry(ctx context.Context, outboxID string) error {
+	err := r.db.update(func(tx *boltTx) error {
+		return boltOutboxTable.delete(tx, outboxID)
+	})
+
+	if err != nil {
+		log.Printf("Failed to delete outbox entry %s: %v", outboxID, err)
+		return err
+	}
+
+	return nil
+}
+
+// moveOutboxEntry moves the lease of an entry from dueAt to next, provided
+// nobody moved it since dueAt was read. Whoever moves it holds it.
+func (r *boltStorageStore) moveOutboxEntry(ctx context.Context, outboxID string, dueAt int64, next int64) error {
+	err := r.db.update(func(tx *boltTx) error {
+		entry, err := boltOutboxTable.get(tx, outboxID)
+		if err != nil {
+			return err
+		}
+		if entry == nil || entry.DueAt != dueAt {
+			return errOutboxEntryTaken
+		}
+
+		entry.DueAt = next
+		return boltOutboxTable.put(tx, entry)
+	})
+
+	if errors.Is(err, errOutboxEntryTaken) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to move outbox entry %s: %v", outboxID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListDueOutboxEntries returns the entries whose request should have finished
+// by now.
+func (r *boltStorageStore) ListDueOutboxEntries(ctx context.Context, now time.Time) ([]models.OutboxEntry, error) {
+	entries := []models.OutboxEntry{}
+	err := r.db.view(func(tx *boltTx) error {
+		return boltOutboxTable.scan(tx, "", func(entry *models.OutboxEntry) error {
+			if entry.DueAt <= now.Unix() {
+				entries = append(entries, *entry)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to scan outbox: %v", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// RescheduleOutboxEntry pushes a failed entry back by outboxBackoff.
+func (r *boltStorageStore) RescheduleOutboxEntry(ctx context.Context, entry *models.OutboxEntry, cause error) error {
+	dueAt := time.Now().Add(outboxBackoff(entry.Attempts)).Unix()
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltOutboxTable.get(tx, entry.OutboxID)
+		if err != nil || current == nil {
+			return err
+		}
+
+		current.DueAt = dueAt
+		current.LastError = cause.Error()
+		current.Attempts++
+		return boltOutboxTable.put(tx, current)
+	})
+
+	if err != nil {
+		log.Printf("Failed to reschedule outbox entry %s: %v", entry.OutboxID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ResumeOutboxEntry takes an upload, replacement or permanent delete from
+// wherever its request left it to the end, like
+// StorageRepository.ResumeOutboxEntry.
+func (r *boltStorageStore) ResumeOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (string, error) {
+	err := r.moveOutboxEntry(ctx, entry.OutboxID, entry.DueAt, time.Now().Add(outboxLease).Unix())
+	if errors.Is(err, errOutboxEntryTaken) {
+		return models.OutboxHeld, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	storageObj, err := r.GetFileByID(ctx, entry.ObjectID)
+	if err != nil && !errors.Is(err, ErrFileNotFound) {
+		return "", err
+	}
+
+	switch entry.Kind {
+	case models.OutboxUpload:
+		return r.resumeUpload(ctx, entry, storageObj)
+	case models.OutboxPurge:
+		return r.resumePurge(ctx, entry, storageObj)
+	case models.OutboxReplace:
+		return r.resumeReplace(ctx, entry, storageObj)
+	default:
+		log.Printf("Dropping outbox entry %s of unknown kind %q", entry.OutboxID, entry.Kind)
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+}
+
+// resumeUpload finalizes the upload if its bytes made it to the blob store
+// and rolls it back otherwise.
+func (r *boltStorageStore) resumeUpload(ctx context.Context, entry *models.OutboxEntry, storageObj *models.StorageObject) (string, error) {
+	if storageObj == nil || storageObj.Status != models.StatusUploading {
+		if entry.BlobKey != "" {
+			if err := r.deleteBlobIfUnused(ctx, entry.BlobKey); err != nil {
+				return "", err
+			}
+		}
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+
+	blob, err := r.getBlob(storageObj.BlobKey)
+	if err != nil {
+		return "", err
+	}
+
+	if blob != nil && blob.VersionID != "" {
+		if _, err := r.finishUpload(storageObj, blob, entry.OutboxID); err != nil {
+			return "", err
+		}
+		return models.OutboxFinalized, nil
+	}
+
+	if err := r.rollbackUpload(ctx, storageObj, entry.OutboxID); err != nil {
+		return "", err
+	}
+	return models.OutboxRolledBack, nil
+}
+
+// resumeReplace rolls a replacement back, keeping the content the file
+// points at.
+func (r *boltStorageStore) resumeReplace(ctx context.Context, entry *models.OutboxEntry, storageObj *models.StorageObject) (string, error) {
+	if storageObj == nil || storageObj.ReplaceID != entry.OutboxID {
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+
+	if err := r.rollbackReplace(ctx, storageObj, entry, ""); err != nil {
+		if errors.Is(err, ErrUploadRolledBack) {
+			return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+		}
+		return "", err
+	}
+	return models.OutboxRolledBack, nil
+}
+
+// resumePurge finishes a permanent delete. A missing record means the request
+// got as far as deleting it, but not as far as cleaning up after it.
+func (r *boltStorageStore) resumePurge(ctx context.Context, entry *models.OutboxEntry, storageObj *models.StorageObject) (string, error) {
+	switch {
+	case storageObj == nil:
+		if entry.BlobKey != "" {
+			if err := r.deleteBlobIfUnused(ctx, entry.BlobKey); err != nil {
+				return "", err
+			}
+		}
+		if err := r.deleteOutboxEntry(ctx, entry.OutboxID); err != nil {
+			return "", err
+		}
+		return models.OutboxFinalized, nil
+	case storageObj.Status == models.StatusDeleting:
+		if err := r.finishPurge(ctx, storageObj, entry.OutboxID); err != nil {
+			return "", err
+		}
+		return models.OutboxFinalized, nil
+	default:
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+}
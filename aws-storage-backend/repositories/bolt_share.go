@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltShareTable = boltTable[models.ShareLink]{
+	name: ShareLinkTable,
+	key:  func(l *models.ShareLink) string { return l.Token },
+	indexes: []boltIndex[models.ShareLink]{
+		{name: "object", key: func(l *models.ShareLink) (string, bool) { return l.ObjectID, true }},
+	},
+	transient: func(l *models.ShareLink) { l.HasPassword = false },
+}
+
+type boltShareStore struct {
+	db *boltDB
+}
+
+func (r *boltShareStore) CreateShareLink(ctx context.Context, link *models.ShareLink) (*models.ShareLink, error) {
+	err := r.db.update(func(tx *boltTx) error {
+		return boltShareTable.insert(tx, link)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save share link for file %s: %v", link.ObjectID, err)
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (r *boltShareStore) GetShareLink(ctx context.Context, token string) (*models.ShareLink, error) {
+	var link *models.ShareLink
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		link, err = boltShareTable.get(tx, token)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for share link: %v", err)
+		return nil, err
+	}
+
+	if link == nil {
+		return nil, ErrShareLinkNotFound
+	}
+
+	return link, nil
+}
+
+func (r *boltShareStore) ListShareLinks(ctx context.Context, objectID string) ([]models.ShareLink, error) {
+	var links []models.ShareLink
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		links, err = boltShareTable.listIndex(tx, "object", boltKey(objectID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list share links of file %s: %v", objectID, err)
+		return nil, err
+	}
+
+	return links, nil
+}
+
+func (r *boltShareStore) RevokeShareLink(ctx context.Context, token string, userID string) (*models.ShareLink, error) {
+	return r.updateShareLink(token, ErrShareLinkNotFound, func(link *models.ShareLink) bool {
+		if link.UserID != userID {
+			return false
+		}
+		if link.RevokedAt == nil {
+			now := time.Now().UTC()
+			link.RevokedAt = &now
+		}
+		return true
+	})
+}
+
+// ConsumeDownload counts one download against the link. The limits are checked
+// in the same transaction, so concurrent downloads cannot go past
+// MaxDownloads.
+func (r *boltShareStore) ConsumeDownload(ctx context.Context, token string, now time.Time) (*models.ShareLink, error) {
+	return r.updateShareLink(token, ErrShareLinkUnavailable, func(link *models.ShareLink) bool {
+		if link.RevokedAt != nil ||
+			(link.ExpiresAt != 0 && link.ExpiresAt <= now.Unix()) ||
+			(link.MaxDownloads != 0 && link.DownloadCount >= link.MaxDownloads) {
+			return false
+		}
+		link.DownloadCount++
+		return true
+	})
+}
+
+// updateShareLink applies update to a link and returns the link it stored, or
+// rejected if the link is missing or update turns it down.
+func (r *boltShareStore) updateShareLink(token string, rejected error, update func(link *models.ShareLink) bool) (*models.ShareLink, error) {
+	var link *models.ShareLink
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		link, err = boltShareTable.get(tx, token)
+		if err != nil {
+			return err
+		}
+		if link == nil || !update(link) {
+			return rejected
+		}
+		return boltShareTable.put(tx, link)
+	})
+
+	if errors.Is(err, rejected) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Update error for share link: %v", err)
+		return nil, err
+	}
+
+	return link, nil
+}
@@ -0,0 +1,905 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltFileTable = boltTable[models.StorageObject]{
+	name: StorageTable,
+	key:  func(o *models.StorageObject) string { return o.ObjectID },
+	indexes: []boltIndex[models.StorageObject]{
+		{name: "user", key: func(o *models.StorageObject) (string, bool) {
+			return o.UserID, true
+		}},
+		// Like UserIDParentIDIndex, files without a ParentID are left out.
+		{name: "parent", key: func(o *models.StorageObject) (string, bool) {
+			return boltKey(o.UserID, o.ParentID), o.ParentID != ""
+		}},
+		{name: "hash", key: func(o *models.StorageObject) (string, bool) {
+			return boltKey(o.UserID, o.ContentHash), o.ContentHash != ""
+		}},
+		{name: "expiry", key: func(o *models.StorageObject) (string, bool) {
+			return boltKey(o.Status, boltSortableInt(o.ExpiresAt)), o.Status != "" && o.ExpiresAt > 0
+		}},
+	},
+	transient: func(o *models.StorageObject) { o.PreviewURL = "" },
+}
+
+// boltStorageStore is StorageRepository on the bolt file. The steps of every
+// change that involve the blob store run outside bolt transactions, in the
+// same order and with the same outbox entries, so a change cut short is
+// finished or rolled back the same way.
+type boltStorageStore struct {
+	fileBlobs
+	db *boltDB
+}
+
+// boltStatusMatches is statusFilter on a stored file.
+func boltStatusMatches(file *models.StorageObject, status string) bool {
+	if status == models.StatusActive {
+		return file.IsActive()
+	}
+	return file.Status == status
+}
+
+// boltVersionMatches is versionCondition on a stored file, which is nil if
+// there is none.
+func boltVersionMatches(file *models.StorageObject, userID string, expectedVersion int64) bool {
+	return file != nil && file.UserID == userID && file.Version == expectedVersion
+}
+
+// boltVersionConflict is versionConflictError for the file found instead.
+func boltVersionConflict(file *models.StorageObject, userID string) error {
+	switch {
+	case file == nil:
+		return ErrFileNotFound
+	case file.UserID != userID:
+		return ErrFileAccessDenied
+	default:
+		return ErrVersionConflict
+	}
+}
+
+func (r *boltStorageStore) GetFile(ctx context.Context, fileID string, userID string) (*models.StorageObject, error) {
+	storageObj, err := r.GetFileByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if storageObj.UserID != userID {
+		return nil, ErrFileAccessDenied
+	}
+
+	return storageObj, nil
+}
+
+// GetFileByID loads a file without checking who owns it. Callers must check
+// access themselves.
+func (r *boltStorageStore) GetFileByID(ctx context.Context, fileID string) (*models.StorageObject, error) {
+	var storageObj *models.StorageObject
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		storageObj, err = boltFileTable.get(tx, fileID)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for fileID %s: %v", fileID, err)
+		return nil, err
+	}
+
+	if storageObj == nil {
+		return nil, ErrFileNotFound
+	}
+
+	return storageObj, nil
+}
+
+// userFiles returns the files of a user that keep accepts.
+func (r *boltStorageStore) userFiles(userID string, keep func(file *models.StorageObject) bool) ([]models.StorageObject, error) {
+	files := []models.StorageObject{}
+	err := r.db.view(func(tx *boltTx) error {
+		return boltFileTable.scanIndex(tx, "user", boltKey(userID, ""), func(file *models.StorageObject) error {
+			if keep(file) {
+				files = append(files, *file)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to list files of user %s: %v", userID, err)
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// ListFiles pages through the user's files in the given status. The user's
+// files are read and sorted in full, which the bolt store is small enough
+// for; the next token holds the sort value and ObjectID of the last file
+// returned.
+func (r *boltStorageStore) ListFiles(ctx context.Context, userID string, query models.ListFilesQuery, status string) (*models.ListStorageObjectsResponse, error) {
+	indexName, rangeKey := listIndexFor(query.SortBy)
+
+	token, err := decodePageToken(indexName, query.SortOrder, query.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var start *models.StorageObject
+	if token != nil {
+		if start, err = boltPageStart(token, rangeKey); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := r.userFiles(userID, func(file *models.StorageObject) bool {
+		return boltListMatches(file, query, status)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ascending := query.SortOrder == models.SortOrderAsc
+	after := func(file *models.StorageObject, other *models.StorageObject) bool {
+		order := compareListed(file, other, rangeKey)
+		if ascending {
+			return order > 0
+		}
+		return order < 0
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return after(&files[j], &files[i])
+	})
+	if start != nil {
+		files = files[sort.Search(len(files), func(i int) bool {
+			return after(&files[i], start)
+		}):]
+	}
+
+	var nextToken *string
+	if int32(len(files)) > query.Limit {
+		files = files[:query.Limit]
+		nextToken, err = boltPageToken(indexName, query.SortOrder, &files[len(files)-1], rangeKey).encode()
+		if err != nil {
+			log.Printf("failed to encode next token: %v", err)
+			return nil, err
+		}
+	}
+
+	return listFilesResponse(files, nextToken), nil
+}
+
+// boltListMatches applies the status and filters of a listing to a file.
+func boltListMatches(file *models.StorageObject, query models.ListFilesQuery, status string) bool {
+	switch {
+	case !boltStatusMatches(file, status),
+		query.UploadedAfter != nil && file.UploadedAt.Before(*query.UploadedAfter),
+		query.UploadedBefore != nil && file.UploadedAt.After(*query.UploadedBefore),
+		query.MinSize != nil && file.FileSize < *query.MinSize,
+		query.MaxSize != nil && file.FileSize > *query.MaxSize,
+		query.ContentType != "" && file.ContentType != query.ContentType:
+		return false
+	}
+
+	// Files uploaded before folders existed have no ParentID and sit in the root.
+	switch query.FolderID {
+	case "":
+		return true
+	case models.RootFolderID:
+		return file.ParentID == "" || file.ParentID == models.RootFolderID
+	default:
+		return file.ParentID == query.FolderID
+	}
+}
+
+// compareListed orders files by the sort attribute of a listing, then by
+// ObjectID so files with the same value keep their place between pages.
+func compareListed(file *models.StorageObject, other *models.StorageObject, rangeKey string) int {
+	var order int
+	switch rangeKey {
+	case "FileName":
+		order = strings.Compare(file.FileName, other.FileName)
+	case "FileSize":
+		order = cmpInt64(file.FileSize, other.FileSize)
+	default:
+		order = file.UploadedAt.Compare(other.UploadedAt)
+	}
+
+	if order != 0 {
+		return order
+	}
+	return strings.Compare(file.ObjectID, other.ObjectID)
+}
+
+func cmpInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// boltPageToken records where a page of a listing ended.
+func boltPageToken(indexName string, sortOrder string, last *models.StorageObject, rangeKey string) *pageToken {
+	token := &pageToken{
+		Index:   indexName,
+		Order:   sortOrder,
+		Strings: map[string]string{"ObjectID": last.ObjectID},
+	}
+
+	switch rangeKey {
+	case "FileName":
+		token.Strings[rangeKey] = last.FileName
+	case "FileSize":
+		token.Numbers = map[string]string{rangeKey: strconv.FormatInt(last.FileSize, 10)}
+	default:
+		token.Strings[rangeKey] = last.UploadedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return token
+}
+
+// boltPageStart reads back the file a page token was made from, as far as
+// compareListed needs it.
+func boltPageStart(token *pageToken, rangeKey string) (*models.StorageObject, error) {
+	start := &models.StorageObject{ObjectID: token.Strings["ObjectID"]}
+	if start.ObjectID == "" {
+		return nil, ErrInvalidNextToken
+	}
+
+	var err error
+	switch rangeKey {
+	case "FileName":
+		start.FileName = token.Strings[rangeKey]
+	case "FileSize":
+		start.FileSize, err = strconv.ParseInt(token.Numbers[rangeKey], 10, 64)
+	default:
+		start.UploadedAt, err = time.Parse(time.RFC3339Nano, token.Strings[rangeKey])
+	}
+	if err != nil {
+		return nil, ErrInvalidNextToken
+	}
+
+	return start, nil
+}
+
+// ListChildFiles returns every object directly inside a folder, whatever its
+// status.
+func (r *boltStorageStore) ListChildFiles(ctx context.Context, userID string, parentID string) ([]models.StorageObject, error) {
+	files := []models.StorageObject{}
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		files, err = boltFileTable.listIndex(tx, "parent", boltKey(userID, parentID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list files in folder %s: %v", parentID, err)
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// ListFilesByStatus returns all of a user's files in one status, unpaginated.
+func (r *boltStorageStore) ListFilesByStatus(ctx context.Context, userID string, status string) ([]models.StorageObject, error) {
+	return r.userFiles(userID, func(file *models.StorageObject) bool {
+		return boltStatusMatches(file, status)
+	})
+}
+
+// ListFilesByRequest returns the active files guests uploaded through one
+// file request.
+func (r *boltStorageStore) ListFilesByRequest(ctx context.Context, userID string, requestID string) ([]models.StorageObject, error) {
+	return r.userFiles(userID, func(file *models.StorageObject) bool {
+		return file.FileRequestID == requestID && file.IsActive()
+	})
+}
+
+// ListAllUserFiles returns every file record of a user, whatever its status.
+func (r *boltStorageStore) ListAllUserFiles(ctx context.Context, userID string) ([]models.StorageObject, error) {
+	return r.userFiles(userID, func(file *models.StorageObject) bool {
+		return true
+	})
+}
+
+func (r *boltStorageStore) UploadFile(ctx context.Context, userID string, parentID string, fileName string, fileSize int64, contentType string, fileData io.ReadSeeker, description *string) (*models.StorageObject, error) {
+	return r.putFile(ctx, &models.StorageObject{
+		UserID:      userID,
+		ParentID:    parentID,
+		FileName:    fileName,
+		FileSize:    fileSize,
+		ContentType: contentType,
+		Description: description,
+	}, fileData)
+}
+
+// UploadRequestedFile stores a file that a guest sent through a file request.
+// It belongs to the owner of the request.
+func (r *boltStorageStore) UploadRequestedFile(ctx context.Context, request *models.FileRequest, parentID string, fileName string, fileSize int64, contentType string, fileData io.ReadSeeker, uploader *models.Uploader) (*models.StorageObject, error) {
+	return r.putFile(ctx, &models.StorageObject{
+		UserID:        request.UserID,
+		ParentID:      parentID,
+		FileName:      fileName,
+		FileSize:      fileSize,
+		ContentType:   contentType,
+		FileRequestID: request.RequestID,
+		Uploader:      uploader,
+	}, fileData)
+}
+
+// putFile saves a new file the way StorageRepository.putFile does.
+func (r *boltStorageStore) putFile(ctx context.Context, storageObj *models.StorageObject, fileData io.Reader) (*models.StorageObject, error) {
+	pending, err := r.createPendingFile(ctx, storageObj, time.Now().Add(serverUploadExpiry))
+	if err != nil {
+		return nil, err
+	}
+
+	body := newDigestingReader(fileData)
+	stored, err := r.blobStore.Put(ctx, pending.S3Key, body, blobstore.PutOptions{
+		ContentType: pending.ContentType,
+		Size:        pending.FileSize,
+	})
+
+	var activated *models.StorageObject
+	if err != nil {
+		log.Printf("Failed to upload file %s: %v", pending.ObjectID, err)
+	} else {
+		digest, size := body.digest()
+		switch {
+		case size != pending.FileSize:
+			err = ErrUploadMismatch
+		case stored.ChecksumSHA256 != "" && stored.ChecksumSHA256 != digest.sha256:
+			err = blobstore.ErrChecksumMismatch
+		default:
+			activated, err = r.activateUpload(ctx, pending, stored.VersionID, digest)
+		}
+	}
+
+	if errors.Is(err, ErrOutboxPending) || errors.Is(err, ErrUploadRolledBack) {
+		return nil, err
+	}
+	if err != nil {
+		if deleteErr := r.DeletePendingFile(ctx, pending); deleteErr != nil {
+			log.Printf("Failed to remove failed upload %s, leaving it to the upload sweeper: %v", pending.ObjectID, deleteErr)
+			return nil, ErrOutboxPending
+		}
+		return nil, err
+	}
+
+	return activated, nil
+}
+
+// MoveFile puts a file into parentID and moves it between the usage totals of
+// the two folders, if the file is still where and what storageObj says and
+// its content is not being replaced.
+func (r *boltStorageStore) MoveFile(ctx context.Context, storageObj *models.StorageObject, parentID string) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	updated := *storageObj
+	updated.ParentID = parentID
+	updated.UpdatedAt = now
+
+	trashed := storageObj.Status == models.StatusTrashed
+	changes := usageChanges{}
+	changes.add(storageObj, -1, trashed)
+	changes.add(&updated, 1, trashed)
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+
+		// Files uploaded before folders existed have no ParentID and sit in
+		// the root.
+		sameParent := current != nil && current.ParentID == storageObj.ParentID
+		if storageObj.ParentID == "" || storageObj.ParentID == models.RootFolderID {
+			sameParent = current != nil && (current.ParentID == "" || current.ParentID == models.RootFolderID)
+		}
+
+		if !sameParent || current.UserID != storageObj.UserID || current.FileSize != storageObj.FileSize ||
+			current.ContentType != storageObj.ContentType || current.Status != storageObj.Status || current.IsReplacing() {
+			return ErrVersionConflict
+		}
+
+		current.ParentID = parentID
+		current.UpdatedAt = now
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if errors.Is(err, ErrVersionConflict) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Update error for fileID %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (r *boltStorageStore) UpdateFileMetadata(ctx context.Context, fileID string, userID string, fileName *string, description *string, expectedVersion int64) (*models.StorageObject, error) {
+	return r.updateFileVersioned(fileID, userID, expectedVersion, func(file *models.StorageObject) {
+		file.UpdatedAt = time.Now().UTC()
+
+		if fileName != nil {
+			file.FileName = *fileName
+		}
+
+		if description != nil {
+			if *description == "" {
+				file.Description = nil
+			} else {
+				value := *description
+				file.Description = &value
+			}
+		}
+	})
+}
+
+// updateFileVersioned applies update only if the caller owns the file, it is
+// active, not quarantined nor having its content replaced, and still at
+// expectedVersion, then bumps the version.
+func (r *boltStorageStore) updateFileVersioned(fileID string, userID string, expectedVersion int64, update func(file *models.StorageObject)) (*models.StorageObject, error) {
+	var updated *models.StorageObject
+	var conflict error
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, fileID)
+		if err != nil {
+			return err
+		}
+
+		if !boltVersionMatches(current, userID, expectedVersion) || !current.IsActive() || current.IsQuarantined() || current.IsReplacing() {
+			conflict = boltVersionConflict(current, userID)
+			return conflict
+		}
+
+		update(current)
+		current.Version = expectedVersion + 1
+		updated = current
+		return boltFileTable.put(tx, current)
+	})
+
+	if conflict != nil {
+		return nil, conflict
+	}
+	if err != nil {
+		log.Printf("Update error for fileID %s: %v", fileID, err)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// ReplaceFileContent overwrites the bytes behind an existing ObjectID, like
+// StorageRepository.ReplaceFileContent.
+func (r *boltStorageStore) ReplaceFileContent(ctx context.Context, storageObj *models.StorageObject, contentType string, fileSize int64, fileData io.ReadSeeker) (*models.StorageObject, error) {
+	if storageObj.SharesBlob() {
+		return nil, ErrVersionConflict
+	}
+
+	return r.replaceContent(ctx, storageObj, fileSize-storageObj.FileSize, func() (*storedContent, error) {
+		body := newDigestingReader(fileData)
+		stored, err := r.blobStore.Put(ctx, storageObj.S3Key, body, blobstore.PutOptions{
+			ContentType: contentType,
+			Size:        fileSize,
+			IfMatch:     storageObj.ETag,
+		})
+		if errors.Is(err, blobstore.ErrPreconditionFailed) {
+			return nil, ErrVersionConflict
+		}
+		if err != nil {
+			log.Printf("Failed to replace file content: %v", err)
+			return nil, err
+		}
+
+		digest, size := body.digest()
+		content := &storedContent{
+			fileSize:       fileSize,
+			contentType:    contentType,
+			etag:           stored.ETag,
+			versionID:      stored.VersionID,
+			checksumSHA256: digest.sha256,
+			checksumCRC32C: digest.crc32c,
+		}
+		if size != fileSize || (stored.ChecksumSHA256 != "" && stored.ChecksumSHA256 != digest.sha256) {
+			return content, ErrUploadMismatch
+		}
+		return content, nil
+	})
+}
+
+// replaceContent gives an active file new content in the three steps of
+// StorageRepository.replaceContent: claim, write, finish.
+func (r *boltStorageStore) replaceContent(ctx context.Context, storageObj *models.StorageObject, reserved int64, write func() (*storedContent, error)) (*models.StorageObject, error) {
+	claimed, entry, err := r.claimContent(storageObj, max(reserved, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	release := holdOutboxEntry(ctx, entry, r.moveOutboxEntry)
+	content, err := write()
+	release()
+
+	var updated *models.StorageObject
+	if err == nil {
+		updated, err = r.finishReplace(claimed, *content)
+	}
+
+	if err == nil {
+		return updated, nil
+	}
+
+	written := ""
+	if content != nil {
+		written = content.versionID
+	}
+
+	if errors.Is(err, ErrUploadRolledBack) {
+		// The worker gave the claim up, possibly before the bytes arrived,
+		// so it cannot have deleted them.
+		if written != "" {
+			if err := r.blobStore.Delete(ctx, storageObj.S3Key, written); err != nil {
+				log.Printf("Failed to delete rolled back content of file %s: %v", storageObj.ObjectID, err)
+			}
+		}
+		return nil, ErrUploadRolledBack
+	}
+
+	if rollbackErr := r.rollbackReplace(ctx, claimed, entry, written); rollbackErr != nil {
+		log.Printf("Failed to roll back replacement of file %s, leaving it to the outbox: %v", storageObj.ObjectID, rollbackErr)
+		return nil, ErrOutboxPending
+	}
+	return nil, err
+}
+
+// claimContent moves an active file that is still at the version storageObj
+// says to its next version and marks it as being replaced, writing the outbox
+// entry that rolls the replacement back if its request does not finish it.
+func (r *boltStorageStore) claimContent(storageObj *models.StorageObject, reserved int64) (*models.StorageObject, *models.OutboxEntry, error) {
+	entry := newOutboxEntry(models.OutboxReplace, storageObj)
+	entry.FileSize = reserved
+	entry.ETag = storageObj.ETag
+	entry.VersionID = storageObj.VersionID
+
+	var conflict error
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+
+		if !boltVersionMatches(current, storageObj.UserID, storageObj.Version) || !current.IsActive() || current.IsReplacing() {
+			conflict = boltVersionConflict(current, storageObj.UserID)
+			return conflict
+		}
+
+		current.ReplaceID = entry.OutboxID
+		current.Version = storageObj.Version + 1
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		return boltOutboxTable.put(tx, entry)
+	})
+
+	if conflict != nil {
+		return nil, nil, conflict
+	}
+	if err != nil {
+		log.Printf("Failed to claim file %s for new content: %v", storageObj.ObjectID, err)
+		return nil, nil, err
+	}
+
+	claimed := *storageObj
+	claimed.Version = storageObj.Version + 1
+	claimed.ReplaceID = entry.OutboxID
+	return &claimed, entry, nil
+}
+
+// finishReplace points a claimed file at its new content, moves it in the
+// usage totals and removes the outbox entry. It returns ErrUploadRolledBack if
+// the claim is gone.
+func (r *boltStorageStore) finishReplace(storageObj *models.StorageObject, content storedContent) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	updated := *storageObj
+	updated.UpdatedAt = now
+	updated.FileSize = content.fileSize
+	updated.ContentType = content.contentType
+	updated.ETag = content.etag
+	updated.VersionID = content.versionID
+	updated.ChecksumSHA256 = content.checksumSHA256
+	updated.ChecksumCRC32C = content.checksumCRC32C
+	updated.ContentHash = contentHashOf(content.checksumSHA256)
+	updated.QuarantinedAt = nil
+	updated.QuarantineReason = ""
+	updated.ReplaceID = ""
+
+	changes := usageChanges{}
+	changes.add(storageObj, -1, false)
+	changes.add(&updated, 1, false)
+	changes.addActivity(now, map[string]int64{"NetBytes": content.fileSize - storageObj.FileSize})
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.ReplaceID != storageObj.ReplaceID {
+			return ErrUploadRolledBack
+		}
+
+		current.UpdatedAt = updated.UpdatedAt
+		current.FileSize = updated.FileSize
+		current.ContentType = updated.ContentType
+		current.ETag = updated.ETag
+		current.VersionID = updated.VersionID
+		current.ChecksumSHA256 = updated.ChecksumSHA256
+		current.ChecksumCRC32C = updated.ChecksumCRC32C
+		current.ContentHash = updated.ContentHash
+		current.QuarantinedAt = nil
+		current.QuarantineReason = ""
+		current.ReplaceID = ""
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		if err := boltOutboxTable.delete(tx, storageObj.ReplaceID); err != nil {
+			return err
+		}
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if errors.Is(err, ErrUploadRolledBack) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Failed to point file %s at its new content: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// rollbackReplace deletes the version a replacement wrote, or the one current
+// in place of what it started from, then gives the claim up and removes the
+// outbox entry, like StorageRepository.rollbackReplace.
+func (r *boltStorageStore) rollbackReplace(ctx context.Context, storageObj *models.StorageObject, entry *models.OutboxEntry, written string) error {
+	if written == "" {
+		current, err := r.blobStore.Head(ctx, storageObj.S3Key, "")
+		if err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+			log.Printf("Failed to look up content of file %s: %v", storageObj.ObjectID, err)
+			return err
+		}
+		if err == nil && replacedBy(entry, current) {
+			written = current.VersionID
+		}
+	}
+
+	if written != "" {
+		if err := r.blobStore.Delete(ctx, storageObj.S3Key, written); err != nil {
+			log.Printf("Failed to delete replaced content of file %s: %v", storageObj.ObjectID, err)
+			return err
+		}
+	}
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.ReplaceID != entry.OutboxID {
+			return ErrUploadRolledBack
+		}
+
+		current.ReplaceID = ""
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		return boltOutboxTable.delete(tx, entry.OutboxID)
+	})
+
+	if errors.Is(err, ErrUploadRolledBack) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to give up the claim on file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
+
+// TrashFile moves an active file to the trash until purgeAt.
+func (r *boltStorageStore) TrashFile(ctx context.Context, storageObj *models.StorageObject, purgeAt time.Time) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	updated := *storageObj
+	updated.Status = models.StatusTrashed
+	updated.DeletedAt = &now
+	updated.ExpiresAt = purgeAt.Unix()
+
+	changes := usageChanges{}
+	changes.addActivity(now, map[string]int64{
+		"Deletions":    1,
+		"DeletedBytes": storageObj.FileSize,
+		"NetBytes":     -storageObj.FileSize,
+	})
+
+	return r.changeFileStatus(storageObj, &updated, models.StatusActive, changes, func(file *models.StorageObject) {
+		file.Status = models.StatusTrashed
+		file.DeletedAt = &now
+		file.ExpiresAt = purgeAt.Unix()
+	})
+}
+
+// RestoreFile brings a trashed file back into parentID.
+func (r *boltStorageStore) RestoreFile(ctx context.Context, storageObj *models.StorageObject, parentID string) (*models.StorageObject, error) {
+	updated := *storageObj
+	updated.Status = models.StatusActive
+	updated.ParentID = parentID
+	updated.DeletedAt = nil
+	updated.ExpiresAt = 0
+
+	changes := usageChanges{}
+	changes.addActivity(time.Now(), map[string]int64{"NetBytes": storageObj.FileSize})
+
+	return r.changeFileStatus(storageObj, &updated, models.StatusTrashed, changes, func(file *models.StorageObject) {
+		file.Status = models.StatusActive
+		file.ParentID = parentID
+		file.DeletedAt = nil
+		file.ExpiresAt = 0
+	})
+}
+
+// changeFileStatus moves a file from fromStatus to the status of updated with
+// update, and its bytes between the active and trashed usage totals, as long
+// as its size and type are still what storageObj says.
+func (r *boltStorageStore) changeFileStatus(storageObj *models.StorageObject, updated *models.StorageObject, fromStatus string, changes usageChanges, update func(file *models.StorageObject)) (*models.StorageObject, error) {
+	changes.add(storageObj, -1, fromStatus == models.StatusTrashed)
+	changes.add(updated, 1, updated.Status == models.StatusTrashed)
+
+	var conflict error
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case current == nil || current.UserID != storageObj.UserID || !boltStatusMatches(current, fromStatus):
+			conflict = ErrFileNotFound
+		case current.FileSize != storageObj.FileSize || current.ContentType != storageObj.ContentType || current.IsReplacing():
+			conflict = ErrVersionConflict
+		}
+		if conflict != nil {
+			return conflict
+		}
+
+		update(current)
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if conflict != nil {
+		return nil, conflict
+	}
+	if err != nil {
+		log.Printf("Update error for fileID %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// PurgeFile permanently removes a trashed file the way
+// StorageRepository.PurgeFile does: it is marked deleting with an outbox
+// entry first, and the outbox worker finishes the delete if it cannot be
+// finished right away.
+func (r *boltStorageStore) PurgeFile(ctx context.Context, storageObj *models.StorageObject) error {
+	entry := newOutboxEntry(models.OutboxPurge, storageObj)
+
+	changes := usageChanges{}
+	changes.add(storageObj, -1, true)
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Status != models.StatusTrashed {
+			return ErrFileNotFound
+		}
+
+		current.Status = models.StatusDeleting
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		if err := boltOutboxTable.put(tx, entry); err != nil {
+			return err
+		}
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to mark file %s for deletion: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	deleting := *storageObj
+	deleting.Status = models.StatusDeleting
+
+	if err := r.finishPurge(ctx, &deleting, entry.OutboxID); err != nil {
+		log.Printf("Failed to purge file %s, leaving it to the outbox: %v", storageObj.ObjectID, err)
+		return ErrOutboxPending
+	}
+
+	return nil
+}
+
+// finishPurge deletes the S3 side of a file marked deleting, then its record
+// and outbox entry. Every step can be repeated.
+func (r *boltStorageStore) finishPurge(ctx context.Context, storageObj *models.StorageObject, outboxID string) error {
+	if !storageObj.SharesBlob() {
+		if err := r.deleteAllVersions(ctx, storageObj); err != nil {
+			return err
+		}
+	}
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+
+		// A record in another state means an earlier attempt already
+		// deleted it and dropped its blob reference.
+		if current == nil || current.Status != models.StatusDeleting {
+			return nil
+		}
+
+		if storageObj.SharesBlob() {
+			if err := releaseBoltBlob(tx, storageObj.BlobKey); err != nil {
+				return err
+			}
+		}
+		return boltFileTable.delete(tx, storageObj.ObjectID)
+	})
+
+	if err != nil {
+		log.Printf("Delete error for fileID : %s reason :%v", storageObj.ObjectID, err)
+		return err
+	}
+
+	if storageObj.SharesBlob() {
+		if err := r.deleteBlobIfUnused(ctx, storageObj.BlobKey); err != nil {
+			return err
+		}
+	}
+
+	return r.deleteOutboxEntry(ctx, outboxID)
+}
@@ -0,0 +1,800 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+var boltBlobTable = boltTable[models.Blob]{
+	name: BlobTable,
+	key:  func(b *models.Blob) string { return b.BlobKey },
+}
+
+var boltMultipartTable = boltTable[models.MultipartUpload]{
+	name: MultipartUploadTable,
+	key:  func(u *models.MultipartUpload) string { return u.ObjectID },
+}
+
+func (r *boltStorageStore) CreatePendingFile(ctx context.Context, userID string, parentID string, fileName string, fileSize int64, contentType string, description *string, expiresAt time.Time) (*models.StorageObject, error) {
+	return r.createPendingFile(ctx, &models.StorageObject{
+		UserID:      userID,
+		ParentID:    parentID,
+		FileName:    fileName,
+		FileSize:    fileSize,
+		ContentType: contentType,
+		Description: description,
+	}, expiresAt)
+}
+
+// createPendingFile gives a new file its ID and records it as pending until
+// expiresAt, with its bytes to be uploaded under users/<id>/<objectID>.
+func (r *boltStorageStore) createPendingFile(ctx context.Context, storageObj *models.StorageObject, expiresAt time.Time) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	storageObj.ObjectID = uuid.New().String()
+	storageObj.S3Key = fmt.Sprintf("users/%s/%s", storageObj.UserID, storageObj.ObjectID)
+	storageObj.S3Bucket = r.bucketName
+	storageObj.UploadedAt = now
+	storageObj.UpdatedAt = now
+	storageObj.Status = models.StatusPending
+	storageObj.ExpiresAt = expiresAt.Unix()
+	storageObj.Version = 1
+
+	err := r.db.update(func(tx *boltTx) error {
+		return boltFileTable.insert(tx, storageObj)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save pending upload: %v", err)
+		return nil, err
+	}
+
+	return storageObj, nil
+}
+
+// ActivateFile checks the object of a multipart upload against the pending
+// record and flips the record to active, leaving the object where it is.
+func (r *boltStorageStore) ActivateFile(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error) {
+	head, err := r.blobStore.Head(ctx, storageObj.S3Key, "")
+	if err != nil {
+		log.Printf("Head error for pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, ErrUploadMismatch
+	}
+
+	if head.Size != storageObj.FileSize || head.ContentType != storageObj.ContentType {
+		return nil, ErrUploadMismatch
+	}
+
+	now := time.Now().UTC()
+
+	activated := *storageObj
+	activated.Status = models.StatusActive
+	activated.ETag = head.ETag
+	activated.VersionID = head.VersionID
+	activated.UpdatedAt = now
+	activated.ExpiresAt = 0
+	activated.ChecksumSHA256 = head.ChecksumSHA256
+	activated.ChecksumCRC32C = head.ChecksumCRC32C
+	activated.ContentHash = contentHashOf(activated.ChecksumSHA256)
+
+	changes := usageChanges{}
+	changes.add(&activated, 1, false)
+	changes.addActivity(now, uploadActivity(&activated))
+
+	err = r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.UserID != storageObj.UserID || current.Status != models.StatusPending {
+			return ErrUploadSessionNotPending
+		}
+
+		current.Status = models.StatusActive
+		current.ETag = activated.ETag
+		current.VersionID = activated.VersionID
+		current.UpdatedAt = now
+		current.ExpiresAt = 0
+		if activated.ChecksumSHA256 != "" {
+			current.ChecksumSHA256 = activated.ChecksumSHA256
+			current.ContentHash = activated.ContentHash
+		}
+		if activated.ChecksumCRC32C != "" {
+			current.ChecksumCRC32C = activated.ChecksumCRC32C
+		}
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if errors.Is(err, ErrUploadSessionNotPending) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Failed to activate upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &activated, nil
+}
+
+// ActivateDirectUpload stores the object a client uploaded with a presigned
+// request the way putFile stores uploads that pass through the server.
+func (r *boltStorageStore) ActivateDirectUpload(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error) {
+	uploadKey := storageObj.S3Key
+
+	head, err := r.blobStore.Head(ctx, uploadKey, "")
+	if err != nil {
+		log.Printf("Head error for pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, ErrUploadMismatch
+	}
+
+	if head.Size != storageObj.FileSize || head.ContentType != storageObj.ContentType {
+		return nil, ErrUploadMismatch
+	}
+
+	object, err := r.blobStore.Get(ctx, uploadKey, blobstore.GetOptions{VersionID: head.VersionID})
+	if err != nil {
+		log.Printf("Failed to read pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+	digest, size, err := digestReader(object.Body)
+	object.Body.Close()
+	if err != nil {
+		log.Printf("Failed to checksum pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+	if size != storageObj.FileSize {
+		return nil, ErrUploadMismatch
+	}
+
+	return r.activateUpload(ctx, storageObj, head.VersionID, digest)
+}
+
+// activateUpload moves the bytes of a pending file into its content-addressed
+// blob and activates the file, in the steps of
+// StorageRepository.activateUpload.
+func (r *boltStorageStore) activateUpload(ctx context.Context, storageObj *models.StorageObject, versionID string, digest *contentDigest) (*models.StorageObject, error) {
+	uploadKey := storageObj.S3Key
+
+	uploading := *storageObj
+	uploading.ContentHash = digest.hash
+	uploading.BlobKey = r.blobKey(storageObj.UserID, digest.hash)
+	uploading.S3Key = uploading.BlobKey
+	uploading.ChecksumSHA256 = digest.sha256
+	uploading.ChecksumCRC32C = digest.crc32c
+	uploading.UpdatedAt = time.Now().UTC()
+	uploading.ExpiresAt = 0
+	uploading.Status = models.StatusUploading
+
+	entry := newOutboxEntry(models.OutboxUpload, &uploading)
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.UserID != storageObj.UserID || current.Status != models.StatusPending {
+			return ErrUploadSessionNotPending
+		}
+
+		current.Status = models.StatusUploading
+		current.ContentHash = uploading.ContentHash
+		current.BlobKey = uploading.BlobKey
+		current.S3Key = uploading.BlobKey
+		current.ChecksumSHA256 = uploading.ChecksumSHA256
+		current.ChecksumCRC32C = uploading.ChecksumCRC32C
+		current.UpdatedAt = uploading.UpdatedAt
+		current.ExpiresAt = 0
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		if err := acquireBoltBlob(tx, uploading.BlobKey); err != nil {
+			return err
+		}
+		return boltOutboxTable.put(tx, entry)
+	})
+
+	if errors.Is(err, ErrUploadSessionNotPending) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Failed to start activating upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	release := holdOutboxEntry(ctx, entry, r.moveOutboxEntry)
+	blob, err := r.getBlob(uploading.BlobKey)
+	if err == nil && (blob == nil || blob.VersionID == "") {
+		blob, err = r.copyBlob(ctx, uploadKey, versionID, uploading.BlobKey, uploading.FileSize, digest)
+	}
+	release()
+
+	var activated *models.StorageObject
+	if err == nil {
+		activated, err = r.finishUpload(&uploading, blob, entry.OutboxID)
+	}
+
+	if errors.Is(err, ErrUploadRolledBack) {
+		// The worker removed the file and released its blob reference,
+		// possibly before the copy.
+		if err := r.deleteBlobIfUnused(ctx, uploading.BlobKey); err != nil {
+			log.Printf("Failed to delete blob of rolled back file %s: %v", storageObj.ObjectID, err)
+		}
+		if err := r.blobStore.DeleteAllVersions(ctx, uploadKey); err != nil {
+			log.Printf("Failed to empty upload key of rolled back file %s: %v", storageObj.ObjectID, err)
+		}
+		return nil, ErrUploadRolledBack
+	}
+
+	if err != nil {
+		revertErr := r.revertDirectUpload(ctx, storageObj, &uploading, entry.OutboxID)
+		if errors.Is(revertErr, ErrUploadRolledBack) {
+			return nil, ErrUploadRolledBack
+		}
+		if revertErr != nil {
+			log.Printf("Failed to return upload %s to pending, leaving it to the outbox: %v", storageObj.ObjectID, revertErr)
+			return nil, ErrOutboxPending
+		}
+		return nil, err
+	}
+
+	if err := r.blobStore.DeleteAllVersions(ctx, uploadKey); err != nil {
+		log.Printf("Failed to empty upload key of file %s: %v", storageObj.ObjectID, err)
+	}
+
+	return activated, nil
+}
+
+// finishUpload activates an uploading file whose bytes are in the blob,
+// counts it towards the user's usage and removes its outbox entry. It returns
+// ErrUploadRolledBack if the file is gone.
+func (r *boltStorageStore) finishUpload(storageObj *models.StorageObject, blob *models.Blob, outboxID string) (*models.StorageObject, error) {
+	activated := *storageObj
+	activated.Status = models.StatusActive
+	activated.ETag = blob.ETag
+
+	changes := usageChanges{}
+	changes.add(&activated, 1, false)
+	changes.addActivity(time.Now(), uploadActivity(&activated))
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return ErrUploadRolledBack
+		}
+		if current.Status != models.StatusUploading {
+			return ErrFileNotFound
+		}
+
+		current.Status = models.StatusActive
+		current.ETag = blob.ETag
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		if err := boltOutboxTable.delete(tx, outboxID); err != nil {
+			return err
+		}
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if errors.Is(err, ErrUploadRolledBack) || errors.Is(err, ErrFileNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("Failed to activate file %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &activated, nil
+}
+
+// rollbackUpload removes an uploading file together with its blob reference
+// and outbox entry, then the blob if no other file holds it.
+func (r *boltStorageStore) rollbackUpload(ctx context.Context, storageObj *models.StorageObject, outboxID string) error {
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Status != models.StatusUploading {
+			return ErrFileNotFound
+		}
+
+		if err := boltFileTable.delete(tx, storageObj.ObjectID); err != nil {
+			return err
+		}
+		if err := releaseBoltBlob(tx, storageObj.BlobKey); err != nil {
+			return err
+		}
+		return boltOutboxTable.delete(tx, outboxID)
+	})
+
+	if errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to roll back upload of file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteBlobIfUnused(ctx, storageObj.BlobKey)
+}
+
+// revertDirectUpload puts a direct upload that could not be moved into its
+// blob back to pending. It returns ErrUploadRolledBack if the outbox worker
+// removed the file first.
+func (r *boltStorageStore) revertDirectUpload(ctx context.Context, pending *models.StorageObject, uploading *models.StorageObject, outboxID string) error {
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, pending.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return ErrUploadRolledBack
+		}
+		if current.Status != models.StatusUploading {
+			return ErrFileNotFound
+		}
+
+		current.Status = models.StatusPending
+		current.S3Key = pending.S3Key
+		current.ExpiresAt = pending.ExpiresAt
+		current.ContentHash = ""
+		current.BlobKey = ""
+		current.ChecksumSHA256 = ""
+		current.ChecksumCRC32C = ""
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		if err := releaseBoltBlob(tx, uploading.BlobKey); err != nil {
+			return err
+		}
+		return boltOutboxTable.delete(tx, outboxID)
+	})
+
+	if errors.Is(err, ErrUploadRolledBack) || errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to return upload %s to pending: %v", pending.ObjectID, err)
+		return err
+	}
+
+	return r.deleteBlobIfUnused(ctx, uploading.BlobKey)
+}
+
+// ListExpiredFiles returns the files in status whose ExpiresAt is before the
+// given time.
+func (r *boltStorageStore) ListExpiredFiles(ctx context.Context, status string, before time.Time) ([]models.StorageObject, error) {
+	var files []models.StorageObject
+	err := r.db.view(func(tx *boltTx) error {
+		return boltFileTable.scanIndex(tx, "expiry", boltKey(status, ""), func(file *models.StorageObject) error {
+			if file.ExpiresAt >= before.Unix() {
+				return errBoltStop
+			}
+			files = append(files, *file)
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to list expired %s files: %v", status, err)
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// DeletePendingFile removes an upload session that was never completed,
+// including whatever the client managed to put in the blob store.
+func (r *boltStorageStore) DeletePendingFile(ctx context.Context, storageObj *models.StorageObject) error {
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Status != models.StatusPending {
+			return ErrUploadSessionNotPending
+		}
+		return boltFileTable.delete(tx, storageObj.ObjectID)
+	})
+
+	if errors.Is(err, ErrUploadSessionNotPending) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Delete error for pending upload %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteAllVersions(ctx, storageObj)
+}
+
+// acquireBoltBlob adds a reference to the blob, creating its record if this
+// is the first, like acquireBlobWrite.
+func acquireBoltBlob(tx *boltTx, key string) error {
+	blob, err := boltBlobTable.get(tx, key)
+	if err != nil {
+		return err
+	}
+	if blob == nil {
+		blob = &models.Blob{BlobKey: key, CreatedAt: time.Now().UTC()}
+	}
+
+	blob.RefCount++
+	return boltBlobTable.put(tx, blob)
+}
+
+// releaseBoltBlob drops a reference to the blob, like releaseBlobWrite. The
+// blob is deleted afterwards by deleteBlobIfUnused.
+func releaseBoltBlob(tx *boltTx, key string) error {
+	blob, err := boltBlobTable.get(tx, key)
+	if err != nil {
+		return err
+	}
+	if blob == nil {
+		return fmt.Errorf("blob %s has no record to release", key)
+	}
+
+	blob.RefCount--
+	return boltBlobTable.put(tx, blob)
+}
+
+func (r *boltStorageStore) getBlob(key string) (*models.Blob, error) {
+	var blob *models.Blob
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		blob, err = boltBlobTable.get(tx, key)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for blob %s: %v", key, err)
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// copyBlob makes the given version of srcKey the content of the blob and
+// records which version holds the bytes.
+func (r *boltStorageStore) copyBlob(ctx context.Context, srcKey string, srcVersionID string, key string, fileSize int64, digest *contentDigest) (*models.Blob, error) {
+	stored, err := r.blobStore.Copy(ctx, srcKey, srcVersionID, key)
+	if err != nil {
+		log.Printf("Failed to copy %s to blob %s: %v", srcKey, key, err)
+		return nil, err
+	}
+
+	return r.recordBlob(ctx, key, stored, fileSize, digest)
+}
+
+// recordBlob records the version that holds the bytes of the blob, like
+// StorageRepository.recordBlob: if another upload recorded its version first,
+// ours is removed and theirs used, and if the record is gone the bytes are
+// removed and ErrUploadRolledBack is returned.
+func (r *boltStorageStore) recordBlob(ctx context.Context, key string, stored *blobstore.ObjectInfo, fileSize int64, digest *contentDigest) (*models.Blob, error) {
+	var blob *models.Blob
+	recorded := false
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		blob, err = boltBlobTable.get(tx, key)
+		if err != nil || blob == nil || blob.VersionID != "" {
+			return err
+		}
+
+		blob.VersionID = stored.VersionID
+		blob.ETag = stored.ETag
+		blob.FileSize = fileSize
+		blob.ChecksumSHA256 = digest.sha256
+		blob.ChecksumCRC32C = digest.crc32c
+		recorded = true
+		return boltBlobTable.put(tx, blob)
+	})
+
+	if err != nil {
+		log.Printf("Failed to record blob %s: %v", key, err)
+		return nil, err
+	}
+
+	if blob == nil {
+		if err := r.blobStore.Delete(ctx, key, stored.VersionID); err != nil {
+			log.Printf("Failed to remove upload of released blob %s: %v", key, err)
+		}
+		return nil, ErrUploadRolledBack
+	}
+
+	if !recorded {
+		if err := r.blobStore.Delete(ctx, key, stored.VersionID); err != nil {
+			log.Printf("Failed to remove duplicate upload of blob %s: %v", key, err)
+		}
+	}
+
+	return blob, nil
+}
+
+// deleteBlobIfUnused deletes a blob nothing refers to any more, and the S3
+// version its record names. It can be called any number of times.
+func (r *boltStorageStore) deleteBlobIfUnused(ctx context.Context, key string) error {
+	var blob *models.Blob
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		blob, err = boltBlobTable.get(tx, key)
+		if err != nil || blob == nil {
+			return err
+		}
+		if blob.RefCount > 0 {
+			blob = nil
+			return nil
+		}
+		return boltBlobTable.delete(tx, key)
+	})
+
+	if err != nil {
+		log.Printf("Failed to delete blob record %s: %v", key, err)
+		return err
+	}
+
+	if blob == nil || blob.VersionID == "" {
+		return nil
+	}
+
+	if err := r.blobStore.Delete(ctx, key, blob.VersionID); err != nil {
+		log.Printf("Failed to delete blob %s: %v", key, err)
+		return err
+	}
+
+	return nil
+}
+
+// DetachBlob gives a file that shares a blob a copy of the bytes under its
+// own key, so its content can be replaced and versioned on its own.
+func (r *boltStorageStore) DetachBlob(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error) {
+	ownKey := fmt.Sprintf("users/%s/%s", storageObj.UserID, storageObj.ObjectID)
+
+	copied, err := r.blobStore.Copy(ctx, storageObj.BlobKey, "", ownKey)
+	if err != nil {
+		log.Printf("Failed to copy blob %s for file %s: %v", storageObj.BlobKey, storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	etag := storageObj.ETag
+	if copied.ETag != "" {
+		etag = copied.ETag
+	}
+
+	var conflict error
+	err = r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if !boltVersionMatches(current, storageObj.UserID, storageObj.Version) || current.BlobKey != storageObj.BlobKey {
+			conflict = boltVersionConflict(current, storageObj.UserID)
+			return conflict
+		}
+
+		current.S3Key = ownKey
+		current.S3Bucket = r.bucketName
+		current.ETag = etag
+		current.VersionID = copied.VersionID
+		current.BlobKey = ""
+		if err := boltFileTable.put(tx, current); err != nil {
+			return err
+		}
+		return releaseBoltBlob(tx, storageObj.BlobKey)
+	})
+
+	if conflict != nil {
+		return nil, conflict
+	}
+	if err != nil {
+		log.Printf("Failed to detach file %s from blob %s: %v", storageObj.ObjectID, storageObj.BlobKey, err)
+		return nil, err
+	}
+
+	if err := r.deleteBlobIfUnused(ctx, storageObj.BlobKey); err != nil {
+		log.Printf("Failed to clean up blob %s of detached file %s: %v", storageObj.BlobKey, storageObj.ObjectID, err)
+	}
+
+	detached := *storageObj
+	detached.S3Key = ownKey
+	detached.S3Bucket = r.bucketName
+	detached.ETag = etag
+	detached.VersionID = copied.VersionID
+	detached.BlobKey = ""
+
+	return &detached, nil
+}
+
+// ListDuplicateCandidates returns the user's active files that have a
+// content hash, ordered by hash.
+func (r *boltStorageStore) ListDuplicateCandidates(ctx context.Context, userID string) ([]models.StorageObject, error) {
+	files := []models.StorageObject{}
+	err := r.db.view(func(tx *boltTx) error {
+		return boltFileTable.scanIndex(tx, "hash", boltKey(userID, ""), func(file *models.StorageObject) error {
+			if file.IsActive() {
+				files = append(files, *file)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to list hashed files of user %s: %v", userID, err)
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (r *boltStorageStore) CreateMultipartUpload(ctx context.Context, storageObj *models.StorageObject, partSize int64, partCount int32) (*models.MultipartUpload, error) {
+	uploadID, err := r.blobStore.CreateMultipartUpload(ctx, storageObj.S3Key, storageObj.ContentType)
+	if err != nil {
+		log.Printf("Failed to create multipart upload for %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	upload := &models.MultipartUpload{
+		ObjectID:  storageObj.ObjectID,
+		UserID:    storageObj.UserID,
+		UploadID:  uploadID,
+		FileSize:  storageObj.FileSize,
+		PartSize:  partSize,
+		PartCount: partCount,
+		Parts:     map[string]models.UploadedPart{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = r.db.update(func(tx *boltTx) error {
+		return boltMultipartTable.put(tx, upload)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save multipart upload: %v", err)
+		r.blobStore.AbortMultipartUpload(ctx, storageObj.S3Key, upload.UploadID)
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (r *boltStorageStore) GetMultipartUpload(ctx context.Context, objectID string) (*models.MultipartUpload, error) {
+	var upload *models.MultipartUpload
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		upload, err = boltMultipartTable.get(tx, objectID)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for multipart upload %s: %v", objectID, err)
+		return nil, err
+	}
+
+	if upload == nil {
+		return nil, ErrMultipartUploadNotFound
+	}
+
+	// gob leaves out empty maps.
+	if upload.Parts == nil {
+		upload.Parts = map[string]models.UploadedPart{}
+	}
+
+	return upload, nil
+}
+
+// UploadPart streams one part through the server to the blob store and
+// records it.
+func (r *boltStorageStore) UploadPart(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, partNumber int32, partSize int64, body io.Reader, expiresAt time.Time) (*models.UploadedPart, error) {
+	stored, err := r.blobStore.UploadPart(ctx, storageObj.S3Key, upload.UploadID, partNumber, partSize, body)
+	if err != nil {
+		log.Printf("Failed to upload part %d of %s: %v", partNumber, storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	part := &models.UploadedPart{
+		PartNumber: partNumber,
+		ETag:       stored.ETag,
+		Size:       partSize,
+		UploadedAt: time.Now().UTC(),
+	}
+
+	if err := r.RecordPart(ctx, storageObj, part, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return part, nil
+}
+
+// RecordPart stores an acknowledged part and pushes the session expiry back
+// in one transaction.
+func (r *boltStorageStore) RecordPart(ctx context.Context, storageObj *models.StorageObject, part *models.UploadedPart, expiresAt time.Time) error {
+	now := time.Now().UTC()
+
+	err := r.db.update(func(tx *boltTx) error {
+		upload, err := boltMultipartTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if upload == nil || current == nil || current.Status != models.StatusPending {
+			return ErrUploadSessionNotPending
+		}
+
+		if upload.Parts == nil {
+			upload.Parts = map[string]models.UploadedPart{}
+		}
+		upload.Parts[strconv.Itoa(int(part.PartNumber))] = *part
+		upload.UpdatedAt = now
+		if err := boltMultipartTable.put(tx, upload); err != nil {
+			return err
+		}
+
+		current.ExpiresAt = expiresAt.Unix()
+		current.UpdatedAt = now
+		return boltFileTable.put(tx, current)
+	})
+
+	if errors.Is(err, ErrUploadSessionNotPending) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to record part %d of %s: %v", part.PartNumber, storageObj.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *boltStorageStore) CompleteMultipartUpload(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, parts []blobstore.Part) error {
+	err := r.blobStore.CompleteMultipartUpload(ctx, storageObj.S3Key, upload.UploadID, parts)
+	if err != nil {
+		log.Printf("Failed to complete multipart upload of %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteMultipartUpload(upload.ObjectID)
+}
+
+// AbortMultipartUpload releases the parts the blob store is holding and
+// forgets the session. The pending StorageObject is left for the caller to
+// remove.
+func (r *boltStorageStore) AbortMultipartUpload(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload) error {
+	if err := r.blobStore.AbortMultipartUpload(ctx, storageObj.S3Key, upload.UploadID); err != nil {
+		return err
+	}
+
+	return r.deleteMultipartUpload(upload.ObjectID)
+}
+
+func (r *boltStorageStore) deleteMultipartUpload(objectID string) error {
+	err := r.db.update(func(tx *boltTx) error {
+		return boltMultipartTable.delete(tx, objectID)
+	})
+
+	if err != nil {
+		log.Printf("Delete error for multipart upload %s: %v", objectID, err)
+		return err
+	}
+
+	return nil
+}
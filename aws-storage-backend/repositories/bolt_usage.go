@@ -0,0 +1,257 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// ListMonthlyUsage returns the user's month and content type aggregates,
+// oldest month first.
+func (r *boltStorageStore) ListMonthlyUsage(ctx context.Context, userID string) ([]models.UsageAggregate, error) {
+	aggregates := []models.UsageAggregate{}
+	err := r.scanUsage(userID, "0", "9~", func(usage *boltUsage) {
+		aggregates = append(aggregates, usage.aggregate())
+	})
+	return aggregates, err
+}
+
+// ListFolderUsage returns the aggregates of the folders the user has files in.
+func (r *boltStorageStore) ListFolderUsage(ctx context.Context, userID string) ([]models.UsageAggregate, error) {
+	aggregates := []models.UsageAggregate{}
+	err := r.scanUsage(userID, folderUsagePrefix, folderUsagePrefix+"~", func(usage *boltUsage) {
+		aggregates = append(aggregates, usage.aggregate())
+	})
+	return aggregates, err
+}
+
+// ListUsageActivity returns the user's activity items for the hours from
+// from up to and including to.
+func (r *boltStorageStore) ListUsageActivity(ctx context.Context, userID string, from time.Time, to time.Time) ([]models.UsageActivity, error) {
+	activity := []models.UsageActivity{}
+	err := r.scanUsage(userID,
+		activityUsagePrefix+from.UTC().Format(usageHourLayout),
+		activityUsagePrefix+to.UTC().Format(usageHourLayout),
+		func(usage *boltUsage) {
+			activity = append(activity, usage.activity())
+		})
+	return activity, err
+}
+
+// scanUsage calls fn with the user's aggregate items with keys between lower
+// and upper, inclusive, in key order.
+func (r *boltStorageStore) scanUsage(userID string, lower string, upper string, fn func(usage *boltUsage)) error {
+	err := r.db.view(func(tx *boltTx) error {
+		return boltUsageTable.scan(tx, boltKey(userID, ""), func(usage *boltUsage) error {
+			switch {
+			case usage.UsageKey < lower:
+				return nil
+			case usage.UsageKey > upper:
+				return errBoltStop
+			}
+			fn(usage)
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to read usage of user %s: %v", userID, err)
+		return err
+	}
+
+	return nil
+}
+
+// RebuildUsage recomputes every aggregate from the stored files and replaces
+// the stored ones, keeping the activity items. It runs in one transaction, so
+// unlike on DynamoDB writes cannot slip in between. It returns how many users
+// were rebuilt.
+func (r *boltStorageStore) RebuildUsage(ctx context.Context) (int, error) {
+	rebuilt := map[string]usageChanges{}
+
+	err := r.db.update(func(tx *boltTx) error {
+		err := boltFileTable.scan(tx, "", func(file *models.StorageObject) error {
+			if !file.IsActive() && file.Status != models.StatusTrashed {
+				// Pending, uploading and deleting files are not counted.
+				return nil
+			}
+
+			changes, ok := rebuilt[file.UserID]
+			if !ok {
+				changes = usageChanges{}
+				rebuilt[file.UserID] = changes
+			}
+			changes.add(file, 1, file.Status == models.StatusTrashed)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		stored, err := boltUsageTable.list(tx, "")
+		if err != nil {
+			return err
+		}
+
+		// Aggregates whose files are all gone are not in the rebuilt set and
+		// have to be removed.
+		for _, usage := range stored {
+			if strings.HasPrefix(usage.UsageKey, activityUsagePrefix) {
+				continue
+			}
+			if err := boltUsageTable.delete(tx, boltKey(usage.UserID, usage.UsageKey)); err != nil {
+				return err
+			}
+		}
+
+		for userID, changes := range rebuilt {
+			for key, change := range changes {
+				err := boltUsageTable.put(tx, &boltUsage{
+					UserID:   userID,
+					UsageKey: key,
+					Labels:   change.labels,
+					Counters: change.counters,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Failed to rebuild usage aggregates: %v", err)
+		return 0, err
+	}
+
+	return len(rebuilt), nil
+}
+
+// ScrubFiles checks every file's bytes against the size and checksums on
+// record, like StorageRepository.ScrubFiles.
+func (r *boltStorageStore) ScrubFiles(ctx context.Context, options models.ScrubOptions) (*models.ScrubReport, error) {
+	report := &models.ScrubReport{
+		StartedAt: time.Now().UTC(),
+		Findings:  []models.ScrubFinding{},
+	}
+
+	var files []models.StorageObject
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		files, err = boltFileTable.list(tx, "")
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to read files: %v", err)
+		return nil, err
+	}
+
+	r.scrub(ctx, report, files, options, r.QuarantineFile)
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// QuarantineFile blocks downloads of a file that failed verification, as long
+// as it still points at the content that was checked and no new content is
+// being written; otherwise it returns ErrVersionConflict.
+func (r *boltStorageStore) QuarantineFile(ctx context.Context, storageObj *models.StorageObject, reason string) error {
+	now := time.Now().UTC()
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.S3Key != storageObj.S3Key || current.VersionID != storageObj.VersionID || current.IsReplacing() {
+			return ErrVersionConflict
+		}
+
+		current.QuarantinedAt = &now
+		current.QuarantineReason = reason
+		return boltFileTable.put(tx, current)
+	})
+
+	if errors.Is(err, ErrVersionConflict) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to quarantine file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
+
+// BlobExists reports whether the blob table has a record for key.
+func (r *boltStorageStore) BlobExists(ctx context.Context, key string) (bool, error) {
+	blob, err := r.getBlob(key)
+	if err != nil {
+		return false, err
+	}
+
+	return blob != nil, nil
+}
+
+// RemoveDanglingFile deletes the record of a file whose S3 object is gone,
+// along with its share of the usage totals, any versions left behind and its
+// reference to a blob. It returns ErrFileNotFound if the record changed since
+// it was read.
+func (r *boltStorageStore) RemoveDanglingFile(ctx context.Context, storageObj *models.StorageObject) error {
+	status := storageObj.Status
+	if storageObj.IsActive() {
+		status = models.StatusActive
+	}
+
+	changes := usageChanges{}
+	changes.add(storageObj, -1, storageObj.Status == models.StatusTrashed)
+
+	err := r.db.update(func(tx *boltTx) error {
+		current, err := boltFileTable.get(tx, storageObj.ObjectID)
+		if err != nil {
+			return err
+		}
+		if !boltVersionMatches(current, storageObj.UserID, storageObj.Version) || current.S3Key != storageObj.S3Key || !boltStatusMatches(current, status) {
+			return ErrFileNotFound
+		}
+
+		if err := boltFileTable.delete(tx, storageObj.ObjectID); err != nil {
+			return err
+		}
+
+		// The blob record may be as gone as the object.
+		if storageObj.SharesBlob() {
+			blob, err := boltBlobTable.get(tx, storageObj.BlobKey)
+			if err != nil {
+				return err
+			}
+			if blob != nil {
+				if err := releaseBoltBlob(tx, storageObj.BlobKey); err != nil {
+					return err
+				}
+			}
+		}
+
+		return addBoltUsage(tx, storageObj.UserID, changes)
+	})
+
+	if errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Failed to remove dangling file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	if storageObj.SharesBlob() {
+		return r.deleteBlobIfUnused(ctx, storageObj.BlobKey)
+	}
+
+	return r.deleteAllVersions(ctx, storageObj)
+}
@@ -0,0 +1,283 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltUserTable = boltTable[models.User]{
+	name: UsersTable,
+	key:  func(u *models.User) string { return u.UserID },
+	indexes: []boltIndex[models.User]{
+		{name: "email", key: func(u *models.User) (string, bool) { return u.UserEmail, true }},
+	},
+}
+
+// boltUserStore keeps users in the bolt file, with their email and username
+// reserved in boltUniqueBucket.
+type boltUserStore struct {
+	db *boltDB
+}
+
+func (r *boltUserStore) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
+	var user *models.User
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		user, err = boltUserTable.get(tx, userID)
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Couldn't get user with id : %v, Here's what happened : %v", userID, err)
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user with id : %v not found", userID)
+	}
+
+	return user, nil
+}
+
+func (r *boltUserStore) GetUserByEmail(ctx context.Context, userEmail string) (*models.User, error) {
+	userEmail = models.NormalizeEmail(userEmail)
+
+	var user *models.User
+	err := r.db.view(func(tx *boltTx) error {
+		return boltUserTable.scanIndex(tx, "email", boltKey(userEmail, ""), func(found *models.User) error {
+			user = found
+			return errBoltStop
+		})
+	})
+
+	if err != nil {
+		log.Printf("couldn't get user with email: %v, error: %v", userEmail, err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateUser saves a new user and reserves its email and username, failing
+// with the error of the first of them that is taken.
+func (r *boltUserStore) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	user.UserEmail = models.NormalizeEmail(user.UserEmail)
+
+	var conflict error
+	err := r.db.update(func(tx *boltTx) error {
+		existing, err := boltUserTable.get(tx, user.UserID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			conflict = ErrUserIDInUse
+			return conflict
+		}
+
+		unique := tx.bucket(boltUniqueBucket)
+		for _, reservation := range []struct {
+			key      string
+			conflict error
+		}{
+			{uniqueEmailKey(user.UserEmail), ErrEmailInUse},
+			{uniqueUserNameKey(user.UserName), ErrUserNameInUse},
+		} {
+			holder := unique.Get([]byte(reservation.key))
+			if holder != nil && string(holder) != user.UserID {
+				conflict = reservation.conflict
+				return conflict
+			}
+			if err := unique.Put([]byte(reservation.key), []byte(user.UserID)); err != nil {
+				return err
+			}
+		}
+
+		return boltUserTable.put(tx, user)
+	})
+
+	if conflict != nil {
+		return nil, conflict
+	}
+	if err != nil {
+		log.Printf("Couldn't create user %v: %v", user.UserID, err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *boltUserStore) UpdateVersionPolicy(ctx context.Context, userID string, maxFileVersions int) (*models.User, error) {
+	return r.updateUser(userID, func(user *models.User) {
+		user.MaxFileVersions = maxFileVersions
+	})
+}
+
+// UpdateStorageQuota sets the user's own quota, or removes it when quota is
+// nil so the default applies again.
+func (r *boltUserStore) UpdateStorageQuota(ctx context.Context, userID string, quota *int64) (*models.User, error) {
+	return r.updateUser(userID, func(user *models.User) {
+		user.StorageQuota = quota
+	})
+}
+
+// updateUser applies update to an existing user and stamps UpdatedAt.
+func (r *boltUserStore) updateUser(userID string, update func(user *models.User)) (*models.User, error) {
+	var user *models.User
+	err := r.db.update(func(tx *boltTx) error {
+		var err error
+		user, err = boltUserTable.get(tx, userID)
+		if err != nil || user == nil {
+			return err
+		}
+
+		update(user)
+		user.UpdatedAt = time.Now().Unix()
+		return boltUserTable.put(tx, user)
+	})
+
+	if err != nil {
+		log.Printf("Couldn't update user %v: %v", userID, err)
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("user with id : %v not found", userID)
+	}
+
+	return user, nil
+}
+
+// ReserveStorage adds size bytes to the user's usage counter, but only if the
+// total stays within quota.
+func (r *boltUserStore) ReserveStorage(ctx context.Context, userID string, size int64, quota int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	if size > quota {
+		return ErrQuotaExceeded
+	}
+
+	exceeded := false
+	err := r.db.update(func(tx *boltTx) error {
+		user, err := boltUserTable.get(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.UsedBytes > quota-size {
+			exceeded = true
+			return nil
+		}
+
+		user.UsedBytes += size
+		return boltUserTable.put(tx, user)
+	})
+
+	if err != nil {
+		log.Printf("Couldn't reserve %d bytes for user %v: %v", size, userID, err)
+		return err
+	}
+
+	if exceeded {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// ReleaseStorage takes size bytes off the user's usage counter. A release that
+// would drive the counter below zero is dropped and logged, as in
+// UserRepository.ReleaseStorage.
+func (r *boltUserStore) ReleaseStorage(ctx context.Context, userID string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	released := true
+	err := r.db.update(func(tx *boltTx) error {
+		user, err := boltUserTable.get(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.UsedBytes < size {
+			released = false
+			return nil
+		}
+
+		user.UsedBytes -= size
+		return boltUserTable.put(tx, user)
+	})
+
+	if err != nil {
+		log.Printf("Couldn't release %d bytes for user %v: %v", size, userID, err)
+		return err
+	}
+
+	if !released {
+		log.Printf("Usage counter of user %v is below %d bytes, skipping release; run cmd/rebuild-usage to recount it", userID, size)
+	}
+
+	return nil
+}
+
+// ListUserIDs returns the ID of every user.
+func (r *boltUserStore) ListUserIDs(ctx context.Context) ([]string, error) {
+	userIDs := []string{}
+	err := r.db.view(func(tx *boltTx) error {
+		return boltUserTable.scan(tx, "", func(user *models.User) error {
+			userIDs = append(userIDs, user.UserID)
+			return nil
+		})
+	})
+
+	if err != nil {
+		log.Printf("Failed to scan users: %v", err)
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// RebuildUsedBytes sets every user's usage counter to the total size of their
+// files, whatever their status, and returns how many users it updated. The
+// files are read in the same transaction, so unlike on DynamoDB it does not
+// race with uploads.
+func (r *boltUserStore) RebuildUsedBytes(ctx context.Context) (int, error) {
+	updated := 0
+	err := r.db.update(func(tx *boltTx) error {
+		usedBytes := map[string]int64{}
+		err := boltFileTable.scan(tx, "", func(file *models.StorageObject) error {
+			usedBytes[file.UserID] += file.FileSize
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		users, err := boltUserTable.list(tx, "")
+		if err != nil {
+			return err
+		}
+
+		for i := range users {
+			users[i].UsedBytes = usedBytes[users[i].UserID]
+			if err := boltUserTable.put(tx, &users[i]); err != nil {
+				return err
+			}
+		}
+
+		updated = len(users)
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Couldn't rebuild usage counters: %v", err)
+		return 0, err
+	}
+
+	return updated, nil
+}
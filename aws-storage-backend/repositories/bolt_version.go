@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+var boltVersionTable = boltTable[models.FileVersion]{
+	name:      FileVersionTable,
+	key:       func(v *models.FileVersion) string { return boltKey(v.ObjectID, v.VersionID) },
+	transient: func(v *models.FileVersion) { v.IsCurrent = false },
+}
+
+// RecordVersion stores the S3 version that storageObj currently points at.
+// Objects written before bucket versioning was enabled have no version ID and
+// are skipped.
+func (r *boltStorageStore) RecordVersion(ctx context.Context, storageObj *models.StorageObject, uploadedBy string) (*models.FileVersion, error) {
+	if storageObj.VersionID == "" {
+		return nil, nil
+	}
+
+	version := &models.FileVersion{
+		ObjectID:       storageObj.ObjectID,
+		VersionID:      storageObj.VersionID,
+		UserID:         storageObj.UserID,
+		FileSize:       storageObj.FileSize,
+		ContentType:    storageObj.ContentType,
+		ETag:           storageObj.ETag,
+		ChecksumSHA256: storageObj.ChecksumSHA256,
+		ChecksumCRC32C: storageObj.ChecksumCRC32C,
+		UploadedBy:     uploadedBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	err := r.db.update(func(tx *boltTx) error {
+		return boltVersionTable.put(tx, version)
+	})
+
+	if err != nil {
+		log.Printf("Failed to save version %s of file %s: %v", version.VersionID, version.ObjectID, err)
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// ListVersions returns every recorded version of a file, newest first.
+func (r *boltStorageStore) ListVersions(ctx context.Context, objectID string) ([]models.FileVersion, error) {
+	var versions []models.FileVersion
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		versions, err = boltVersionTable.list(tx, boltKey(objectID, ""))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Failed to list versions of file %s: %v", objectID, err)
+		return nil, err
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].CreatedAt.After(versions[j].CreatedAt)
+	})
+
+	return versions, nil
+}
+
+func (r *boltStorageStore) GetVersion(ctx context.Context, objectID string, versionID string) (*models.FileVersion, error) {
+	var version *models.FileVersion
+	err := r.db.view(func(tx *boltTx) error {
+		var err error
+		version, err = boltVersionTable.get(tx, boltKey(objectID, versionID))
+		return err
+	})
+
+	if err != nil {
+		log.Printf("Get error for version %s of file %s: %v", versionID, objectID, err)
+		return nil, err
+	}
+
+	if version == nil {
+		return nil, ErrFileVersionNotFound
+	}
+
+	return version, nil
+}
+
+// RestoreVersion copies an older version on top of the object and points the
+// metadata at the copy, like StorageRepository.RestoreVersion.
+func (r *boltStorageStore) RestoreVersion(ctx context.Context, storageObj *models.StorageObject, version *models.FileVersion) (*models.StorageObject, error) {
+	return r.replaceContent(ctx, storageObj, version.FileSize-storageObj.FileSize, func() (*storedContent, error) {
+		copied, err := r.blobStore.Copy(ctx, storageObj.S3Key, version.VersionID, storageObj.S3Key)
+		if err != nil {
+			log.Printf("Failed to copy version %s of file %s: %v", version.VersionID, storageObj.ObjectID, err)
+			return nil, err
+		}
+
+		etag := version.ETag
+		if copied.ETag != "" {
+			etag = copied.ETag
+		}
+
+		return &storedContent{
+			fileSize:       version.FileSize,
+			contentType:    version.ContentType,
+			etag:           etag,
+			versionID:      copied.VersionID,
+			checksumSHA256: version.ChecksumSHA256,
+			checksumCRC32C: version.ChecksumCRC32C,
+		}, nil
+	})
+}
+
+// DeleteVersion permanently removes one version's bytes and its record.
+func (r *boltStorageStore) DeleteVersion(ctx context.Context, storageObj *models.StorageObject, version *models.FileVersion) error {
+	err := r.blobStore.Delete(ctx, storageObj.S3Key, version.VersionID)
+	if err != nil {
+		log.Printf("Delete error for version %s of file %s: %v", version.VersionID, storageObj.ObjectID, err)
+		return err
+	}
+
+	err = r.db.update(func(tx *boltTx) error {
+		return boltVersionTable.delete(tx, boltKey(version.ObjectID, version.VersionID))
+	})
+
+	if err != nil {
+		log.Printf("Delete error for version %s of file %s: %v", version.VersionID, version.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
+
+// deleteAllVersions removes every version and delete marker of an object,
+// then the version records.
+func (r *boltStorageStore) deleteAllVersions(ctx context.Context, storageObj *models.StorageObject) error {
+	if err := r.blobStore.DeleteAllVersions(ctx, storageObj.S3Key); err != nil {
+		log.Printf("Failed to delete stored versions of file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	err := r.db.update(func(tx *boltTx) error {
+		versions, err := boltVersionTable.list(tx, boltKey(storageObj.ObjectID, ""))
+		if err != nil {
+			return err
+		}
+
+		for _, version := range versions {
+			if err := boltVersionTable.delete(tx, boltKey(version.ObjectID, version.VersionID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		log.Printf("Failed to delete version records of file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
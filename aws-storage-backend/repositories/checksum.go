@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// contentDigest holds the checksums of some content. sha256 and crc32c are
+// base64 encoded, the way S3 reports them; hash is the hex SHA-256 the blobs
+// are keyed by.
+type contentDigest struct {
+	hash   string
+	sha256 string
+	crc32c string
+}
+
+// digestingReader checksums the bytes read through it, so an upload is
+// checksummed on its way to the blob store instead of being read twice.
+type digestingReader struct {
+	io.Reader
+	sha  hash.Hash
+	crc  hash.Hash32
+	size byteCounter
+}
+
+func newDigestingReader(fileData io.Reader) *digestingReader {
+	d := &digestingReader{sha: sha256.New(), crc: crc32.New(crc32cTable)}
+	d.Reader = io.TeeReader(fileData, io.MultiWriter(d.sha, d.crc, &d.size))
+	return d
+}
+
+// digest returns the checksums of what was read so far and how many bytes
+// that was.
+func (d *digestingReader) digest() (*contentDigest, int64) {
+	return newContentDigest(d.sha, d.crc), int64(d.size)
+}
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// digestReader checksums everything fileData yields and returns how many
+// bytes that was.
+func digestReader(fileData io.Reader) (*contentDigest, int64, error) {
+	sha := sha256.New()
+	crc := crc32.New(crc32cTable)
+
+	size, err := io.Copy(io.MultiWriter(sha, crc), fileData)
+	if err != nil {
+		return nil, size, err
+	}
+
+	return newContentDigest(sha, crc), size, nil
+}
+
+func newContentDigest(sha hash.Hash, crc hash.Hash32) *contentDigest {
+	shaSum := sha.Sum(nil)
+
+	crcSum := make([]byte, 4)
+	binary.BigEndian.PutUint32(crcSum, crc.Sum32())
+
+	return &contentDigest{
+		hash:   hex.EncodeToString(shaSum),
+		sha256: base64.StdEncoding.EncodeToString(shaSum),
+		crc32c: base64.StdEncoding.EncodeToString(crcSum),
+	}
+}
+
+// contentHashOf turns a base64 SHA-256 checksum into the hex form blobs and
+// duplicate detection use. Composite checksums of multipart uploads ("...-N")
+// are not a hash of the content and yield "".
+func contentHashOf(checksumSHA256 string) string {
+	sum, err := base64.StdEncoding.DecodeString(checksumSHA256)
+	if err != nil || len(sum) != sha256.Size {
+		return ""
+	}
+	return hex.EncodeToString(sum)
+}
@@ -0,0 +1,224 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const FileRequestTable = "file_request"
+
+var (
+	ErrFileRequestNotFound    = errors.New("file request not found")
+	ErrFileRequestUnavailable = errors.New("file request has expired, was closed or is full")
+)
+
+type FileRequestRepository struct {
+	dynamoService *config.DynamoDBService
+}
+
+func NewFileRequestRepository(dynamoService *config.DynamoDBService) *FileRequestRepository {
+	return &FileRequestRepository{
+		dynamoService: dynamoService,
+	}
+}
+
+func (r *FileRequestRepository) CreateFileRequest(ctx context.Context, request *models.FileRequest) (*models.FileRequest, error) {
+	item, err := attributevalue.MarshalMap(request)
+	if err != nil {
+		log.Printf("Failed to marshal file request: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(FileRequestTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(RequestID)"),
+	})
+
+	if err != nil {
+		log.Printf("Failed to save file request: %v", err)
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func (r *FileRequestRepository) GetFileRequest(ctx context.Context, requestID string, userID string) (*models.FileRequest, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(FileRequestTable),
+		Key: map[string]types.AttributeValue{
+			"RequestID": &types.AttributeValueMemberS{Value: requestID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for file request %s: %v", requestID, err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrFileRequestNotFound
+	}
+
+	var request models.FileRequest
+	if err := attributevalue.UnmarshalMap(result.Item, &request); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	if request.UserID != userID {
+		return nil, ErrFileRequestNotFound
+	}
+
+	return &request, nil
+}
+
+func (r *FileRequestRepository) GetFileRequestByToken(ctx context.Context, token string) (*models.FileRequest, error) {
+	result, err := r.dynamoService.Client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(FileRequestTable),
+		IndexName:              aws.String("TokenIndex"),
+		KeyConditionExpression: aws.String("#token = :token"),
+		ExpressionAttributeNames: map[string]string{
+			"#token": "Token",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":token": &types.AttributeValueMemberS{Value: token},
+		},
+	})
+
+	if err != nil {
+		log.Printf("Query error for file request token: %v", err)
+		return nil, err
+	}
+
+	if len(result.Items) == 0 {
+		return nil, ErrFileRequestNotFound
+	}
+
+	var request models.FileRequest
+	if err := attributevalue.UnmarshalMap(result.Items[0], &request); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func (r *FileRequestRepository) ListFileRequests(ctx context.Context, userID string) ([]models.FileRequest, error) {
+	requests := []models.FileRequest{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(FileRequestTable),
+		IndexName:              aws.String("UserIDIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list file requests of user %s: %v", userID, err)
+			return nil, err
+		}
+
+		var items []models.FileRequest
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal file requests: %v", err)
+			return nil, err
+		}
+		requests = append(requests, items...)
+	}
+
+	return requests, nil
+}
+
+func (r *FileRequestRepository) RevokeFileRequest(ctx context.Context, requestID string, userID string) error {
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(FileRequestTable),
+		Key: map[string]types.AttributeValue{
+			"RequestID": &types.AttributeValueMemberS{Value: requestID},
+		},
+		UpdateExpression:    aws.String("SET RevokedAt = if_not_exists(RevokedAt, :now)"),
+		ConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":    &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrFileRequestNotFound
+		}
+		log.Printf("UpdateItem error for file request %s: %v", requestID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ReserveUpload takes one of the request's upload slots before the bytes are
+// stored, so concurrent guests cannot go past MaxFiles. ReleaseUpload gives
+// the slot back if the upload then fails.
+func (r *FileRequestRepository) ReserveUpload(ctx context.Context, requestID string, now time.Time) error {
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(FileRequestTable),
+		Key: map[string]types.AttributeValue{
+			"RequestID": &types.AttributeValueMemberS{Value: requestID},
+		},
+		UpdateExpression: aws.String("ADD UploadCount :one"),
+		ConditionExpression: aws.String("attribute_exists(RequestID) AND attribute_not_exists(RevokedAt)" +
+			" AND (attribute_not_exists(ExpiresAt) OR ExpiresAt > :now)" +
+			" AND (attribute_not_exists(MaxFiles) OR UploadCount < MaxFiles)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrFileRequestUnavailable
+		}
+		log.Printf("UpdateItem error for file request %s: %v", requestID, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *FileRequestRepository) ReleaseUpload(ctx context.Context, requestID string) error {
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(FileRequestTable),
+		Key: map[string]types.AttributeValue{
+			"RequestID": &types.AttributeValueMemberS{Value: requestID},
+		},
+		UpdateExpression:    aws.String("ADD UploadCount :minusOne"),
+		ConditionExpression: aws.String("UploadCount > :zero"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":minusOne": &types.AttributeValueMemberN{Value: "-1"},
+			":zero":     &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+
+	if err != nil {
+		log.Printf("Failed to release upload slot of file request %s: %v", requestID, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+const FolderTable = "folder"
+
+var (
+	ErrFolderNotFound     = errors.New("folder not found")
+	ErrFolderAccessDenied = errors.New("unauthorized: folder does not belong to user")
+)
+
+type FolderRepository struct {
+	dynamoService *config.DynamoDBService
+}
+
+func NewFolderRepository(dynamoService *config.DynamoDBService) *FolderRepository {
+	return &FolderRepository{
+		dynamoService: dynamoService,
+	}
+}
+
+func (r *FolderRepository) CreateFolder(ctx context.Context, userID string, name string, parentID string) (*models.Folder, error) {
+	now := time.Now().UTC()
+	folder := &models.Folder{
+		FolderID:  uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		ParentID:  parentID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	item, err := attributevalue.MarshalMap(folder)
+	if err != nil {
+		log.Printf("Failed to marshal folder: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(FolderTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(FolderID)"),
+	})
+
+	if err != nil {
+		log.Printf("Failed to save folder to DynamoDB: %v", err)
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+func (r *FolderRepository) GetFolder(ctx context.Context, folderID string, userID string) (*models.Folder, error) {
+	folder, err := r.GetFolderByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if folder.UserID != userID {
+		return nil, ErrFolderAccessDenied
+	}
+
+	return folder, nil
+}
+
+// GetFolderByID loads a folder without checking who owns it. Callers must
+// check access themselves.
+func (r *FolderRepository) GetFolderByID(ctx context.Context, folderID string) (*models.Folder, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(FolderTable),
+		Key: map[string]types.AttributeValue{
+			"FolderID": &types.AttributeValueMemberS{Value: folderID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for folderID %s: %v", folderID, err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrFolderNotFound
+	}
+
+	var folder models.Folder
+	if err := attributevalue.UnmarshalMap(result.Item, &folder); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &folder, nil
+}
+
+func (r *FolderRepository) ListChildFolders(ctx context.Context, userID string, parentID string) ([]models.Folder, error) {
+	folders := []models.Folder{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(FolderTable),
+		IndexName:              aws.String("UserIDParentIDIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID AND ParentID = :parentID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID":   &types.AttributeValueMemberS{Value: userID},
+			":parentID": &types.AttributeValueMemberS{Value: parentID},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list child folders of %s: %v", parentID, err)
+			return nil, err
+		}
+
+		var items []models.Folder
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal folders: %v", err)
+			return nil, err
+		}
+		folders = append(folders, items...)
+	}
+
+	return folders, nil
+}
+
+func (r *FolderRepository) RenameFolder(ctx context.Context, folderID string, userID string, name string) (*models.Folder, error) {
+	return r.updateFolder(ctx, folderID, userID, "SET #name = :value, UpdatedAt = :now", map[string]string{"#name": "Name"}, name)
+}
+
+func (r *FolderRepository) MoveFolder(ctx context.Context, folderID string, userID string, parentID string) (*models.Folder, error) {
+	return r.updateFolder(ctx, folderID, userID, "SET ParentID = :value, UpdatedAt = :now", nil, parentID)
+}
+
+func (r *FolderRepository) DeleteFolder(ctx context.Context, folderID string, userID string) error {
+	_, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(FolderTable),
+		Key: map[string]types.AttributeValue{
+			"FolderID": &types.AttributeValueMemberS{Value: folderID},
+		},
+		ConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrFolderNotFound
+		}
+		log.Printf("DeleteItem error for folderID %s: %v", folderID, err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *FolderRepository) updateFolder(ctx context.Context, folderID string, userID string, updateExpression string, names map[string]string, value string) (*models.Folder, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(FolderTable),
+		Key: map[string]types.AttributeValue{
+			"FolderID": &types.AttributeValueMemberS{Value: folderID},
+		},
+		UpdateExpression:    aws.String(updateExpression),
+		ConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value":  &types.AttributeValueMemberS{Value: value},
+			":now":    &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+
+	result, err := r.dynamoService.Client.UpdateItem(ctx, input)
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, ErrFolderNotFound
+		}
+		log.Printf("UpdateItem error for folderID %s: %v", folderID, err)
+		return nil, err
+	}
+
+	var folder models.Folder
+	if err := attributevalue.UnmarshalMap(result.Attributes, &folder); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &folder, nil
+}
@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const AccessGrantTable = "access_grant"
+
+var ErrGrantNotFound = errors.New("grant not found")
+
+type GrantRepository struct {
+	dynamoService *config.DynamoDBService
+}
+
+func NewGrantRepository(dynamoService *config.DynamoDBService) *GrantRepository {
+	return &GrantRepository{
+		dynamoService: dynamoService,
+	}
+}
+
+// PutGrant creates a grant or changes the role of an existing one, keeping its
+// CreatedAt. It returns the previous grant, or nil if there was none.
+func (r *GrantRepository) PutGrant(ctx context.Context, grant *models.Grant) (*models.Grant, error) {
+	now := grant.UpdatedAt.Format(time.RFC3339Nano)
+
+	result, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(AccessGrantTable),
+		Key: map[string]types.AttributeValue{
+			"ResourceID": &types.AttributeValueMemberS{Value: grant.ResourceID},
+			"GranteeID":  &types.AttributeValueMemberS{Value: grant.GranteeID},
+		},
+		UpdateExpression: aws.String("SET #role = :role, GranteeEmail = :granteeEmail, ResourceType = :resourceType, " +
+			"OwnerID = :ownerID, UpdatedAt = :now, CreatedAt = if_not_exists(CreatedAt, :now)"),
+		ExpressionAttributeNames: map[string]string{
+			"#role": "Role",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":role":         &types.AttributeValueMemberS{Value: grant.Role},
+			":granteeEmail": &types.AttributeValueMemberS{Value: grant.GranteeEmail},
+			":resourceType": &types.AttributeValueMemberS{Value: grant.ResourceType},
+			":ownerID":      &types.AttributeValueMemberS{Value: grant.OwnerID},
+			":now":          &types.AttributeValueMemberS{Value: now},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+
+	if err != nil {
+		log.Printf("Failed to save grant on %s: %v", grant.ResourceID, err)
+		return nil, err
+	}
+
+	if len(result.Attributes) == 0 {
+		return nil, nil
+	}
+
+	var previous models.Grant
+	if err := attributevalue.UnmarshalMap(result.Attributes, &previous); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	grant.CreatedAt = previous.CreatedAt
+	return &previous, nil
+}
+
+func (r *GrantRepository) GetGrant(ctx context.Context, resourceID string, granteeID string) (*models.Grant, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(AccessGrantTable),
+		Key: map[string]types.AttributeValue{
+			"ResourceID": &types.AttributeValueMemberS{Value: resourceID},
+			"GranteeID":  &types.AttributeValueMemberS{Value: granteeID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for grant on %s: %v", resourceID, err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrGrantNotFound
+	}
+
+	var grant models.Grant
+	if err := attributevalue.UnmarshalMap(result.Item, &grant); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &grant, nil
+}
+
+func (r *GrantRepository) ListGrants(ctx context.Context, resourceID string) ([]models.Grant, error) {
+	return r.queryGrants(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(AccessGrantTable),
+		KeyConditionExpression: aws.String("ResourceID = :resourceID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":resourceID": &types.AttributeValueMemberS{Value: resourceID},
+		},
+	})
+}
+
+// ListGrantsForGrantee returns everything shared with a user, newest first.
+func (r *GrantRepository) ListGrantsForGrantee(ctx context.Context, granteeID string) ([]models.Grant, error) {
+	return r.queryGrants(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(AccessGrantTable),
+		IndexName:              aws.String("GranteeIDCreatedAtIndex"),
+		KeyConditionExpression: aws.String("GranteeID = :granteeID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":granteeID": &types.AttributeValueMemberS{Value: granteeID},
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+}
+
+// DeleteGrant removes a grant and returns it.
+func (r *GrantRepository) DeleteGrant(ctx context.Context, resourceID string, granteeID string) (*models.Grant, error) {
+	result, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(AccessGrantTable),
+		Key: map[string]types.AttributeValue{
+			"ResourceID": &types.AttributeValueMemberS{Value: resourceID},
+			"GranteeID":  &types.AttributeValueMemberS{Value: granteeID},
+		},
+		ConditionExpression: aws.String("attribute_exists(ResourceID)"),
+		ReturnValues:        types.ReturnValueAllOld,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, ErrGrantNotFound
+		}
+		log.Printf("DeleteItem error for grant on %s: %v", resourceID, err)
+		return nil, err
+	}
+
+	var grant models.Grant
+	if err := attributevalue.UnmarshalMap(result.Attributes, &grant); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &grant, nil
+}
+
+func (r *GrantRepository) queryGrants(ctx context.Context, input *dynamodb.QueryInput) ([]models.Grant, error) {
+	grants := []models.Grant{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to query grants: %v", err)
+			return nil, err
+		}
+
+		var items []models.Grant
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal grants: %v", err)
+			return nil, err
+		}
+		grants = append(grants, items...)
+	}
+
+	return grants, nil
+}
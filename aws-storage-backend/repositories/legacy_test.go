@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/testdb"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// Tables written by older releases hold items later code no longer writes,
+// such as files without a Status. Only DynamoDB has such tables.
+
+func TestRebuildUsedBytesCountsLegacyFiles(t *testing.T) {
+	ctx := context.Background()
+	service := testdb.New(t)
+	userRepo := NewUserRepository(service)
+
+	if _, err := userRepo.CreateUser(ctx, &models.User{UserID: "user-1", UserName: "user-1", UserEmail: "user-1@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	files := []models.StorageObject{
+		{UserID: "user-1", FileSize: 100, Status: models.StatusActive},
+		{UserID: "user-1", FileSize: 7},
+	}
+	for i, file := range files {
+		file.ObjectID = fmt.Sprintf("object-%d", i)
+		file.ParentID = models.RootFolderID
+		file.FileName = file.ObjectID
+		file.UploadedAt = time.Now()
+		item, err := attributevalue.MarshalMap(file)
+		if err != nil {
+			t.Fatalf("MarshalMap: %v", err)
+		}
+		if _, err := service.Client.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String(StorageTable), Item: item}); err != nil {
+			t.Fatalf("PutItem: %v", err)
+		}
+	}
+
+	if _, err := userRepo.RebuildUsedBytes(ctx); err != nil {
+		t.Fatalf("RebuildUsedBytes: %v", err)
+	}
+
+	user, err := userRepo.GetUserByID(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.UsedBytes != 107 {
+		t.Errorf("UsedBytes = %d, want 107", user.UsedBytes)
+	}
+}
@@ -0,0 +1,253 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const MultipartUploadTable = "multipart_upload"
+
+var ErrMultipartUploadNotFound = errors.New("multipart upload not found")
+
+func (r *StorageRepository) CreateMultipartUpload(ctx context.Context, storageObj *models.StorageObject, partSize int64, partCount int32) (*models.MultipartUpload, error) {
+	uploadID, err := r.blobStore.CreateMultipartUpload(ctx, storageObj.S3Key, storageObj.ContentType)
+	if err != nil {
+		log.Printf("Failed to create multipart upload for %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	upload := &models.MultipartUpload{
+		ObjectID:  storageObj.ObjectID,
+		UserID:    storageObj.UserID,
+		UploadID:  uploadID,
+		FileSize:  storageObj.FileSize,
+		PartSize:  partSize,
+		PartCount: partCount,
+		Parts:     map[string]models.UploadedPart{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	item, err := attributevalue.MarshalMap(upload)
+	if err != nil {
+		log.Printf("Failed to marshal multipart upload: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(MultipartUploadTable),
+		Item:      item,
+	})
+
+	if err != nil {
+		log.Printf("Failed to save multipart upload to DynamoDB: %v", err)
+		r.blobStore.AbortMultipartUpload(ctx, storageObj.S3Key, upload.UploadID)
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (r *StorageRepository) GetMultipartUpload(ctx context.Context, objectID string) (*models.MultipartUpload, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(MultipartUploadTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID": &types.AttributeValueMemberS{Value: objectID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for multipart upload %s: %v", objectID, err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrMultipartUploadNotFound
+	}
+
+	var upload models.MultipartUpload
+	if err := attributevalue.UnmarshalMap(result.Item, &upload); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+func (r *fileBlobs) PresignUploadPart(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, partNumber int32, partSize int64, expiresIn time.Duration) (string, error) {
+	partURL, err := r.blobStore.PresignUploadPart(ctx, storageObj.S3Key, upload.UploadID, partNumber, partSize, expiresIn)
+	if err != nil {
+		log.Printf("Failed to presign part %d of %s: %v", partNumber, storageObj.ObjectID, err)
+		return "", err
+	}
+
+	return partURL, nil
+}
+
+// UploadPart streams one part through the server to the blob store and
+// records it.
+func (r *StorageRepository) UploadPart(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, partNumber int32, partSize int64, body io.Reader, expiresAt time.Time) (*models.UploadedPart, error) {
+	stored, err := r.blobStore.UploadPart(ctx, storageObj.S3Key, upload.UploadID, partNumber, partSize, body)
+	if err != nil {
+		log.Printf("Failed to upload part %d of %s: %v", partNumber, storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	part := &models.UploadedPart{
+		PartNumber: partNumber,
+		ETag:       stored.ETag,
+		Size:       partSize,
+		UploadedAt: time.Now().UTC(),
+	}
+
+	if err := r.RecordPart(ctx, storageObj, part, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return part, nil
+}
+
+// RecordPart stores an acknowledged part and pushes the session expiry back,
+// in one transaction so a resumed client never sees progress without the
+// extension or the other way round.
+func (r *StorageRepository) RecordPart(ctx context.Context, storageObj *models.StorageObject, part *models.UploadedPart, expiresAt time.Time) error {
+	partItem, err := attributevalue.Marshal(part)
+	if err != nil {
+		log.Printf("Failed to marshal uploaded part: %v", err)
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	_, err = r.dynamoService.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName: aws.String(MultipartUploadTable),
+					Key: map[string]types.AttributeValue{
+						"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+					},
+					UpdateExpression:         aws.String("SET Parts.#part = :part, UpdatedAt = :now"),
+					ConditionExpression:      aws.String("attribute_exists(ObjectID)"),
+					ExpressionAttributeNames: map[string]string{"#part": strconv.Itoa(int(part.PartNumber))},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":part": partItem,
+						":now":  &types.AttributeValueMemberS{Value: now},
+					},
+				},
+			},
+			{
+				Update: &types.Update{
+					TableName: aws.String(StorageTable),
+					Key: map[string]types.AttributeValue{
+						"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+					},
+					UpdateExpression:         aws.String("SET ExpiresAt = :expiresAt, UpdatedAt = :now"),
+					ConditionExpression:      aws.String("#status = :pending"),
+					ExpressionAttributeNames: statusAttributeNames,
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+						":pending":   &types.AttributeValueMemberS{Value: models.StatusPending},
+						":now":       &types.AttributeValueMemberS{Value: now},
+					},
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) {
+			return ErrUploadSessionNotPending
+		}
+		log.Printf("Failed to record part %d of %s: %v", part.PartNumber, storageObj.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListUploadedParts asks the blob store which parts it actually holds. It is
+// the source of truth when completing, since presigned part uploads may never
+// have been acknowledged.
+func (r *fileBlobs) ListUploadedParts(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload) ([]blobstore.Part, error) {
+	parts, err := r.blobStore.ListParts(ctx, storageObj.S3Key, upload.UploadID)
+	if err != nil {
+		log.Printf("Failed to list parts of %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+func (r *StorageRepository) CompleteMultipartUpload(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, parts []blobstore.Part) error {
+	err := r.blobStore.CompleteMultipartUpload(ctx, storageObj.S3Key, upload.UploadID, parts)
+	if err != nil {
+		log.Printf("Failed to complete multipart upload of %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteMultipartUploadItem(ctx, upload.ObjectID)
+}
+
+// AbortMultipartUpload releases the parts the blob store is holding and
+// forgets the session. The pending StorageObject is left for the caller to
+// remove.
+func (r *StorageRepository) AbortMultipartUpload(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload) error {
+	if err := r.blobStore.AbortMultipartUpload(ctx, storageObj.S3Key, upload.UploadID); err != nil {
+		return err
+	}
+
+	return r.deleteMultipartUploadItem(ctx, upload.ObjectID)
+}
+
+// ListStaleMultipartUploads returns multipart uploads under users/ that the
+// blob store has been holding since before the given time.
+func (r *fileBlobs) ListStaleMultipartUploads(ctx context.Context, initiatedBefore time.Time) ([]blobstore.MultipartUpload, error) {
+	uploads, err := r.blobStore.ListMultipartUploads(ctx, "users/")
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []blobstore.MultipartUpload
+	for _, upload := range uploads {
+		if upload.Initiated.Before(initiatedBefore) {
+			stale = append(stale, upload)
+		}
+	}
+
+	return stale, nil
+}
+
+func (r *fileBlobs) AbortStaleMultipartUpload(ctx context.Context, upload blobstore.MultipartUpload) error {
+	return r.blobStore.AbortMultipartUpload(ctx, upload.Key, upload.UploadID)
+}
+
+func (r *StorageRepository) deleteMultipartUploadItem(ctx context.Context, objectID string) error {
+	_, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(MultipartUploadTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID": &types.AttributeValueMemberS{Value: objectID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("DeleteItem error for multipart upload %s: %v", objectID, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+const NotificationTable = "notification"
+
+// notificationIDLayout is fixed width so that IDs sort by creation time.
+const notificationIDLayout = "20060102T150405.000000000Z"
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+type NotificationRepository struct {
+	dynamoService *config.DynamoDBService
+}
+
+func NewNotificationRepository(dynamoService *config.DynamoDBService) *NotificationRepository {
+	return &NotificationRepository{
+		dynamoService: dynamoService,
+	}
+}
+
+func (r *NotificationRepository) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	notification.NotificationID = notification.CreatedAt.UTC().Format(notificationIDLayout) + "-" + uuid.New().String()
+
+	item, err := attributevalue.MarshalMap(notification)
+	if err != nil {
+		log.Printf("Failed to marshal notification: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(NotificationTable),
+		Item:      item,
+	})
+
+	if err != nil {
+		log.Printf("Failed to save notification for user %s: %v", notification.UserID, err)
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// ListNotifications returns up to limit notifications, newest first.
+func (r *NotificationRepository) ListNotifications(ctx context.Context, userID string, limit int32, unreadOnly bool) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(NotificationTable),
+		KeyConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	if unreadOnly {
+		input.FilterExpression = aws.String("#read = :false")
+		input.ExpressionAttributeNames = map[string]string{"#read": "Read"}
+		input.ExpressionAttributeValues[":false"] = &types.AttributeValueMemberBOOL{Value: false}
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, input)
+	for paginator.HasMorePages() && int32(len(notifications)) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list notifications of user %s: %v", userID, err)
+			return nil, err
+		}
+
+		var items []models.Notification
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal notifications: %v", err)
+			return nil, err
+		}
+		notifications = append(notifications, items...)
+	}
+
+	if int32(len(notifications)) > limit {
+		notifications = notifications[:limit]
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID string, notificationID string) error {
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(NotificationTable),
+		Key: map[string]types.AttributeValue{
+			"UserID":         &types.AttributeValueMemberS{Value: userID},
+			"NotificationID": &types.AttributeValueMemberS{Value: notificationID},
+		},
+		UpdateExpression:         aws.String("SET #read = :true"),
+		ConditionExpression:      aws.String("attribute_exists(NotificationID)"),
+		ExpressionAttributeNames: map[string]string{"#read": "Read"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrNotificationNotFound
+		}
+		log.Printf("UpdateItem error for notification %s: %v", notificationID, err)
+		return err
+	}
+
+	return nil
+}
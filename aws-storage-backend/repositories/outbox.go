@@ -0,0 +1,354 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+const OutboxTable = "outbox"
+
+const (
+	// outboxLease is how long an entry is left to whoever holds it: the
+	// request that wrote it, or the worker once it has taken it over. A
+	// request still streaming the upload renews the lease every
+	// outboxRenewInterval, so only entries of requests that are gone or stuck
+	// fall to the worker.
+	outboxLease         = 5 * time.Minute
+	outboxRenewInterval = time.Minute
+	// maxOutboxBackoff caps how long a failing entry waits between attempts.
+	maxOutboxBackoff = time.Hour
+)
+
+var ErrOutboxPending = errors.New("the change could not be finished right away and will be completed in the background")
+
+// ErrUploadRolledBack means the outbox worker took an upload over from its
+// request and rolled it back before the request could finish it. The worker
+// gives the quota back.
+var ErrUploadRolledBack = errors.New("the upload took too long and was cancelled; upload the file again")
+
+// errOutboxEntryTaken means someone else moved the entry's lease first.
+var errOutboxEntryTaken = errors.New("outbox entry is held by someone else")
+
+func newOutboxEntry(kind string, storageObj *models.StorageObject) *models.OutboxEntry {
+	now := time.Now().UTC()
+	return &models.OutboxEntry{
+		OutboxID:  uuid.New().String(),
+		Kind:      kind,
+		ObjectID:  storageObj.ObjectID,
+		UserID:    storageObj.UserID,
+		FileSize:  storageObj.FileSize,
+		BlobKey:   storageObj.BlobKey,
+		CreatedAt: now,
+		DueAt:     now.Add(outboxLease).Unix(),
+	}
+}
+
+func outboxPutWrite(entry *models.OutboxEntry) (types.TransactWriteItem, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		log.Printf("Failed to marshal outbox entry: %v", err)
+		return types.TransactWriteItem{}, err
+	}
+
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName: aws.String(OutboxTable),
+			Item:      item,
+		},
+	}, nil
+}
+
+func outboxDeleteWrite(outboxID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String(OutboxTable),
+			Key: map[string]types.AttributeValue{
+				"OutboxID": &types.AttributeValueMemberS{Value: outboxID},
+			},
+		},
+	}
+}
+
+func (r *StorageRepository) deleteOutboxEntry(ctx context.Context, outboxID string) error {
+	_, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(OutboxTable),
+		Key: map[string]types.AttributeValue{
+			"OutboxID": &types.AttributeValueMemberS{Value: outboxID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("Failed to delete outbox entry %s: %v", outboxID, err)
+		return err
+	}
+
+	return nil
+}
+
+// moveOutboxEntry moves the lease of an entry from dueAt to next, provided
+// nobody moved it since dueAt was read. Whoever moves it holds it.
+func (r *StorageRepository) moveOutboxEntry(ctx context.Context, outboxID string, dueAt int64, next int64) error {
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(OutboxTable),
+		Key: map[string]types.AttributeValue{
+			"OutboxID": &types.AttributeValueMemberS{Value: outboxID},
+		},
+		UpdateExpression:    aws.String("SET DueAt = :next"),
+		ConditionExpression: aws.String("DueAt = :dueAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":dueAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(dueAt, 10)},
+			":next":  &types.AttributeValueMemberN{Value: strconv.FormatInt(next, 10)},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return errOutboxEntryTaken
+		}
+		log.Printf("Failed to move outbox entry %s: %v", outboxID, err)
+		return err
+	}
+
+	return nil
+}
+
+// holdOutboxEntry renews the lease of the request's own entry with move until
+// the returned func is called. Once the worker has taken the entry over it
+// stops renewing; the request then finds out when it tries to finish.
+func holdOutboxEntry(ctx context.Context, entry *models.OutboxEntry, move func(ctx context.Context, outboxID string, dueAt int64, next int64) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(outboxRenewInterval)
+		defer ticker.Stop()
+
+		dueAt := entry.DueAt
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			next := time.Now().Add(outboxLease).Unix()
+			if err := move(ctx, entry.OutboxID, dueAt, next); err != nil {
+				if errors.Is(err, errOutboxEntryTaken) {
+					log.Printf("Outbox worker took over the upload of file %s", entry.ObjectID)
+				}
+				return
+			}
+			dueAt = next
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// ListDueOutboxEntries returns the entries whose request should have finished
+// by now. The outbox only holds work in flight, so it is scanned.
+func (r *StorageRepository) ListDueOutboxEntries(ctx context.Context, now time.Time) ([]models.OutboxEntry, error) {
+	entries := []models.OutboxEntry{}
+
+	paginator := dynamodb.NewScanPaginator(r.dynamoService.Client, &dynamodb.ScanInput{
+		TableName:        aws.String(OutboxTable),
+		FilterExpression: aws.String("DueAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan outbox: %v", err)
+			return nil, err
+		}
+
+		var items []models.OutboxEntry
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal outbox entries: %v", err)
+			return nil, err
+		}
+		entries = append(entries, items...)
+	}
+
+	return entries, nil
+}
+
+// outboxBackoff is how long an entry waits after its attempt number
+// attempts failed: twice as long after every attempt, up to maxOutboxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts >= 6 {
+		return maxOutboxBackoff
+	}
+	return min(time.Minute<<attempts, maxOutboxBackoff)
+}
+
+// RescheduleOutboxEntry pushes a failed entry back by outboxBackoff.
+func (r *StorageRepository) RescheduleOutboxEntry(ctx context.Context, entry *models.OutboxEntry, cause error) error {
+	backoff := outboxBackoff(entry.Attempts)
+
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(OutboxTable),
+		Key: map[string]types.AttributeValue{
+			"OutboxID": &types.AttributeValueMemberS{Value: entry.OutboxID},
+		},
+		UpdateExpression:    aws.String("SET DueAt = :dueAt, LastError = :lastError ADD Attempts :one"),
+		ConditionExpression: aws.String("attribute_exists(OutboxID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":dueAt":     &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(backoff).Unix(), 10)},
+			":lastError": &types.AttributeValueMemberS{Value: cause.Error()},
+			":one":       &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+		log.Printf("Failed to reschedule outbox entry %s: %v", entry.OutboxID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ResumeOutboxEntry takes an upload, replacement or permanent delete from
+// wherever its request left it to the end. It reports whether the change was finalized,
+// rolled back, had already been dealt with, or is still held by its request.
+func (r *StorageRepository) ResumeOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (string, error) {
+	// Take the entry over, unless its request renewed the lease since the
+	// entry was listed.
+	err := r.moveOutboxEntry(ctx, entry.OutboxID, entry.DueAt, time.Now().Add(outboxLease).Unix())
+	if errors.Is(err, errOutboxEntryTaken) {
+		return models.OutboxHeld, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	storageObj, err := r.GetFileByID(ctx, entry.ObjectID)
+	if err != nil && !errors.Is(err, ErrFileNotFound) {
+		return "", err
+	}
+
+	switch entry.Kind {
+	case models.OutboxUpload:
+		return r.resumeUpload(ctx, entry, storageObj)
+	case models.OutboxPurge:
+		return r.resumePurge(ctx, entry, storageObj)
+	case models.OutboxReplace:
+		return r.resumeReplace(ctx, entry, storageObj)
+	default:
+		log.Printf("Dropping outbox entry %s of unknown kind %q", entry.OutboxID, entry.Kind)
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+}
+
+// resumeUpload finalizes the upload if its bytes made it to S3 and rolls it
+// back otherwise. The client that sent them is long gone, so nothing can be
+// uploaded again.
+func (r *StorageRepository) resumeUpload(ctx context.Context, entry *models.OutboxEntry, storageObj *models.StorageObject) (string, error) {
+	if storageObj == nil || storageObj.Status != models.StatusUploading {
+		if entry.BlobKey != "" {
+			if err := r.deleteBlobIfUnused(ctx, entry.BlobKey); err != nil {
+				return "", err
+			}
+		}
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+
+	blob, err := r.getBlob(ctx, storageObj.BlobKey)
+	if err != nil {
+		return "", err
+	}
+
+	if blob != nil && blob.VersionID != "" {
+		if _, err := r.finishUpload(ctx, storageObj, blob, entry.OutboxID); err != nil {
+			return "", err
+		}
+		return models.OutboxFinalized, nil
+	}
+
+	if err := r.rollbackUpload(ctx, storageObj, entry.OutboxID); err != nil {
+		return "", err
+	}
+	return models.OutboxRolledBack, nil
+}
+
+// resumeReplace rolls a replacement back. Its request may have written the
+// new bytes, but it is gone and took their checksums with it, so the file
+// keeps the content it points at. A version written on top of what the
+// replacement started from is deleted, which makes that current again.
+func (r *StorageRepository) resumeReplace(ctx context.Context, entry *models.OutboxEntry, storageObj *models.StorageObject) (string, error) {
+	if storageObj == nil || storageObj.ReplaceID != entry.OutboxID {
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+
+	if err := r.rollbackReplace(ctx, storageObj, entry, ""); err != nil {
+		if errors.Is(err, ErrUploadRolledBack) {
+			return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+		}
+		return "", err
+	}
+	return models.OutboxRolledBack, nil
+}
+
+// replacedBy reports whether current is a version written after the content a
+// replacement started from. Files recorded before versions and ETags were
+// have nothing to compare with and are left as they are.
+func replacedBy(entry *models.OutboxEntry, current *blobstore.ObjectInfo) bool {
+	switch {
+	case current.VersionID == "":
+		return false
+	case entry.VersionID != "":
+		return current.VersionID != entry.VersionID
+	case entry.ETag != "":
+		return current.ETag != entry.ETag
+	default:
+		return false
+	}
+}
+
+// resumePurge finishes a permanent delete. A missing record means the request
+// got as far as deleting it, but not as far as cleaning up after it.
+func (r *StorageRepository) resumePurge(ctx context.Context, entry *models.OutboxEntry, storageObj *models.StorageObject) (string, error) {
+	switch {
+	case storageObj == nil:
+		if entry.BlobKey != "" {
+			if err := r.deleteBlobIfUnused(ctx, entry.BlobKey); err != nil {
+				return "", err
+			}
+		}
+		if err := r.deleteOutboxEntry(ctx, entry.OutboxID); err != nil {
+			return "", err
+		}
+		return models.OutboxFinalized, nil
+	case storageObj.Status == models.StatusDeleting:
+		if err := r.finishPurge(ctx, storageObj, entry.OutboxID); err != nil {
+			return "", err
+		}
+		return models.OutboxFinalized, nil
+	default:
+		return models.OutboxDiscarded, r.deleteOutboxEntry(ctx, entry.OutboxID)
+	}
+}
@@ -0,0 +1,276 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/testdb"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// stalledStore holds every Put until release is closed, like a request still
+// streaming a large upload. With stored set the bytes are stored before the
+// Put stalls, like a request that stopped before it could record them. With
+// copies set it holds every Copy instead, like a request moving a large
+// upload into its blob.
+type stalledStore struct {
+	blobstore.BlobStore
+	started chan struct{}
+	release chan struct{}
+	stored  bool
+	copies  bool
+}
+
+func (s *stalledStore) Copy(ctx context.Context, srcKey string, srcVersionID string, dstKey string) (*blobstore.ObjectInfo, error) {
+	if s.copies {
+		close(s.started)
+		<-s.release
+	}
+	return s.BlobStore.Copy(ctx, srcKey, srcVersionID, dstKey)
+}
+
+func (s *stalledStore) Put(ctx context.Context, key string, body io.Reader, options blobstore.PutOptions) (*blobstore.ObjectInfo, error) {
+	if s.copies {
+		return s.BlobStore.Put(ctx, key, body, options)
+	}
+	if s.stored {
+		info, err := s.BlobStore.Put(ctx, key, body, options)
+		close(s.started)
+		<-s.release
+		return info, err
+	}
+
+	close(s.started)
+	<-s.release
+	return s.BlobStore.Put(ctx, key, body, options)
+}
+
+// dueEntries returns the outbox entries as the worker sees them once every
+// lease has run out.
+func dueEntries(t *testing.T, storageRepo *StorageRepository) []models.OutboxEntry {
+	t.Helper()
+
+	entries, err := storageRepo.ListDueOutboxEntries(context.Background(), time.Now().Add(outboxLease+time.Minute))
+	if err != nil {
+		t.Fatalf("ListDueOutboxEntries: %v", err)
+	}
+	return entries
+}
+
+func TestResumeOutboxEntryRespectsLease(t *testing.T) {
+	tests := []struct {
+		name        string
+		renewed     bool
+		wantOutcome string
+		wantFile    bool
+	}{
+		{name: "lease ran out", wantOutcome: models.OutboxRolledBack},
+		{name: "renewed since listed", renewed: true, wantOutcome: models.OutboxHeld, wantFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := &stalledStore{BlobStore: blobstore.NewMemoryStore(nil), started: make(chan struct{}), release: make(chan struct{}), copies: true}
+			storageRepo := NewStorageRepository(testdb.New(t), store, config.Defaults())
+
+			uploaded := make(chan error, 1)
+			go func() {
+				_, err := storageRepo.UploadFile(ctx, "user-1", models.RootFolderID, "a.txt", 5, "text/plain", strings.NewReader("hello"), nil)
+				uploaded <- err
+			}()
+			<-store.started
+
+			entries := dueEntries(t, storageRepo)
+			if len(entries) != 1 {
+				t.Fatalf("got %d outbox entries, want 1", len(entries))
+			}
+			entry := entries[0]
+
+			if tt.renewed {
+				if err := storageRepo.moveOutboxEntry(ctx, entry.OutboxID, entry.DueAt, entry.DueAt+60); err != nil {
+					t.Fatalf("moveOutboxEntry: %v", err)
+				}
+			}
+
+			outcome, err := storageRepo.ResumeOutboxEntry(ctx, &entry)
+			if err != nil {
+				t.Fatalf("ResumeOutboxEntry: %v", err)
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("outcome = %q, want %q", outcome, tt.wantOutcome)
+			}
+
+			close(store.release)
+			err = <-uploaded
+
+			_, getErr := storageRepo.GetFileByID(ctx, entry.ObjectID)
+			if tt.wantFile {
+				if err != nil || getErr != nil {
+					t.Fatalf("upload: %v, file: %v; want the request to finish the upload", err, getErr)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrUploadRolledBack) {
+				t.Errorf("upload returned %v, want ErrUploadRolledBack", err)
+			}
+			if !errors.Is(getErr, ErrFileNotFound) {
+				t.Errorf("GetFileByID after rollback: %v", getErr)
+			}
+			if objects, err := store.List(ctx, ""); err != nil || len(objects) != 0 {
+				t.Errorf("blob store holds %v (%v), want the bytes of the rolled back upload gone", objects, err)
+			}
+			if blob, err := storageRepo.getBlob(ctx, entry.BlobKey); err != nil || blob != nil {
+				t.Errorf("blob record %+v (%v) survived the rollback", blob, err)
+			}
+			if entries := dueEntries(t, storageRepo); len(entries) != 0 {
+				t.Errorf("outbox still holds %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestResumeOutboxEntryFinishesArrivedUpload(t *testing.T) {
+	ctx := context.Background()
+	storageRepo := NewStorageRepository(testdb.New(t), blobstore.NewMemoryStore(nil), config.Defaults())
+
+	// What activateUpload leaves behind when its request dies after the
+	// bytes were copied into the blob but before the file was activated.
+	digest, _, err := digestReader(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("digestReader: %v", err)
+	}
+	uploaded, err := storageRepo.blobStore.Put(ctx, "users/user-1/object-1", strings.NewReader("hello"), blobstore.PutOptions{ContentType: "text/plain"})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	storageObj := &models.StorageObject{
+		ObjectID:    "object-1",
+		UserID:      "user-1",
+		ParentID:    models.RootFolderID,
+		FileName:    "a.txt",
+		FileSize:    5,
+		ContentType: "text/plain",
+		ContentHash: digest.hash,
+		BlobKey:     storageRepo.blobKey("user-1", digest.hash),
+		UploadedAt:  time.Now().UTC(),
+		Status:      models.StatusUploading,
+	}
+	item, err := attributevalue.MarshalMap(storageObj)
+	if err != nil {
+		t.Fatalf("MarshalMap: %v", err)
+	}
+	entry := newOutboxEntry(models.OutboxUpload, storageObj)
+	entryWrite, err := outboxPutWrite(entry)
+	if err != nil {
+		t.Fatalf("outboxPutWrite: %v", err)
+	}
+	_, err = storageRepo.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Put: &types.Put{TableName: aws.String(StorageTable), Item: item},
+	}, usageChanges{}, acquireBlobWrite(storageObj.BlobKey), entryWrite)
+	if err != nil {
+		t.Fatalf("writeWithUsage: %v", err)
+	}
+	if _, err := storageRepo.copyBlob(ctx, uploaded.Key, uploaded.VersionID, storageObj.BlobKey, storageObj.FileSize, digest); err != nil {
+		t.Fatalf("copyBlob: %v", err)
+	}
+
+	entries := dueEntries(t, storageRepo)
+	if len(entries) != 1 {
+		t.Fatalf("got %d outbox entries, want 1", len(entries))
+	}
+	outcome, err := storageRepo.ResumeOutboxEntry(ctx, &entries[0])
+	if err != nil || outcome != models.OutboxFinalized {
+		t.Fatalf("ResumeOutboxEntry: %q, %v; want %q", outcome, err, models.OutboxFinalized)
+	}
+
+	file, err := storageRepo.GetFileByID(ctx, storageObj.ObjectID)
+	if err != nil || !file.IsActive() {
+		t.Fatalf("GetFileByID: %+v, %v; want the file active", file, err)
+	}
+	if entries := dueEntries(t, storageRepo); len(entries) != 0 {
+		t.Errorf("outbox still holds %d entries", len(entries))
+	}
+}
+
+func TestResumeOutboxEntryRollsBackReplacement(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored bool
+	}{
+		{name: "before the bytes arrived"},
+		{name: "after the bytes arrived", stored: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := testdb.New(t)
+			memory := blobstore.NewMemoryStore(nil)
+			storageRepo := NewStorageRepository(db, memory, config.Defaults())
+
+			uploaded, err := storageRepo.UploadFile(ctx, "user-1", models.RootFolderID, "a.txt", 5, "text/plain", strings.NewReader("hello"), nil)
+			if err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			storageObj, err := storageRepo.DetachBlob(ctx, uploaded)
+			if err != nil {
+				t.Fatalf("DetachBlob: %v", err)
+			}
+
+			store := &stalledStore{BlobStore: memory, started: make(chan struct{}), release: make(chan struct{}), stored: tt.stored}
+			stalledRepo := NewStorageRepository(db, store, config.Defaults())
+
+			replaced := make(chan error, 1)
+			go func() {
+				_, err := stalledRepo.ReplaceFileContent(ctx, storageObj, "text/plain", 12, strings.NewReader("hello, world"))
+				replaced <- err
+			}()
+			<-store.started
+
+			claimed, err := storageRepo.GetFileByID(ctx, storageObj.ObjectID)
+			if err != nil || !claimed.IsReplacing() || claimed.Version != storageObj.Version+1 {
+				t.Fatalf("file during the replacement: %+v, %v; want it claimed at the next version", claimed, err)
+			}
+
+			entries := dueEntries(t, storageRepo)
+			if len(entries) != 1 || entries[0].Kind != models.OutboxReplace {
+				t.Fatalf("got outbox entries %+v, want one replacement", entries)
+			}
+			outcome, err := storageRepo.ResumeOutboxEntry(ctx, &entries[0])
+			if err != nil || outcome != models.OutboxRolledBack {
+				t.Fatalf("ResumeOutboxEntry: %q, %v; want %q", outcome, err, models.OutboxRolledBack)
+			}
+
+			close(store.release)
+			if err := <-replaced; !errors.Is(err, ErrUploadRolledBack) {
+				t.Errorf("replacement returned %v, want ErrUploadRolledBack", err)
+			}
+
+			file, err := storageRepo.GetFileByID(ctx, storageObj.ObjectID)
+			if err != nil {
+				t.Fatalf("GetFileByID: %v", err)
+			}
+			if file.IsReplacing() || file.FileSize != 5 || file.ETag != storageObj.ETag {
+				t.Errorf("file after the rollback: %+v, want it back at its old content", file)
+			}
+			current, err := memory.Head(ctx, file.S3Key, "")
+			if err != nil || current.VersionID != file.VersionID {
+				t.Errorf("current stored version %+v (%v), want %s", current, err, file.VersionID)
+			}
+			if entries := dueEntries(t, storageRepo); len(entries) != 0 {
+				t.Errorf("outbox still holds %d entries", len(entries))
+			}
+		})
+	}
+}
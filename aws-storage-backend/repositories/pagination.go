@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrInvalidNextToken = errors.New("invalid next token")
+
+// pageToken is the JSON shape behind the opaque nextToken handed to clients.
+// Only string and number key attributes are used by our tables, so the
+// LastEvaluatedKey is flattened into two maps instead of a full AttributeValue.
+type pageToken struct {
+	Index   string            `json:"i"`
+	Order   string            `json:"o"`
+	Strings map[string]string `json:"s,omitempty"`
+	Numbers map[string]string `json:"n,omitempty"`
+}
+
+func encodeNextToken(indexName string, sortOrder string, lastKey map[string]types.AttributeValue) (*string, error) {
+	if len(lastKey) == 0 {
+		return nil, nil
+	}
+
+	token := &pageToken{
+		Index:   indexName,
+		Order:   sortOrder,
+		Strings: map[string]string{},
+		Numbers: map[string]string{},
+	}
+
+	for name, value := range lastKey {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			token.Strings[name] = v.Value
+		case *types.AttributeValueMemberN:
+			token.Numbers[name] = v.Value
+		default:
+			return nil, errors.New("unsupported key attribute type in LastEvaluatedKey")
+		}
+	}
+
+	return token.encode()
+}
+
+func decodeNextToken(indexName string, sortOrder string, encoded string) (map[string]types.AttributeValue, error) {
+	token, err := decodePageToken(indexName, sortOrder, encoded)
+	if token == nil || err != nil {
+		return nil, err
+	}
+
+	startKey := make(map[string]types.AttributeValue, len(token.Strings)+len(token.Numbers))
+	for name, value := range token.Strings {
+		startKey[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	for name, value := range token.Numbers {
+		startKey[name] = &types.AttributeValueMemberN{Value: value}
+	}
+
+	return startKey, nil
+}
+
+func (t *pageToken) encode() (*string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return &encoded, nil
+}
+
+// decodePageToken returns nil for an empty token, and ErrInvalidNextToken for
+// one that was not produced by a listing of indexName in sortOrder.
+func decodePageToken(indexName string, sortOrder string, encoded string) (*pageToken, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidNextToken
+	}
+
+	var token pageToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, ErrInvalidNextToken
+	}
+
+	// A token is only valid for the index it was produced from, otherwise
+	// DynamoDB rejects the ExclusiveStartKey, and for the order it was read
+	// in, otherwise the next page silently skips everything before the key.
+	if token.Index != indexName || token.Order != sortOrder || len(token.Strings)+len(token.Numbers) == 0 {
+		return nil, ErrInvalidNextToken
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+func TestNextToken(t *testing.T) {
+	lastKey := map[string]types.AttributeValue{
+		"ObjectID": &types.AttributeValueMemberS{Value: "object-1"},
+		"UserID":   &types.AttributeValueMemberS{Value: "user-1"},
+		"FileSize": &types.AttributeValueMemberN{Value: "42"},
+	}
+	token, err := encodeNextToken("UserIDFileSizeIndex", models.SortOrderDesc, lastKey)
+	if err != nil || token == nil {
+		t.Fatalf("encodeNextToken: %v, %v", token, err)
+	}
+
+	tests := []struct {
+		name      string
+		indexName string
+		sortOrder string
+		token     string
+		want      map[string]types.AttributeValue
+		wantErr   bool
+	}{
+		{name: "same listing", indexName: "UserIDFileSizeIndex", sortOrder: models.SortOrderDesc, token: *token, want: lastKey},
+		{name: "no token", indexName: "UserIDFileSizeIndex", sortOrder: models.SortOrderDesc, token: ""},
+		{name: "other order", indexName: "UserIDFileSizeIndex", sortOrder: models.SortOrderAsc, token: *token, wantErr: true},
+		{name: "other index", indexName: "UserIDFileNameIndex", sortOrder: models.SortOrderDesc, token: *token, wantErr: true},
+		{name: "not base64", indexName: "UserIDFileSizeIndex", sortOrder: models.SortOrderDesc, token: "%%%", wantErr: true},
+		{name: "not a token", indexName: "UserIDFileSizeIndex", sortOrder: models.SortOrderDesc, token: "e30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeNextToken(tt.indexName, tt.sortOrder, tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidNextToken) {
+					t.Fatalf("decodeNextToken: %v, want ErrInvalidNextToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeNextToken: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeNextToken = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
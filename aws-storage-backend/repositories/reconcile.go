@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const (
+	userPrefix       = "users/"
+	globalBlobPrefix = "blobs/"
+)
+
+// ListUserPrefixes returns the IDs of the users that have anything stored
+// under users/<id>/.
+func (r *fileBlobs) ListUserPrefixes(ctx context.Context) ([]string, error) {
+	userIDs, err := r.blobStore.ListPrefixes(ctx, userPrefix)
+	if err != nil {
+		log.Printf("Failed to list user prefixes: %v", err)
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// ListUserObjects lists the current objects under users/<userID>/.
+func (r *fileBlobs) ListUserObjects(ctx context.Context, userID string) ([]models.StoredObject, error) {
+	return r.listObjects(ctx, userPrefix+userID+"/")
+}
+
+// ListGlobalBlobObjects lists the blobs shared across users.
+func (r *fileBlobs) ListGlobalBlobObjects(ctx context.Context) ([]models.StoredObject, error) {
+	return r.listObjects(ctx, globalBlobPrefix)
+}
+
+func (r *fileBlobs) listObjects(ctx context.Context, prefix string) ([]models.StoredObject, error) {
+	infos, err := r.blobStore.List(ctx, prefix)
+	if err != nil {
+		log.Printf("Failed to list objects under %s: %v", prefix, err)
+		return nil, err
+	}
+
+	objects := make([]models.StoredObject, 0, len(infos))
+	for _, info := range infos {
+		objects = append(objects, models.StoredObject{
+			Key:          info.Key,
+			ETag:         info.ETag,
+			Size:         info.Size,
+			LastModified: info.LastModified,
+		})
+	}
+
+	return objects, nil
+}
+
+// IsBlobKey reports whether key is where deduplicated content is stored,
+// blobs/<hash> or users/<id>/blobs/<hash>, as opposed to the object of a
+// single file.
+func IsBlobKey(key string) bool {
+	if hash, ok := strings.CutPrefix(key, globalBlobPrefix); ok {
+		return hash != "" && !strings.Contains(hash, "/")
+	}
+
+	rest, ok := strings.CutPrefix(key, userPrefix)
+	if !ok {
+		return false
+	}
+	userID, rest, ok := strings.Cut(rest, "/")
+	if !ok || userID == "" {
+		return false
+	}
+	hash, ok := strings.CutPrefix(rest, "blobs/")
+	return ok && hash != "" && !strings.Contains(hash, "/")
+}
+
+// BlobExists reports whether the blob table has a record for key.
+func (r *StorageRepository) BlobExists(ctx context.Context, key string) (bool, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(BlobTable),
+		Key: map[string]types.AttributeValue{
+			"BlobKey": &types.AttributeValueMemberS{Value: key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for blob %s: %v", key, err)
+		return false, err
+	}
+
+	return result.Item != nil, nil
+}
+
+// ObjectExists reports whether the blob store has a current object under key.
+func (r *fileBlobs) ObjectExists(ctx context.Context, key string) (bool, error) {
+	_, err := r.blobStore.Head(ctx, key, "")
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Head error for %s: %v", key, err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DeleteOrphanObject removes the current version of an object nothing points
+// at, provided it is still the one that was listed; otherwise it returns
+// ErrVersionConflict. Older versions are left alone: each surfaces as the
+// current one and is looked at again by the next run.
+func (r *fileBlobs) DeleteOrphanObject(ctx context.Context, object models.StoredObject) error {
+	current, err := r.blobStore.Head(ctx, object.Key, "")
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Head error for %s: %v", object.Key, err)
+		return err
+	}
+
+	// Listings and heads report times at different precisions.
+	if current.ETag != object.ETag || !current.LastModified.Truncate(time.Second).Equal(object.LastModified.Truncate(time.Second)) {
+		return ErrVersionConflict
+	}
+
+	if err := r.blobStore.Delete(ctx, object.Key, current.VersionID); err != nil {
+		log.Printf("Failed to delete orphaned object %s: %v", object.Key, err)
+		return err
+	}
+	return nil
+}
+
+// RemoveDanglingFile deletes the record of a file whose S3 object is gone,
+// along with its share of the usage totals, any versions left behind and its
+// reference to a blob. It returns ErrFileNotFound if the record changed since
+// it was read.
+func (r *StorageRepository) RemoveDanglingFile(ctx context.Context, storageObj *models.StorageObject) error {
+	status := storageObj.Status
+	if storageObj.IsActive() {
+		status = models.StatusActive
+	}
+
+	values := map[string]types.AttributeValue{
+		":s3Key":  &types.AttributeValueMemberS{Value: storageObj.S3Key},
+		":status": &types.AttributeValueMemberS{Value: status},
+	}
+	condition := versionCondition(storageObj.UserID, storageObj.Version, values) + " AND S3Key = :s3Key AND " + statusFilter(status)
+	delete(values, ":nextVersion")
+
+	changes := usageChanges{}
+	changes.add(storageObj, -1, storageObj.Status == models.StatusTrashed)
+
+	// The blob record may be as gone as the object.
+	var extra []types.TransactWriteItem
+	if storageObj.SharesBlob() {
+		blob, err := r.getBlob(ctx, storageObj.BlobKey)
+		if err != nil {
+			return err
+		}
+		if blob != nil {
+			extra = append(extra, releaseBlobWrite(storageObj.BlobKey))
+		}
+	}
+
+	_, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  statusAttributeNames,
+			ExpressionAttributeValues: values,
+		},
+	}, changes, extra...)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return ErrFileNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to remove dangling file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	if storageObj.SharesBlob() {
+		return r.deleteBlobIfUnused(ctx, storageObj.BlobKey)
+	}
+
+	// Noncurrent versions may outlive the current object, for example behind a
+	// delete marker.
+	return r.deleteAllVersions(ctx, storageObj)
+}
@@ -0,0 +1,33 @@
+package repositories_test
+
+import (
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func TestIsBlobKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{key: "blobs/abc", want: true},
+		{key: "users/user-1/blobs/abc", want: true},
+		{key: "users/user-1/3f2c9a0e-1b7d-4c55-9a63-0d4a1c2b5e77", want: false},
+		{key: "users/user-1/blobs", want: false},
+		{key: "users/user-1/blobs/", want: false},
+		{key: "users/user-1/notes/blobs/abc", want: false},
+		{key: "users//blobs/abc", want: false},
+		{key: "users/user-1/blobs/abc/def", want: false},
+		{key: "blobs/", want: false},
+		{key: "archive/blobs/abc", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := repositories.IsBlobKey(tt.key); got != tt.want {
+				t.Errorf("IsBlobKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
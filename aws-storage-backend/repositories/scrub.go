@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// ScrubFiles walks the storage table and checks that every file's bytes are
+// still in the blob store with the size and checksums on record. Files that fail are
+// quarantined if options ask for it. Files recorded before checksums existed
+// are only checked for existence and size.
+func (r *StorageRepository) ScrubFiles(ctx context.Context, options models.ScrubOptions) (*models.ScrubReport, error) {
+	report := &models.ScrubReport{
+		StartedAt: time.Now().UTC(),
+		Findings:  []models.ScrubFinding{},
+	}
+
+	paginator := dynamodb.NewScanPaginator(r.dynamoService.Client, &dynamodb.ScanInput{
+		TableName: aws.String(StorageTable),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan files: %v", err)
+			return nil, err
+		}
+
+		var items []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal files: %v", err)
+			return nil, err
+		}
+
+		r.scrub(ctx, report, items, options, r.QuarantineFile)
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// scrub verifies files and adds what it finds to report, quarantining them
+// with quarantine if options ask for it.
+func (r *fileBlobs) scrub(ctx context.Context, report *models.ScrubReport, files []models.StorageObject, options models.ScrubOptions, quarantine func(context.Context, *models.StorageObject, string) error) {
+	for i := range files {
+		if (!files[i].IsActive() && files[i].Status != models.StatusTrashed) || files[i].IsQuarantined() || files[i].IsReplacing() {
+			report.Skipped++
+			continue
+		}
+
+		findings, err := r.VerifyFile(ctx, &files[i], options.Deep)
+		if err != nil {
+			// One unreadable object should not stop the run.
+			log.Printf("Failed to verify file %s: %v", files[i].ObjectID, err)
+			continue
+		}
+		report.Checked++
+
+		if len(findings) > 0 && options.Quarantine {
+			err := quarantine(ctx, &files[i], findings[0].Problem)
+			switch {
+			case err == nil:
+				for j := range findings {
+					findings[j].Quarantined = true
+				}
+			case !errors.Is(err, ErrVersionConflict):
+				log.Printf("Failed to quarantine file %s: %v", files[i].ObjectID, err)
+			}
+		}
+		report.Findings = append(report.Findings, findings...)
+	}
+}
+
+// VerifyFile compares a file's metadata with the object S3 holds for it. With
+// deep set the bytes are downloaded and their checksums recomputed.
+func (r *fileBlobs) VerifyFile(ctx context.Context, storageObj *models.StorageObject, deep bool) ([]models.ScrubFinding, error) {
+	findings := []models.ScrubFinding{}
+	report := func(problem string, expected string, actual string) {
+		findings = append(findings, models.ScrubFinding{
+			ObjectID: storageObj.ObjectID,
+			UserID:   storageObj.UserID,
+			S3Key:    storageObj.S3Key,
+			Problem:  problem,
+			Expected: expected,
+			Actual:   actual,
+		})
+	}
+
+	head, err := r.blobStore.Head(ctx, storageObj.S3Key, storageObj.VersionID)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		report(models.ScrubMissing, storageObj.VersionID, "")
+		return findings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if head.Size != storageObj.FileSize {
+		report(models.ScrubSizeMismatch, fmt.Sprint(storageObj.FileSize), fmt.Sprint(head.Size))
+	}
+
+	checksumSHA256, checksumCRC32C := head.ChecksumSHA256, head.ChecksumCRC32C
+	if storageObj.ChecksumSHA256 != "" {
+		switch checksumSHA256 {
+		case "":
+			report(models.ScrubChecksumMissing, "sha256:"+storageObj.ChecksumSHA256, "")
+		case storageObj.ChecksumSHA256:
+		default:
+			report(models.ScrubChecksumMismatch, "sha256:"+storageObj.ChecksumSHA256, "sha256:"+checksumSHA256)
+		}
+	}
+	if storageObj.ChecksumCRC32C != "" && checksumCRC32C != "" && checksumCRC32C != storageObj.ChecksumCRC32C {
+		report(models.ScrubChecksumMismatch, "crc32c:"+storageObj.ChecksumCRC32C, "crc32c:"+checksumCRC32C)
+	}
+
+	if !deep || len(findings) > 0 {
+		return findings, nil
+	}
+
+	output, err := r.blobStore.Get(ctx, storageObj.S3Key, blobstore.GetOptions{VersionID: storageObj.VersionID})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	digest, size, err := digestReader(output.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if size != storageObj.FileSize {
+		report(models.ScrubSizeMismatch, fmt.Sprint(storageObj.FileSize), fmt.Sprint(size))
+	}
+	if storageObj.ChecksumSHA256 != "" && digest.sha256 != storageObj.ChecksumSHA256 {
+		report(models.ScrubChecksumMismatch, "sha256:"+storageObj.ChecksumSHA256, "sha256:"+digest.sha256)
+	}
+	if storageObj.ChecksumCRC32C != "" && digest.crc32c != storageObj.ChecksumCRC32C {
+		report(models.ScrubChecksumMismatch, "crc32c:"+storageObj.ChecksumCRC32C, "crc32c:"+digest.crc32c)
+	}
+
+	return findings, nil
+}
+
+// QuarantineFile blocks downloads of a file that failed verification. It only
+// applies while the file still points at the content that was checked and no
+// new content is being written; otherwise it returns ErrVersionConflict and
+// the next run looks again.
+func (r *StorageRepository) QuarantineFile(ctx context.Context, storageObj *models.StorageObject, reason string) error {
+	values := map[string]types.AttributeValue{
+		":now":    &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+		":reason": &types.AttributeValueMemberS{Value: reason},
+		":s3Key":  &types.AttributeValueMemberS{Value: storageObj.S3Key},
+	}
+
+	condition := "S3Key = :s3Key AND attribute_not_exists(VersionID) AND attribute_not_exists(ReplaceID)"
+	if storageObj.VersionID != "" {
+		condition = "S3Key = :s3Key AND VersionID = :versionID AND attribute_not_exists(ReplaceID)"
+		values[":versionID"] = &types.AttributeValueMemberS{Value: storageObj.VersionID}
+	}
+
+	_, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(StorageTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+		},
+		UpdateExpression:          aws.String("SET QuarantinedAt = :now, QuarantineReason = :reason"),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrVersionConflict
+		}
+		log.Printf("Failed to quarantine file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return nil
+}
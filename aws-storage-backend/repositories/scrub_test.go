@@ -0,0 +1,140 @@
+package repositories_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// rottenStore reports what was stored but hands out different bytes, like a
+// disk that decayed under an object S3 still describes correctly.
+type rottenStore struct {
+	blobstore.BlobStore
+	rotten bool
+}
+
+func (s *rottenStore) Get(ctx context.Context, key string, options blobstore.GetOptions) (*blobstore.Object, error) {
+	object, err := s.BlobStore.Get(ctx, key, options)
+	if err != nil || !s.rotten {
+		return object, err
+	}
+
+	content, err := io.ReadAll(object.Body)
+	object.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	content[0] ^= 0xff
+	object.Body = io.NopCloser(strings.NewReader(string(content)))
+	return object, nil
+}
+
+func TestScrubFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		damage       func(t *testing.T, store *rottenStore, file *models.StorageObject)
+		options      models.ScrubOptions
+		wantProblems []string
+	}{
+		{name: "intact"},
+		{name: "intact, deep", options: models.ScrubOptions{Deep: true}},
+		{name: "missing", wantProblems: []string{models.ScrubMissing}, damage: func(t *testing.T, store *rottenStore, file *models.StorageObject) {
+			if err := store.DeleteAllVersions(context.Background(), file.S3Key); err != nil {
+				t.Fatalf("DeleteAllVersions: %v", err)
+			}
+		}},
+		{name: "overwritten", wantProblems: []string{models.ScrubChecksumMismatch, models.ScrubChecksumMismatch}, damage: func(t *testing.T, store *rottenStore, file *models.StorageObject) {
+			if _, err := store.Put(context.Background(), file.S3Key, strings.NewReader("jello"), blobstore.PutOptions{ContentType: "text/plain"}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}},
+		{name: "truncated", wantProblems: []string{models.ScrubSizeMismatch, models.ScrubChecksumMismatch, models.ScrubChecksumMismatch}, damage: func(t *testing.T, store *rottenStore, file *models.StorageObject) {
+			if _, err := store.Put(context.Background(), file.S3Key, strings.NewReader("hel"), blobstore.PutOptions{ContentType: "text/plain"}); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+		}},
+		{name: "rotten, shallow", damage: func(t *testing.T, store *rottenStore, file *models.StorageObject) {
+			store.rotten = true
+		}},
+		{name: "rotten, deep", options: models.ScrubOptions{Deep: true}, wantProblems: []string{models.ScrubChecksumMismatch, models.ScrubChecksumMismatch}, damage: func(t *testing.T, store *rottenStore, file *models.StorageObject) {
+			store.rotten = true
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			for _, quarantine := range []bool{false, true} {
+				name := tt.name
+				if quarantine {
+					name += ", quarantining"
+				}
+				t.Run(name, func(t *testing.T) {
+					ctx := context.Background()
+					store := &rottenStore{BlobStore: blobstore.NewMemoryStore(nil)}
+					storage := driver.Open(t, store, config.Defaults()).Storage
+
+					file, err := storage.UploadFile(ctx, "user-1", models.RootFolderID, "a.txt", 5, "text/plain", strings.NewReader("hello"), nil)
+					if err != nil {
+						t.Fatalf("UploadFile: %v", err)
+					}
+					// Pending uploads have no bytes to check yet.
+					if _, err := storage.CreatePendingFile(ctx, "user-1", models.RootFolderID, "b.txt", 5, "text/plain", nil, time.Now().Add(time.Hour)); err != nil {
+						t.Fatalf("CreatePendingFile: %v", err)
+					}
+					if tt.damage != nil {
+						tt.damage(t, store, file)
+					}
+
+					options := tt.options
+					options.Quarantine = quarantine
+					report, err := storage.ScrubFiles(ctx, options)
+					if err != nil {
+						t.Fatalf("ScrubFiles: %v", err)
+					}
+
+					var problems []string
+					for _, finding := range report.Findings {
+						problems = append(problems, finding.Problem)
+						if finding.ObjectID != file.ObjectID || finding.Quarantined != quarantine {
+							t.Errorf("finding %+v, want it about %s and quarantined %t", finding, file.ObjectID, quarantine)
+						}
+					}
+					if strings.Join(problems, ",") != strings.Join(tt.wantProblems, ",") {
+						t.Errorf("problems = %v, want %v", problems, tt.wantProblems)
+					}
+					if report.Checked != 1 || report.Skipped != 1 {
+						t.Errorf("checked %d and skipped %d files, want 1 and 1", report.Checked, report.Skipped)
+					}
+
+					after, err := storage.GetFileByID(ctx, file.ObjectID)
+					if err != nil {
+						t.Fatalf("GetFileByID: %v", err)
+					}
+					wantQuarantined := quarantine && len(tt.wantProblems) > 0
+					if after.IsQuarantined() != wantQuarantined {
+						t.Errorf("quarantined = %t, want %t", after.IsQuarantined(), wantQuarantined)
+					}
+					if wantQuarantined && after.QuarantineReason != tt.wantProblems[0] {
+						t.Errorf("QuarantineReason = %q, want %q", after.QuarantineReason, tt.wantProblems[0])
+					}
+
+					// Quarantined files are not checked again.
+					report, err = storage.ScrubFiles(ctx, options)
+					if err != nil {
+						t.Fatalf("ScrubFiles: %v", err)
+					}
+					if wantQuarantined && (report.Checked != 0 || len(report.Findings) != 0) {
+						t.Errorf("second run checked %d files and found %d problems, want the quarantined file skipped", report.Checked, len(report.Findings))
+					}
+				})
+			}
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const ShareLinkTable = "share_link"
+
+var (
+	ErrShareLinkNotFound    = errors.New("share link not found")
+	ErrShareLinkUnavailable = errors.New("share link has expired, was revoked or reached its download limit")
+)
+
+type ShareRepository struct {
+	dynamoService *config.DynamoDBService
+}
+
+func NewShareRepository(dynamoService *config.DynamoDBService) *ShareRepository {
+	return &ShareRepository{
+		dynamoService: dynamoService,
+	}
+}
+
+func (r *ShareRepository) CreateShareLink(ctx context.Context, link *models.ShareLink) (*models.ShareLink, error) {
+	item, err := attributevalue.MarshalMap(link)
+	if err != nil {
+		log.Printf("Failed to marshal share link: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(ShareLinkTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#token)"),
+		ExpressionAttributeNames: map[string]string{
+			"#token": "Token",
+		},
+	})
+
+	if err != nil {
+		log.Printf("Failed to save share link for file %s: %v", link.ObjectID, err)
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (r *ShareRepository) GetShareLink(ctx context.Context, token string) (*models.ShareLink, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(ShareLinkTable),
+		Key: map[string]types.AttributeValue{
+			"Token": &types.AttributeValueMemberS{Value: token},
+		},
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for share link: %v", err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrShareLinkNotFound
+	}
+
+	var link models.ShareLink
+	if err := attributevalue.UnmarshalMap(result.Item, &link); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+func (r *ShareRepository) ListShareLinks(ctx context.Context, objectID string) ([]models.ShareLink, error) {
+	links := []models.ShareLink{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(ShareLinkTable),
+		IndexName:              aws.String("ObjectIDIndex"),
+		KeyConditionExpression: aws.String("ObjectID = :objectID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":objectID": &types.AttributeValueMemberS{Value: objectID},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list share links of file %s: %v", objectID, err)
+			return nil, err
+		}
+
+		var items []models.ShareLink
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal share links: %v", err)
+			return nil, err
+		}
+		links = append(links, items...)
+	}
+
+	return links, nil
+}
+
+func (r *ShareRepository) RevokeShareLink(ctx context.Context, token string, userID string) (*models.ShareLink, error) {
+	result, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(ShareLinkTable),
+		Key: map[string]types.AttributeValue{
+			"Token": &types.AttributeValueMemberS{Value: token},
+		},
+		UpdateExpression:    aws.String("SET RevokedAt = if_not_exists(RevokedAt, :now)"),
+		ConditionExpression: aws.String("UserID = :userID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":    &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+			":userID": &types.AttributeValueMemberS{Value: userID},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, ErrShareLinkNotFound
+		}
+		log.Printf("UpdateItem error for share link: %v", err)
+		return nil, err
+	}
+
+	var link models.ShareLink
+	if err := attributevalue.UnmarshalMap(result.Attributes, &link); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+// ConsumeDownload counts one download against the link. The limits are checked
+// in the same write, so concurrent downloads cannot go past MaxDownloads.
+func (r *ShareRepository) ConsumeDownload(ctx context.Context, token string, now time.Time) (*models.ShareLink, error) {
+	result, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(ShareLinkTable),
+		Key: map[string]types.AttributeValue{
+			"Token": &types.AttributeValueMemberS{Value: token},
+		},
+		UpdateExpression: aws.String("ADD DownloadCount :one"),
+		ConditionExpression: aws.String("attribute_exists(#token) AND attribute_not_exists(RevokedAt)" +
+			" AND (attribute_not_exists(ExpiresAt) OR ExpiresAt > :now)" +
+			" AND (attribute_not_exists(MaxDownloads) OR DownloadCount < MaxDownloads)"),
+		ExpressionAttributeNames: map[string]string{
+			"#token": "Token",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, ErrShareLinkUnavailable
+		}
+		log.Printf("UpdateItem error for share link: %v", err)
+		return nil, err
+	}
+
+	var link models.ShareLink
+	if err := attributevalue.UnmarshalMap(result.Attributes, &link); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &link, nil
+}
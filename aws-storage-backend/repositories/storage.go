@@ -6,349 +6,955 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
 	"github.com/berkkaradalan/AwsGo-Storage/config"
 	"github.com/berkkaradalan/AwsGo-Storage/models"
-	"github.com/google/uuid"
 )
 
 const StorageTable = "storage"
 
+// statusFilter matches items in the given status, bound to :status. Items
+// written before upload sessions existed have no Status and are active.
+func statusFilter(status string) string {
+	if status == models.StatusActive {
+		return "(attribute_not_exists(#status) OR #status = :status)"
+	}
+	return "#status = :status"
+}
+
+// Status is a DynamoDB reserved word.
+var statusAttributeNames = map[string]string{"#status": "Status"}
+
+var (
+	ErrFileNotFound     = errors.New("file not found")
+	ErrFileAccessDenied = errors.New("unauthorized: file does not belong to user")
+	ErrNotModified      = errors.New("not modified")
+	ErrInvalidRange     = errors.New("requested range not satisfiable")
+	ErrVersionConflict  = errors.New("file was modified by another request, reload and try again")
+	ErrFileQuarantined  = errors.New("file failed an integrity check and is quarantined")
+)
+
+// fileBlobs is the part of a storage store that only deals with the blob
+// store, the same whichever metadata store keeps the records.
+type fileBlobs struct {
+	blobStore  blobstore.BlobStore
+	bucketName string
+	dedupScope string
+}
+
+func newFileBlobs(blobStore blobstore.BlobStore, cfg *config.Config) fileBlobs {
+	return fileBlobs{
+		blobStore:  blobStore,
+		bucketName: cfg.AWS.S3Bucket,
+		dedupScope: cfg.Storage.DedupScope,
+	}
+}
+
 type StorageRepository struct {
-	s3Service 		*config.S3BucketService
+	fileBlobs
 	dynamoService	*config.DynamoDBService
-	bucketName		string
 }
 
-func NewStorageRepository(dynamoservice *config.DynamoDBService, s3service *config.S3BucketService) *StorageRepository {
+func NewStorageRepository(dynamoservice *config.DynamoDBService, blobStore blobstore.BlobStore, cfg *config.Config) *StorageRepository {
 	return &StorageRepository{
-		s3Service: s3service,
+		fileBlobs: newFileBlobs(blobStore, cfg),
 		dynamoService: dynamoservice,
-		bucketName: config.LoadEnv().S3_BUCKET_NAME,
 	}
 }
 
-func (r *StorageRepository) UploadFile(ctx context.Context, userID string, fileName string, fileSize int64, contentType string, fileData io.Reader, description *string) (*models.StorageObject, error) {
-	objectID := uuid.New().String()
-	s3Key := fmt.Sprintf("users/%s/%s", userID, objectID)
+func (r *StorageRepository) UploadFile(ctx context.Context, userID string, parentID string, fileName string, fileSize int64, contentType string, fileData io.ReadSeeker, description *string) (*models.StorageObject, error) {
+	return r.putFile(ctx, &models.StorageObject{
+		UserID:      userID,
+		ParentID:    parentID,
+		FileName:    fileName,
+		FileSize:    fileSize,
+		ContentType: contentType,
+		Description: description,
+	}, fileData)
+}
+
+// UploadRequestedFile stores a file that a guest sent through a file request.
+// It belongs to the owner of the request.
+func (r *StorageRepository) UploadRequestedFile(ctx context.Context, request *models.FileRequest, parentID string, fileName string, fileSize int64, contentType string, fileData io.ReadSeeker, uploader *models.Uploader) (*models.StorageObject, error) {
+	return r.putFile(ctx, &models.StorageObject{
+		UserID:        request.UserID,
+		ParentID:      parentID,
+		FileName:      fileName,
+		FileSize:      fileSize,
+		ContentType:   contentType,
+		FileRequestID: request.RequestID,
+		Uploader:      uploader,
+	}, fileData)
+}
+
+// serverUploadExpiry bounds how long a file uploaded through the server can
+// stay pending. A request that dies mid-upload leaves the session to the
+// upload sweeper once it expires.
+const serverUploadExpiry = time.Hour
+
+// putFile gives a new file its ID and saves it the way direct uploads are
+// saved: the record is written as pending, the body streams once to the
+// file's upload key and is checksummed on the way, and activateUpload moves
+// it into its content-addressed blob, copying the bytes only if no file has
+// them yet. If a step fails the pending file is removed again; if that fails
+// too, ErrOutboxPending is returned and the upload sweeper removes it once
+// it expires. If the outbox worker rolled the upload back first,
+// ErrUploadRolledBack is returned.
+func (r *StorageRepository) putFile(ctx context.Context, storageObj *models.StorageObject, fileData io.Reader) (*models.StorageObject, error) {
+	pending, err := r.createPendingFile(ctx, storageObj, time.Now().Add(serverUploadExpiry))
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := r.s3Service.Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(r.bucketName),
-		Key:         aws.String(s3Key),
-		Body:        fileData,
-		ContentType: aws.String(contentType),
+	body := newDigestingReader(fileData)
+	stored, err := r.blobStore.Put(ctx, pending.S3Key, body, blobstore.PutOptions{
+		ContentType: pending.ContentType,
+		Size:        pending.FileSize,
 	})
 
+	var activated *models.StorageObject
 	if err != nil {
-		log.Printf("Failed to upload file to S3: %v", err)
+		log.Printf("Failed to upload file %s: %v", pending.ObjectID, err)
+	} else {
+		digest, size := body.digest()
+		switch {
+		case size != pending.FileSize:
+			err = ErrUploadMismatch
+		case stored.ChecksumSHA256 != "" && stored.ChecksumSHA256 != digest.sha256:
+			err = blobstore.ErrChecksumMismatch
+		default:
+			activated, err = r.activateUpload(ctx, pending, stored.VersionID, digest)
+		}
+	}
+
+	if errors.Is(err, ErrOutboxPending) || errors.Is(err, ErrUploadRolledBack) {
+		return nil, err
+	}
+	if err != nil {
+		if deleteErr := r.DeletePendingFile(ctx, pending); deleteErr != nil {
+			log.Printf("Failed to remove failed upload %s, leaving it to the upload sweeper: %v", pending.ObjectID, deleteErr)
+			return nil, ErrOutboxPending
+		}
 		return nil, err
 	}
 
-	storageObj := &models.StorageObject{
-		ObjectID:    objectID,
-		UserID:      userID,
-		FileName:    fileName,
-		FileSize:    fileSize,
-		ContentType: contentType,
-		S3Key:       s3Key,
-		S3Bucket:    r.bucketName,
-		UploadedAt:  time.Now(),
-		UpdatedAt:   time.Now(),
-		Description: description,
+	return activated, nil
+}
+
+// finishUpload activates an uploading file whose bytes are in the blob,
+// counts it towards the user's usage and removes its outbox entry. It returns
+// ErrUploadRolledBack if the file is gone, which means the outbox worker
+// rolled the upload back.
+func (r *StorageRepository) finishUpload(ctx context.Context, storageObj *models.StorageObject, blob *models.Blob, outboxID string) (*models.StorageObject, error) {
+	activated := *storageObj
+	activated.Status = models.StatusActive
+	activated.ETag = blob.ETag
+
+	changes := usageChanges{}
+	changes.add(&activated, 1, false)
+	changes.addActivity(time.Now(), uploadActivity(&activated))
+
+	current, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:         aws.String("SET #status = :active, ETag = :etag"),
+			ConditionExpression:      aws.String("#status = :uploading"),
+			ExpressionAttributeNames: statusAttributeNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":active":    &types.AttributeValueMemberS{Value: models.StatusActive},
+				":uploading": &types.AttributeValueMemberS{Value: models.StatusUploading},
+				":etag":      &types.AttributeValueMemberS{Value: blob.ETag},
+			},
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		},
+	}, changes, outboxDeleteWrite(outboxID))
+
+	if errors.Is(err, errWriteConditionFailed) {
+		if current == nil {
+			return nil, ErrUploadRolledBack
+		}
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to activate file %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &activated, nil
+}
+
+// rollbackUpload removes an uploading file together with its blob reference
+// and outbox entry, then the blob if no other file holds it.
+func (r *StorageRepository) rollbackUpload(ctx context.Context, storageObj *models.StorageObject, outboxID string) error {
+	_, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			ConditionExpression:      aws.String("#status = :uploading"),
+			ExpressionAttributeNames: statusAttributeNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":uploading": &types.AttributeValueMemberS{Value: models.StatusUploading},
+			},
+		},
+	}, usageChanges{}, releaseBlobWrite(storageObj.BlobKey), outboxDeleteWrite(outboxID))
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return ErrFileNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to roll back upload of file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteBlobIfUnused(ctx, storageObj.BlobKey)
+}
+
+// ListFiles pages through the user's files in the given status (active files
+// or the trash).
+func (r *StorageRepository) ListFiles(ctx context.Context, userID string, query models.ListFilesQuery, status string) (*models.ListStorageObjectsResponse, error) {
+	indexName, rangeKey := listIndexFor(query.SortBy)
+
+	keyCondition := "UserID = :userID"
+	filters := []string{statusFilter(status)}
+	values := map[string]types.AttributeValue{
+		":userID": &types.AttributeValueMemberS{Value: userID},
+		":status": &types.AttributeValueMemberS{Value: status},
+	}
+
+	// Range filters on the index sort key go into the key condition, the rest
+	// are applied as a FilterExpression after DynamoDB reads the page.
+	addRange := func(attribute string, lower types.AttributeValue, upper types.AttributeValue) {
+		var condition string
+		switch {
+		case lower != nil && upper != nil:
+			condition = fmt.Sprintf("%s BETWEEN :%sFrom AND :%sTo", attribute, attribute, attribute)
+		case lower != nil:
+			condition = fmt.Sprintf("%s >= :%sFrom", attribute, attribute)
+		case upper != nil:
+			condition = fmt.Sprintf("%s <= :%sTo", attribute, attribute)
+		default:
+			return
+		}
+
+		if lower != nil {
+			values[":"+attribute+"From"] = lower
+		}
+		if upper != nil {
+			values[":"+attribute+"To"] = upper
+		}
+
+		if attribute == rangeKey {
+			keyCondition += " AND " + condition
+		} else {
+			filters = append(filters, condition)
+		}
+	}
+
+	var uploadedFrom, uploadedTo, sizeFrom, sizeTo types.AttributeValue
+	if query.UploadedAfter != nil {
+		uploadedFrom = &types.AttributeValueMemberS{Value: query.UploadedAfter.UTC().Format(time.RFC3339Nano)}
+	}
+	if query.UploadedBefore != nil {
+		uploadedTo = &types.AttributeValueMemberS{Value: query.UploadedBefore.UTC().Format(time.RFC3339Nano)}
+	}
+	if query.MinSize != nil {
+		sizeFrom = &types.AttributeValueMemberN{Value: strconv.FormatInt(*query.MinSize, 10)}
+	}
+	if query.MaxSize != nil {
+		sizeTo = &types.AttributeValueMemberN{Value: strconv.FormatInt(*query.MaxSize, 10)}
+	}
+	addRange("UploadedAt", uploadedFrom, uploadedTo)
+	addRange("FileSize", sizeFrom, sizeTo)
+
+	// Files uploaded before folders existed have no ParentID and sit in the root.
+	switch query.FolderID {
+	case "":
+	case models.RootFolderID:
+		filters = append(filters, "(attribute_not_exists(ParentID) OR ParentID = :parentID)")
+		values[":parentID"] = &types.AttributeValueMemberS{Value: models.RootFolderID}
+	default:
+		filters = append(filters, "ParentID = :parentID")
+		values[":parentID"] = &types.AttributeValueMemberS{Value: query.FolderID}
+	}
+
+	if query.ContentType != "" {
+		filters = append(filters, "ContentType = :contentType")
+		values[":contentType"] = &types.AttributeValueMemberS{Value: query.ContentType}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(StorageTable),
+		IndexName:                 aws.String(indexName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		ExpressionAttributeNames:  statusAttributeNames,
+		ExpressionAttributeValues: values,
+		FilterExpression:          aws.String(strings.Join(filters, " AND ")),
+		ScanIndexForward:          aws.Bool(query.SortOrder == models.SortOrderAsc),
 	}
 
-	item, err := attributevalue.MarshalMap(storageObj)
+	startKey, err := decodeNextToken(indexName, query.SortOrder, query.NextToken)
 	if err != nil {
-		log.Printf("Failed to marshal storage object: %v", err)
 		return nil, err
 	}
 
-	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(StorageTable),
-		Item:      item,
-	})
+	// Limit is applied by DynamoDB before the filter, so keep reading until the
+	// page is full or the index is exhausted.
+	files := []models.StorageObject{}
+	for {
+		input.ExclusiveStartKey = startKey
+		input.Limit = aws.Int32(query.Limit - int32(len(files)))
+
+		result, err := r.dynamoService.Client.Query(ctx, input)
+		if err != nil {
+			log.Printf("couldn't get user files with userID : %v, error : %v", userID, err)
+			return nil, err
+		}
 
+		var page []models.StorageObject
+		err = attributevalue.UnmarshalListOfMaps(result.Items, &page)
+		if err != nil {
+			log.Printf("failed to unmarshal dynamodb items: %v", err)
+			return nil, err
+		}
+		files = append(files, page...)
+
+		startKey = result.LastEvaluatedKey
+		if len(startKey) == 0 || int32(len(files)) >= query.Limit {
+			break
+		}
+	}
+
+	nextToken, err := encodeNextToken(indexName, query.SortOrder, startKey)
 	if err != nil {
-		log.Printf("Failed to save metadata to DynamoDB: %v", err)
+		log.Printf("failed to encode next token: %v", err)
 		return nil, err
 	}
 
-	return storageObj, nil
+	return listFilesResponse(files, nextToken), nil
 }
 
-func (r *StorageRepository) ListFiles(ctx context.Context, userID string) (*models.ListStorageObjectsResponse, error) {
-	result, err := r.dynamoService.Client.Query(ctx, &dynamodb.QueryInput{
-		TableName: aws.String(StorageTable),
-		IndexName: aws.String("UserIDIndex"),
-		KeyConditionExpression: aws.String("UserID = :userID"),
+func listFilesResponse(files []models.StorageObject, nextToken *string) *models.ListStorageObjectsResponse {
+	message := "Files fetched successfully"
+	if len(files) == 0 {
+		message = "No files found"
+	}
+
+	return &models.ListStorageObjectsResponse{
+		Success:   true,
+		Message:   message,
+		Data:      files,
+		Count:     len(files),
+		NextToken: nextToken,
+	}
+}
+
+// ListChildFiles returns every object directly inside a folder, whatever its
+// status.
+func (r *StorageRepository) ListChildFiles(ctx context.Context, userID string, parentID string) ([]models.StorageObject, error) {
+	files := []models.StorageObject{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(StorageTable),
+		IndexName:              aws.String("UserIDParentIDIndex"),
+		KeyConditionExpression: aws.String("UserID = :userID AND ParentID = :parentID"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":userID": &types.AttributeValueMemberS{Value: userID},
+			":userID":   &types.AttributeValueMemberS{Value: userID},
+			":parentID": &types.AttributeValueMemberS{Value: parentID},
 		},
 	})
 
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list files in folder %s: %v", parentID, err)
+			return nil, err
+		}
+
+		var items []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal files: %v", err)
+			return nil, err
+		}
+		files = append(files, items...)
+	}
+
+	return files, nil
+}
+
+// MoveFile puts a file into parentID and moves it between the usage totals of
+// the two folders. The write only goes through if the file is still where and
+// what storageObj says, and its content is not being replaced.
+func (r *StorageRepository) MoveFile(ctx context.Context, storageObj *models.StorageObject, parentID string) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	updated := *storageObj
+	updated.ParentID = parentID
+	updated.UpdatedAt = now
+
+	values := map[string]types.AttributeValue{
+		":parentID":    &types.AttributeValueMemberS{Value: parentID},
+		":now":         &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+		":userID":      &types.AttributeValueMemberS{Value: storageObj.UserID},
+		":fileSize":    &types.AttributeValueMemberN{Value: strconv.FormatInt(storageObj.FileSize, 10)},
+		":contentType": &types.AttributeValueMemberS{Value: storageObj.ContentType},
+	}
+
+	// Files uploaded before folders existed have no ParentID and sit in the root.
+	parentCondition := "ParentID = :oldParentID"
+	values[":oldParentID"] = &types.AttributeValueMemberS{Value: storageObj.ParentID}
+	if storageObj.ParentID == "" || storageObj.ParentID == models.RootFolderID {
+		parentCondition = "(attribute_not_exists(ParentID) OR ParentID = :oldParentID)"
+		values[":oldParentID"] = &types.AttributeValueMemberS{Value: models.RootFolderID}
+	}
+
+	// Status is absent on files written before upload sessions existed.
+	statusCondition := "attribute_not_exists(#status)"
+	if storageObj.Status != "" {
+		statusCondition = "#status = :status"
+		values[":status"] = &types.AttributeValueMemberS{Value: storageObj.Status}
+	}
+
+	trashed := storageObj.Status == models.StatusTrashed
+	changes := usageChanges{}
+	changes.add(storageObj, -1, trashed)
+	changes.add(&updated, 1, trashed)
+
+	_, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:          aws.String("SET ParentID = :parentID, UpdatedAt = :now"),
+			ConditionExpression:       aws.String("UserID = :userID AND FileSize = :fileSize AND ContentType = :contentType AND " + parentCondition + " AND " + statusCondition + " AND attribute_not_exists(ReplaceID)"),
+			ExpressionAttributeNames:  statusAttributeNames,
+			ExpressionAttributeValues: values,
+		},
+	}, changes)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return nil, ErrVersionConflict
+	}
 	if err != nil {
-		log.Printf("couldn't get user files with userID : %v, error : %v", userID, err)
+		log.Printf("UpdateItem error for fileID %s: %v", storageObj.ObjectID, err)
 		return nil, err
 	}
 
-	if len(result.Items) == 0 {
-		return &models.ListStorageObjectsResponse{
-			Success: true,
-			Message: "No files found",
-			Data:    []models.StorageObject{},
-			Count:   0,
-		}, nil
+	return &updated, nil
+}
+
+func (r *StorageRepository) UpdateFileMetadata(ctx context.Context, fileID string, userID string, fileName *string, description *string, expectedVersion int64) (*models.StorageObject, error) {
+	sets := []string{"UpdatedAt = :now", "Version = :nextVersion"}
+	removes := []string{}
+	values := map[string]types.AttributeValue{
+		":now": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
 	}
 
-	var files []models.StorageObject
+	if fileName != nil {
+		sets = append(sets, "FileName = :fileName")
+		values[":fileName"] = &types.AttributeValueMemberS{Value: *fileName}
+	}
+
+	if description != nil {
+		if *description == "" {
+			removes = append(removes, "Description")
+		} else {
+			sets = append(sets, "Description = :description")
+			values[":description"] = &types.AttributeValueMemberS{Value: *description}
+		}
+	}
+
+	updateExpression := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	return r.updateFileVersioned(ctx, fileID, userID, expectedVersion, updateExpression, values)
+}
 
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &files)
+// ReplaceFileContent overwrites the bytes behind an existing ObjectID. The
+// version is claimed before anything goes to S3, and the S3 write is
+// conditional on the ETag we have on record, so two concurrent replacements
+// cannot both win. The bytes are checksummed as they stream; if they turn out
+// not to be fileSize long the replacement is rolled back. A file sharing a
+// blob has to be detached first.
+func (r *StorageRepository) ReplaceFileContent(ctx context.Context, storageObj *models.StorageObject, contentType string, fileSize int64, fileData io.ReadSeeker) (*models.StorageObject, error) {
+	if storageObj.SharesBlob() {
+		return nil, ErrVersionConflict
+	}
+
+	return r.replaceContent(ctx, storageObj, fileSize-storageObj.FileSize, func() (*storedContent, error) {
+		body := newDigestingReader(fileData)
+		stored, err := r.blobStore.Put(ctx, storageObj.S3Key, body, blobstore.PutOptions{
+			ContentType: contentType,
+			Size:        fileSize,
+			IfMatch:     storageObj.ETag,
+		})
+		if errors.Is(err, blobstore.ErrPreconditionFailed) {
+			return nil, ErrVersionConflict
+		}
+		if err != nil {
+			log.Printf("Failed to replace file content: %v", err)
+			return nil, err
+		}
 
+		digest, size := body.digest()
+		content := &storedContent{
+			fileSize:       fileSize,
+			contentType:    contentType,
+			etag:           stored.ETag,
+			versionID:      stored.VersionID,
+			checksumSHA256: digest.sha256,
+			checksumCRC32C: digest.crc32c,
+		}
+		if size != fileSize || (stored.ChecksumSHA256 != "" && stored.ChecksumSHA256 != digest.sha256) {
+			return content, ErrUploadMismatch
+		}
+		return content, nil
+	})
+}
+
+// storedContent describes bytes that were just written to a file's S3 key.
+type storedContent struct {
+	fileSize       int64
+	contentType    string
+	etag           string
+	versionID      string
+	checksumSHA256 string
+	checksumCRC32C string
+}
+
+// replaceContent gives an active file new content in three steps, like
+// putFile: the file is claimed at its next version together with an outbox
+// entry, write puts the bytes in S3, and the record is pointed at them as the
+// entry is removed. While the claim is held, edits, moves and trashing of the
+// file fail with ErrVersionConflict. If a step fails the bytes written are
+// deleted, which makes the previous content current again, and the claim is
+// given up; the version number stays used. If that fails too,
+// ErrOutboxPending is returned and the outbox worker rolls the replacement
+// back. If the worker did so first, ErrUploadRolledBack is returned.
+//
+// reserved is how many bytes the caller reserved for the file to grow; the
+// worker gives them back when it rolls back.
+func (r *StorageRepository) replaceContent(ctx context.Context, storageObj *models.StorageObject, reserved int64, write func() (*storedContent, error)) (*models.StorageObject, error) {
+	claimed, entry, err := r.claimContent(ctx, storageObj, max(reserved, 0))
 	if err != nil {
-		log.Printf("failed to unmarshal dynamodb items: %v", err)
 		return nil, err
 	}
 
-	// var nextToken *string
-	// if val, ok := result.LastEvaluatedKey["userID"]; ok{
-	// 	if attr, ok := val.(*types.AttributeValueMemberS); ok {
-	// 		nextToken = aws.String(attr.Value)
-	// 	}
-	// }
+	release := holdOutboxEntry(ctx, entry, r.moveOutboxEntry)
+	content, err := write()
+	release()
+
+	var updated *models.StorageObject
+	if err == nil {
+		updated, err = r.finishReplace(ctx, claimed, *content)
+	}
 
-	response := &models.ListStorageObjectsResponse{
-		Success: true,
-		Message: "Files fetched successfully",
-		Data: files,
-		Count: len(files),
-		// NextToken: nextToken,
+	if err == nil {
+		return updated, nil
 	}
 
-	return response, nil
+	written := ""
+	if content != nil {
+		written = content.versionID
+	}
+
+	if errors.Is(err, ErrUploadRolledBack) {
+		// The worker gave the claim up, possibly before the bytes arrived,
+		// so it cannot have deleted them.
+		if written != "" {
+			if err := r.blobStore.Delete(ctx, storageObj.S3Key, written); err != nil {
+				log.Printf("Failed to delete rolled back content of file %s: %v", storageObj.ObjectID, err)
+			}
+		}
+		return nil, ErrUploadRolledBack
+	}
+
+	if rollbackErr := r.rollbackReplace(ctx, claimed, entry, written); rollbackErr != nil {
+		log.Printf("Failed to roll back replacement of file %s, leaving it to the outbox: %v", storageObj.ObjectID, rollbackErr)
+		return nil, ErrOutboxPending
+	}
+	return nil, err
 }
 
-func (r *StorageRepository) DownloadFile(ctx context.Context, fileID string, userID string) ([]byte, error){
-	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
-        TableName: aws.String(StorageTable),
-        Key: map[string]types.AttributeValue{
-            "ObjectID": &types.AttributeValueMemberS{Value: fileID},
-        },
-    })
+// claimContent moves an active file that is still at the version storageObj
+// says to its next version and marks it as being replaced, writing the outbox
+// entry that rolls the replacement back if its request does not finish it.
+func (r *StorageRepository) claimContent(ctx context.Context, storageObj *models.StorageObject, reserved int64) (*models.StorageObject, *models.OutboxEntry, error) {
+	entry := newOutboxEntry(models.OutboxReplace, storageObj)
+	entry.FileSize = reserved
+	entry.ETag = storageObj.ETag
+	entry.VersionID = storageObj.VersionID
+
+	entryWrite, err := outboxPutWrite(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := map[string]types.AttributeValue{
+		":replaceID": &types.AttributeValueMemberS{Value: entry.OutboxID},
+		":status":    &types.AttributeValueMemberS{Value: models.StatusActive},
+	}
+	condition := versionCondition(storageObj.UserID, storageObj.Version, values) + " AND " + statusFilter(models.StatusActive) + " AND attribute_not_exists(ReplaceID)"
 
+	current, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:                    aws.String("SET ReplaceID = :replaceID, Version = :nextVersion"),
+			ConditionExpression:                 aws.String(condition),
+			ExpressionAttributeNames:            statusAttributeNames,
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		},
+	}, usageChanges{}, entryWrite)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return nil, nil, versionConflictError(current, storageObj.UserID)
+	}
 	if err != nil {
-        log.Printf("GetItem error for fileID %s: %v", fileID, err)
-        return nil, err
-    }
-
-    if result.Item == nil {
-        return nil, errors.New("file not found")
-    }
-
-    var storageObj models.StorageObject
-    err = attributevalue.UnmarshalMap(result.Item, &storageObj)
-    if err != nil {
-        log.Printf("unmarshal error: %v", err)
-        return nil, err
-    }
-
-    if storageObj.UserID != userID {
-        return nil, errors.New("unauthorized: file does not belong to user")
-    }
-
-    output, err := r.s3Service.Client.GetObject(ctx, &s3.GetObjectInput{
-        Bucket: aws.String(storageObj.S3Bucket),
-        Key:    aws.String(storageObj.S3Key),
-    })
-    if err != nil {
-        log.Printf("S3 download error: %v", err)
-        return nil, err
-    }
-    defer output.Body.Close()
-
-    data, err := io.ReadAll(output.Body)
-    if err != nil {
-        log.Printf("read error: %v", err)
-        return nil, err
-    }
-
-    return data, nil
+		log.Printf("Failed to claim file %s for new content: %v", storageObj.ObjectID, err)
+		return nil, nil, err
+	}
+
+	claimed := *storageObj
+	claimed.Version = storageObj.Version + 1
+	claimed.ReplaceID = entry.OutboxID
+	return &claimed, entry, nil
 }
 
-func (r *StorageRepository) GeneratePresignedURL(ctx context.Context, s3Key string, contentType string, expiresIn time.Duration) (string, error) {
-    presignClient := s3.NewPresignClient(r.s3Service.Client)
+// finishReplace points a claimed file at its new content, moves it in the
+// usage totals from its old size and type to the new ones and removes the
+// outbox entry. The claim guarantees the old ones are still what storageObj
+// says. New content also lifts a quarantine. It returns ErrUploadRolledBack if
+// the claim is gone, which means the outbox worker rolled the replacement
+// back.
+func (r *StorageRepository) finishReplace(ctx context.Context, storageObj *models.StorageObject, content storedContent) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	values := map[string]types.AttributeValue{
+		":now":         &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+		":fileSize":    &types.AttributeValueMemberN{Value: strconv.FormatInt(content.fileSize, 10)},
+		":contentType": &types.AttributeValueMemberS{Value: content.contentType},
+		":etag":        &types.AttributeValueMemberS{Value: content.etag},
+		":versionID":   &types.AttributeValueMemberS{Value: content.versionID},
+		":replaceID":   &types.AttributeValueMemberS{Value: storageObj.ReplaceID},
+	}
 
-	input := &s3.GetObjectInput{
-        Bucket: aws.String(r.bucketName),
-        Key:    aws.String(s3Key),
-    }
+	updated := *storageObj
+	updated.UpdatedAt = now
+	updated.FileSize = content.fileSize
+	updated.ContentType = content.contentType
+	updated.ETag = content.etag
+	updated.VersionID = content.versionID
+	updated.ChecksumSHA256 = content.checksumSHA256
+	updated.ChecksumCRC32C = content.checksumCRC32C
+	updated.ContentHash = contentHashOf(content.checksumSHA256)
+	updated.QuarantinedAt = nil
+	updated.QuarantineReason = ""
+	updated.ReplaceID = ""
+
+	set := []string{"UpdatedAt = :now", "FileSize = :fileSize", "ContentType = :contentType", "ETag = :etag", "VersionID = :versionID"}
+	remove := []string{"ReplaceID", "QuarantinedAt", "QuarantineReason"}
+	optional := map[string]string{
+		"ChecksumSHA256": updated.ChecksumSHA256,
+		"ChecksumCRC32C": updated.ChecksumCRC32C,
+		"ContentHash":    updated.ContentHash,
+	}
+	for _, attribute := range []string{"ChecksumSHA256", "ChecksumCRC32C", "ContentHash"} {
+		if optional[attribute] == "" {
+			remove = append(remove, attribute)
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s = :%s", attribute, attribute))
+		values[":"+attribute] = &types.AttributeValueMemberS{Value: optional[attribute]}
+	}
+	updateExpression := "SET " + strings.Join(set, ", ") + " REMOVE " + strings.Join(remove, ", ")
+
+	changes := usageChanges{}
+	changes.add(storageObj, -1, false)
+	changes.add(&updated, 1, false)
+	changes.addActivity(now, map[string]int64{"NetBytes": content.fileSize - storageObj.FileSize})
+
+	_, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:          aws.String(updateExpression),
+			ConditionExpression:       aws.String("ReplaceID = :replaceID"),
+			ExpressionAttributeValues: values,
+		},
+	}, changes, outboxDeleteWrite(storageObj.ReplaceID))
 
-	if contentType == "application/pdf" {
-        input.ResponseContentType = aws.String("application/pdf")
-        input.ResponseContentDisposition = aws.String("inline")
-    }
-    
-	request, err := presignClient.PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
-        opts.Expires = expiresIn
-    })
-    
-    if err != nil {
-        log.Printf("Failed to generate presigned URL: %v", err)
-        return "", err
-    }
-    
-    return request.URL, nil
+	if errors.Is(err, errWriteConditionFailed) {
+		return nil, ErrUploadRolledBack
+	}
+	if err != nil {
+		log.Printf("Failed to point file %s at its new content: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &updated, nil
 }
 
-func (r *StorageRepository) DeleteFile(ctx context.Context, userID string, fileID string) (*string, error){
-	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
-        TableName: aws.String(StorageTable),
-        Key: map[string]types.AttributeValue{
-            "ObjectID": &types.AttributeValueMemberS{Value: fileID},
-        },
-    })
+// rollbackReplace deletes the version a replacement wrote, which makes the
+// content the file still points at current again, then gives the claim up and
+// removes the outbox entry. When the version written is not known, because
+// the write failed or its request is gone, whatever version is current in
+// place of the one the replacement started from is deleted.
+func (r *StorageRepository) rollbackReplace(ctx context.Context, storageObj *models.StorageObject, entry *models.OutboxEntry, written string) error {
+	if written == "" {
+		current, err := r.blobStore.Head(ctx, storageObj.S3Key, "")
+		if err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+			log.Printf("Failed to look up content of file %s: %v", storageObj.ObjectID, err)
+			return err
+		}
+		if err == nil && replacedBy(entry, current) {
+			written = current.VersionID
+		}
+	}
 
+	if written != "" {
+		if err := r.blobStore.Delete(ctx, storageObj.S3Key, written); err != nil {
+			log.Printf("Failed to delete replaced content of file %s: %v", storageObj.ObjectID, err)
+			return err
+		}
+	}
+
+	_, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:    aws.String("REMOVE ReplaceID"),
+			ConditionExpression: aws.String("ReplaceID = :replaceID"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":replaceID": &types.AttributeValueMemberS{Value: entry.OutboxID},
+			},
+		},
+	}, usageChanges{}, outboxDeleteWrite(entry.OutboxID))
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return ErrUploadRolledBack
+	}
 	if err != nil {
-        log.Printf("GetItem error for fileID %s: %v", fileID, err)
-        return nil, err
-    }
+		log.Printf("Failed to give up the claim on file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
 
-    if result.Item == nil {
-        return nil, errors.New("file not found")
-    }
+	return nil
+}
 
-	var storageObj models.StorageObject
-    err = attributevalue.UnmarshalMap(result.Item, &storageObj)
-    if err != nil {
-        log.Printf("unmarshal error: %v", err)
-        return nil, err
-    }
+// versionCondition requires the caller to own the file and the file to still
+// be at expectedVersion, and binds the values the next version needs.
+func versionCondition(userID string, expectedVersion int64, values map[string]types.AttributeValue) string {
+	values[":userID"] = &types.AttributeValueMemberS{Value: userID}
+	values[":expectedVersion"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)}
+	values[":nextVersion"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion+1, 10)}
 
-	if storageObj.UserID != userID {
-        return nil, errors.New("unauthorized: file does not belong to user")
-    }
+	// Items written before versioning have no Version and count as version 0.
+	if expectedVersion == 0 {
+		return "UserID = :userID AND (attribute_not_exists(Version) OR Version = :expectedVersion)"
+	}
+	return "UserID = :userID AND Version = :expectedVersion"
+}
+
+// versionConflictError explains why a versioned write failed, from the item
+// DynamoDB found instead.
+func versionConflictError(item map[string]types.AttributeValue, userID string) error {
+	var current models.StorageObject
+	switch {
+	case item == nil:
+		return ErrFileNotFound
+	case attributevalue.UnmarshalMap(item, &current) == nil && current.UserID != userID:
+		return ErrFileAccessDenied
+	default:
+		return ErrVersionConflict
+	}
+}
 
-	_, err = r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+// updateFileVersioned applies an update only if the caller owns the file, it
+// is active, not quarantined nor having its content replaced, and still at
+// expectedVersion, then bumps the version.
+func (r *StorageRepository) updateFileVersioned(ctx context.Context, fileID string, userID string, expectedVersion int64, updateExpression string, values map[string]types.AttributeValue) (*models.StorageObject, error) {
+	values[":status"] = &types.AttributeValueMemberS{Value: models.StatusActive}
+	condition := versionCondition(userID, expectedVersion, values) + " AND " + statusFilter(models.StatusActive) + " AND attribute_not_exists(QuarantinedAt) AND attribute_not_exists(ReplaceID)"
+
+	result, err := r.dynamoService.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(StorageTable),
 		Key: map[string]types.AttributeValue{
-            "ObjectID": &types.AttributeValueMemberS{Value: fileID},
-        },
+			"ObjectID": &types.AttributeValueMemberS{Value: fileID},
+		},
+		UpdateExpression:                    aws.String(updateExpression),
+		ConditionExpression:                 aws.String(condition),
+		ExpressionAttributeNames:            statusAttributeNames,
+		ExpressionAttributeValues:           values,
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	})
 
 	if err != nil {
-		log.Printf("DeleteItem error for fileID : %s reason :%v", err)
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, versionConflictError(conditionErr.Item, userID)
+		}
+		log.Printf("UpdateItem error for fileID %s: %v", fileID, err)
 		return nil, err
 	}
 
-	_, err = r.s3Service.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(r.bucketName),
-		Key: aws.String(storageObj.S3Key),
-	})
+	var storageObj models.StorageObject
+	if err := attributevalue.UnmarshalMap(result.Attributes, &storageObj); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &storageObj, nil
+}
+
+func listIndexFor(sortBy string) (indexName string, rangeKey string) {
+	switch sortBy {
+	case models.SortByFileName:
+		return "UserIDFileNameIndex", "FileName"
+	case models.SortByFileSize:
+		return "UserIDFileSizeIndex", "FileSize"
+	default:
+		return "UserIDUploadedAtIndex", "UploadedAt"
+	}
+}
 
+func (r *StorageRepository) GetFile(ctx context.Context, fileID string, userID string) (*models.StorageObject, error) {
+	storageObj, err := r.GetFileByID(ctx, fileID)
 	if err != nil {
-		log.Printf("DeleteObject error for fileID %s: %v", fileID, err)
 		return nil, err
 	}
 
-	deletionMessage := fmt.Sprintf("Object with id :%v deleted.", fileID)
+	if storageObj.UserID != userID {
+		return nil, ErrFileAccessDenied
+	}
 
-	return &deletionMessage, nil
+	return storageObj, nil
 }
 
-func (r *StorageRepository) GetDashboardMetrics(ctx context.Context, userID string) (*models.DashboardResponse, error) {
-	result, err := r.dynamoService.Client.Query(ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(StorageTable),
-		IndexName:              aws.String("UserIDIndex"),
-		KeyConditionExpression: aws.String("UserID = :userID"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":userID": &types.AttributeValueMemberS{Value: userID},
+// GetFileByID loads a file without checking who owns it. Callers must check
+// access themselves.
+func (r *StorageRepository) GetFileByID(ctx context.Context, fileID string) (*models.StorageObject, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(StorageTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID": &types.AttributeValueMemberS{Value: fileID},
 		},
 	})
 
 	if err != nil {
-		log.Printf("Failed to query user files: %v", err)
+		log.Printf("GetItem error for fileID %s: %v", fileID, err)
 		return nil, err
 	}
 
-	var files []models.StorageObject
-	if len(result.Items) > 0 {
-		err = attributevalue.UnmarshalListOfMaps(result.Items, &files)
-		if err != nil {
-			log.Printf("Failed to unmarshal files: %v", err)
-			return nil, err
-		}
+	if result.Item == nil {
+		return nil, ErrFileNotFound
 	}
 
-	monthlyData := groupFilesByMonth(files)
-
-	totalSize := int64(0)
-	totalFiles := 0
-	for _, usage := range monthlyData {
-		totalSize += usage.TotalSize
-		totalFiles += usage.FileCount
+	var storageObj models.StorageObject
+	err = attributevalue.UnmarshalMap(result.Item, &storageObj)
+	if err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
 	}
 
-	return &models.DashboardResponse{
-		Success: true,
-		Message: "Dashboard data fetched successfully",
-		Data: models.DashboardData{
-			Months: monthlyData,
-			Summary: map[string]interface{}{
-				"totalSizeInBytes": totalSize,
-				"totalSizeInMB":    float64(totalSize) / (1024 * 1024),
-				"totalSizeInGB":    float64(totalSize) / (1024 * 1024 * 1024),
-				"totalFiles":       totalFiles,
-			},
-		},
-	}, nil
+	return &storageObj, nil
 }
 
-func groupFilesByMonth(files []models.StorageObject) []models.MonthlyUsage {
-	now := time.Now()
+func (r *fileBlobs) HeadFile(ctx context.Context, storageObj *models.StorageObject) (*models.FileInfo, error) {
+	if storageObj.IsQuarantined() {
+		return nil, ErrFileQuarantined
+	}
 
-	months := make([]string, 12)
-	monthNames := []string{
-		"Janua", "Febru", "March", "April", "May", "June",
-		"July", "Augus", "Septe", "Octob", "Novem", "Decem",
+	head, err := r.blobStore.Head(ctx, storageObj.S3Key, "")
+	if err != nil {
+		log.Printf("Head error for fileID %s: %v", storageObj.ObjectID, err)
+		return nil, err
 	}
 
-	monthMap := make(map[string]*models.MonthlyUsage)
+	return &models.FileInfo{
+		ObjectID:     storageObj.ObjectID,
+		FileName:     storageObj.FileName,
+		ContentType:  storageObj.ContentType,
+		Size:         head.Size,
+		ETag:         head.ETag,
+		LastModified: storageObj.UpdatedAt,
+	}, nil
+}
 
-	for i := 11; i >= 0; i-- {
-		date := now.AddDate(0, -i, 0)
-		yearMonth := date.Format("2006-01")
-		months[11-i] = yearMonth
+// OpenFile starts streaming the object from S3. The caller owns the returned
+// Body and must close it. Older versions of a quarantined file can still be
+// read, which is how its owner gets back to good content.
+func (r *fileBlobs) OpenFile(ctx context.Context, storageObj *models.StorageObject, req models.DownloadRequest) (*models.FileDownload, error) {
+	if storageObj.IsQuarantined() && req.VersionID == "" {
+		return nil, ErrFileQuarantined
+	}
 
-		monthIndex := int(date.Month()) - 1
-		monthMap[yearMonth] = &models.MonthlyUsage{
-			Month:     yearMonth,
-			MonthName: monthNames[monthIndex],
-			TotalSize: 0,
-			FileCount: 0,
-			SizeInMB:  0,
-		}
+	object, err := r.blobStore.Get(ctx, storageObj.S3Key, blobstore.GetOptions{
+		VersionID:   req.VersionID,
+		Range:       req.Range,
+		IfNoneMatch: req.IfNoneMatch,
+	})
+	switch {
+	case errors.Is(err, blobstore.ErrNotModified):
+		return nil, ErrNotModified
+	case errors.Is(err, blobstore.ErrInvalidRange):
+		return nil, ErrInvalidRange
+	case err != nil:
+		log.Printf("Download error: %v", err)
+		return nil, err
 	}
 
-	for _, file := range files {
-		yearMonth := file.UploadedAt.Format("2006-01")
+	return &models.FileDownload{
+		FileInfo: models.FileInfo{
+			ObjectID:     storageObj.ObjectID,
+			FileName:     storageObj.FileName,
+			ContentType:  storageObj.ContentType,
+			Size:         storageObj.FileSize,
+			ETag:         object.ETag,
+			LastModified: storageObj.UpdatedAt,
+		},
+		Body:          object.Body,
+		ContentLength: object.ContentLength,
+		ContentRange:  object.ContentRange,
+	}, nil
+}
 
-		if usage, exists := monthMap[yearMonth]; exists {
-			usage.TotalSize += file.FileSize
-			usage.FileCount++
-			usage.SizeInMB = float64(usage.TotalSize) / (1024 * 1024)
-		}
+func (r *fileBlobs) GeneratePresignedURL(ctx context.Context, s3Key string, contentType string, expiresIn time.Duration) (string, error) {
+	options := blobstore.PresignGetOptions{ExpiresIn: expiresIn}
+
+	if contentType == "application/pdf" {
+		options.ContentType = "application/pdf"
+		options.ContentDisposition = "inline"
 	}
 
-	result := make([]models.MonthlyUsage, 0, 12)
-	for _, month := range months {
-		if usage, exists := monthMap[month]; exists {
-			result = append(result, *usage)
-		}
+	presignedURL, err := r.blobStore.PresignGet(ctx, s3Key, options)
+	if err != nil {
+		log.Printf("Failed to generate presigned URL: %v", err)
+		return "", err
 	}
-	return result
+
+	return presignedURL, nil
 }
@@ -0,0 +1,70 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func TestListFilesPages(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		storage := driver.OpenDefault(t).Storage
+
+		for i := 1; i <= 5; i++ {
+			content := strings.Repeat("x", i)
+			if _, err := storage.UploadFile(ctx, "user-1", models.RootFolderID, fmt.Sprintf("file-%d", i), int64(i), "text/plain", strings.NewReader(content), nil); err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+		}
+
+		tests := []struct {
+			sortOrder string
+			want      []string
+		}{
+			{sortOrder: models.SortOrderAsc, want: []string{"file-1", "file-2", "file-3", "file-4", "file-5"}},
+			{sortOrder: models.SortOrderDesc, want: []string{"file-5", "file-4", "file-3", "file-2", "file-1"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.sortOrder, func(t *testing.T) {
+				query := models.ListFilesQuery{Limit: 2, SortBy: models.SortByFileSize, SortOrder: tt.sortOrder}
+
+				var got []string
+				for {
+					page, err := storage.ListFiles(ctx, "user-1", query, models.StatusActive)
+					if err != nil {
+						t.Fatalf("ListFiles: %v", err)
+					}
+					for _, file := range page.Data {
+						got = append(got, file.FileName)
+					}
+					if page.NextToken == nil {
+						break
+					}
+					query.NextToken = *page.NextToken
+				}
+
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("pages hold %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		first, err := storage.ListFiles(ctx, "user-1", models.ListFilesQuery{Limit: 2, SortBy: models.SortByFileSize, SortOrder: models.SortOrderAsc}, models.StatusActive)
+		if err != nil || first.NextToken == nil {
+			t.Fatalf("ListFiles: %v, %v", first, err)
+		}
+		_, err = storage.ListFiles(ctx, "user-1", models.ListFilesQuery{Limit: 2, SortBy: models.SortByFileSize, SortOrder: models.SortOrderDesc, NextToken: *first.NextToken}, models.StatusActive)
+		if !errors.Is(err, repositories.ErrInvalidNextToken) {
+			t.Errorf("token reused with the other order: %v, want ErrInvalidNextToken", err)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/metastore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// The interfaces below are what the services depend on, one per aggregate.
+// The repositories in this package implement them on DynamoDB, and the bolt
+// stores on an embedded bbolt file; which one is used is picked at startup.
+
+type UserStore interface {
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, userEmail string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
+	UpdateVersionPolicy(ctx context.Context, userID string, maxFileVersions int) (*models.User, error)
+	UpdateStorageQuota(ctx context.Context, userID string, quota *int64) (*models.User, error)
+	ReserveStorage(ctx context.Context, userID string, size int64, quota int64) error
+	ReleaseStorage(ctx context.Context, userID string, size int64) error
+	ListUserIDs(ctx context.Context) ([]string, error)
+	RebuildUsedBytes(ctx context.Context) (int, error)
+}
+
+// ObjectStore holds file records and their content.
+type ObjectStore interface {
+	GetFile(ctx context.Context, fileID string, userID string) (*models.StorageObject, error)
+	GetFileByID(ctx context.Context, fileID string) (*models.StorageObject, error)
+	HeadFile(ctx context.Context, storageObj *models.StorageObject) (*models.FileInfo, error)
+	OpenFile(ctx context.Context, storageObj *models.StorageObject, req models.DownloadRequest) (*models.FileDownload, error)
+	GeneratePresignedURL(ctx context.Context, s3Key string, contentType string, expiresIn time.Duration) (string, error)
+	ListFiles(ctx context.Context, userID string, query models.ListFilesQuery, status string) (*models.ListStorageObjectsResponse, error)
+	ListChildFiles(ctx context.Context, userID string, parentID string) ([]models.StorageObject, error)
+	ListFilesByStatus(ctx context.Context, userID string, status string) ([]models.StorageObject, error)
+	ListFilesByRequest(ctx context.Context, userID string, requestID string) ([]models.StorageObject, error)
+	ListAllUserFiles(ctx context.Context, userID string) ([]models.StorageObject, error)
+	ListExpiredFiles(ctx context.Context, status string, before time.Time) ([]models.StorageObject, error)
+	ListDuplicateCandidates(ctx context.Context, userID string) ([]models.StorageObject, error)
+	UploadFile(ctx context.Context, userID string, parentID string, fileName string, fileSize int64, contentType string, fileData io.ReadSeeker, description *string) (*models.StorageObject, error)
+	UploadRequestedFile(ctx context.Context, request *models.FileRequest, parentID string, fileName string, fileSize int64, contentType string, fileData io.ReadSeeker, uploader *models.Uploader) (*models.StorageObject, error)
+	ReplaceFileContent(ctx context.Context, storageObj *models.StorageObject, contentType string, fileSize int64, fileData io.ReadSeeker) (*models.StorageObject, error)
+	UpdateFileMetadata(ctx context.Context, fileID string, userID string, fileName *string, description *string, expectedVersion int64) (*models.StorageObject, error)
+	MoveFile(ctx context.Context, storageObj *models.StorageObject, parentID string) (*models.StorageObject, error)
+	DetachBlob(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error)
+	TrashFile(ctx context.Context, storageObj *models.StorageObject, purgeAt time.Time) (*models.StorageObject, error)
+	RestoreFile(ctx context.Context, storageObj *models.StorageObject, parentID string) (*models.StorageObject, error)
+	PurgeFile(ctx context.Context, storageObj *models.StorageObject) error
+}
+
+// UploadStore holds upload sessions, presigned and multipart.
+type UploadStore interface {
+	CreatePendingFile(ctx context.Context, userID string, parentID string, fileName string, fileSize int64, contentType string, description *string, expiresAt time.Time) (*models.StorageObject, error)
+	PresignUpload(ctx context.Context, storageObj *models.StorageObject, method string, expiresIn time.Duration) (*models.UploadSessionResponse, error)
+	ActivateDirectUpload(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error)
+	ActivateFile(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error)
+	DeletePendingFile(ctx context.Context, storageObj *models.StorageObject) error
+	CreateMultipartUpload(ctx context.Context, storageObj *models.StorageObject, partSize int64, partCount int32) (*models.MultipartUpload, error)
+	GetMultipartUpload(ctx context.Context, objectID string) (*models.MultipartUpload, error)
+	PresignUploadPart(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, partNumber int32, partSize int64, expiresIn time.Duration) (string, error)
+	UploadPart(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, partNumber int32, partSize int64, body io.Reader, expiresAt time.Time) (*models.UploadedPart, error)
+	RecordPart(ctx context.Context, storageObj *models.StorageObject, part *models.UploadedPart, expiresAt time.Time) error
+	ListUploadedParts(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload) ([]blobstore.Part, error)
+	CompleteMultipartUpload(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload, parts []blobstore.Part) error
+	AbortMultipartUpload(ctx context.Context, storageObj *models.StorageObject, upload *models.MultipartUpload) error
+	ListStaleMultipartUploads(ctx context.Context, initiatedBefore time.Time) ([]blobstore.MultipartUpload, error)
+	AbortStaleMultipartUpload(ctx context.Context, upload blobstore.MultipartUpload) error
+}
+
+type VersionStore interface {
+	RecordVersion(ctx context.Context, storageObj *models.StorageObject, uploadedBy string) (*models.FileVersion, error)
+	ListVersions(ctx context.Context, objectID string) ([]models.FileVersion, error)
+	GetVersion(ctx context.Context, objectID string, versionID string) (*models.FileVersion, error)
+	RestoreVersion(ctx context.Context, storageObj *models.StorageObject, version *models.FileVersion) (*models.StorageObject, error)
+	DeleteVersion(ctx context.Context, storageObj *models.StorageObject, version *models.FileVersion) error
+}
+
+type OutboxStore interface {
+	ListDueOutboxEntries(ctx context.Context, now time.Time) ([]models.OutboxEntry, error)
+	ResumeOutboxEntry(ctx context.Context, entry *models.OutboxEntry) (string, error)
+	RescheduleOutboxEntry(ctx context.Context, entry *models.OutboxEntry, cause error) error
+}
+
+// UsageStore reads the per-month and per-folder usage aggregates.
+type UsageStore interface {
+	ListMonthlyUsage(ctx context.Context, userID string) ([]models.UsageAggregate, error)
+	ListFolderUsage(ctx context.Context, userID string) ([]models.UsageAggregate, error)
+	ListUsageActivity(ctx context.Context, userID string, from time.Time, to time.Time) ([]models.UsageActivity, error)
+}
+
+// MaintenanceStore is what scrub, reconcile and rebuild-usage need on top of
+// ObjectStore.
+type MaintenanceStore interface {
+	RebuildUsage(ctx context.Context) (int, error)
+	ScrubFiles(ctx context.Context, options models.ScrubOptions) (*models.ScrubReport, error)
+	QuarantineFile(ctx context.Context, storageObj *models.StorageObject, reason string) error
+	ListUserPrefixes(ctx context.Context) ([]string, error)
+	ListUserObjects(ctx context.Context, userID string) ([]models.StoredObject, error)
+	ListGlobalBlobObjects(ctx context.Context) ([]models.StoredObject, error)
+	BlobExists(ctx context.Context, key string) (bool, error)
+	ObjectExists(ctx context.Context, key string) (bool, error)
+	DeleteOrphanObject(ctx context.Context, object models.StoredObject) error
+	RemoveDanglingFile(ctx context.Context, storageObj *models.StorageObject) error
+}
+
+// StorageStore is every aggregate StorageRepository keeps. Files, their
+// upload sessions, versions, outbox entries and usage are written together in
+// one transaction, so they share an implementation.
+type StorageStore interface {
+	ObjectStore
+	UploadStore
+	VersionStore
+	OutboxStore
+	UsageStore
+	MaintenanceStore
+}
+
+type FolderStore interface {
+	CreateFolder(ctx context.Context, userID string, name string, parentID string) (*models.Folder, error)
+	GetFolder(ctx context.Context, folderID string, userID string) (*models.Folder, error)
+	GetFolderByID(ctx context.Context, folderID string) (*models.Folder, error)
+	ListChildFolders(ctx context.Context, userID string, parentID string) ([]models.Folder, error)
+	RenameFolder(ctx context.Context, folderID string, userID string, name string) (*models.Folder, error)
+	MoveFolder(ctx context.Context, folderID string, userID string, parentID string) (*models.Folder, error)
+	DeleteFolder(ctx context.Context, folderID string, userID string) error
+}
+
+type ShareStore interface {
+	CreateShareLink(ctx context.Context, link *models.ShareLink) (*models.ShareLink, error)
+	GetShareLink(ctx context.Context, token string) (*models.ShareLink, error)
+	ListShareLinks(ctx context.Context, objectID string) ([]models.ShareLink, error)
+	RevokeShareLink(ctx context.Context, token string, userID string) (*models.ShareLink, error)
+	ConsumeDownload(ctx context.Context, token string, now time.Time) (*models.ShareLink, error)
+}
+
+type GrantStore interface {
+	PutGrant(ctx context.Context, grant *models.Grant) (*models.Grant, error)
+	GetGrant(ctx context.Context, resourceID string, granteeID string) (*models.Grant, error)
+	ListGrants(ctx context.Context, resourceID string) ([]models.Grant, error)
+	ListGrantsForGrantee(ctx context.Context, granteeID string) ([]models.Grant, error)
+	DeleteGrant(ctx context.Context, resourceID string, granteeID string) (*models.Grant, error)
+}
+
+type NotificationStore interface {
+	CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error)
+	ListNotifications(ctx context.Context, userID string, limit int32, unreadOnly bool) ([]models.Notification, error)
+	MarkRead(ctx context.Context, userID string, notificationID string) error
+}
+
+type FileRequestStore interface {
+	CreateFileRequest(ctx context.Context, request *models.FileRequest) (*models.FileRequest, error)
+	GetFileRequest(ctx context.Context, requestID string, userID string) (*models.FileRequest, error)
+	GetFileRequestByToken(ctx context.Context, token string) (*models.FileRequest, error)
+	ListFileRequests(ctx context.Context, userID string) ([]models.FileRequest, error)
+	RevokeFileRequest(ctx context.Context, requestID string, userID string) error
+	ReserveUpload(ctx context.Context, requestID string, now time.Time) error
+	ReleaseUpload(ctx context.Context, requestID string) error
+}
+
+// Stores holds one store per aggregate, all kept in the same metadata store.
+type Stores struct {
+	Users         UserStore
+	Storage       StorageStore
+	Folders       FolderStore
+	Shares        ShareStore
+	Grants        GrantStore
+	Notifications NotificationStore
+	FileRequests  FileRequestStore
+
+	// closer releases the metadata store, if it holds anything open.
+	closer io.Closer
+}
+
+// Close releases the metadata store the stores keep their records in.
+func (s *Stores) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// NewDynamoDBStores returns the stores kept in DynamoDB tables.
+func NewDynamoDBStores(service *config.DynamoDBService, blobStore blobstore.BlobStore, cfg *config.Config) *Stores {
+	return &Stores{
+		Users:         NewUserRepository(service),
+		Storage:       NewStorageRepository(service, blobStore, cfg),
+		Folders:       NewFolderRepository(service),
+		Shares:        NewShareRepository(service),
+		Grants:        NewGrantRepository(service),
+		Notifications: NewNotificationRepository(service),
+		FileRequests:  NewFileRequestRepository(service),
+	}
+}
+
+// OpenStores returns the stores of the metadata store cfg names. DynamoDB
+// tables are created if they are missing, like ConnectDatabase does.
+func OpenStores(cfg *config.Config, blobStore blobstore.BlobStore) (*Stores, error) {
+	if cfg.MetadataStore.Driver == metastore.DriverBolt {
+		return OpenBoltStores(cfg.MetadataStore.Path, blobStore, cfg)
+	}
+	return NewDynamoDBStores(config.ConnectDatabase(cfg), blobStore, cfg), nil
+}
+
+var (
+	_ UserStore         = (*UserRepository)(nil)
+	_ StorageStore      = (*StorageRepository)(nil)
+	_ FolderStore       = (*FolderRepository)(nil)
+	_ ShareStore        = (*ShareRepository)(nil)
+	_ GrantStore        = (*GrantRepository)(nil)
+	_ NotificationStore = (*NotificationRepository)(nil)
+	_ FileRequestStore  = (*FileRequestRepository)(nil)
+)
@@ -0,0 +1,254 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// TrashFile moves an active file to the trash. ExpiresAt is set to the purge
+// deadline so the purger can find it through StatusExpiresAtIndex.
+func (r *StorageRepository) TrashFile(ctx context.Context, storageObj *models.StorageObject, purgeAt time.Time) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	updated := *storageObj
+	updated.Status = models.StatusTrashed
+	updated.DeletedAt = &now
+	updated.ExpiresAt = purgeAt.Unix()
+
+	changes := usageChanges{}
+	changes.addActivity(now, map[string]int64{
+		"Deletions":    1,
+		"DeletedBytes": storageObj.FileSize,
+		"NetBytes":     -storageObj.FileSize,
+	})
+
+	return r.changeFileStatus(ctx, storageObj, &updated, models.StatusActive, changes,
+		"SET #status = :newStatus, DeletedAt = :now, ExpiresAt = :purgeAt",
+		map[string]types.AttributeValue{
+			":newStatus": &types.AttributeValueMemberS{Value: models.StatusTrashed},
+			":now":       &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+			":purgeAt":   &types.AttributeValueMemberN{Value: strconv.FormatInt(purgeAt.Unix(), 10)},
+		})
+}
+
+// RestoreFile brings a trashed file back into parentID.
+func (r *StorageRepository) RestoreFile(ctx context.Context, storageObj *models.StorageObject, parentID string) (*models.StorageObject, error) {
+	updated := *storageObj
+	updated.Status = models.StatusActive
+	updated.ParentID = parentID
+	updated.DeletedAt = nil
+	updated.ExpiresAt = 0
+
+	changes := usageChanges{}
+	changes.addActivity(time.Now(), map[string]int64{"NetBytes": storageObj.FileSize})
+
+	return r.changeFileStatus(ctx, storageObj, &updated, models.StatusTrashed, changes,
+		"SET #status = :newStatus, ParentID = :parentID REMOVE DeletedAt, ExpiresAt",
+		map[string]types.AttributeValue{
+			":newStatus": &types.AttributeValueMemberS{Value: models.StatusActive},
+			":parentID":  &types.AttributeValueMemberS{Value: parentID},
+		})
+}
+
+// PurgeFile permanently removes a trashed file's metadata and all of its
+// versions. A file sharing a blob gives up its reference instead; the bytes go
+// with the last file that holds them. The file is first marked deleting, with
+// its bytes taken out of the usage totals and an outbox entry written in the
+// same transaction. If the S3 side cannot be finished right away,
+// ErrOutboxPending is returned and the outbox worker completes the delete.
+func (r *StorageRepository) PurgeFile(ctx context.Context, storageObj *models.StorageObject) error {
+	entry := newOutboxEntry(models.OutboxPurge, storageObj)
+	entryWrite, err := outboxPutWrite(entry)
+	if err != nil {
+		return err
+	}
+
+	changes := usageChanges{}
+	changes.add(storageObj, -1, true)
+
+	_, err = r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:         aws.String("SET #status = :deleting"),
+			ConditionExpression:      aws.String("#status = :trashed"),
+			ExpressionAttributeNames: statusAttributeNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":deleting": &types.AttributeValueMemberS{Value: models.StatusDeleting},
+				":trashed":  &types.AttributeValueMemberS{Value: models.StatusTrashed},
+			},
+		},
+	}, changes, entryWrite)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return ErrFileNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to mark file %s for deletion: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	deleting := *storageObj
+	deleting.Status = models.StatusDeleting
+
+	if err := r.finishPurge(ctx, &deleting, entry.OutboxID); err != nil {
+		log.Printf("Failed to purge file %s, leaving it to the outbox: %v", storageObj.ObjectID, err)
+		return ErrOutboxPending
+	}
+
+	return nil
+}
+
+// finishPurge deletes the S3 side of a file marked deleting, then its record
+// and outbox entry. Every step can be repeated, so the outbox worker runs it
+// again from the start after a failure.
+func (r *StorageRepository) finishPurge(ctx context.Context, storageObj *models.StorageObject, outboxID string) error {
+	extra := []types.TransactWriteItem{}
+	if storageObj.SharesBlob() {
+		extra = append(extra, releaseBlobWrite(storageObj.BlobKey))
+	} else if err := r.deleteAllVersions(ctx, storageObj); err != nil {
+		return err
+	}
+
+	_, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			ConditionExpression:      aws.String("#status = :deleting"),
+			ExpressionAttributeNames: statusAttributeNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":deleting": &types.AttributeValueMemberS{Value: models.StatusDeleting},
+			},
+		},
+	}, usageChanges{}, extra...)
+
+	// A failed condition means an earlier attempt already deleted the record
+	// and dropped its blob reference.
+	if err != nil && !errors.Is(err, errWriteConditionFailed) {
+		log.Printf("DeleteItem error for fileID : %s reason :%v", storageObj.ObjectID, err)
+		return err
+	}
+
+	if storageObj.SharesBlob() {
+		if err := r.deleteBlobIfUnused(ctx, storageObj.BlobKey); err != nil {
+			return err
+		}
+	}
+
+	return r.deleteOutboxEntry(ctx, outboxID)
+}
+
+// ListFilesByStatus returns all of a user's files in one status, unpaginated.
+func (r *StorageRepository) ListFilesByStatus(ctx context.Context, userID string, status string) ([]models.StorageObject, error) {
+	return r.queryUserFiles(ctx, userID, statusFilter(status), map[string]types.AttributeValue{
+		":status": &types.AttributeValueMemberS{Value: status},
+	})
+}
+
+// ListFilesByRequest returns the active files guests uploaded through one
+// file request.
+func (r *StorageRepository) ListFilesByRequest(ctx context.Context, userID string, requestID string) ([]models.StorageObject, error) {
+	return r.queryUserFiles(ctx, userID, "FileRequestID = :requestID AND "+statusFilter(models.StatusActive), map[string]types.AttributeValue{
+		":requestID": &types.AttributeValueMemberS{Value: requestID},
+		":status":    &types.AttributeValueMemberS{Value: models.StatusActive},
+	})
+}
+
+// ListAllUserFiles returns every file record of a user, whatever its status.
+func (r *StorageRepository) ListAllUserFiles(ctx context.Context, userID string) ([]models.StorageObject, error) {
+	return r.queryUserFiles(ctx, userID, "", map[string]types.AttributeValue{})
+}
+
+func (r *StorageRepository) queryUserFiles(ctx context.Context, userID string, filter string, values map[string]types.AttributeValue) ([]models.StorageObject, error) {
+	files := []models.StorageObject{}
+	values[":userID"] = &types.AttributeValueMemberS{Value: userID}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(StorageTable),
+		IndexName:                 aws.String("UserIDIndex"),
+		KeyConditionExpression:    aws.String("UserID = :userID"),
+		ExpressionAttributeValues: values,
+	}
+	if filter != "" {
+		input.FilterExpression = aws.String(filter)
+		input.ExpressionAttributeNames = statusAttributeNames
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, input)
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to query files of user %s: %v", userID, err)
+			return nil, err
+		}
+
+		var items []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal files: %v", err)
+			return nil, err
+		}
+		files = append(files, items...)
+	}
+
+	return files, nil
+}
+
+// changeFileStatus moves a file from fromStatus to the status of updated, and
+// its bytes between the active and trashed usage totals with it, on top of
+// the activity already in changes. The write
+// only goes through if the size and type are still what storageObj says, so
+// the totals move by the right amount.
+func (r *StorageRepository) changeFileStatus(ctx context.Context, storageObj *models.StorageObject, updated *models.StorageObject, fromStatus string, changes usageChanges, updateExpression string, values map[string]types.AttributeValue) (*models.StorageObject, error) {
+	values[":userID"] = &types.AttributeValueMemberS{Value: storageObj.UserID}
+	values[":status"] = &types.AttributeValueMemberS{Value: fromStatus}
+	values[":fileSize"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(storageObj.FileSize, 10)}
+	values[":contentType"] = &types.AttributeValueMemberS{Value: storageObj.ContentType}
+
+	changes.add(storageObj, -1, fromStatus == models.StatusTrashed)
+	changes.add(updated, 1, updated.Status == models.StatusTrashed)
+
+	current, err := r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:                    aws.String(updateExpression),
+			ConditionExpression:                 aws.String("UserID = :userID AND " + statusFilter(fromStatus) + " AND FileSize = :fileSize AND ContentType = :contentType AND attribute_not_exists(ReplaceID)"),
+			ExpressionAttributeNames:            statusAttributeNames,
+			ExpressionAttributeValues:           values,
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		},
+	}, changes)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		var found models.StorageObject
+		if current == nil || attributevalue.UnmarshalMap(current, &found) != nil || found.UserID != storageObj.UserID {
+			return nil, ErrFileNotFound
+		}
+		if (fromStatus == models.StatusActive && !found.IsActive()) || (fromStatus != models.StatusActive && found.Status != fromStatus) {
+			return nil, ErrFileNotFound
+		}
+		return nil, ErrVersionConflict
+	}
+	if err != nil {
+		log.Printf("UpdateItem error for fileID %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,410 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUploadSessionNotPending = errors.New("upload session is not pending")
+	ErrUploadMismatch          = errors.New("uploaded object does not match the upload session")
+)
+
+func (r *StorageRepository) CreatePendingFile(ctx context.Context, userID string, parentID string, fileName string, fileSize int64, contentType string, description *string, expiresAt time.Time) (*models.StorageObject, error) {
+	return r.createPendingFile(ctx, &models.StorageObject{
+		UserID:      userID,
+		ParentID:    parentID,
+		FileName:    fileName,
+		FileSize:    fileSize,
+		ContentType: contentType,
+		Description: description,
+	}, expiresAt)
+}
+
+// createPendingFile gives a new file its ID and records it as pending until
+// expiresAt, with its bytes to be uploaded under users/<id>/<objectID>.
+func (r *StorageRepository) createPendingFile(ctx context.Context, storageObj *models.StorageObject, expiresAt time.Time) (*models.StorageObject, error) {
+	now := time.Now().UTC()
+
+	storageObj.ObjectID = uuid.New().String()
+	storageObj.S3Key = fmt.Sprintf("users/%s/%s", storageObj.UserID, storageObj.ObjectID)
+	storageObj.S3Bucket = r.bucketName
+	storageObj.UploadedAt = now
+	storageObj.UpdatedAt = now
+	storageObj.Status = models.StatusPending
+	storageObj.ExpiresAt = expiresAt.Unix()
+	storageObj.Version = 1
+
+	item, err := attributevalue.MarshalMap(storageObj)
+	if err != nil {
+		log.Printf("Failed to marshal storage object: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(StorageTable),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(ObjectID)"),
+	})
+
+	if err != nil {
+		log.Printf("Failed to save pending upload to DynamoDB: %v", err)
+		return nil, err
+	}
+
+	return storageObj, nil
+}
+
+func (r *fileBlobs) PresignUpload(ctx context.Context, storageObj *models.StorageObject, method string, expiresIn time.Duration) (*models.UploadSessionResponse, error) {
+	options := blobstore.PresignUploadOptions{
+		ExpiresIn:   expiresIn,
+		ContentType: storageObj.ContentType,
+		Size:        storageObj.FileSize,
+	}
+
+	response := &models.UploadSessionResponse{
+		ObjectID:  storageObj.ObjectID,
+		Method:    method,
+		ExpiresAt: time.Unix(storageObj.ExpiresAt, 0).UTC(),
+	}
+
+	if method == "post" {
+		request, err := r.blobStore.PresignPost(ctx, storageObj.S3Key, options)
+		if err != nil {
+			return nil, err
+		}
+
+		response.URL = request.URL
+		response.Fields = request.Fields
+		return response, nil
+	}
+
+	request, err := r.blobStore.PresignPut(ctx, storageObj.S3Key, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client has to send these headers verbatim or the PUT is rejected.
+	response.URL = request.URL
+	response.Headers = request.Headers
+
+	return response, nil
+}
+
+// ActivateFile checks the object of a multipart upload against the pending
+// record and flips the record to active, leaving the object where it is. The
+// server completed the upload itself, so no one can write to the key again;
+// the file keeps whichever full-object checksums the blob store has for it.
+func (r *StorageRepository) ActivateFile(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error) {
+	head, err := r.blobStore.Head(ctx, storageObj.S3Key, "")
+	if err != nil {
+		log.Printf("Head error for pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, ErrUploadMismatch
+	}
+
+	if head.Size != storageObj.FileSize || head.ContentType != storageObj.ContentType {
+		return nil, ErrUploadMismatch
+	}
+
+	now := time.Now().UTC()
+
+	activated := *storageObj
+	activated.Status = models.StatusActive
+	activated.ETag = head.ETag
+	activated.VersionID = head.VersionID
+	activated.UpdatedAt = now
+	activated.ExpiresAt = 0
+	activated.ChecksumSHA256 = head.ChecksumSHA256
+	activated.ChecksumCRC32C = head.ChecksumCRC32C
+	activated.ContentHash = contentHashOf(activated.ChecksumSHA256)
+
+	updateExpression := "SET #status = :active, ETag = :etag, VersionID = :versionID, UpdatedAt = :now"
+	values := map[string]types.AttributeValue{
+		":active":    &types.AttributeValueMemberS{Value: models.StatusActive},
+		":pending":   &types.AttributeValueMemberS{Value: models.StatusPending},
+		":etag":      &types.AttributeValueMemberS{Value: activated.ETag},
+		":versionID": &types.AttributeValueMemberS{Value: activated.VersionID},
+		":now":       &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+		":userID":    &types.AttributeValueMemberS{Value: storageObj.UserID},
+	}
+	if activated.ChecksumSHA256 != "" {
+		updateExpression += ", ChecksumSHA256 = :sha256, ContentHash = :contentHash"
+		values[":sha256"] = &types.AttributeValueMemberS{Value: activated.ChecksumSHA256}
+		values[":contentHash"] = &types.AttributeValueMemberS{Value: activated.ContentHash}
+	}
+	if activated.ChecksumCRC32C != "" {
+		updateExpression += ", ChecksumCRC32C = :crc32c"
+		values[":crc32c"] = &types.AttributeValueMemberS{Value: activated.ChecksumCRC32C}
+	}
+
+	changes := usageChanges{}
+	changes.add(&activated, 1, false)
+	changes.addActivity(now, uploadActivity(&activated))
+
+	_, err = r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:          aws.String(updateExpression + " REMOVE ExpiresAt"),
+			ConditionExpression:       aws.String("UserID = :userID AND #status = :pending"),
+			ExpressionAttributeNames:  statusAttributeNames,
+			ExpressionAttributeValues: values,
+		},
+	}, changes)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return nil, ErrUploadSessionNotPending
+	}
+	if err != nil {
+		log.Printf("Failed to activate upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	return &activated, nil
+}
+
+// ActivateDirectUpload stores the object a client uploaded with a presigned
+// request the way putFile stores uploads that pass through the server: the
+// version it finds is read for its checksums and moved into its blob by
+// activateUpload. A presigned request sent again afterwards cannot change the
+// file; whatever it writes is left under the upload key for reconcile to
+// remove.
+func (r *StorageRepository) ActivateDirectUpload(ctx context.Context, storageObj *models.StorageObject) (*models.StorageObject, error) {
+	uploadKey := storageObj.S3Key
+
+	head, err := r.blobStore.Head(ctx, uploadKey, "")
+	if err != nil {
+		log.Printf("Head error for pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, ErrUploadMismatch
+	}
+
+	if head.Size != storageObj.FileSize || head.ContentType != storageObj.ContentType {
+		return nil, ErrUploadMismatch
+	}
+
+	object, err := r.blobStore.Get(ctx, uploadKey, blobstore.GetOptions{VersionID: head.VersionID})
+	if err != nil {
+		log.Printf("Failed to read pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+	digest, size, err := digestReader(object.Body)
+	object.Body.Close()
+	if err != nil {
+		log.Printf("Failed to checksum pending upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+	if size != storageObj.FileSize {
+		return nil, ErrUploadMismatch
+	}
+
+	return r.activateUpload(ctx, storageObj, head.VersionID, digest)
+}
+
+// activateUpload moves the bytes of a pending file, stored as versionID of
+// its upload key, into the content-addressed blob and activates the file.
+// The bytes are copied only if no file has the same content yet; otherwise
+// the file shares the existing blob. Either way the upload key is emptied.
+// The record is marked uploading along with a reference to the blob and an
+// outbox entry first, as in putFile. If the move fails the file goes back to
+// pending, unless the outbox took over.
+func (r *StorageRepository) activateUpload(ctx context.Context, storageObj *models.StorageObject, versionID string, digest *contentDigest) (*models.StorageObject, error) {
+	uploadKey := storageObj.S3Key
+
+	uploading := *storageObj
+	uploading.ContentHash = digest.hash
+	uploading.BlobKey = r.blobKey(storageObj.UserID, digest.hash)
+	uploading.S3Key = uploading.BlobKey
+	uploading.ChecksumSHA256 = digest.sha256
+	uploading.ChecksumCRC32C = digest.crc32c
+	uploading.UpdatedAt = time.Now().UTC()
+	uploading.ExpiresAt = 0
+	uploading.Status = models.StatusUploading
+
+	entry := newOutboxEntry(models.OutboxUpload, &uploading)
+	entryWrite, err := outboxPutWrite(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.writeWithUsage(ctx, storageObj.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+			},
+			UpdateExpression:         aws.String("SET #status = :uploading, ContentHash = :contentHash, BlobKey = :blobKey, S3Key = :blobKey, ChecksumSHA256 = :sha256, ChecksumCRC32C = :crc32c, UpdatedAt = :now REMOVE ExpiresAt"),
+			ConditionExpression:      aws.String("UserID = :userID AND #status = :pending"),
+			ExpressionAttributeNames: statusAttributeNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":uploading":   &types.AttributeValueMemberS{Value: models.StatusUploading},
+				":pending":     &types.AttributeValueMemberS{Value: models.StatusPending},
+				":userID":      &types.AttributeValueMemberS{Value: storageObj.UserID},
+				":contentHash": &types.AttributeValueMemberS{Value: uploading.ContentHash},
+				":blobKey":     &types.AttributeValueMemberS{Value: uploading.BlobKey},
+				":sha256":      &types.AttributeValueMemberS{Value: uploading.ChecksumSHA256},
+				":crc32c":      &types.AttributeValueMemberS{Value: uploading.ChecksumCRC32C},
+				":now":         &types.AttributeValueMemberS{Value: uploading.UpdatedAt.Format(time.RFC3339Nano)},
+			},
+		},
+	}, usageChanges{}, acquireBlobWrite(uploading.BlobKey), entryWrite)
+
+	if errors.Is(err, errWriteConditionFailed) {
+		return nil, ErrUploadSessionNotPending
+	}
+	if err != nil {
+		log.Printf("Failed to start activating upload %s: %v", storageObj.ObjectID, err)
+		return nil, err
+	}
+
+	release := holdOutboxEntry(ctx, entry, r.moveOutboxEntry)
+	blob, err := r.getBlob(ctx, uploading.BlobKey)
+	if err == nil && (blob == nil || blob.VersionID == "") {
+		blob, err = r.copyBlob(ctx, uploadKey, versionID, uploading.BlobKey, uploading.FileSize, digest)
+	}
+	release()
+
+	var activated *models.StorageObject
+	if err == nil {
+		activated, err = r.finishUpload(ctx, &uploading, blob, entry.OutboxID)
+	}
+
+	if errors.Is(err, ErrUploadRolledBack) {
+		// The worker removed the file, so nothing needs the upload key any
+		// more, and it released the blob reference, possibly before the copy.
+		if err := r.deleteBlobIfUnused(ctx, uploading.BlobKey); err != nil {
+			log.Printf("Failed to delete blob of rolled back file %s: %v", storageObj.ObjectID, err)
+		}
+		if err := r.blobStore.DeleteAllVersions(ctx, uploadKey); err != nil {
+			log.Printf("Failed to empty upload key of rolled back file %s: %v", storageObj.ObjectID, err)
+		}
+		return nil, ErrUploadRolledBack
+	}
+
+	if err != nil {
+		revertErr := r.revertDirectUpload(ctx, storageObj, &uploading, entry.OutboxID)
+		if errors.Is(revertErr, ErrUploadRolledBack) {
+			return nil, ErrUploadRolledBack
+		}
+		if revertErr != nil {
+			log.Printf("Failed to return upload %s to pending, leaving it to the outbox: %v", storageObj.ObjectID, revertErr)
+			return nil, ErrOutboxPending
+		}
+		return nil, err
+	}
+
+	if err := r.blobStore.DeleteAllVersions(ctx, uploadKey); err != nil {
+		log.Printf("Failed to empty upload key of file %s: %v", storageObj.ObjectID, err)
+	}
+
+	return activated, nil
+}
+
+// revertDirectUpload puts a direct upload that could not be moved into its
+// blob back to pending, so the client can complete it again. It returns
+// ErrUploadRolledBack if the outbox worker removed the file first.
+func (r *StorageRepository) revertDirectUpload(ctx context.Context, pending *models.StorageObject, uploading *models.StorageObject, outboxID string) error {
+	current, err := r.writeWithUsage(ctx, pending.UserID, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(StorageTable),
+			Key: map[string]types.AttributeValue{
+				"ObjectID": &types.AttributeValueMemberS{Value: pending.ObjectID},
+			},
+			UpdateExpression:         aws.String("SET #status = :pending, S3Key = :s3Key, ExpiresAt = :expiresAt REMOVE ContentHash, BlobKey, ChecksumSHA256, ChecksumCRC32C"),
+			ConditionExpression:      aws.String("#status = :uploading"),
+			ExpressionAttributeNames: statusAttributeNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pending":   &types.AttributeValueMemberS{Value: models.StatusPending},
+				":uploading": &types.AttributeValueMemberS{Value: models.StatusUploading},
+				":s3Key":     &types.AttributeValueMemberS{Value: pending.S3Key},
+				":expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(pending.ExpiresAt, 10)},
+			},
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		},
+	}, usageChanges{}, releaseBlobWrite(uploading.BlobKey), outboxDeleteWrite(outboxID))
+
+	if errors.Is(err, errWriteConditionFailed) {
+		if current == nil {
+			return ErrUploadRolledBack
+		}
+		return ErrFileNotFound
+	}
+	if err != nil {
+		log.Printf("Failed to return upload %s to pending: %v", pending.ObjectID, err)
+		return err
+	}
+
+	return r.deleteBlobIfUnused(ctx, uploading.BlobKey)
+}
+
+func (r *StorageRepository) ListExpiredFiles(ctx context.Context, status string, before time.Time) ([]models.StorageObject, error) {
+	var files []models.StorageObject
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:                aws.String(StorageTable),
+		IndexName:                aws.String("StatusExpiresAtIndex"),
+		KeyConditionExpression:   aws.String("#status = :status AND ExpiresAt < :before"),
+		ExpressionAttributeNames: statusAttributeNames,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status": &types.AttributeValueMemberS{Value: status},
+			":before": &types.AttributeValueMemberN{Value: strconv.FormatInt(before.Unix(), 10)},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to query expired %s files: %v", status, err)
+			return nil, err
+		}
+
+		var items []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal expired files: %v", err)
+			return nil, err
+		}
+		files = append(files, items...)
+	}
+
+	return files, nil
+}
+
+// DeletePendingFile removes an upload session that was never completed,
+// including whatever the client managed to put in the blob store.
+func (r *StorageRepository) DeletePendingFile(ctx context.Context, storageObj *models.StorageObject) error {
+	_, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(StorageTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID": &types.AttributeValueMemberS{Value: storageObj.ObjectID},
+		},
+		ConditionExpression:      aws.String("#status = :pending"),
+		ExpressionAttributeNames: statusAttributeNames,
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: models.StatusPending},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrUploadSessionNotPending
+		}
+		log.Printf("DeleteItem error for pending upload %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteAllVersions(ctx, storageObj)
+}
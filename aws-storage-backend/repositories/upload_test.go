@@ -0,0 +1,270 @@
+package repositories_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// directUpload starts an upload session for content and puts what the client
+// sends with the presigned request under its upload key.
+func directUpload(t *testing.T, storage repositories.StorageStore, blobStore blobstore.BlobStore, content string, sent string) *models.StorageObject {
+	t.Helper()
+	ctx := context.Background()
+
+	pending, err := storage.CreatePendingFile(ctx, "user-1", models.RootFolderID, "a.txt", int64(len(content)), "text/plain", nil, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CreatePendingFile: %v", err)
+	}
+	if _, err := blobStore.Put(ctx, pending.S3Key, strings.NewReader(sent), blobstore.PutOptions{ContentType: "text/plain"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	return pending
+}
+
+func readFile(t *testing.T, storage repositories.StorageStore, objectID string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	storageObj, err := storage.GetFileByID(ctx, objectID)
+	if err != nil {
+		t.Fatalf("GetFileByID: %v", err)
+	}
+	download, err := storage.OpenFile(ctx, storageObj, models.DownloadRequest{})
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer download.Body.Close()
+
+	content, err := io.ReadAll(download.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return string(content)
+}
+
+// readOnce is a request body that cannot be rewound: reading it again after
+// it ended or seeking in it fails the test.
+type readOnce struct {
+	t    *testing.T
+	body io.Reader
+	done bool
+}
+
+func (r *readOnce) Read(p []byte) (int, error) {
+	if r.done {
+		r.t.Errorf("body read again after it ended")
+	}
+	n, err := r.body.Read(p)
+	if err == io.EOF {
+		r.done = true
+	}
+	return n, err
+}
+
+func (r *readOnce) Seek(offset int64, whence int) (int64, error) {
+	r.t.Errorf("body rewound")
+	return 0, errors.New("body cannot be rewound")
+}
+
+// brokenStore fails every Put after reading the body.
+type brokenStore struct {
+	blobstore.BlobStore
+}
+
+func (s brokenStore) Put(ctx context.Context, key string, body io.Reader, options blobstore.PutOptions) (*blobstore.ObjectInfo, error) {
+	io.Copy(io.Discard, body)
+	return nil, errors.New("connection reset")
+}
+
+func TestUploadFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		size     int64
+		broken   bool
+		wantErr  error
+	}{
+		{name: "new content", content: "hello", size: 5},
+		{name: "content another file has", existing: "hello", content: "hello", size: 5},
+		{name: "body shorter than declared", content: "hell", size: 5, wantErr: repositories.ErrUploadMismatch},
+		{name: "write fails", content: "hello", size: 5, broken: true},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				memory := blobstore.NewMemoryStore(nil)
+				var blobStore blobstore.BlobStore = memory
+				if tt.broken {
+					blobStore = brokenStore{memory}
+				}
+				storage := driver.Open(t, blobStore, config.Defaults()).Storage
+
+				var existing *models.StorageObject
+				if tt.existing != "" {
+					var err error
+					existing, err = storage.UploadFile(ctx, "user-1", models.RootFolderID, "existing.txt", int64(len(tt.existing)), "text/plain", strings.NewReader(tt.existing), nil)
+					if err != nil {
+						t.Fatalf("UploadFile: %v", err)
+					}
+				}
+
+				body := &readOnce{t: t, body: strings.NewReader(tt.content)}
+				uploaded, err := storage.UploadFile(ctx, "user-1", models.RootFolderID, "a.txt", tt.size, "text/plain", body, nil)
+				if tt.wantErr != nil || tt.broken {
+					if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+						t.Fatalf("UploadFile: %v, want %v", err, tt.wantErr)
+					}
+					if files, err := storage.ListAllUserFiles(ctx, "user-1"); err != nil || len(files) != 0 {
+						t.Errorf("failed upload left files %+v (%v)", files, err)
+					}
+					if objects, err := memory.List(ctx, ""); err != nil || len(objects) != 0 {
+						t.Errorf("failed upload left objects %v (%v)", objects, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("UploadFile: %v", err)
+				}
+
+				sum := sha256.Sum256([]byte(tt.content))
+				if uploaded.ContentHash != hex.EncodeToString(sum[:]) || uploaded.ChecksumSHA256 == "" || uploaded.ChecksumCRC32C == "" {
+					t.Errorf("uploaded file %+v does not record the checksums of its content", uploaded)
+				}
+				if uploaded.S3Key != uploaded.BlobKey || uploaded.Status != models.StatusActive || uploaded.ExpiresAt != 0 {
+					t.Errorf("uploaded file %+v is not an active file stored in its blob", uploaded)
+				}
+				if existing != nil && existing.BlobKey != uploaded.BlobKey {
+					t.Errorf("uploaded file is in blob %s, want the blob %s of the file with the same content", uploaded.BlobKey, existing.BlobKey)
+				}
+
+				// Only the blob is left: the upload key was emptied and
+				// content another file has is not stored twice.
+				objects, err := memory.List(ctx, "")
+				if err != nil || len(objects) != 1 || objects[0].Key != uploaded.BlobKey {
+					t.Errorf("blob store holds %v (%v), want only blob %s", objects, err, uploaded.BlobKey)
+				}
+				if got := readFile(t, storage, uploaded.ObjectID); got != tt.content {
+					t.Errorf("file reads %q, want %q", got, tt.content)
+				}
+			})
+		}
+	})
+}
+
+func TestActivateDirectUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		sent     string
+		wantErr  error
+	}{
+		{name: "new content", content: "hello", sent: "hello"},
+		{name: "content another file has", existing: "hello", content: "hello", sent: "hello"},
+		{name: "wrong size", content: "hello", sent: "hello world", wantErr: repositories.ErrUploadMismatch},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				blobStore := blobstore.NewMemoryStore(nil)
+				storage := driver.Open(t, blobStore, config.Defaults()).Storage
+
+				var existing *models.StorageObject
+				if tt.existing != "" {
+					var err error
+					existing, err = storage.UploadFile(ctx, "user-1", models.RootFolderID, "existing.txt", int64(len(tt.existing)), "text/plain", strings.NewReader(tt.existing), nil)
+					if err != nil {
+						t.Fatalf("UploadFile: %v", err)
+					}
+				}
+
+				pending := directUpload(t, storage, blobStore, tt.content, tt.sent)
+				activated, err := storage.ActivateDirectUpload(ctx, pending)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("ActivateDirectUpload: %v, want %v", err, tt.wantErr)
+					}
+					if file, err := storage.GetFileByID(ctx, pending.ObjectID); err != nil || file.Status != models.StatusPending {
+						t.Fatalf("session after failed activation: %+v, %v; want it pending", file, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("ActivateDirectUpload: %v", err)
+				}
+
+				sum := sha256.Sum256([]byte(tt.content))
+				if activated.ContentHash != hex.EncodeToString(sum[:]) || activated.S3Key != activated.BlobKey || !activated.SharesBlob() {
+					t.Errorf("activated file %+v is not stored in the blob of its content", activated)
+				}
+				if existing != nil && existing.BlobKey != activated.BlobKey {
+					t.Errorf("activated file is in blob %s, want the blob %s of the file with the same content", activated.BlobKey, existing.BlobKey)
+				}
+				if objects, err := blobStore.List(ctx, pending.S3Key); err != nil || len(objects) != 0 {
+					t.Errorf("upload key still holds %v (%v)", objects, err)
+				}
+
+				// The blob is held by both files, so purging the other one must
+				// leave it in place.
+				if existing != nil {
+					trashed, err := storage.TrashFile(ctx, existing, time.Now())
+					if err != nil {
+						t.Fatalf("TrashFile: %v", err)
+					}
+					if err := storage.PurgeFile(ctx, trashed); err != nil {
+						t.Fatalf("PurgeFile: %v", err)
+					}
+				}
+				if got := readFile(t, storage, activated.ObjectID); got != tt.content {
+					t.Errorf("file reads %q, want %q", got, tt.content)
+				}
+				if exists, err := storage.BlobExists(ctx, activated.BlobKey); err != nil || !exists {
+					t.Errorf("blob record of the activated file is gone (%v)", err)
+				}
+			})
+		}
+	})
+}
+
+func TestPresignedUploadSentAgainAfterActivation(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		blobStore := blobstore.NewMemoryStore(nil)
+		storage := driver.Open(t, blobStore, config.Defaults()).Storage
+
+		pending := directUpload(t, storage, blobStore, "hello", "hello")
+		activated, err := storage.ActivateDirectUpload(ctx, pending)
+		if err != nil {
+			t.Fatalf("ActivateDirectUpload: %v", err)
+		}
+
+		// The presigned PUT is still valid and the client sends other bytes.
+		if _, err := blobStore.Put(ctx, pending.S3Key, strings.NewReader("HELLO"), blobstore.PutOptions{ContentType: "text/plain"}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		if got := readFile(t, storage, activated.ObjectID); got != "hello" {
+			t.Errorf("file reads %q after the presigned request was sent again, want %q", got, "hello")
+		}
+		if _, err := storage.ActivateDirectUpload(ctx, pending); !errors.Is(err, repositories.ErrUploadSessionNotPending) {
+			t.Errorf("second activation: %v, want ErrUploadSessionNotPending", err)
+		}
+	})
+}
@@ -0,0 +1,371 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const UsageTable = "usage_aggregate"
+
+// usageMonthLayout formats the UTC month a file counts towards.
+const usageMonthLayout = "2006-01"
+
+// usageHourLayout formats the UTC hour an activity item covers.
+const usageHourLayout = "2006-01-02T15"
+
+// Aggregate keys are the month ("2006-01"), the month and a content type
+// ("2006-01#image/png"), or one of these prefixes followed by a folder ID or
+// an hour.
+const (
+	folderUsagePrefix   = "folder#"
+	activityUsagePrefix = "activity#"
+)
+
+// usageWriteAttempts is how often a write is tried when its transaction loses
+// a race with another write to the same aggregate item.
+const usageWriteAttempts = 3
+
+// errWriteConditionFailed means the condition on the file item of a
+// transaction failed, as opposed to the transaction losing a race.
+var errWriteConditionFailed = errors.New("write condition failed")
+
+// usageChange is what one write does to one aggregate item: the string
+// attributes that describe the item, and how much each counter moves.
+type usageChange struct {
+	labels   map[string]string
+	counters map[string]int64
+}
+
+// usageChanges collects the changes of one write by aggregate key, so that a
+// transaction touches every aggregate item only once.
+type usageChanges map[string]*usageChange
+
+func (c usageChanges) item(key string, labels map[string]string) *usageChange {
+	change, ok := c[key]
+	if !ok {
+		change = &usageChange{labels: labels, counters: map[string]int64{}}
+		c[key] = change
+	}
+	return change
+}
+
+// add counts storageObj into (sign 1) or out of (sign -1) the totals of its
+// month, content type and folder, on the active or on the trashed side.
+func (c usageChanges) add(storageObj *models.StorageObject, sign int64, trashed bool) {
+	month := storageObj.UploadedAt.UTC().Format(usageMonthLayout)
+
+	folderID := storageObj.ParentID
+	if folderID == "" {
+		folderID = models.RootFolderID
+	}
+
+	changes := []*usageChange{
+		c.item(month, map[string]string{"Month": month}),
+		c.item(folderUsagePrefix+folderID, map[string]string{"FolderID": folderID}),
+	}
+	if storageObj.ContentType != "" {
+		changes = append(changes, c.item(month+"#"+storageObj.ContentType, map[string]string{"Month": month, "ContentType": storageObj.ContentType}))
+	}
+
+	bytesCounter, filesCounter := "Bytes", "FileCount"
+	if trashed {
+		bytesCounter, filesCounter = "TrashedBytes", "TrashedFiles"
+	}
+
+	for _, change := range changes {
+		change.counters[bytesCounter] += sign * storageObj.FileSize
+		change.counters[filesCounter] += sign
+	}
+}
+
+// addActivity counts an event in the activity item of the hour at falls in.
+func (c usageChanges) addActivity(at time.Time, counters map[string]int64) {
+	hour := at.UTC().Format(usageHourLayout)
+	change := c.item(activityUsagePrefix+hour, map[string]string{"Hour": hour})
+
+	for name, value := range counters {
+		change.counters[name] += value
+	}
+}
+
+func uploadActivity(storageObj *models.StorageObject) map[string]int64 {
+	return map[string]int64{
+		"Uploads":       1,
+		"UploadedBytes": storageObj.FileSize,
+		"NetBytes":      storageObj.FileSize,
+	}
+}
+
+// transactItems turns the changes into ADD updates on the aggregate items.
+// Counters that do not move, and items with none that do, are left out.
+func (c usageChanges) transactItems(userID string) []types.TransactWriteItem {
+	items := []types.TransactWriteItem{}
+
+	for key, change := range c {
+		names := map[string]string{}
+		values := map[string]types.AttributeValue{}
+
+		adds := []string{}
+		for name, value := range change.counters {
+			if value == 0 {
+				continue
+			}
+			placeholder := strconv.Itoa(len(names))
+			names["#n"+placeholder] = name
+			values[":v"+placeholder] = &types.AttributeValueMemberN{Value: strconv.FormatInt(value, 10)}
+			adds = append(adds, fmt.Sprintf("#n%s :v%s", placeholder, placeholder))
+		}
+		if len(adds) == 0 {
+			continue
+		}
+
+		sets := []string{}
+		for name, value := range change.labels {
+			placeholder := strconv.Itoa(len(names))
+			names["#n"+placeholder] = name
+			values[":v"+placeholder] = &types.AttributeValueMemberS{Value: value}
+			sets = append(sets, fmt.Sprintf("#n%s = :v%s", placeholder, placeholder))
+		}
+
+		items = append(items, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(UsageTable),
+				Key: map[string]types.AttributeValue{
+					"UserID":   &types.AttributeValueMemberS{Value: userID},
+					"UsageKey": &types.AttributeValueMemberS{Value: key},
+				},
+				UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ") + " ADD " + strings.Join(adds, ", ")),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		})
+	}
+
+	return items
+}
+
+// writeWithUsage runs write in one transaction with the aggregate updates in
+// changes and any extra writes that belong with it, so the totals cannot
+// drift from the files they count. If the condition of write fails, the item
+// it found is returned together with errWriteConditionFailed.
+func (r *StorageRepository) writeWithUsage(ctx context.Context, userID string, write types.TransactWriteItem, changes usageChanges, extra ...types.TransactWriteItem) (map[string]types.AttributeValue, error) {
+	items := append([]types.TransactWriteItem{write}, extra...)
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: append(items, changes.transactItems(userID)...),
+	}
+
+	var err error
+	for attempt := 0; attempt < usageWriteAttempts; attempt++ {
+		_, err = r.dynamoService.Client.TransactWriteItems(ctx, input)
+		if err == nil {
+			return nil, nil
+		}
+
+		var cancelled *types.TransactionCanceledException
+		if !errors.As(err, &cancelled) {
+			break
+		}
+
+		if len(cancelled.CancellationReasons) > 0 && aws.ToString(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return cancelled.CancellationReasons[0].Item, errWriteConditionFailed
+		}
+
+		if !lostTransactionRace(cancelled) {
+			break
+		}
+	}
+
+	log.Printf("Failed to write file of user %s with usage: %v", userID, err)
+	return nil, err
+}
+
+func lostTransactionRace(cancelled *types.TransactionCanceledException) bool {
+	for _, reason := range cancelled.CancellationReasons {
+		if aws.ToString(reason.Code) == "TransactionConflict" {
+			return true
+		}
+	}
+	return false
+}
+
+// ListMonthlyUsage returns the user's month and content type aggregates,
+// oldest month first.
+func (r *StorageRepository) ListMonthlyUsage(ctx context.Context, userID string) ([]models.UsageAggregate, error) {
+	aggregates := []models.UsageAggregate{}
+	err := r.queryUsage(ctx, userID, "0", "9~", &aggregates)
+	return aggregates, err
+}
+
+// ListFolderUsage returns the aggregates of the folders the user has files in.
+func (r *StorageRepository) ListFolderUsage(ctx context.Context, userID string) ([]models.UsageAggregate, error) {
+	aggregates := []models.UsageAggregate{}
+	err := r.queryUsage(ctx, userID, folderUsagePrefix, folderUsagePrefix+"~", &aggregates)
+	return aggregates, err
+}
+
+// ListUsageActivity returns the user's activity items for the hours from
+// from up to and including to.
+func (r *StorageRepository) ListUsageActivity(ctx context.Context, userID string, from time.Time, to time.Time) ([]models.UsageActivity, error) {
+	activity := []models.UsageActivity{}
+	err := r.queryUsage(ctx, userID,
+		activityUsagePrefix+from.UTC().Format(usageHourLayout),
+		activityUsagePrefix+to.UTC().Format(usageHourLayout),
+		&activity)
+	return activity, err
+}
+
+// queryUsage reads the user's aggregate items with keys between lower and
+// upper into out, which must point to a slice.
+func (r *StorageRepository) queryUsage(ctx context.Context, userID string, lower string, upper string, out interface{}) error {
+	items := []map[string]types.AttributeValue{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(UsageTable),
+		KeyConditionExpression: aws.String("UserID = :userID AND UsageKey BETWEEN :lower AND :upper"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":userID": &types.AttributeValueMemberS{Value: userID},
+			":lower":  &types.AttributeValueMemberS{Value: lower},
+			":upper":  &types.AttributeValueMemberS{Value: upper},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to query usage of user %s: %v", userID, err)
+			return err
+		}
+		items = append(items, page.Items...)
+	}
+
+	if err := attributevalue.UnmarshalListOfMaps(items, out); err != nil {
+		log.Printf("Failed to unmarshal usage of user %s: %v", userID, err)
+		return err
+	}
+
+	return nil
+}
+
+// RebuildUsage recomputes every aggregate from the storage table and replaces
+// the stored ones. Activity items record history the files no longer hold, so
+// they are kept as they are. Writes that happen while it runs may be counted twice or
+// not at all, so it is meant to run while the server is stopped. It returns
+// how many users were rebuilt.
+func (r *StorageRepository) RebuildUsage(ctx context.Context) (int, error) {
+	rebuilt := map[string]usageChanges{}
+
+	files := dynamodb.NewScanPaginator(r.dynamoService.Client, &dynamodb.ScanInput{
+		TableName: aws.String(StorageTable),
+	})
+
+	for files.HasMorePages() {
+		page, err := files.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan files: %v", err)
+			return 0, err
+		}
+
+		var items []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal files: %v", err)
+			return 0, err
+		}
+
+		for i := range items {
+			if !items[i].IsActive() && items[i].Status != models.StatusTrashed {
+				// Pending, uploading and deleting files are not counted.
+				continue
+			}
+
+			changes, ok := rebuilt[items[i].UserID]
+			if !ok {
+				changes = usageChanges{}
+				rebuilt[items[i].UserID] = changes
+			}
+			changes.add(&items[i], 1, items[i].Status == models.StatusTrashed)
+		}
+	}
+
+	for userID, changes := range rebuilt {
+		for key, change := range changes {
+			aggregate := models.UsageAggregate{
+				UserID:       userID,
+				UsageKey:     key,
+				Month:        change.labels["Month"],
+				ContentType:  change.labels["ContentType"],
+				FolderID:     change.labels["FolderID"],
+				Bytes:        change.counters["Bytes"],
+				FileCount:    change.counters["FileCount"],
+				TrashedBytes: change.counters["TrashedBytes"],
+				TrashedFiles: change.counters["TrashedFiles"],
+			}
+
+			item, err := attributevalue.MarshalMap(aggregate)
+			if err != nil {
+				log.Printf("Failed to marshal usage aggregate: %v", err)
+				return 0, err
+			}
+
+			_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName: aws.String(UsageTable),
+				Item:      item,
+			})
+			if err != nil {
+				log.Printf("Failed to save usage aggregate %s of user %s: %v", key, userID, err)
+				return 0, err
+			}
+		}
+	}
+
+	// Aggregates whose files are all gone are not in the rebuilt set and have
+	// to be removed.
+	stale := dynamodb.NewScanPaginator(r.dynamoService.Client, &dynamodb.ScanInput{
+		TableName:            aws.String(UsageTable),
+		ProjectionExpression: aws.String("UserID, UsageKey"),
+	})
+
+	for stale.HasMorePages() {
+		page, err := stale.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan usage aggregates: %v", err)
+			return 0, err
+		}
+
+		var items []models.UsageAggregate
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal usage aggregates: %v", err)
+			return 0, err
+		}
+
+		for _, aggregate := range items {
+			if _, ok := rebuilt[aggregate.UserID][aggregate.UsageKey]; ok || strings.HasPrefix(aggregate.UsageKey, activityUsagePrefix) {
+				continue
+			}
+
+			_, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(UsageTable),
+				Key: map[string]types.AttributeValue{
+					"UserID":   &types.AttributeValueMemberS{Value: aggregate.UserID},
+					"UsageKey": &types.AttributeValueMemberS{Value: aggregate.UsageKey},
+				},
+			})
+			if err != nil {
+				log.Printf("Failed to delete usage aggregate %s of user %s: %v", aggregate.UsageKey, aggregate.UserID, err)
+				return 0, err
+			}
+		}
+	}
+
+	return len(rebuilt), nil
+}
@@ -0,0 +1,119 @@
+package repositories_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// usage returns the month, content type and folder aggregates of a user by
+// key.
+func usage(t *testing.T, storage repositories.StorageStore, userID string) map[string]models.UsageAggregate {
+	t.Helper()
+	ctx := context.Background()
+
+	monthly, err := storage.ListMonthlyUsage(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListMonthlyUsage: %v", err)
+	}
+	folders, err := storage.ListFolderUsage(ctx, userID)
+	if err != nil {
+		t.Fatalf("ListFolderUsage: %v", err)
+	}
+
+	aggregates := map[string]models.UsageAggregate{}
+	for _, aggregate := range append(monthly, folders...) {
+		aggregates[aggregate.UsageKey] = aggregate
+	}
+	return aggregates
+}
+
+func TestRebuildUsage(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		storage := driver.OpenDefault(t).Storage
+
+		upload := func(userID string, parentID string, contentType string, content string) *models.StorageObject {
+			t.Helper()
+			file, err := storage.UploadFile(ctx, userID, parentID, "file", int64(len(content)), contentType, strings.NewReader(content), nil)
+			if err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			return file
+		}
+		trash := func(file *models.StorageObject) *models.StorageObject {
+			t.Helper()
+			trashed, err := storage.TrashFile(ctx, file, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatalf("TrashFile: %v", err)
+			}
+			return trashed
+		}
+
+		upload("user-1", models.RootFolderID, "text/plain", "hello")
+		upload("user-1", "folder-1", "image/png", "png bytes")
+		trash(upload("user-1", models.RootFolderID, "text/plain", "trashed"))
+		// The only PDF and the only file in folder-2: once it is purged its
+		// aggregates count nothing and the rebuild drops them.
+		if err := storage.PurgeFile(ctx, trash(upload("user-1", "folder-2", "application/pdf", "purged"))); err != nil {
+			t.Fatalf("PurgeFile: %v", err)
+		}
+		upload("user-2", models.RootFolderID, "text/plain", "other user")
+
+		now := time.Now()
+		activity, err := storage.ListUsageActivity(ctx, "user-1", now.Add(-time.Hour), now.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("ListUsageActivity: %v", err)
+		}
+		// The rebuild finds what the writes kept, minus the aggregates that
+		// count nothing any more.
+		want := map[string]map[string]models.UsageAggregate{}
+		for _, userID := range []string{"user-1", "user-2"} {
+			want[userID] = usage(t, storage, userID)
+			for key, aggregate := range want[userID] {
+				if aggregate.FileCount == 0 && aggregate.TrashedFiles == 0 {
+					delete(want[userID], key)
+				}
+			}
+		}
+
+		month := now.UTC().Format("2006-01")
+		if got := want["user-1"][month]; got.Bytes != 14 || got.FileCount != 2 || got.TrashedBytes != 7 || got.TrashedFiles != 1 {
+			t.Fatalf("aggregate of %s before the rebuild = %+v", month, got)
+		}
+		if _, ok := usage(t, storage, "user-1")["folder#folder-2"]; !ok {
+			t.Fatal("purging the last file of folder-2 removed its aggregate; the test needs one left behind")
+		}
+
+		// A second rebuild must not count anything twice.
+		for run := 1; run <= 2; run++ {
+			users, err := storage.RebuildUsage(ctx)
+			if err != nil {
+				t.Fatalf("RebuildUsage: %v", err)
+			}
+			if users != 2 {
+				t.Errorf("rebuilt %d users, want 2", users)
+			}
+
+			for userID, aggregates := range want {
+				if got := usage(t, storage, userID); !reflect.DeepEqual(got, aggregates) {
+					t.Errorf("run %d: aggregates of %s = %+v, want %+v", run, userID, got, aggregates)
+				}
+			}
+
+			kept, err := storage.ListUsageActivity(ctx, "user-1", now.Add(-time.Hour), now.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("ListUsageActivity: %v", err)
+			}
+			if !reflect.DeepEqual(kept, activity) {
+				t.Errorf("run %d: activity = %+v, want it kept as %+v", run, kept, activity)
+			}
+		}
+	})
+}
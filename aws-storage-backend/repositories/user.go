@@ -2,8 +2,11 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -15,11 +18,64 @@ import (
 
 const UsersTable = "user"
 
+// UserUniqueTable reserves emails and usernames. Its items are keyed by
+// uniqueEmailKey and uniqueUserNameKey and name the user holding them.
+const UserUniqueTable = "user_unique"
+
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+var (
+	ErrEmailInUse    = errors.New("email is already in use")
+	ErrUserNameInUse = errors.New("username is already in use")
+	ErrUserIDInUse   = errors.New("user ID is already in use")
+)
+
+func uniqueEmailKey(email string) string {
+	return "email#" + models.NormalizeEmail(email)
+}
+
+func uniqueUserNameKey(userName string) string {
+	return "username#" + userName
+}
+
+// reserveUnique claims key for userID. It succeeds again for the user that
+// already holds key, so reservations can be repeated.
+func reserveUnique(key string, userID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName: aws.String(UserUniqueTable),
+			Item: map[string]types.AttributeValue{
+				"UniqueKey": &types.AttributeValueMemberS{Value: key},
+				"UserID":    &types.AttributeValueMemberS{Value: userID},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(UniqueKey) OR UserID = :userID"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":userID": &types.AttributeValueMemberS{Value: userID},
+			},
+		},
+	}
+}
+
+// uniqueConflict turns the cancellation of a transaction whose items are
+// listed in reasons into the error of the first condition that failed.
+func uniqueConflict(err error, reasons ...error) error {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		return nil
+	}
+	for i, reason := range cancelled.CancellationReasons {
+		if i < len(reasons) && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+			return reasons[i]
+		}
+	}
+	return nil
+}
+
 type UserRepository struct {
 	service *config.DynamoDBService
 }
 
-func NewUserRepository(service *config.DynamoDBService, s3Service *config.S3BucketService) *UserRepository {
+func NewUserRepository(service *config.DynamoDBService) *UserRepository {
 	return &UserRepository{
 		service: service,
 	}
@@ -56,26 +112,67 @@ func (r *UserRepository) GetUserByID(ctx context.Context, userId string) (*model
 
 }
 
+// CreateUser saves a new user together with the reservations of its email
+// and username, so that of two registrations racing for either only one
+// succeeds. The email is stored normalized.
 func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	user.UserEmail = models.NormalizeEmail(user.UserEmail)
+
 	item, err := attributevalue.MarshalMap(*user)
 
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = r.service.Client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(UsersTable),
-		Item:      item,
+	_, err = r.service.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(UsersTable),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(UserID)"),
+				},
+			},
+			reserveUnique(uniqueEmailKey(user.UserEmail), user.UserID),
+			reserveUnique(uniqueUserNameKey(user.UserName), user.UserID),
+		},
 	})
 
 	if err != nil {
+		if conflict := uniqueConflict(err, ErrUserIDInUse, ErrEmailInUse, ErrUserNameInUse); conflict != nil {
+			return nil, conflict
+		}
+		log.Printf("Couldn't create user %v: %v", user.UserID, err)
 		return nil, err
 	}
 
 	return user, nil
 }
 
+// ReserveUniqueKeys reserves the email and username of an existing user, for
+// users created before reservations were written.
+func (r *UserRepository) ReserveUniqueKeys(ctx context.Context, user *models.User) error {
+	_, err := r.service.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			reserveUnique(uniqueEmailKey(user.UserEmail), user.UserID),
+			reserveUnique(uniqueUserNameKey(user.UserName), user.UserID),
+		},
+	})
+
+	if err != nil {
+		if conflict := uniqueConflict(err, ErrEmailInUse, ErrUserNameInUse); conflict != nil {
+			return conflict
+		}
+		log.Printf("Couldn't reserve email and username of user %v: %v", user.UserID, err)
+		return err
+	}
+
+	return nil
+}
+
 func (r *UserRepository) GetUserByEmail(ctx context.Context, userEmail string) (*models.User, error) {
+	userEmail = models.NormalizeEmail(userEmail)
+
 	result, err := r.service.Client.Query(ctx, &dynamodb.QueryInput{
 		TableName: aws.String(UsersTable),
 		IndexName: aws.String("UserEmailIndex"),
@@ -129,4 +226,235 @@ func (r *UserRepository) GetUserByUserName(ctx context.Context, userName string)
 	}
 
 	return &user, nil
-}
\ No newline at end of file
+}
+func (r *UserRepository) UpdateVersionPolicy(ctx context.Context, userID string, maxFileVersions int) (*models.User, error) {
+	result, err := r.service.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    aws.String("SET MaxFileVersions = :maxFileVersions, UpdatedAt = :now"),
+		ConditionExpression: aws.String("attribute_exists(UserID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":maxFileVersions": &types.AttributeValueMemberN{Value: strconv.Itoa(maxFileVersions)},
+			":now":             &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, fmt.Errorf("user with id : %v not found", userID)
+		}
+		log.Printf("Couldn't update version policy of user %v: %v", userID, err)
+		return nil, err
+	}
+
+	var user models.User
+	if err := attributevalue.UnmarshalMap(result.Attributes, &user); err != nil {
+		log.Printf("User unmarshal failed: %v", err)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ReserveStorage adds size bytes to the user's usage counter, but only if the
+// total stays within quota. The check and the increment are one conditional
+// update, so parallel uploads cannot push the user past the quota.
+func (r *UserRepository) ReserveStorage(ctx context.Context, userID string, size int64, quota int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	if size > quota {
+		return ErrQuotaExceeded
+	}
+
+	_, err := r.service.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    aws.String("ADD UsedBytes :size"),
+		ConditionExpression: aws.String("attribute_exists(UserID) AND (attribute_not_exists(UsedBytes) OR UsedBytes <= :limit)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":size":  &types.AttributeValueMemberN{Value: strconv.FormatInt(size, 10)},
+			":limit": &types.AttributeValueMemberN{Value: strconv.FormatInt(quota-size, 10)},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrQuotaExceeded
+		}
+		log.Printf("Couldn't reserve %d bytes for user %v: %v", size, userID, err)
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseStorage takes size bytes off the user's usage counter. A release that
+// would drive the counter below zero means it has drifted from the stored
+// files; it is dropped and logged, and RebuildUsedBytes sets it right.
+func (r *UserRepository) ReleaseStorage(ctx context.Context, userID string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	_, err := r.service.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    aws.String("ADD UsedBytes :size"),
+		ConditionExpression: aws.String("UsedBytes >= :released"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":size":     &types.AttributeValueMemberN{Value: strconv.FormatInt(-size, 10)},
+			":released": &types.AttributeValueMemberN{Value: strconv.FormatInt(size, 10)},
+		},
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			log.Printf("Usage counter of user %v is below %d bytes, skipping release; run cmd/rebuild-usage to recount it", userID, size)
+			return nil
+		}
+		log.Printf("Couldn't release %d bytes for user %v: %v", size, userID, err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateStorageQuota sets the user's own quota, or removes it when quota is
+// nil so the default applies again.
+func (r *UserRepository) UpdateStorageQuota(ctx context.Context, userID string, quota *int64) (*models.User, error) {
+	updateExpression := "REMOVE StorageQuota SET UpdatedAt = :now"
+	values := map[string]types.AttributeValue{
+		":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	if quota != nil {
+		updateExpression = "SET StorageQuota = :quota, UpdatedAt = :now"
+		values[":quota"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(*quota, 10)}
+	}
+
+	result, err := r.service.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTable),
+		Key: map[string]types.AttributeValue{
+			"UserID": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(UserID)"),
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, fmt.Errorf("user with id : %v not found", userID)
+		}
+		log.Printf("Couldn't update storage quota of user %v: %v", userID, err)
+		return nil, err
+	}
+
+	var user models.User
+	if err := attributevalue.UnmarshalMap(result.Attributes, &user); err != nil {
+		log.Printf("User unmarshal failed: %v", err)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// RebuildUsedBytes sets every user's usage counter to the total size of their
+// files. Every item in the storage table counts, whatever its status: trashed
+// files and pending uploads hold quota too. Uploads and deletes running at the
+// same time are not accounted for, so run it while the server is stopped. It
+// returns how many users it updated.
+func (r *UserRepository) RebuildUsedBytes(ctx context.Context) (int, error) {
+	usedBytes := map[string]int64{}
+
+	paginator := dynamodb.NewScanPaginator(r.service.Client, &dynamodb.ScanInput{
+		TableName:            aws.String(StorageTable),
+		ProjectionExpression: aws.String("UserID, FileSize"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan files: %v", err)
+			return 0, err
+		}
+
+		var files []models.StorageObject
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &files); err != nil {
+			log.Printf("Failed to unmarshal files: %v", err)
+			return 0, err
+		}
+		for _, file := range files {
+			usedBytes[file.UserID] += file.FileSize
+		}
+	}
+
+	userIDs, err := r.ListUserIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		_, err := r.service.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(UsersTable),
+			Key: map[string]types.AttributeValue{
+				"UserID": &types.AttributeValueMemberS{Value: userID},
+			},
+			UpdateExpression:    aws.String("SET UsedBytes = :usedBytes"),
+			ConditionExpression: aws.String("attribute_exists(UserID)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":usedBytes": &types.AttributeValueMemberN{Value: strconv.FormatInt(usedBytes[userID], 10)},
+			},
+		})
+		if err != nil {
+			var conditionErr *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionErr) {
+				// Deleted since the scan.
+				continue
+			}
+			log.Printf("Couldn't set usage counter of user %v: %v", userID, err)
+			return 0, err
+		}
+	}
+
+	return len(userIDs), nil
+}
+
+// ListUserIDs returns the ID of every user.
+func (r *UserRepository) ListUserIDs(ctx context.Context) ([]string, error) {
+	userIDs := []string{}
+
+	paginator := dynamodb.NewScanPaginator(r.service.Client, &dynamodb.ScanInput{
+		TableName:            aws.String(UsersTable),
+		ProjectionExpression: aws.String("UserID"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to scan users: %v", err)
+			return nil, err
+		}
+
+		for _, item := range page.Items {
+			if userID, ok := item["UserID"].(*types.AttributeValueMemberS); ok {
+				userIDs = append(userIDs, userID.Value)
+			}
+		}
+	}
+
+	return userIDs, nil
+}
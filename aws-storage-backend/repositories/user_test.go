@@ -0,0 +1,262 @@
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func createUser(t *testing.T, users repositories.UserStore, userID string, usedBytes int64) {
+	t.Helper()
+
+	_, err := users.CreateUser(context.Background(), &models.User{
+		UserID:    userID,
+		UserName:  userID,
+		UserEmail: userID + "@example.com",
+		UsedBytes: usedBytes,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+}
+
+func usedBytes(t *testing.T, users repositories.UserStore, userID string) int64 {
+	t.Helper()
+
+	user, err := users.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	return user.UsedBytes
+}
+
+func TestReserveStorageConcurrently(t *testing.T) {
+	tests := []struct {
+		name      string
+		usedBytes int64
+		quota     int64
+		size      int64
+		uploads   int
+		wantOK    int
+	}{
+		{name: "all fit", quota: 1000, size: 10, uploads: 20, wantOK: 20},
+		{name: "exactly fills the quota", quota: 100, size: 10, uploads: 20, wantOK: 10},
+		{name: "partly used", usedBytes: 75, quota: 100, size: 10, uploads: 20, wantOK: 2},
+		{name: "already full", usedBytes: 100, quota: 100, size: 1, uploads: 10, wantOK: 0},
+		{name: "larger than the quota", quota: 100, size: 101, uploads: 5, wantOK: 0},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				users := driver.OpenDefault(t).Users
+				createUser(t, users, "user-1", tt.usedBytes)
+
+				var wg sync.WaitGroup
+				errs := make(chan error, tt.uploads)
+				for i := 0; i < tt.uploads; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						errs <- users.ReserveStorage(context.Background(), "user-1", tt.size, tt.quota)
+					}()
+				}
+				wg.Wait()
+				close(errs)
+
+				ok := 0
+				for err := range errs {
+					switch {
+					case err == nil:
+						ok++
+					case !errors.Is(err, repositories.ErrQuotaExceeded):
+						t.Fatalf("ReserveStorage: %v", err)
+					}
+				}
+
+				if ok != tt.wantOK {
+					t.Errorf("%d reservations succeeded, want %d", ok, tt.wantOK)
+				}
+				if got, want := usedBytes(t, users, "user-1"), tt.usedBytes+int64(ok)*tt.size; got != want {
+					t.Errorf("UsedBytes = %d, want %d", got, want)
+				}
+			})
+		}
+	})
+}
+
+func TestReleaseStorage(t *testing.T) {
+	tests := []struct {
+		name      string
+		usedBytes int64
+		release   int64
+		want      int64
+	}{
+		{name: "part", usedBytes: 100, release: 30, want: 70},
+		{name: "all", usedBytes: 100, release: 100, want: 0},
+		{name: "more than used", usedBytes: 10, release: 30, want: 10},
+		{name: "nothing", usedBytes: 10, release: 0, want: 10},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				users := driver.OpenDefault(t).Users
+				createUser(t, users, "user-1", tt.usedBytes)
+
+				if err := users.ReleaseStorage(context.Background(), "user-1", tt.release); err != nil {
+					t.Fatalf("ReleaseStorage: %v", err)
+				}
+				if got := usedBytes(t, users, "user-1"); got != tt.want {
+					t.Errorf("UsedBytes = %d, want %d", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestRebuildUsedBytes(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		stores := driver.OpenDefault(t)
+
+		// Counters that drifted: uploads stored without a reservation, and a
+		// release that was never applied.
+		createUser(t, stores.Users, "user-1", 0)
+		createUser(t, stores.Users, "user-2", 500)
+		createUser(t, stores.Users, "user-3", 42)
+
+		upload := func(userID string, size int) *models.StorageObject {
+			t.Helper()
+			file, err := stores.Storage.UploadFile(ctx, userID, models.RootFolderID, "file.txt", int64(size), "text/plain", strings.NewReader(strings.Repeat("x", size)), nil)
+			if err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			return file
+		}
+		upload("user-1", 100)
+		if _, err := stores.Storage.TrashFile(ctx, upload("user-1", 20), time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("TrashFile: %v", err)
+		}
+		if _, err := stores.Storage.CreatePendingFile(ctx, "user-1", models.RootFolderID, "pending.txt", 3, "text/plain", nil, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("CreatePendingFile: %v", err)
+		}
+		upload("user-2", 50)
+
+		updated, err := stores.Users.RebuildUsedBytes(ctx)
+		if err != nil {
+			t.Fatalf("RebuildUsedBytes: %v", err)
+		}
+		if updated != 3 {
+			t.Errorf("updated %d users, want 3", updated)
+		}
+
+		for userID, want := range map[string]int64{"user-1": 123, "user-2": 50, "user-3": 0} {
+			if got := usedBytes(t, stores.Users, userID); got != want {
+				t.Errorf("UsedBytes of %s = %d, want %d", userID, got, want)
+			}
+		}
+	})
+}
+
+func TestCreateUserReservesUniqueKeys(t *testing.T) {
+	existing := &models.User{UserID: "user-1", UserName: "alice", UserEmail: "Alice@Example.com"}
+
+	// free is a user that takes the keys a refused user did not clash on,
+	// which must not stay reserved.
+	tests := []struct {
+		name    string
+		user    models.User
+		wantErr error
+		free    models.User
+	}{
+		{name: "new user", user: models.User{UserID: "user-2", UserName: "bob", UserEmail: "bob@example.com"}},
+		{name: "same email in other case", user: models.User{UserID: "user-2", UserName: "bob", UserEmail: "alice@EXAMPLE.com"}, wantErr: repositories.ErrEmailInUse,
+			free: models.User{UserID: "user-3", UserName: "bob", UserEmail: "carol@example.com"}},
+		{name: "same username", user: models.User{UserID: "user-2", UserName: "alice", UserEmail: "bob@example.com"}, wantErr: repositories.ErrUserNameInUse,
+			free: models.User{UserID: "user-3", UserName: "carol", UserEmail: "bob@example.com"}},
+		{name: "same ID", user: models.User{UserID: "user-1", UserName: "bob", UserEmail: "bob@example.com"}, wantErr: repositories.ErrUserIDInUse,
+			free: models.User{UserID: "user-3", UserName: "bob", UserEmail: "bob@example.com"}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				users := driver.OpenDefault(t).Users
+				first := *existing
+				if _, err := users.CreateUser(ctx, &first); err != nil {
+					t.Fatalf("CreateUser: %v", err)
+				}
+
+				user := tt.user
+				_, err := users.CreateUser(ctx, &user)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateUser: %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil {
+					free := tt.free
+					if _, err := users.CreateUser(ctx, &free); err != nil {
+						t.Errorf("CreateUser with the keys the refused user left: %v", err)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestCreateUserConcurrently(t *testing.T) {
+	tests := []struct {
+		name string
+		user func(i int) *models.User
+	}{
+		{name: "same username", user: func(i int) *models.User {
+			return &models.User{UserID: fmt.Sprintf("user-%d", i), UserName: "alice", UserEmail: fmt.Sprintf("alice%d@example.com", i)}
+		}},
+		{name: "same email", user: func(i int) *models.User {
+			return &models.User{UserID: fmt.Sprintf("user-%d", i), UserName: fmt.Sprintf("alice%d", i), UserEmail: "alice@example.com"}
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				users := driver.OpenDefault(t).Users
+
+				var wg sync.WaitGroup
+				errs := make(chan error, 10)
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, err := users.CreateUser(context.Background(), tt.user(i))
+						errs <- err
+					}()
+				}
+				wg.Wait()
+				close(errs)
+
+				created := 0
+				for err := range errs {
+					switch {
+					case err == nil:
+						created++
+					case !errors.Is(err, repositories.ErrEmailInUse) && !errors.Is(err, repositories.ErrUserNameInUse):
+						t.Fatalf("CreateUser: %v", err)
+					}
+				}
+				if created != 1 {
+					t.Errorf("%d users were created, want 1", created)
+				}
+			})
+		}
+	})
+}
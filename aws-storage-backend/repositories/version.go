@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+const FileVersionTable = "file_version"
+
+var ErrFileVersionNotFound = errors.New("file version not found")
+
+// RecordVersion stores the S3 version that storageObj currently points at.
+// Objects written before bucket versioning was enabled have no version ID and
+// are skipped.
+func (r *StorageRepository) RecordVersion(ctx context.Context, storageObj *models.StorageObject, uploadedBy string) (*models.FileVersion, error) {
+	if storageObj.VersionID == "" {
+		return nil, nil
+	}
+
+	version := &models.FileVersion{
+		ObjectID:       storageObj.ObjectID,
+		VersionID:      storageObj.VersionID,
+		UserID:         storageObj.UserID,
+		FileSize:       storageObj.FileSize,
+		ContentType:    storageObj.ContentType,
+		ETag:           storageObj.ETag,
+		ChecksumSHA256: storageObj.ChecksumSHA256,
+		ChecksumCRC32C: storageObj.ChecksumCRC32C,
+		UploadedBy:     uploadedBy,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	item, err := attributevalue.MarshalMap(version)
+	if err != nil {
+		log.Printf("Failed to marshal file version: %v", err)
+		return nil, err
+	}
+
+	_, err = r.dynamoService.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(FileVersionTable),
+		Item:      item,
+	})
+
+	if err != nil {
+		log.Printf("Failed to save version %s of file %s: %v", version.VersionID, version.ObjectID, err)
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// ListVersions returns every recorded version of a file, newest first.
+func (r *StorageRepository) ListVersions(ctx context.Context, objectID string) ([]models.FileVersion, error) {
+	versions := []models.FileVersion{}
+
+	paginator := dynamodb.NewQueryPaginator(r.dynamoService.Client, &dynamodb.QueryInput{
+		TableName:              aws.String(FileVersionTable),
+		KeyConditionExpression: aws.String("ObjectID = :objectID"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":objectID": &types.AttributeValueMemberS{Value: objectID},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to list versions of file %s: %v", objectID, err)
+			return nil, err
+		}
+
+		var items []models.FileVersion
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			log.Printf("Failed to unmarshal file versions: %v", err)
+			return nil, err
+		}
+		versions = append(versions, items...)
+	}
+
+	// S3 version IDs are opaque, so the sort key gives no useful order.
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].CreatedAt.After(versions[j].CreatedAt)
+	})
+
+	return versions, nil
+}
+
+func (r *StorageRepository) GetVersion(ctx context.Context, objectID string, versionID string) (*models.FileVersion, error) {
+	result, err := r.dynamoService.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(FileVersionTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID":  &types.AttributeValueMemberS{Value: objectID},
+			"VersionID": &types.AttributeValueMemberS{Value: versionID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("GetItem error for version %s of file %s: %v", versionID, objectID, err)
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrFileVersionNotFound
+	}
+
+	var version models.FileVersion
+	if err := attributevalue.UnmarshalMap(result.Item, &version); err != nil {
+		log.Printf("unmarshal error: %v", err)
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// RestoreVersion copies an older version on top of the object, which makes it
+// the newest stored version, and points the metadata at the copy. Like a
+// replacement, the version is claimed before the copy is made.
+func (r *StorageRepository) RestoreVersion(ctx context.Context, storageObj *models.StorageObject, version *models.FileVersion) (*models.StorageObject, error) {
+	return r.replaceContent(ctx, storageObj, version.FileSize-storageObj.FileSize, func() (*storedContent, error) {
+		copied, err := r.blobStore.Copy(ctx, storageObj.S3Key, version.VersionID, storageObj.S3Key)
+		if err != nil {
+			log.Printf("Failed to copy version %s of file %s: %v", version.VersionID, storageObj.ObjectID, err)
+			return nil, err
+		}
+
+		etag := version.ETag
+		if copied.ETag != "" {
+			etag = copied.ETag
+		}
+
+		return &storedContent{
+			fileSize:       version.FileSize,
+			contentType:    version.ContentType,
+			etag:           etag,
+			versionID:      copied.VersionID,
+			checksumSHA256: version.ChecksumSHA256,
+			checksumCRC32C: version.ChecksumCRC32C,
+		}, nil
+	})
+}
+
+// DeleteVersion permanently removes one version's bytes and its record.
+func (r *StorageRepository) DeleteVersion(ctx context.Context, storageObj *models.StorageObject, version *models.FileVersion) error {
+	err := r.blobStore.Delete(ctx, storageObj.S3Key, version.VersionID)
+	if err != nil {
+		log.Printf("Delete error for version %s of file %s: %v", version.VersionID, storageObj.ObjectID, err)
+		return err
+	}
+
+	return r.deleteVersionRecord(ctx, version.ObjectID, version.VersionID)
+}
+
+// deleteAllVersions removes every version and delete marker of an object, so
+// a permanent delete does not leave noncurrent bytes behind in the store.
+func (r *StorageRepository) deleteAllVersions(ctx context.Context, storageObj *models.StorageObject) error {
+	if err := r.blobStore.DeleteAllVersions(ctx, storageObj.S3Key); err != nil {
+		log.Printf("Failed to delete stored versions of file %s: %v", storageObj.ObjectID, err)
+		return err
+	}
+
+	versions, err := r.ListVersions(ctx, storageObj.ObjectID)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if err := r.deleteVersionRecord(ctx, version.ObjectID, version.VersionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *StorageRepository) deleteVersionRecord(ctx context.Context, objectID string, versionID string) error {
+	_, err := r.dynamoService.Client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(FileVersionTable),
+		Key: map[string]types.AttributeValue{
+			"ObjectID":  &types.AttributeValueMemberS{Value: objectID},
+			"VersionID": &types.AttributeValueMemberS{Value: versionID},
+		},
+	})
+
+	if err != nil {
+		log.Printf("DeleteItem error for version %s of file %s: %v", versionID, objectID, err)
+		return err
+	}
+
+	return nil
+}
@@ -7,10 +7,10 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(userHandler *handlers.UserHandler, storageHandler *handlers.StorageHandler, env config.Env, authConfig *config.AuthConfig) *gin.Engine{
+func SetupRouter(userHandler *handlers.UserHandler, storageHandler *handlers.StorageHandler, shareHandler *handlers.ShareHandler, notificationHandler *handlers.NotificationHandler, blobHandler *handlers.BlobHandler, cfg *config.Config, authConfig *config.AuthConfig) *gin.Engine{
 	router := gin.Default()
 
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(cfg.Server.CORSOrigins))
 	
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -24,18 +24,81 @@ func SetupRouter(userHandler *handlers.UserHandler, storageHandler *handlers.Sto
 		routes.GET("/user/:id", userHandler.GetUserByID)
 		routes.POST("/user/register", userHandler.CreateUser)
 		routes.POST("/user/login", userHandler.Login)
+		routes.GET("/share/:token", shareHandler.GetSharedFile)
+		routes.GET("/share/:token/download", shareHandler.DownloadSharedFile)
+		routes.GET("/requests/:token", storageHandler.GetPublicFileRequest)
+		routes.POST("/requests/:token/upload", storageHandler.UploadToFileRequest)
 	}
 
 	protected := router.Group("/api/v1")
 	protected.Use(middleware.AuthMiddleware(authConfig))
 	{
 		protected.GET("/user/me", userHandler.GetProfile)
+		protected.PUT("/user/me/version-policy", userHandler.SetVersionPolicy)
 		protected.POST("/storage/upload", storageHandler.UploadFile)
+		protected.POST("/storage/uploads", storageHandler.CreateUploadSession)
+		protected.POST("/storage/uploads/:id/complete", storageHandler.CompleteUploadSession)
+		protected.POST("/storage/multipart", storageHandler.InitiateMultipartUpload)
+		protected.GET("/storage/multipart/:id", storageHandler.GetMultipartUpload)
+		protected.GET("/storage/multipart/:id/parts/:partNumber/url", storageHandler.GetPartURL)
+		protected.PUT("/storage/multipart/:id/parts/:partNumber", storageHandler.UploadPart)
+		protected.POST("/storage/multipart/:id/parts/:partNumber", storageHandler.AcknowledgePart)
+		protected.POST("/storage/multipart/:id/complete", storageHandler.CompleteMultipartUpload)
+		protected.DELETE("/storage/multipart/:id", storageHandler.AbortMultipartUpload)
 		protected.GET("/storage/files", storageHandler.ListFiles)
 		protected.GET("/storage/files/:id/download", storageHandler.DownloadFile)
+		protected.HEAD("/storage/files/:id/download", storageHandler.HeadFile)
 		protected.DELETE("/storage/files/:id/delete", storageHandler.DeleteFile)
+		protected.PATCH("/storage/files/:id", storageHandler.UpdateFileMetadata)
+		protected.PUT("/storage/files/:id/content", storageHandler.ReplaceFileContent)
+		protected.POST("/storage/files/:id/move", storageHandler.MoveFile)
+		protected.GET("/storage/files/:id/versions", storageHandler.ListFileVersions)
+		protected.GET("/storage/files/:id/versions/:versionId/download", storageHandler.DownloadFileVersion)
+		protected.POST("/storage/files/:id/versions/:versionId/restore", storageHandler.RestoreFileVersion)
+		protected.DELETE("/storage/files/:id/versions/:versionId", storageHandler.DeleteFileVersion)
+		protected.GET("/storage/duplicates", storageHandler.FindDuplicates)
+		protected.GET("/storage/trash", storageHandler.ListTrash)
+		protected.POST("/storage/trash/:id/restore", storageHandler.RestoreFile)
+		protected.DELETE("/storage/trash/:id", storageHandler.DeleteTrashedFile)
+		protected.DELETE("/storage/trash", storageHandler.EmptyTrash)
+		protected.POST("/storage/files/:id/shares", shareHandler.CreateShareLink)
+		protected.GET("/storage/files/:id/shares", shareHandler.ListShareLinks)
+		protected.DELETE("/storage/shares/:token", shareHandler.RevokeShareLink)
+		protected.POST("/storage/files/:id/grants", storageHandler.GrantFileAccess)
+		protected.GET("/storage/files/:id/grants", storageHandler.ListFileGrants)
+		protected.DELETE("/storage/files/:id/grants/:userId", storageHandler.RevokeFileAccess)
+		protected.GET("/storage/shared", storageHandler.ListSharedWithMe)
+		protected.POST("/storage/requests", storageHandler.CreateFileRequest)
+		protected.GET("/storage/requests", storageHandler.ListFileRequests)
+		protected.GET("/storage/requests/:id/uploads", storageHandler.ListFileRequestUploads)
+		protected.DELETE("/storage/requests/:id", storageHandler.RevokeFileRequest)
+		protected.POST("/storage/folders", storageHandler.CreateFolder)
+		protected.GET("/storage/folders/:id", storageHandler.GetFolder)
+		protected.GET("/storage/folders/:id/path", storageHandler.GetFolderPath)
+		protected.PATCH("/storage/folders/:id", storageHandler.RenameFolder)
+		protected.POST("/storage/folders/:id/move", storageHandler.MoveFolder)
+		protected.DELETE("/storage/folders/:id", storageHandler.DeleteFolder)
+		protected.POST("/storage/folders/:id/grants", storageHandler.GrantFolderAccess)
+		protected.GET("/storage/folders/:id/grants", storageHandler.ListFolderGrants)
+		protected.DELETE("/storage/folders/:id/grants/:userId", storageHandler.RevokeFolderAccess)
+		protected.GET("/notifications", notificationHandler.ListNotifications)
+		protected.POST("/notifications/:id/read", notificationHandler.MarkNotificationRead)
 		protected.GET("/storage/dashboard", storageHandler.GetDashboardMetrics)
 	}
 
+	admin := router.Group("/api/v1/admin")
+	admin.Use(middleware.AuthMiddleware(authConfig), middleware.AdminMiddleware(authConfig))
+	{
+		admin.PUT("/users/:id/quota", userHandler.SetStorageQuota)
+	}
+
+	// Signed URLs of the local and in-memory blob stores carry their own
+	// authorization, so these routes sit outside the auth middleware.
+	if blobHandler != nil {
+		router.GET("/blobs/*key", blobHandler.GetBlob)
+		router.PUT("/blobs/*key", blobHandler.PutBlob)
+		router.POST("/blobs/*key", blobHandler.PostBlob)
+	}
+
 	return router
 }
\ No newline at end of file
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// authorizeFile loads an active file and checks that userID holds at least
+// role on it, as its owner or through a grant on the file or a folder above it.
+func (s *StorageService) authorizeFile(ctx context.Context, userID string, fileID string, role string) (*models.StorageObject, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+
+	storageObj, err := s.storageRepo.GetFileByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !storageObj.IsActive() {
+		return nil, repositories.ErrFileNotFound
+	}
+
+	if storageObj.UserID == userID {
+		return storageObj, nil
+	}
+
+	granted, _, err := s.grantedRole(ctx, userID, storageObj.ObjectID, storageObj.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if models.RoleRank(granted) < models.RoleRank(role) {
+		return nil, repositories.ErrFileAccessDenied
+	}
+
+	return storageObj, nil
+}
+
+// authorizeFolder is authorizeFile for folders. It also returns the highest
+// folder the user can see, which is the root for owners and the topmost
+// granted folder for everyone else.
+func (s *StorageService) authorizeFolder(ctx context.Context, userID string, folderID string, role string) (*models.Folder, string, error) {
+	folder, err := s.folderRepo.GetFolderByID(ctx, folderID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if folder.UserID == userID {
+		return folder, models.RootFolderID, nil
+	}
+
+	granted, visibleRoot, err := s.grantedRole(ctx, userID, folder.FolderID, folder.ParentID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if models.RoleRank(granted) < models.RoleRank(role) {
+		return nil, "", repositories.ErrFolderAccessDenied
+	}
+
+	return folder, visibleRoot, nil
+}
+
+// grantedRole walks from resourceID up through its folders and returns the
+// strongest role granted to userID on the way, along with the topmost
+// resource that carries a grant.
+func (s *StorageService) grantedRole(ctx context.Context, userID string, resourceID string, parentID string) (string, string, error) {
+	role, topGrant := "", ""
+
+	current, next := resourceID, parentID
+	for depth := 0; ; depth++ {
+		if depth > maxFolderDepth {
+			return "", "", ErrFolderTooDeep
+		}
+
+		grant, err := s.grantRepo.GetGrant(ctx, current, userID)
+		switch {
+		case err == nil:
+			if models.RoleRank(grant.Role) > models.RoleRank(role) {
+				role = grant.Role
+			}
+			topGrant = current
+		case !errors.Is(err, repositories.ErrGrantNotFound):
+			return "", "", err
+		}
+
+		if next == "" || next == models.RootFolderID {
+			return role, topGrant, nil
+		}
+
+		folder, err := s.folderRepo.GetFolderByID(ctx, next)
+		if errors.Is(err, repositories.ErrFolderNotFound) {
+			return role, topGrant, nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+
+		current, next = folder.FolderID, folder.ParentID
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// grant shares a resource of user-1 with user-2.
+func (s *testService) grant(t *testing.T, resourceType string, resourceID string, role string) {
+	t.Helper()
+
+	if _, err := s.GrantAccess(context.Background(), "user-1", resourceType, resourceID, models.GrantAccessRequest{Email: "user-2@example.com", Role: role}); err != nil {
+		t.Fatalf("GrantAccess: %v", err)
+	}
+}
+
+func TestGrantedRole(t *testing.T) {
+	type grant struct{ on, role string }
+
+	tests := []struct {
+		name     string
+		grants   []grant
+		wantRole string
+		wantTop  string
+	}{
+		{name: "no grant"},
+		{name: "on the file", grants: []grant{{"file", models.RoleViewer}}, wantRole: models.RoleViewer, wantTop: "file"},
+		{name: "on its folder", grants: []grant{{"b", models.RoleEditor}}, wantRole: models.RoleEditor, wantTop: "b"},
+		{name: "on a folder further up", grants: []grant{{"a", models.RoleViewer}}, wantRole: models.RoleViewer, wantTop: "a"},
+		{name: "stronger role further down", grants: []grant{{"a", models.RoleViewer}, {"b", models.RoleEditor}}, wantRole: models.RoleEditor, wantTop: "a"},
+		{name: "stronger role further up", grants: []grant{{"file", models.RoleViewer}, {"a", models.RoleEditor}}, wantRole: models.RoleEditor, wantTop: "a"},
+		{name: "on an unrelated folder", grants: []grant{{"c", models.RoleEditor}}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+
+				// a/b/file and c, all owned by user-1.
+				ids := map[string]string{}
+				ids["a"] = s.folder(t, "a", "")
+				ids["b"] = s.folder(t, "b", ids["a"])
+				ids["c"] = s.folder(t, "c", "")
+				file := s.upload(t, "user-1", ids["b"], "a.txt", "hello")
+				ids["file"] = file.ObjectID
+
+				for _, g := range tt.grants {
+					resourceType := models.ResourceTypeFolder
+					if g.on == "file" {
+						resourceType = models.ResourceTypeFile
+					}
+					s.grant(t, resourceType, ids[g.on], g.role)
+				}
+
+				role, top, err := s.grantedRole(ctx, "user-2", file.ObjectID, file.ParentID)
+				if err != nil {
+					t.Fatalf("grantedRole: %v", err)
+				}
+				if role != tt.wantRole || top != ids[tt.wantTop] {
+					t.Errorf("grantedRole = %q from %q, want %q from %q", role, top, tt.wantRole, ids[tt.wantTop])
+				}
+
+				// Only editors may change the file.
+				_, err = s.authorizeFile(ctx, "user-2", file.ObjectID, models.RoleEditor)
+				if allowed := err == nil; allowed != (tt.wantRole == models.RoleEditor) {
+					t.Errorf("authorizeFile as editor: %v", err)
+				}
+				if err != nil && !errors.Is(err, repositories.ErrFileAccessDenied) {
+					t.Errorf("authorizeFile as editor: %v, want ErrFileAccessDenied", err)
+				}
+			})
+		}
+	})
+}
+
+func TestMaxFolderDepth(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+
+		parentID := ""
+		for depth := 1; depth <= maxFolderDepth-1; depth++ {
+			parentID = s.folder(t, "deep", parentID)
+		}
+		deepest := parentID
+
+		if _, err := s.CreateFolder(ctx, "user-1", models.CreateFolderRequest{Name: "too deep", ParentID: deepest}); !errors.Is(err, ErrFolderTooDeep) {
+			t.Errorf("CreateFolder below the deepest folder: %v, want ErrFolderTooDeep", err)
+		}
+		other := s.folder(t, "other", "")
+		if _, err := s.MoveFolder(ctx, "user-1", other, deepest); !errors.Is(err, ErrFolderTooDeep) {
+			t.Errorf("MoveFolder below the deepest folder: %v, want ErrFolderTooDeep", err)
+		}
+	})
+}
+
+func TestFolderWalksStopOnCycles(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+		a := s.folder(t, "a", "")
+		b := s.folder(t, "b", a)
+		file := s.upload(t, "user-1", b, "a.txt", "hello")
+
+		// A corrupted tree: a is moved below its own child behind the
+		// service's back.
+		if _, err := s.stores.Folders.MoveFolder(ctx, a, "user-1", b); err != nil {
+			t.Fatalf("MoveFolder: %v", err)
+		}
+
+		if _, _, err := s.grantedRole(ctx, "user-2", file.ObjectID, file.ParentID); !errors.Is(err, ErrFolderTooDeep) {
+			t.Errorf("grantedRole: %v, want ErrFolderTooDeep", err)
+		}
+		if _, err := s.GetFolderPath(ctx, "user-1", b); !errors.Is(err, ErrFolderTooDeep) {
+			t.Errorf("GetFolderPath: %v, want ErrFolderTooDeep", err)
+		}
+		if _, err := s.DeleteFolder(ctx, "user-1", a); !errors.Is(err, ErrFolderTooDeep) {
+			t.Errorf("DeleteFolder: %v, want ErrFolderTooDeep", err)
+		}
+	})
+}
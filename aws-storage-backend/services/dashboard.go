@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+const (
+	dashboardDateLayout = "2006-01-02"
+	// maxDashboardBuckets keeps a day-by-day series over many years from
+	// being built by accident.
+	maxDashboardBuckets = 400
+	defaultDashboardTop = 10
+)
+
+var ErrInvalidDashboardRange = errors.New("invalid dashboard range")
+
+// dashboardPeriod is a resolved DashboardQuery. from and end are bucket
+// boundaries in loc; end is exclusive.
+type dashboardPeriod struct {
+	loc         *time.Location
+	from        time.Time
+	end         time.Time
+	granularity string
+}
+
+// GetDashboardMetrics builds the dashboard from the usage aggregates. The
+// series comes from hourly activity items, so in timezones that are not a
+// whole number of hours off UTC an event can land in the neighbouring bucket.
+func (s *StorageService) GetDashboardMetrics(ctx context.Context, userID string, query models.DashboardQuery) (*models.DashboardResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	now := time.Now()
+	period, err := resolveDashboardPeriod(query, now)
+	if err != nil {
+		return nil, err
+	}
+
+	top := query.Top
+	if top == 0 {
+		top = defaultDashboardTop
+	}
+
+	monthly, err := s.storageRepo.ListMonthlyUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	activity, err := s.storageRepo.ListUsageActivity(ctx, userID, period.from, now)
+	if err != nil {
+		return nil, err
+	}
+
+	folders, err := s.folderUsage(ctx, userID, top)
+	if err != nil {
+		return nil, err
+	}
+
+	largest, err := s.storageRepo.ListFiles(ctx, userID, models.ListFilesQuery{
+		Limit:     int32(top),
+		SortBy:    models.SortByFileSize,
+		SortOrder: models.SortOrderDesc,
+	}, models.StatusActive)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := models.DashboardSummary{}
+	for _, aggregate := range monthly {
+		if aggregate.ContentType != "" {
+			continue
+		}
+		summary.TotalSizeInBytes += aggregate.Bytes
+		summary.TotalFiles += aggregate.FileCount
+		summary.TrashSizeInBytes += aggregate.TrashedBytes
+		summary.TrashFiles += aggregate.TrashedFiles
+	}
+	summary.TotalSizeInMB = float64(summary.TotalSizeInBytes) / (1024 * 1024)
+	summary.TotalSizeInGB = float64(summary.TotalSizeInBytes) / (1024 * 1024 * 1024)
+
+	summary.QuotaInBytes = s.storageQuota(user)
+	summary.UsedInBytes = user.UsedBytes
+	summary.RemainingInBytes = summary.QuotaInBytes - user.UsedBytes
+	if summary.RemainingInBytes < 0 {
+		summary.RemainingInBytes = 0
+	}
+
+	series := buildUsageSeries(period, activity, summary.TotalSizeInBytes)
+	for _, bucket := range series {
+		summary.Uploads += bucket.Uploads
+		summary.UploadedBytes += bucket.UploadedBytes
+		summary.Deletions += bucket.Deletions
+		summary.DeletedBytes += bucket.DeletedBytes
+		summary.GrowthInBytes += bucket.NetBytes
+	}
+	if len(series) > 0 {
+		startBytes := series[0].StoredBytes - series[0].NetBytes
+		if startBytes > 0 {
+			rate := float64(summary.GrowthInBytes) / float64(startBytes) * 100
+			summary.GrowthRate = &rate
+		}
+	}
+
+	largestFiles := make([]models.LargestFile, 0, len(largest.Data))
+	for _, file := range largest.Data {
+		largestFiles = append(largestFiles, models.LargestFile{
+			ObjectID:    file.ObjectID,
+			FileName:    file.FileName,
+			FileSize:    file.FileSize,
+			ContentType: file.ContentType,
+			ParentID:    file.ParentID,
+			UploadedAt:  file.UploadedAt,
+		})
+	}
+
+	return &models.DashboardResponse{
+		Success: true,
+		Message: "Dashboard data fetched successfully",
+		Data: models.DashboardData{
+			Range: models.DashboardRange{
+				From:        period.from,
+				To:          period.end,
+				Granularity: period.granularity,
+				Timezone:    period.loc.String(),
+			},
+			Months:       groupUsageByMonth(monthly, period),
+			Series:       series,
+			ContentTypes: contentTypeUsage(monthly),
+			Folders:      folders,
+			LargestFiles: largestFiles,
+			Summary:      summary,
+		},
+	}, nil
+}
+
+func resolveDashboardPeriod(query models.DashboardQuery, now time.Time) (*dashboardPeriod, error) {
+	timezone := query.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidDashboardRange, timezone)
+	}
+
+	period := &dashboardPeriod{loc: loc, granularity: query.Granularity}
+	if period.granularity == "" {
+		period.granularity = models.GranularityMonth
+	}
+
+	to := now.In(loc)
+	if query.To != "" {
+		to, err = time.ParseInLocation(dashboardDateLayout, query.To, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: to must be a date like 2006-01-02", ErrInvalidDashboardRange)
+		}
+	}
+	period.end = period.next(period.start(to))
+
+	var from time.Time
+	switch {
+	case query.From != "":
+		from, err = time.ParseInLocation(dashboardDateLayout, query.From, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: from must be a date like 2006-01-02", ErrInvalidDashboardRange)
+		}
+	case period.granularity == models.GranularityDay:
+		from = period.end.AddDate(0, 0, -30)
+	case period.granularity == models.GranularityWeek:
+		from = period.end.AddDate(0, 0, -7*12)
+	default:
+		from = period.end.AddDate(0, -12, 0)
+	}
+	period.from = period.start(from)
+
+	if !period.from.Before(period.end) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidDashboardRange)
+	}
+
+	buckets := 0
+	for start := period.from; start.Before(period.end); start = period.next(start) {
+		buckets++
+		if buckets > maxDashboardBuckets {
+			return nil, fmt.Errorf("%w: more than %d %ss requested", ErrInvalidDashboardRange, maxDashboardBuckets, period.granularity)
+		}
+	}
+
+	return period, nil
+}
+
+// start returns the beginning of the bucket t falls in. Weeks start on Monday.
+func (p *dashboardPeriod) start(t time.Time) time.Time {
+	t = t.In(p.loc)
+
+	switch p.granularity {
+	case models.GranularityDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.loc)
+	case models.GranularityWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.loc)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, p.loc)
+	}
+}
+
+func (p *dashboardPeriod) next(start time.Time) time.Time {
+	switch p.granularity {
+	case models.GranularityDay:
+		return start.AddDate(0, 0, 1)
+	case models.GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
+
+func (p *dashboardPeriod) label(start time.Time) string {
+	if p.granularity == models.GranularityMonth {
+		return start.Format("January 2006")
+	}
+	return start.Format(dashboardDateLayout)
+}
+
+// buildUsageSeries spreads the activity over the buckets of the period.
+// Activity runs up to now, past the end of the period, so that the stored
+// bytes at the end of each bucket can be worked back from storedNow.
+func buildUsageSeries(period *dashboardPeriod, activity []models.UsageActivity, storedNow int64) []models.UsageBucket {
+	series := []models.UsageBucket{}
+	index := map[int64]int{}
+	for start := period.from; start.Before(period.end); start = period.next(start) {
+		index[start.Unix()] = len(series)
+		series = append(series, models.UsageBucket{Start: start, Label: period.label(start)})
+	}
+
+	netSinceEnd := int64(0)
+	for _, item := range activity {
+		hour, err := time.ParseInLocation("2006-01-02T15", item.Hour, time.UTC)
+		if err != nil {
+			continue
+		}
+
+		if !hour.Before(period.end) {
+			netSinceEnd += item.NetBytes
+			continue
+		}
+
+		i, ok := index[period.start(hour).Unix()]
+		if !ok {
+			continue
+		}
+		series[i].Uploads += item.Uploads
+		series[i].UploadedBytes += item.UploadedBytes
+		series[i].Deletions += item.Deletions
+		series[i].DeletedBytes += item.DeletedBytes
+		series[i].NetBytes += item.NetBytes
+	}
+
+	stored := storedNow - netSinceEnd
+	for i := len(series) - 1; i >= 0; i-- {
+		series[i].StoredBytes = stored
+		stored -= series[i].NetBytes
+	}
+
+	return series
+}
+
+// groupUsageByMonth lists the months the period touches in its location,
+// oldest first. The aggregates are kept per UTC month, so a file uploaded
+// within hours of a month boundary can be counted in the neighbouring month.
+func groupUsageByMonth(aggregates []models.UsageAggregate, period *dashboardPeriod) []models.MonthlyUsage {
+	months := &dashboardPeriod{loc: period.loc, granularity: models.GranularityMonth}
+
+	byMonth := map[string]models.UsageAggregate{}
+	for _, aggregate := range aggregates {
+		if aggregate.ContentType == "" {
+			byMonth[aggregate.Month] = aggregate
+		}
+	}
+
+	result := []models.MonthlyUsage{}
+	for date := months.start(period.from); date.Before(period.end); date = months.next(date) {
+		yearMonth := date.Format("2006-01")
+		aggregate := byMonth[yearMonth]
+
+		result = append(result, models.MonthlyUsage{
+			Month:     yearMonth,
+			MonthName: date.Month().String(),
+			TotalSize: aggregate.Bytes,
+			FileCount: int(aggregate.FileCount),
+			SizeInMB:  float64(aggregate.Bytes) / (1024 * 1024),
+		})
+	}
+	return result
+}
+
+// contentTypeUsage adds up the active files of each content type over all
+// months, largest first.
+func contentTypeUsage(aggregates []models.UsageAggregate) []models.ContentTypeUsage {
+	byType := map[string]*models.ContentTypeUsage{}
+	for _, aggregate := range aggregates {
+		if aggregate.ContentType == "" {
+			continue
+		}
+
+		usage, ok := byType[aggregate.ContentType]
+		if !ok {
+			usage = &models.ContentTypeUsage{ContentType: aggregate.ContentType}
+			byType[aggregate.ContentType] = usage
+		}
+		usage.TotalSize += aggregate.Bytes
+		usage.FileCount += aggregate.FileCount
+	}
+
+	result := []models.ContentTypeUsage{}
+	for _, usage := range byType {
+		if usage.FileCount > 0 {
+			result = append(result, *usage)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalSize != result[j].TotalSize {
+			return result[i].TotalSize > result[j].TotalSize
+		}
+		return result[i].ContentType < result[j].ContentType
+	})
+	return result
+}
+
+// folderUsage returns the top folders by the size of the active files
+// directly inside them.
+func (s *StorageService) folderUsage(ctx context.Context, userID string, top int) ([]models.FolderUsage, error) {
+	aggregates, err := s.storageRepo.ListFolderUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(aggregates, func(i, j int) bool {
+		return aggregates[i].Bytes > aggregates[j].Bytes
+	})
+
+	result := []models.FolderUsage{}
+	for _, aggregate := range aggregates {
+		if len(result) == top {
+			break
+		}
+		if aggregate.FileCount <= 0 {
+			continue
+		}
+
+		usage := models.FolderUsage{
+			FolderID:  aggregate.FolderID,
+			TotalSize: aggregate.Bytes,
+			FileCount: aggregate.FileCount,
+		}
+
+		if aggregate.FolderID != models.RootFolderID {
+			folder, err := s.folderRepo.GetFolder(ctx, aggregate.FolderID, userID)
+			switch {
+			case err == nil:
+				usage.FolderName = folder.Name
+			case !errors.Is(err, repositories.ErrFolderNotFound):
+				return nil, err
+			}
+		}
+
+		result = append(result, usage)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+func TestGroupUsageByMonth(t *testing.T) {
+	aggregates := []models.UsageAggregate{
+		{Month: "2025-12", Bytes: 100, FileCount: 1},
+		{Month: "2026-01", Bytes: 200, FileCount: 2},
+		{Month: "2026-01", ContentType: "text/plain", Bytes: 200, FileCount: 2},
+		{Month: "2026-02", Bytes: 300, FileCount: 3},
+	}
+
+	tests := []struct {
+		name       string
+		query      models.DashboardQuery
+		now        time.Time
+		wantMonths []string
+		wantBytes  map[string]int64
+	}{
+		{
+			name:       "default period",
+			now:        time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+			wantMonths: []string{"2025-02", "2025-03", "2025-04", "2025-05", "2025-06", "2025-07", "2025-08", "2025-09", "2025-10", "2025-11", "2025-12", "2026-01"},
+			wantBytes:  map[string]int64{"2025-12": 100, "2026-01": 200},
+		},
+		{
+			name:       "already next month in the caller's timezone",
+			query:      models.DashboardQuery{From: "2026-01-01", Timezone: "Asia/Tokyo"},
+			now:        time.Date(2026, 1, 31, 20, 0, 0, 0, time.UTC),
+			wantMonths: []string{"2026-01", "2026-02"},
+			wantBytes:  map[string]int64{"2026-01": 200, "2026-02": 300},
+		},
+		{
+			name:       "still last month in the caller's timezone",
+			query:      models.DashboardQuery{Granularity: models.GranularityDay, From: "2026-01-20", Timezone: "America/New_York"},
+			now:        time.Date(2026, 2, 1, 2, 0, 0, 0, time.UTC),
+			wantMonths: []string{"2026-01"},
+			wantBytes:  map[string]int64{"2026-01": 200},
+		},
+		{
+			name:       "weeks across the new year",
+			query:      models.DashboardQuery{Granularity: models.GranularityWeek, From: "2025-12-10", To: "2026-01-10"},
+			now:        time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantMonths: []string{"2025-12", "2026-01"},
+			wantBytes:  map[string]int64{"2025-12": 100, "2026-01": 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period, err := resolveDashboardPeriod(tt.query, tt.now)
+			if err != nil {
+				t.Fatalf("resolveDashboardPeriod: %v", err)
+			}
+
+			months := groupUsageByMonth(aggregates, period)
+
+			var got []string
+			for _, month := range months {
+				got = append(got, month.Month)
+				if month.TotalSize != tt.wantBytes[month.Month] {
+					t.Errorf("%s holds %d bytes, want %d", month.Month, month.TotalSize, tt.wantBytes[month.Month])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantMonths) {
+				t.Errorf("months = %v, want %v", got, tt.wantMonths)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// FindDuplicates groups the user's active files by content. Groups that
+// would free the most space if cleaned up come first.
+func (s *StorageService) FindDuplicates(ctx context.Context, userID string) (*models.DuplicatesResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	files, err := s.storageRepo.ListDuplicateCandidates(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := map[string]*models.DuplicateGroup{}
+	for _, file := range files {
+		group, ok := byHash[file.ContentHash]
+		if !ok {
+			group = &models.DuplicateGroup{ContentHash: file.ContentHash, FileSize: file.FileSize}
+			byHash[file.ContentHash] = group
+		}
+		group.Files = append(group.Files, file)
+		group.Count++
+	}
+
+	groups := []models.DuplicateGroup{}
+	for _, group := range byHash {
+		if group.Count > 1 {
+			groups = append(groups, *group)
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		wasteI := groups[i].FileSize * int64(groups[i].Count-1)
+		wasteJ := groups[j].FileSize * int64(groups[j].Count-1)
+		if wasteI != wasteJ {
+			return wasteI > wasteJ
+		}
+		return groups[i].ContentHash < groups[j].ContentHash
+	})
+
+	return &models.DuplicatesResponse{
+		Success: true,
+		Message: "Duplicate files fetched successfully",
+		Groups:  groups,
+		Count:   len(groups),
+	}, nil
+}
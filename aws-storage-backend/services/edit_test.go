@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// lossyStore stores what it is given, but once fail is set it reports every
+// Put as failed, like a write whose response got lost.
+type lossyStore struct {
+	blobstore.BlobStore
+	fail bool
+}
+
+func (s *lossyStore) Put(ctx context.Context, key string, body io.Reader, options blobstore.PutOptions) (*blobstore.ObjectInfo, error) {
+	info, err := s.BlobStore.Put(ctx, key, body, options)
+	if err == nil && s.fail {
+		return nil, errors.New("connection reset")
+	}
+	return info, err
+}
+
+// content reads the bytes currently stored for a file and checks they are the
+// ones its record points at.
+func (s *testService) content(t *testing.T, storageObj *models.StorageObject) string {
+	t.Helper()
+
+	object, err := s.blobStore.Get(context.Background(), storageObj.S3Key, blobstore.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer object.Body.Close()
+
+	if object.ETag != storageObj.ETag {
+		t.Errorf("stored content has ETag %q, the file records %q", object.ETag, storageObj.ETag)
+	}
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	return string(data)
+}
+
+func (s *testService) quarantine(t *testing.T, fileID string) {
+	t.Helper()
+
+	if err := s.stores.Storage.QuarantineFile(context.Background(), s.file(t, fileID), "checksum mismatch"); err != nil {
+		t.Fatalf("QuarantineFile: %v", err)
+	}
+}
+
+func TestUpdateFileMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, s *testService) string
+		version *int64
+		wantErr error
+	}{
+		{name: "active file", setup: func(t *testing.T, s *testService) string {
+			return s.upload(t, "user-1", "", "a.txt", "hello").ObjectID
+		}},
+		{name: "stale version", version: new(int64), wantErr: repositories.ErrVersionConflict, setup: func(t *testing.T, s *testService) string {
+			return s.upload(t, "user-1", "", "a.txt", "hello").ObjectID
+		}},
+		{name: "in the trash", wantErr: repositories.ErrFileNotFound, setup: func(t *testing.T, s *testService) string {
+			file := s.upload(t, "user-1", "", "a.txt", "hello")
+			if _, err := s.DeleteFile(context.Background(), "user-1", file.ObjectID); err != nil {
+				t.Fatalf("DeleteFile: %v", err)
+			}
+			return file.ObjectID
+		}},
+		{name: "pending upload", wantErr: repositories.ErrFileNotFound, setup: func(t *testing.T, s *testService) string {
+			pending, err := s.stores.Storage.CreatePendingFile(context.Background(), "user-1", models.RootFolderID, "a.txt", 5, "text/plain", nil, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatalf("CreatePendingFile: %v", err)
+			}
+			return pending.ObjectID
+		}},
+		{name: "quarantined", wantErr: repositories.ErrFileQuarantined, setup: func(t *testing.T, s *testService) string {
+			file := s.upload(t, "user-1", "", "a.txt", "hello")
+			s.quarantine(t, file.ObjectID)
+			return file.ObjectID
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s := newTestService(t, driver)
+				fileID := tt.setup(t, s)
+				before := s.file(t, fileID)
+
+				name := "b.txt"
+				_, err := s.UpdateFileMetadata(context.Background(), "user-1", fileID, models.UpdateFileMetadataRequest{FileName: &name, Version: tt.version})
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("UpdateFileMetadata: %v, want %v", err, tt.wantErr)
+				}
+
+				after := s.file(t, fileID)
+				if tt.wantErr != nil {
+					if after.FileName != before.FileName || after.Version != before.Version {
+						t.Errorf("refused edit changed the file to %q at version %d", after.FileName, after.Version)
+					}
+					return
+				}
+				if after.FileName != name || after.Version != before.Version+1 {
+					t.Errorf("edited file is %q at version %d, want %q at version %d", after.FileName, after.Version, name, before.Version+1)
+				}
+			})
+		}
+	})
+}
+
+func TestReplaceFileContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		quarantined bool
+		version     *int64
+		failWrite   bool
+		wantErr     bool
+		wantContent string
+	}{
+		{name: "new content", wantContent: "hello, world"},
+		{name: "quarantined file", quarantined: true, wantContent: "hello, world"},
+		{name: "stale version", version: new(int64), wantErr: true, wantContent: "hello"},
+		{name: "write lost after storing", failWrite: true, wantErr: true, wantContent: "hello"},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				store := &lossyStore{BlobStore: blobstore.NewMemoryStore(nil)}
+				s := newTestServiceOn(t, driver, store)
+				fileID := s.upload(t, "user-1", "", "a.txt", "hello").ObjectID
+				if tt.quarantined {
+					s.quarantine(t, fileID)
+				}
+				before := s.file(t, fileID)
+				store.fail = tt.failWrite
+
+				_, err := s.ReplaceFileContent(ctx, "user-1", fileID, fileHeader(t, "a.txt", "hello, world"), tt.version)
+				if tt.wantErr != (err != nil) {
+					t.Fatalf("ReplaceFileContent: %v, want error %v", err, tt.wantErr)
+				}
+
+				after := s.file(t, fileID)
+				if got := s.content(t, after); got != tt.wantContent {
+					t.Errorf("content = %q, want %q", got, tt.wantContent)
+				}
+				if got := s.usedBytes(t, "user-1"); got != int64(len(tt.wantContent)) {
+					t.Errorf("UsedBytes = %d, want %d", got, len(tt.wantContent))
+				}
+				if after.IsReplacing() {
+					t.Errorf("file is still claimed by replacement %s", after.ReplaceID)
+				}
+				if !tt.wantErr && (after.IsQuarantined() || after.Version <= before.Version) {
+					t.Errorf("replaced file is at version %d (was %d), quarantined %v", after.Version, before.Version, after.IsQuarantined())
+				}
+
+				// A failed replacement must not keep the file from being
+				// edited.
+				store.fail = false
+				name := "b.txt"
+				if _, err := s.UpdateFileMetadata(ctx, "user-1", fileID, models.UpdateFileMetadataRequest{FileName: &name}); err != nil && !after.IsQuarantined() {
+					t.Errorf("UpdateFileMetadata after the replacement: %v", err)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"slices"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"github.com/google/uuid"
+)
+
+// CreateFileRequest opens a link guests can use to upload into one of the
+// user's folders. Limits left unset fall back to the normal upload rules.
+func (s *StorageService) CreateFileRequest(ctx context.Context, userID string, req models.CreateFileRequestRequest) (*models.FileRequest, error) {
+	folderID, err := s.resolveParentID(ctx, userID, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxFileSize := req.MaxFileSize
+	if maxFileSize == 0 || maxFileSize > s.storageConfig.MaxUploadSize {
+		maxFileSize = s.storageConfig.MaxUploadSize
+	}
+
+	for _, contentType := range req.AllowedContentTypes {
+		if !s.storageConfig.AllowsContentType(contentType) {
+			return nil, fmt.Errorf("file type %q is not allowed", contentType)
+		}
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	request := &models.FileRequest{
+		RequestID:           uuid.New().String(),
+		Token:               token,
+		UserID:              userID,
+		FolderID:            folderID,
+		Title:               req.Title,
+		MaxFiles:            req.MaxFiles,
+		MaxFileSize:         maxFileSize,
+		AllowedContentTypes: req.AllowedContentTypes,
+		CreatedAt:           now,
+	}
+
+	if req.ExpiresInHours > 0 {
+		request.ExpiresAt = now.Add(time.Duration(req.ExpiresInHours) * time.Hour).Unix()
+	}
+
+	return s.requestRepo.CreateFileRequest(ctx, request)
+}
+
+func (s *StorageService) ListFileRequests(ctx context.Context, userID string) (*models.FileRequestsResponse, error) {
+	requests, err := s.requestRepo.ListFileRequests(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FileRequestsResponse{
+		Success:  true,
+		Message:  "File requests fetched successfully",
+		Requests: requests,
+	}, nil
+}
+
+// ListFileRequestUploads returns what guests have uploaded through a request,
+// including the name and email they gave.
+func (s *StorageService) ListFileRequestUploads(ctx context.Context, userID string, requestID string) (*models.FileRequestUploadsResponse, error) {
+	request, err := s.requestRepo.GetFileRequest(ctx, requestID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.storageRepo.ListFilesByRequest(ctx, userID, request.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FileRequestUploadsResponse{
+		Success: true,
+		Message: "Uploads fetched successfully",
+		Files:   files,
+	}, nil
+}
+
+func (s *StorageService) RevokeFileRequest(ctx context.Context, userID string, requestID string) (*string, error) {
+	if requestID == "" {
+		return nil, repositories.ErrFileRequestNotFound
+	}
+
+	if err := s.requestRepo.RevokeFileRequest(ctx, requestID, userID); err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("File request %v closed.", requestID)
+	return &message, nil
+}
+
+// GetPublicFileRequest describes an open request to a guest.
+func (s *StorageService) GetPublicFileRequest(ctx context.Context, token string) (*models.PublicFileRequest, error) {
+	request, err := s.openFileRequest(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	public := &models.PublicFileRequest{
+		Title:               request.Title,
+		ExpiresAt:           request.ExpiresAt,
+		MaxFileSize:         request.MaxFileSize,
+		AllowedContentTypes: request.AllowedContentTypes,
+	}
+
+	if request.MaxFiles > 0 {
+		left := request.MaxFiles - request.UploadCount
+		public.FilesLeft = &left
+	}
+
+	return public, nil
+}
+
+// UploadToFileRequest stores a guest's file in the request owner's folder. A
+// slot is reserved before the upload so MaxFiles holds under concurrent
+// uploads, and handed back if the upload fails.
+func (s *StorageService) UploadToFileRequest(ctx context.Context, token string, file *multipart.FileHeader, uploader *models.Uploader) (*models.UploadFileResponse, error) {
+	if file == nil {
+		return nil, fmt.Errorf("file is required.")
+	}
+
+	request, err := s.openFileRequest(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := file.Header.Get("Content-Type")
+	if err := s.validateUpload(file.Filename, contentType, file.Size, request.MaxFileSize); err != nil {
+		return nil, err
+	}
+
+	if len(request.AllowedContentTypes) > 0 && !slices.Contains(request.AllowedContentTypes, contentType) {
+		return nil, fmt.Errorf("file type not allowed for this request")
+	}
+
+	// The owner may have deleted the target folder since creating the request.
+	parentID := request.FolderID
+	if _, err := s.resolveParentID(ctx, request.UserID, parentID); errors.Is(err, repositories.ErrFolderNotFound) {
+		parentID = models.RootFolderID
+	} else if err != nil {
+		return nil, err
+	}
+
+	if uploader != nil && uploader.Name == "" && uploader.Email == "" {
+		uploader = nil
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		log.Printf("Failed to open file: %v", err)
+		return nil, fmt.Errorf("failed to open file")
+	}
+	defer src.Close()
+
+	// Guest uploads count against the quota of the request's owner.
+	if err := s.reserveStorage(ctx, request.UserID, file.Size); err != nil {
+		return nil, err
+	}
+
+	if err := s.requestRepo.ReserveUpload(ctx, request.RequestID, time.Now()); err != nil {
+		s.releaseStorage(ctx, request.UserID, file.Size)
+		return nil, err
+	}
+
+	storageObj, err := s.storageRepo.UploadRequestedFile(ctx, request, parentID, file.Filename, file.Size, contentType, src, uploader)
+	if errors.Is(err, repositories.ErrOutboxPending) {
+		return nil, err
+	}
+	if err != nil {
+		if !errors.Is(err, repositories.ErrUploadRolledBack) {
+			s.releaseStorage(ctx, request.UserID, file.Size)
+		}
+		if releaseErr := s.requestRepo.ReleaseUpload(ctx, request.RequestID); releaseErr != nil {
+			log.Printf("Failed to release upload slot of file request %s: %v", request.RequestID, releaseErr)
+		}
+		return nil, err
+	}
+
+	s.recordVersion(ctx, storageObj, models.UploadedByGuest)
+
+	return &models.UploadFileResponse{
+		ObjectID:       storageObj.ObjectID,
+		FileName:       storageObj.FileName,
+		FileSize:       storageObj.FileSize,
+		ContentType:    storageObj.ContentType,
+		UploadedAt:     storageObj.UploadedAt,
+		ChecksumSHA256: storageObj.ChecksumSHA256,
+		ChecksumCRC32C: storageObj.ChecksumCRC32C,
+		Message:        "File uploaded successfully",
+	}, nil
+}
+
+// openFileRequest loads a request by token and checks it still takes uploads.
+// ReserveUpload repeats the checks atomically when a file actually arrives.
+func (s *StorageService) openFileRequest(ctx context.Context, token string) (*models.FileRequest, error) {
+	if token == "" {
+		return nil, repositories.ErrFileRequestNotFound
+	}
+
+	request, err := s.requestRepo.GetFileRequestByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.RevokedAt != nil || (request.ExpiresAt > 0 && time.Now().Unix() >= request.ExpiresAt) {
+		return nil, repositories.ErrFileRequestUnavailable
+	}
+
+	if request.MaxFiles > 0 && request.UploadCount >= request.MaxFiles {
+		return nil, repositories.ErrFileRequestUnavailable
+	}
+
+	return request, nil
+}
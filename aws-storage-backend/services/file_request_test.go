@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// fileRequest opens a file request of user-1 on its root folder.
+func (s *testService) fileRequest(t *testing.T, req models.CreateFileRequestRequest) *models.FileRequest {
+	t.Helper()
+
+	req.Title = "Documents"
+	request, err := s.CreateFileRequest(context.Background(), "user-1", req)
+	if err != nil {
+		t.Fatalf("CreateFileRequest: %v", err)
+	}
+	return request
+}
+
+func (s *testService) fileRequestUploads(t *testing.T, requestID string) []models.StorageObject {
+	t.Helper()
+
+	response, err := s.ListFileRequestUploads(context.Background(), "user-1", requestID)
+	if err != nil {
+		t.Fatalf("ListFileRequestUploads: %v", err)
+	}
+	return response.Files
+}
+
+func TestUploadToFileRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     models.CreateFileRequestRequest
+		setup   func(t *testing.T, s *testService, request *models.FileRequest)
+		wantErr bool
+		wantIs  error
+	}{
+		{name: "open request", req: models.CreateFileRequestRequest{MaxFiles: 2, ExpiresInHours: 1}},
+		{name: "larger than allowed", req: models.CreateFileRequestRequest{MaxFileSize: 4}, wantErr: true},
+		{name: "content type not allowed", req: models.CreateFileRequestRequest{AllowedContentTypes: []string{"image/png"}}, wantErr: true},
+		{name: "full", req: models.CreateFileRequestRequest{MaxFiles: 1}, wantIs: repositories.ErrFileRequestUnavailable, setup: func(t *testing.T, s *testService, request *models.FileRequest) {
+			if _, err := s.UploadToFileRequest(context.Background(), request.Token, fileHeader(t, "first.txt", "hello"), nil); err != nil {
+				t.Fatalf("UploadToFileRequest: %v", err)
+			}
+		}},
+		{name: "closed", wantIs: repositories.ErrFileRequestUnavailable, setup: func(t *testing.T, s *testService, request *models.FileRequest) {
+			if _, err := s.RevokeFileRequest(context.Background(), "user-1", request.RequestID); err != nil {
+				t.Fatalf("RevokeFileRequest: %v", err)
+			}
+		}},
+		{name: "unknown token", wantIs: repositories.ErrFileRequestNotFound, setup: func(t *testing.T, s *testService, request *models.FileRequest) {
+			request.Token = "unknown"
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				request := s.fileRequest(t, tt.req)
+				if tt.setup != nil {
+					tt.setup(t, s, request)
+				}
+				before := len(s.fileRequestUploads(t, request.RequestID))
+				usedBefore := s.usedBytes(t, "user-1")
+
+				uploader := &models.Uploader{Name: "Guest", Email: "guest@example.com"}
+				_, err := s.UploadToFileRequest(ctx, request.Token, fileHeader(t, "a.txt", "hello"), uploader)
+
+				uploads := s.fileRequestUploads(t, request.RequestID)
+				if tt.wantErr || tt.wantIs != nil {
+					if err == nil || (tt.wantIs != nil && !errors.Is(err, tt.wantIs)) {
+						t.Fatalf("UploadToFileRequest: %v, want %v", err, tt.wantIs)
+					}
+					if len(uploads) != before || s.usedBytes(t, "user-1") != usedBefore {
+						t.Errorf("refused upload left %d uploads and %d used bytes, want %d and %d", len(uploads), s.usedBytes(t, "user-1"), before, usedBefore)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("UploadToFileRequest: %v", err)
+				}
+
+				if len(uploads) != 1 || uploads[0].Uploader == nil || *uploads[0].Uploader != *uploader || uploads[0].UserID != "user-1" {
+					t.Errorf("uploads = %+v, want a.txt from %+v in user-1's space", uploads, uploader)
+				}
+				if used := s.usedBytes(t, "user-1"); used != usedBefore+5 {
+					t.Errorf("user-1 uses %d bytes, want %d", used, usedBefore+5)
+				}
+
+				public, err := s.GetPublicFileRequest(ctx, request.Token)
+				if err != nil {
+					t.Fatalf("GetPublicFileRequest: %v", err)
+				}
+				if public.FilesLeft == nil || *public.FilesLeft != 1 {
+					t.Errorf("FilesLeft = %v, want 1", public.FilesLeft)
+				}
+			})
+		}
+	})
+}
+
+func TestFileRequestExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		wantErr   error
+	}{
+		{name: "not yet expired", expiresAt: time.Now().Add(time.Minute)},
+		{name: "expired", expiresAt: time.Now().Add(-time.Minute), wantErr: repositories.ErrFileRequestUnavailable},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+
+				request, err := s.stores.FileRequests.CreateFileRequest(ctx, &models.FileRequest{
+					RequestID: "request-1",
+					Token:     "token-1",
+					UserID:    "user-1",
+					FolderID:  models.RootFolderID,
+					Title:     "Documents",
+					ExpiresAt: tt.expiresAt.Unix(),
+					CreatedAt: time.Now().UTC(),
+				})
+				if err != nil {
+					t.Fatalf("CreateFileRequest: %v", err)
+				}
+
+				if _, err := s.GetPublicFileRequest(ctx, request.Token); !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetPublicFileRequest: %v, want %v", err, tt.wantErr)
+				}
+				// The slot reservation checks the expiry again, for guests who
+				// opened the form before it ran out.
+				if err := s.stores.FileRequests.ReserveUpload(ctx, request.RequestID, time.Now()); !errors.Is(err, tt.wantErr) {
+					t.Errorf("ReserveUpload: %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+	})
+}
+
+func TestUploadToFileRequestReleasesSlot(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		store := &lossyStore{BlobStore: blobstore.NewMemoryStore(nil), fail: true}
+		s := newTestServiceOn(t, driver, store)
+		request := s.fileRequest(t, models.CreateFileRequestRequest{MaxFiles: 1})
+
+		if _, err := s.UploadToFileRequest(ctx, request.Token, fileHeader(t, "a.txt", "hello"), nil); err == nil {
+			t.Fatal("UploadToFileRequest succeeded on a failing blob store")
+		}
+
+		// The failed upload handed its slot back, so the one file the request
+		// takes can still arrive.
+		store.fail = false
+		if _, err := s.UploadToFileRequest(ctx, request.Token, fileHeader(t, "a.txt", "hello"), nil); err != nil {
+			t.Fatalf("UploadToFileRequest after a failed upload: %v", err)
+		}
+		if used := s.usedBytes(t, "user-1"); used != 5 {
+			t.Errorf("user-1 uses %d bytes, want 5", used)
+		}
+	})
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// maxFolderDepth bounds every walk up the tree, so a corrupted ParentID chain
+// cannot loop forever.
+const maxFolderDepth = 64
+
+var (
+	ErrFolderCycle    = errors.New("a folder cannot be moved into itself or one of its subfolders")
+	ErrFolderTooDeep  = fmt.Errorf("folders cannot be nested more than %d levels deep", maxFolderDepth)
+	ErrRootFolderEdit = errors.New("the root folder cannot be changed")
+)
+
+func (s *StorageService) CreateFolder(ctx context.Context, userID string, req models.CreateFolderRequest) (*models.Folder, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	parentID, err := s.resolveParentID(ctx, userID, req.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := s.folderPath(ctx, userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(path) >= maxFolderDepth {
+		return nil, ErrFolderTooDeep
+	}
+
+	return s.folderRepo.CreateFolder(ctx, userID, req.Name, parentID)
+}
+
+// GetFolder returns a folder with its direct subfolders and its breadcrumb
+// path. Files are listed through ListFiles with a folderId filter.
+func (s *StorageService) GetFolder(ctx context.Context, userID string, folderID string) (*models.FolderResponse, error) {
+	folder, ownerID, visibleRoot, err := s.viewableFolder(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	folderID = models.RootFolderID
+	if folder != nil {
+		folderID = folder.FolderID
+	}
+
+	children, err := s.folderRepo.ListChildFolders(ctx, ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := s.visibleFolderPath(ctx, ownerID, folderID, visibleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FolderResponse{
+		Success: true,
+		Message: "Folder fetched successfully",
+		Folder:  folder,
+		Folders: children,
+		Path:    path,
+	}, nil
+}
+
+func (s *StorageService) GetFolderPath(ctx context.Context, userID string, folderID string) ([]models.FolderPathEntry, error) {
+	folder, ownerID, visibleRoot, err := s.viewableFolder(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if folder == nil {
+		return s.folderPath(ctx, ownerID, models.RootFolderID)
+	}
+
+	return s.visibleFolderPath(ctx, ownerID, folder.FolderID, visibleRoot)
+}
+
+func (s *StorageService) RenameFolder(ctx context.Context, userID string, folderID string, name string) (*models.Folder, error) {
+	if folderID == "" || folderID == models.RootFolderID {
+		return nil, ErrRootFolderEdit
+	}
+
+	folder, _, err := s.authorizeFolder(ctx, userID, folderID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.folderRepo.RenameFolder(ctx, folderID, folder.UserID, name)
+}
+
+func (s *StorageService) MoveFolder(ctx context.Context, userID string, folderID string, parentID string) (*models.Folder, error) {
+	if folderID == "" || folderID == models.RootFolderID {
+		return nil, ErrRootFolderEdit
+	}
+
+	if _, err := s.folderRepo.GetFolder(ctx, folderID, userID); err != nil {
+		return nil, err
+	}
+
+	parentID, err := s.resolveParentID(ctx, userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := s.folderPath(ctx, userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range path {
+		if entry.FolderID == folderID {
+			return nil, ErrFolderCycle
+		}
+	}
+
+	if len(path) >= maxFolderDepth {
+		return nil, ErrFolderTooDeep
+	}
+
+	return s.folderRepo.MoveFolder(ctx, folderID, userID, parentID)
+}
+
+// DeleteFolder removes a folder and every folder below it, and moves all of
+// their files to the trash. Restored files land in the root folder.
+func (s *StorageService) DeleteFolder(ctx context.Context, userID string, folderID string) (*string, error) {
+	if folderID == "" || folderID == models.RootFolderID {
+		return nil, ErrRootFolderEdit
+	}
+
+	if _, err := s.folderRepo.GetFolder(ctx, folderID, userID); err != nil {
+		return nil, err
+	}
+
+	fileCount, folderCount, err := s.deleteFolderTree(ctx, userID, folderID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	message := fmt.Sprintf("Folder with id :%v deleted with %d subfolders, %d files moved to trash.", folderID, folderCount-1, fileCount)
+	return &message, nil
+}
+
+func (s *StorageService) MoveFile(ctx context.Context, userID string, fileID string, parentID string) (*models.StorageObject, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+
+	parentID, err := s.resolveParentID(ctx, userID, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	storageObj, err := s.storageRepo.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.storageRepo.MoveFile(ctx, storageObj, parentID)
+}
+
+// deleteFolderTree deletes depth-first so that a failure part way through
+// never leaves a folder item whose parent is already gone.
+func (s *StorageService) deleteFolderTree(ctx context.Context, userID string, folderID string, depth int) (int, int, error) {
+	if depth > maxFolderDepth {
+		return 0, 0, ErrFolderTooDeep
+	}
+
+	fileCount, folderCount := 0, 0
+
+	children, err := s.folderRepo.ListChildFolders(ctx, userID, folderID)
+	if err != nil {
+		return fileCount, folderCount, err
+	}
+
+	for _, child := range children {
+		files, folders, err := s.deleteFolderTree(ctx, userID, child.FolderID, depth+1)
+		fileCount += files
+		folderCount += folders
+		if err != nil {
+			return fileCount, folderCount, err
+		}
+	}
+
+	files, err := s.storageRepo.ListChildFiles(ctx, userID, folderID)
+	if err != nil {
+		return fileCount, folderCount, err
+	}
+
+	purgeAt := time.Now().Add(s.storageConfig.TrashRetention)
+	for i := range files {
+		var err error
+		switch files[i].Status {
+		case models.StatusTrashed, models.StatusUploading, models.StatusDeleting:
+			// Files still moving into or out of S3 are left to the outbox.
+			continue
+		case models.StatusPending:
+			err = s.deletePendingFile(ctx, &files[i])
+		default:
+			_, err = s.storageRepo.TrashFile(ctx, &files[i], purgeAt)
+		}
+
+		if err != nil {
+			log.Printf("Failed to delete file %s in folder %s: %v", files[i].ObjectID, folderID, err)
+			return fileCount, folderCount, err
+		}
+		fileCount++
+	}
+
+	if err := s.folderRepo.DeleteFolder(ctx, folderID, userID); err != nil {
+		return fileCount, folderCount, err
+	}
+	folderCount++
+
+	return fileCount, folderCount, nil
+}
+
+// viewableFolder resolves a folder the user may read. The root folder comes
+// back as nil and always belongs to the user themselves.
+func (s *StorageService) viewableFolder(ctx context.Context, userID string, folderID string) (*models.Folder, string, string, error) {
+	if folderID == "" || folderID == models.RootFolderID {
+		return nil, userID, models.RootFolderID, nil
+	}
+
+	folder, visibleRoot, err := s.authorizeFolder(ctx, userID, folderID, models.RoleViewer)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return folder, folder.UserID, visibleRoot, nil
+}
+
+// visibleFolderPath is folderPath cut down to start at visibleRoot, so users
+// with a grant do not see the names of the owner's folders above it.
+func (s *StorageService) visibleFolderPath(ctx context.Context, ownerID string, folderID string, visibleRoot string) ([]models.FolderPathEntry, error) {
+	path, err := s.folderPath(ctx, ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, entry := range path {
+		if entry.FolderID == visibleRoot {
+			return path[i:], nil
+		}
+	}
+
+	return path, nil
+}
+
+// resolveParentID maps an optional folder ID from a request onto the ParentID
+// to store, checking that the folder exists and belongs to the user.
+func (s *StorageService) resolveParentID(ctx context.Context, userID string, folderID string) (string, error) {
+	if folderID == "" || folderID == models.RootFolderID {
+		return models.RootFolderID, nil
+	}
+
+	folder, err := s.folderRepo.GetFolder(ctx, folderID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return folder.FolderID, nil
+}
+
+// folderPath walks from folderID up to the root and returns the breadcrumb
+// from the root down, root included.
+func (s *StorageService) folderPath(ctx context.Context, userID string, folderID string) ([]models.FolderPathEntry, error) {
+	path := []models.FolderPathEntry{}
+
+	for current := folderID; current != models.RootFolderID; {
+		if len(path) > maxFolderDepth {
+			return nil, ErrFolderTooDeep
+		}
+
+		folder, err := s.folderRepo.GetFolder(ctx, current, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		path = append(path, models.FolderPathEntry{FolderID: folder.FolderID, Name: folder.Name})
+		current = folder.ParentID
+	}
+
+	path = append(path, models.FolderPathEntry{FolderID: models.RootFolderID, Name: "My Files"})
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return path, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// folder creates a folder of user-1 through the service.
+func (s *testService) folder(t *testing.T, name string, parentID string) string {
+	t.Helper()
+
+	folder, err := s.CreateFolder(context.Background(), "user-1", models.CreateFolderRequest{Name: name, ParentID: parentID})
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	return folder.FolderID
+}
+
+func TestMoveFolder(t *testing.T) {
+	tests := []struct {
+		name    string
+		folder  string
+		parent  string
+		wantErr error
+	}{
+		{name: "into itself", folder: "a", parent: "a", wantErr: ErrFolderCycle},
+		{name: "into its child", folder: "a", parent: "b", wantErr: ErrFolderCycle},
+		{name: "into its grandchild", folder: "a", parent: "c", wantErr: ErrFolderCycle},
+		{name: "into a sibling", folder: "a", parent: "d"},
+		{name: "up to the root", folder: "c", parent: models.RootFolderID},
+		{name: "the root folder", folder: models.RootFolderID, parent: "d", wantErr: ErrRootFolderEdit},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+
+				// a/b/c and d, all at the root.
+				ids := map[string]string{models.RootFolderID: models.RootFolderID}
+				ids["a"] = s.folder(t, "a", "")
+				ids["b"] = s.folder(t, "b", ids["a"])
+				ids["c"] = s.folder(t, "c", ids["b"])
+				ids["d"] = s.folder(t, "d", "")
+
+				moved, err := s.MoveFolder(ctx, "user-1", ids[tt.folder], ids[tt.parent])
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MoveFolder: %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && moved.ParentID != ids[tt.parent] {
+					t.Errorf("moved folder has parent %s, want %s", moved.ParentID, ids[tt.parent])
+				}
+
+				// Whatever happened, every folder still reaches the root.
+				for _, name := range []string{"a", "b", "c", "d"} {
+					if _, err := s.GetFolderPath(ctx, "user-1", ids[name]); err != nil {
+						t.Errorf("GetFolderPath(%s): %v", name, err)
+					}
+				}
+			})
+		}
+	})
+}
+
+func TestDeleteFolder(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+
+		a := s.folder(t, "a", "")
+		b := s.folder(t, "b", a)
+		c := s.folder(t, "c", b)
+		d := s.folder(t, "d", "")
+
+		inA := s.upload(t, "user-1", a, "a.txt", "hello")
+		inC := s.upload(t, "user-1", c, "c.txt", "hello, world")
+		inD := s.upload(t, "user-1", d, "d.txt", "hi")
+		if err := s.reserveStorage(ctx, "user-1", 7); err != nil {
+			t.Fatalf("reserveStorage: %v", err)
+		}
+		pending, err := s.stores.Storage.CreatePendingFile(ctx, "user-1", b, "b.txt", 7, "text/plain", nil, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("CreatePendingFile: %v", err)
+		}
+		usedBefore := s.usedBytes(t, "user-1")
+
+		if _, err := s.DeleteFolder(ctx, "user-1", a); err != nil {
+			t.Fatalf("DeleteFolder: %v", err)
+		}
+
+		for _, id := range []string{a, b, c} {
+			if _, err := s.stores.Folders.GetFolderByID(ctx, id); !errors.Is(err, repositories.ErrFolderNotFound) {
+				t.Errorf("GetFolderByID(%s) after the delete: %v, want ErrFolderNotFound", id, err)
+			}
+		}
+		if _, err := s.stores.Folders.GetFolderByID(ctx, d); err != nil {
+			t.Errorf("sibling folder is gone: %v", err)
+		}
+
+		for _, file := range []*models.StorageObject{inA, inC} {
+			if got := s.file(t, file.ObjectID); got.Status != models.StatusTrashed {
+				t.Errorf("%s is %s, want it in the trash", file.FileName, got.Status)
+			}
+		}
+		if got := s.file(t, inD.ObjectID); !got.IsActive() {
+			t.Errorf("file in the sibling folder is %s, want it active", got.Status)
+		}
+		if _, err := s.stores.Storage.GetFileByID(ctx, pending.ObjectID); !errors.Is(err, repositories.ErrFileNotFound) {
+			t.Errorf("pending upload after the delete: %v, want ErrFileNotFound", err)
+		}
+		// Trashed files keep their quota until they are purged; the pending
+		// upload gives its reservation back.
+		if got := s.usedBytes(t, "user-1"); got != usedBefore-7 {
+			t.Errorf("UsedBytes = %d, want %d", got, usedBefore-7)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+var (
+	ErrGranteeNotFound = errors.New("no registered user with that email")
+	ErrGrantToSelf     = errors.New("you already own this item")
+)
+
+// GrantAccess shares a file or folder the user owns with another user, or
+// changes the role of an existing grant.
+func (s *StorageService) GrantAccess(ctx context.Context, ownerID string, resourceType string, resourceID string, req models.GrantAccessRequest) (*models.Grant, error) {
+	resourceName, err := s.ownedResourceName(ctx, ownerID, resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	grantee, err := s.userRepo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if grantee == nil {
+		return nil, ErrGranteeNotFound
+	}
+	if grantee.UserID == ownerID {
+		return nil, ErrGrantToSelf
+	}
+
+	now := time.Now().UTC()
+	grant := &models.Grant{
+		ResourceID:   resourceID,
+		GranteeID:    grantee.UserID,
+		GranteeEmail: grantee.UserEmail,
+		ResourceType: resourceType,
+		OwnerID:      ownerID,
+		Role:         req.Role,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	previous, err := s.grantRepo.PutGrant(ctx, grant)
+	if err != nil {
+		return nil, err
+	}
+
+	if previous != nil && previous.Role == grant.Role {
+		return grant, nil
+	}
+
+	s.notify(ctx, &models.Notification{
+		UserID:       grantee.UserID,
+		Type:         models.NotificationGrantAdded,
+		ActorID:      ownerID,
+		ResourceID:   resourceID,
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Role:         grant.Role,
+		CreatedAt:    now,
+	})
+
+	return grant, nil
+}
+
+func (s *StorageService) ListGrants(ctx context.Context, ownerID string, resourceType string, resourceID string) (*models.GrantsResponse, error) {
+	if _, err := s.ownedResourceName(ctx, ownerID, resourceType, resourceID); err != nil {
+		return nil, err
+	}
+
+	grants, err := s.grantRepo.ListGrants(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.GrantsResponse{
+		Success: true,
+		Message: "Grants fetched successfully",
+		Grants:  grants,
+	}, nil
+}
+
+func (s *StorageService) RevokeAccess(ctx context.Context, ownerID string, resourceType string, resourceID string, granteeID string) (*string, error) {
+	resourceName, err := s.ownedResourceName(ctx, ownerID, resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	grant, err := s.grantRepo.DeleteGrant(ctx, resourceID, granteeID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(ctx, &models.Notification{
+		UserID:       grant.GranteeID,
+		Type:         models.NotificationGrantRevoked,
+		ActorID:      ownerID,
+		ResourceID:   resourceID,
+		ResourceType: resourceType,
+		ResourceName: resourceName,
+		Role:         grant.Role,
+		CreatedAt:    time.Now().UTC(),
+	})
+
+	message := fmt.Sprintf("Access of user %v to %v %v revoked.", granteeID, resourceType, resourceID)
+	return &message, nil
+}
+
+// ListSharedWithMe returns the files and folders other users granted the user
+// access to. Grants whose item has since been deleted are left out.
+func (s *StorageService) ListSharedWithMe(ctx context.Context, userID string) (*models.SharedWithMeResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	grants, err := s.grantRepo.ListGrantsForGrantee(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []models.SharedItem{}
+	for _, grant := range grants {
+		item := models.SharedItem{Grant: grant}
+
+		switch grant.ResourceType {
+		case models.ResourceTypeFile:
+			storageObj, err := s.storageRepo.GetFileByID(ctx, grant.ResourceID)
+			if errors.Is(err, repositories.ErrFileNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !storageObj.IsActive() {
+				continue
+			}
+			item.File = storageObj
+		case models.ResourceTypeFolder:
+			folder, err := s.folderRepo.GetFolderByID(ctx, grant.ResourceID)
+			if errors.Is(err, repositories.ErrFolderNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			item.Folder = folder
+		default:
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	return &models.SharedWithMeResponse{
+		Success: true,
+		Message: "Shared items fetched successfully",
+		Items:   items,
+	}, nil
+}
+
+// ownedResourceName checks that the user owns the file or folder and returns
+// its name for notifications. Only owners manage grants.
+func (s *StorageService) ownedResourceName(ctx context.Context, ownerID string, resourceType string, resourceID string) (string, error) {
+	switch resourceType {
+	case models.ResourceTypeFile:
+		storageObj, err := s.authorizeFile(ctx, ownerID, resourceID, models.RoleOwner)
+		if err != nil {
+			return "", err
+		}
+		return storageObj.FileName, nil
+	case models.ResourceTypeFolder:
+		if resourceID == "" || resourceID == models.RootFolderID {
+			return "", ErrRootFolderEdit
+		}
+		folder, err := s.folderRepo.GetFolder(ctx, resourceID, ownerID)
+		if err != nil {
+			return "", err
+		}
+		return folder.Name, nil
+	default:
+		return "", fmt.Errorf("unknown resource type %q", resourceType)
+	}
+}
+
+// notify stores a notification. The change it reports has already happened,
+// so a failure here is logged rather than returned.
+func (s *StorageService) notify(ctx context.Context, notification *models.Notification) {
+	if _, err := s.notifyRepo.CreateNotification(ctx, notification); err != nil {
+		log.Printf("Failed to notify user %s about %s: %v", notification.UserID, notification.ResourceID, err)
+	}
+}
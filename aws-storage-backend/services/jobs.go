@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPeriodically calls job every interval until ctx is done. Failures are
+// logged and retried on the next tick.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) (int, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				processed, err := job(ctx)
+				if err != nil {
+					log.Printf("%s failed: %v", name, err)
+					continue
+				}
+				if processed > 0 {
+					log.Printf("%s processed %d items", name, processed)
+				}
+			}
+		}
+	}()
+}
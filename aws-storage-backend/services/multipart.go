@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+const (
+	minPartSize            = 8 * 1024 * 1024 // S3 needs at least 5 MB for every part but the last
+	maxPartCount           = 10000           // S3 limit
+	multipartSessionExpiry = 24 * time.Hour
+	staleMultipartAge      = 2 * multipartSessionExpiry
+)
+
+var (
+	ErrInvalidPartNumber = errors.New("invalid part number")
+	ErrInvalidPartSize   = errors.New("part size does not match the upload plan")
+	ErrIncompleteUpload  = errors.New("not all parts have been uploaded")
+	ErrPartMismatch      = errors.New("part does not match what the blob store received")
+)
+
+func (s *StorageService) InitiateMultipartUpload(ctx context.Context, userID string, req models.InitiateMultipartUploadRequest) (*models.MultipartUploadResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	if err := s.validateUpload(req.FileName, req.ContentType, req.FileSize, s.storageConfig.MaxMultipartUploadSize); err != nil {
+		return nil, err
+	}
+
+	partSize, partCount := planParts(req.FileSize)
+
+	parentID, err := s.resolveParentID(ctx, userID, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.reserveStorage(ctx, userID, req.FileSize); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(multipartSessionExpiry)
+	storageObj, err := s.storageRepo.CreatePendingFile(ctx, userID, parentID, req.FileName, req.FileSize, req.ContentType, req.Description, expiresAt)
+	if err != nil {
+		s.releaseStorage(ctx, userID, req.FileSize)
+		return nil, err
+	}
+
+	upload, err := s.storageRepo.CreateMultipartUpload(ctx, storageObj, partSize, partCount)
+	if err != nil {
+		if cleanupErr := s.deletePendingFile(ctx, storageObj); cleanupErr != nil {
+			log.Printf("Failed to remove pending file %s after multipart init error: %v", storageObj.ObjectID, cleanupErr)
+		}
+		return nil, err
+	}
+
+	return multipartResponse(storageObj, upload), nil
+}
+
+func (s *StorageService) GetMultipartUpload(ctx context.Context, userID string, objectID string) (*models.MultipartUploadResponse, error) {
+	storageObj, upload, err := s.loadMultipartUpload(ctx, userID, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return multipartResponse(storageObj, upload), nil
+}
+
+func (s *StorageService) GetPartURL(ctx context.Context, userID string, objectID string, partNumber int32) (*models.PartURLResponse, error) {
+	storageObj, upload, err := s.loadMultipartUpload(ctx, userID, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := expectedPartSize(upload, partNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.storageRepo.PresignUploadPart(ctx, storageObj, upload, partNumber, size, uploadURLExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PartURLResponse{
+		PartNumber: partNumber,
+		URL:        url,
+		Size:       size,
+		ExpiresAt:  time.Now().Add(uploadURLExpiry).UTC(),
+	}, nil
+}
+
+func (s *StorageService) UploadPart(ctx context.Context, userID string, objectID string, partNumber int32, contentLength int64, body io.Reader) (*models.UploadedPart, error) {
+	storageObj, upload, err := s.loadMultipartUpload(ctx, userID, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := expectedPartSize(upload, partNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentLength != size {
+		return nil, ErrInvalidPartSize
+	}
+
+	return s.storageRepo.UploadPart(ctx, storageObj, upload, partNumber, size, body, time.Now().Add(multipartSessionExpiry))
+}
+
+// AcknowledgePart records a part the client sent to S3 with a presigned URL,
+// so a resumed client knows it can skip it. The part is only recorded if S3
+// holds it with the ETag the client reports and the size the plan gives it.
+func (s *StorageService) AcknowledgePart(ctx context.Context, userID string, objectID string, partNumber int32, etag string) (*models.UploadedPart, error) {
+	storageObj, upload, err := s.loadMultipartUpload(ctx, userID, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := expectedPartSize(upload, partNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded, err := s.storageRepo.ListUploadedParts(ctx, storageObj, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored *blobstore.Part
+	for i := range uploaded {
+		if uploaded[i].PartNumber == partNumber {
+			stored = &uploaded[i]
+			break
+		}
+	}
+	// Browsers may hand the ETag header over with or without its quotes.
+	if stored == nil || strings.Trim(stored.ETag, `"`) != strings.Trim(etag, `"`) || stored.Size != size {
+		return nil, ErrPartMismatch
+	}
+
+	part := &models.UploadedPart{
+		PartNumber: partNumber,
+		ETag:       stored.ETag,
+		Size:       size,
+		UploadedAt: time.Now().UTC(),
+	}
+
+	if err := s.storageRepo.RecordPart(ctx, storageObj, part, time.Now().Add(multipartSessionExpiry)); err != nil {
+		return nil, err
+	}
+
+	return part, nil
+}
+
+func (s *StorageService) CompleteMultipartUpload(ctx context.Context, userID string, objectID string) (*models.UploadFileResponse, error) {
+	storageObj, upload, err := s.loadMultipartUpload(ctx, userID, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.storageRepo.ListUploadedParts(ctx, storageObj, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	if int32(len(parts)) != upload.PartCount {
+		return nil, ErrIncompleteUpload
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+
+	for i, part := range parts {
+		size, _ := expectedPartSize(upload, part.PartNumber)
+		if part.PartNumber != int32(i+1) || part.Size != size {
+			return nil, ErrIncompleteUpload
+		}
+	}
+
+	if err := s.storageRepo.CompleteMultipartUpload(ctx, storageObj, upload, parts); err != nil {
+		return nil, err
+	}
+
+	activated, err := s.storageRepo.ActivateFile(ctx, storageObj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordVersion(ctx, activated, userID)
+
+	return &models.UploadFileResponse{
+		ObjectID:       activated.ObjectID,
+		FileName:       activated.FileName,
+		FileSize:       activated.FileSize,
+		ContentType:    activated.ContentType,
+		UploadedAt:     activated.UploadedAt,
+		Description:    activated.Description,
+		ParentID:       activated.ParentID,
+		ChecksumSHA256: activated.ChecksumSHA256,
+		ChecksumCRC32C: activated.ChecksumCRC32C,
+		Message:        "File uploaded successfully",
+	}, nil
+}
+
+func (s *StorageService) AbortMultipartUpload(ctx context.Context, userID string, objectID string) error {
+	storageObj, upload, err := s.loadMultipartUpload(ctx, userID, objectID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storageRepo.AbortMultipartUpload(ctx, storageObj, upload); err != nil {
+		return err
+	}
+
+	return s.deletePendingFile(ctx, storageObj)
+}
+
+// sweepStaleMultipartUploads aborts multipart uploads that the blob store
+// still holds but that no live session tracks any more, e.g. after a crash
+// between CreateMultipartUpload and the DynamoDB write.
+func (s *StorageService) sweepStaleMultipartUploads(ctx context.Context) (int, error) {
+	stale, err := s.storageRepo.ListStaleMultipartUploads(ctx, time.Now().Add(-staleMultipartAge))
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, upload := range stale {
+		objectID := upload.Key[strings.LastIndex(upload.Key, "/")+1:]
+
+		tracked, err := s.storageRepo.GetMultipartUpload(ctx, objectID)
+		if err == nil && tracked.UploadID == upload.UploadID {
+			// Still owned by a pending session; the session expiry handles it.
+			continue
+		}
+		if err != nil && !errors.Is(err, repositories.ErrMultipartUploadNotFound) {
+			log.Printf("Failed to look up multipart upload for %s: %v", objectID, err)
+			continue
+		}
+
+		if err := s.storageRepo.AbortStaleMultipartUpload(ctx, upload); err != nil {
+			log.Printf("Failed to abort stale multipart upload %s: %v", upload.UploadID, err)
+			continue
+		}
+		aborted++
+	}
+
+	return aborted, nil
+}
+
+func (s *StorageService) loadMultipartUpload(ctx context.Context, userID string, objectID string) (*models.StorageObject, *models.MultipartUpload, error) {
+	if objectID == "" {
+		return nil, nil, errors.New("file ID cannot be empty")
+	}
+
+	storageObj, err := s.storageRepo.GetFile(ctx, objectID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if storageObj.Status != models.StatusPending {
+		return nil, nil, repositories.ErrUploadSessionNotPending
+	}
+
+	if time.Now().Unix() > storageObj.ExpiresAt {
+		return nil, nil, ErrUploadSessionExpired
+	}
+
+	upload, err := s.storageRepo.GetMultipartUpload(ctx, objectID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return storageObj, upload, nil
+}
+
+// planParts picks the smallest part size (in whole MB, at least minPartSize)
+// that keeps the upload within S3's part count limit.
+func planParts(fileSize int64) (int64, int32) {
+	partSize := int64(minPartSize)
+	if needed := (fileSize + maxPartCount - 1) / maxPartCount; needed > partSize {
+		const mb = 1024 * 1024
+		partSize = (needed + mb - 1) / mb * mb
+	}
+
+	partCount := (fileSize + partSize - 1) / partSize
+	return partSize, int32(partCount)
+}
+
+func expectedPartSize(upload *models.MultipartUpload, partNumber int32) (int64, error) {
+	if partNumber < 1 || partNumber > upload.PartCount {
+		return 0, ErrInvalidPartNumber
+	}
+
+	if partNumber < upload.PartCount {
+		return upload.PartSize, nil
+	}
+
+	return upload.FileSize - upload.PartSize*int64(upload.PartCount-1), nil
+}
+
+func multipartResponse(storageObj *models.StorageObject, upload *models.MultipartUpload) *models.MultipartUploadResponse {
+	parts := make([]models.UploadedPart, 0, len(upload.Parts))
+	missing := []int32{}
+
+	for partNumber := int32(1); partNumber <= upload.PartCount; partNumber++ {
+		if part, ok := upload.Parts[strconv.Itoa(int(partNumber))]; ok {
+			parts = append(parts, part)
+		} else {
+			missing = append(missing, partNumber)
+		}
+	}
+
+	return &models.MultipartUploadResponse{
+		ObjectID:     storageObj.ObjectID,
+		FileName:     storageObj.FileName,
+		FileSize:     upload.FileSize,
+		PartSize:     upload.PartSize,
+		PartCount:    upload.PartCount,
+		Parts:        parts,
+		MissingParts: missing,
+		ExpiresAt:    time.Unix(storageObj.ExpiresAt, 0).UTC(),
+	}
+}
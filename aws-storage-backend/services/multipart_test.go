@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// agedStore reports every multipart upload as started age ago.
+type agedStore struct {
+	blobstore.BlobStore
+	age time.Duration
+}
+
+func (s agedStore) ListMultipartUploads(ctx context.Context, prefix string) ([]blobstore.MultipartUpload, error) {
+	uploads, err := s.BlobStore.ListMultipartUploads(ctx, prefix)
+	for i := range uploads {
+		uploads[i].Initiated = uploads[i].Initiated.Add(-s.age)
+	}
+	return uploads, err
+}
+
+// initiate starts a multipart upload of a size-byte text file for user-1.
+func (s *testService) initiate(t *testing.T, size int64) *models.MultipartUploadResponse {
+	t.Helper()
+
+	upload, err := s.InitiateMultipartUpload(context.Background(), "user-1", models.InitiateMultipartUploadRequest{FileName: "a.txt", ContentType: "text/plain", FileSize: size})
+	if err != nil {
+		t.Fatalf("InitiateMultipartUpload: %v", err)
+	}
+	return upload
+}
+
+// session returns the file of a multipart upload and the upload the blob
+// store holds for it.
+func (s *testService) session(t *testing.T, objectID string) (*models.StorageObject, *models.MultipartUpload) {
+	t.Helper()
+
+	upload, err := s.stores.Storage.GetMultipartUpload(context.Background(), objectID)
+	if err != nil {
+		t.Fatalf("GetMultipartUpload: %v", err)
+	}
+	return s.file(t, objectID), upload
+}
+
+func TestPlanParts(t *testing.T) {
+	const mb = 1024 * 1024
+
+	tests := []struct {
+		name          string
+		fileSize      int64
+		wantPartSize  int64
+		wantPartCount int32
+	}{
+		{name: "one byte", fileSize: 1, wantPartSize: 8 * mb, wantPartCount: 1},
+		{name: "one full part", fileSize: 8 * mb, wantPartSize: 8 * mb, wantPartCount: 1},
+		{name: "one byte over a part", fileSize: 8*mb + 1, wantPartSize: 8 * mb, wantPartCount: 2},
+		{name: "largest with minimum parts", fileSize: maxPartCount * 8 * mb, wantPartSize: 8 * mb, wantPartCount: maxPartCount},
+		{name: "one byte over that", fileSize: maxPartCount*8*mb + 1, wantPartSize: 9 * mb, wantPartCount: 8889},
+		{name: "largest S3 object", fileSize: 5 * 1024 * 1024 * mb, wantPartSize: 525 * mb, wantPartCount: 9987},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partSize, partCount := planParts(tt.fileSize)
+			if partSize != tt.wantPartSize || partCount != tt.wantPartCount {
+				t.Fatalf("planParts(%d) = %d, %d; want %d, %d", tt.fileSize, partSize, partCount, tt.wantPartSize, tt.wantPartCount)
+			}
+
+			upload := &models.MultipartUpload{FileSize: tt.fileSize, PartSize: partSize, PartCount: partCount}
+			var total int64
+			for partNumber := int32(1); partNumber <= partCount; partNumber++ {
+				size, err := expectedPartSize(upload, partNumber)
+				if err != nil {
+					t.Fatalf("expectedPartSize(%d): %v", partNumber, err)
+				}
+				if size <= 0 || size > partSize || (partNumber < partCount && size < minPartSize) {
+					t.Fatalf("part %d is %d bytes", partNumber, size)
+				}
+				total += size
+			}
+			if total != tt.fileSize {
+				t.Errorf("parts add up to %d bytes, want %d", total, tt.fileSize)
+			}
+
+			for _, partNumber := range []int32{0, partCount + 1} {
+				if _, err := expectedPartSize(upload, partNumber); !errors.Is(err, ErrInvalidPartNumber) {
+					t.Errorf("expectedPartSize(%d): %v, want ErrInvalidPartNumber", partNumber, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAcknowledgePart(t *testing.T) {
+	tests := []struct {
+		name       string
+		sent       string
+		partNumber int32
+		etag       func(stored *blobstore.Part) string
+		wantErr    error
+	}{
+		{name: "part as stored", sent: "hello", partNumber: 1, etag: func(stored *blobstore.Part) string { return stored.ETag }},
+		{name: "ETag without quotes", sent: "hello", partNumber: 1, etag: func(stored *blobstore.Part) string { return strings.Trim(stored.ETag, `"`) }},
+		{name: "other ETag", sent: "hello", partNumber: 1, wantErr: ErrPartMismatch, etag: func(stored *blobstore.Part) string { return `"0123456789abcdef0123456789abcdef"` }},
+		{name: "part never sent", partNumber: 1, wantErr: ErrPartMismatch, etag: func(stored *blobstore.Part) string { return `"0123456789abcdef0123456789abcdef"` }},
+		{name: "part shorter than planned", sent: "hell", partNumber: 1, wantErr: ErrPartMismatch, etag: func(stored *blobstore.Part) string { return stored.ETag }},
+		{name: "part outside the plan", sent: "hello", partNumber: 2, wantErr: ErrInvalidPartNumber, etag: func(stored *blobstore.Part) string { return stored.ETag }},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				upload := s.initiate(t, 5)
+
+				// The client sends the part straight to the blob store with a
+				// presigned URL.
+				stored := &blobstore.Part{}
+				if tt.sent != "" {
+					file, session := s.session(t, upload.ObjectID)
+					var err error
+					stored, err = s.blobStore.UploadPart(ctx, file.S3Key, session.UploadID, 1, int64(len(tt.sent)), strings.NewReader(tt.sent))
+					if err != nil {
+						t.Fatalf("UploadPart: %v", err)
+					}
+				}
+
+				_, err := s.AcknowledgePart(ctx, "user-1", upload.ObjectID, tt.partNumber, tt.etag(stored))
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AcknowledgePart: %v, want %v", err, tt.wantErr)
+				}
+
+				resumed, err := s.GetMultipartUpload(ctx, "user-1", upload.ObjectID)
+				if err != nil {
+					t.Fatalf("GetMultipartUpload: %v", err)
+				}
+				wantMissing := []int32{}
+				if tt.wantErr != nil {
+					wantMissing = []int32{1}
+				}
+				if !reflect.DeepEqual(resumed.MissingParts, wantMissing) {
+					t.Errorf("MissingParts = %v, want %v", resumed.MissingParts, wantMissing)
+				}
+				if tt.wantErr == nil && (len(resumed.Parts) != 1 || resumed.Parts[0].ETag != stored.ETag) {
+					t.Errorf("Parts = %+v, want part 1 with ETag %s", resumed.Parts, stored.ETag)
+				}
+			})
+		}
+	})
+}
+
+func TestResumeMultipartUpload(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+		first, last := strings.Repeat("a", minPartSize), "b"
+		upload := s.initiate(t, int64(len(first+last)))
+		if upload.PartCount != 2 || !reflect.DeepEqual(upload.MissingParts, []int32{1, 2}) {
+			t.Fatalf("new upload has %d parts, missing %v; want 2, both missing", upload.PartCount, upload.MissingParts)
+		}
+
+		if _, err := s.UploadPart(ctx, "user-1", upload.ObjectID, 2, 1, strings.NewReader(last)); err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		resumed, err := s.GetMultipartUpload(ctx, "user-1", upload.ObjectID)
+		if err != nil {
+			t.Fatalf("GetMultipartUpload: %v", err)
+		}
+		if !reflect.DeepEqual(resumed.MissingParts, []int32{1}) || len(resumed.Parts) != 1 || resumed.Parts[0].PartNumber != 2 {
+			t.Fatalf("resumed upload has parts %+v, missing %v; want part 2, missing 1", resumed.Parts, resumed.MissingParts)
+		}
+
+		if _, err := s.CompleteMultipartUpload(ctx, "user-1", upload.ObjectID); !errors.Is(err, ErrIncompleteUpload) {
+			t.Fatalf("CompleteMultipartUpload with a part missing: %v, want ErrIncompleteUpload", err)
+		}
+		if file := s.file(t, upload.ObjectID); file.Status != models.StatusPending {
+			t.Fatalf("file is %s after the refused completion, want it pending", file.Status)
+		}
+
+		if _, err := s.UploadPart(ctx, "user-1", upload.ObjectID, 1, int64(len(first)), strings.NewReader(first)); err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		if _, err := s.CompleteMultipartUpload(ctx, "user-1", upload.ObjectID); err != nil {
+			t.Fatalf("CompleteMultipartUpload: %v", err)
+		}
+
+		file := s.file(t, upload.ObjectID)
+		if !file.IsActive() || s.content(t, file) != first+last {
+			t.Errorf("completed file is %s and does not hold the parts in order", file.Status)
+		}
+		if got := s.usedBytes(t, "user-1"); got != file.FileSize {
+			t.Errorf("UsedBytes = %d, want %d", got, file.FileSize)
+		}
+	})
+}
+
+func TestAbortMultipartUpload(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+		upload := s.initiate(t, 5)
+		if _, err := s.UploadPart(ctx, "user-1", upload.ObjectID, 1, 5, strings.NewReader("hello")); err != nil {
+			t.Fatalf("UploadPart: %v", err)
+		}
+		if got := s.usedBytes(t, "user-1"); got != 5 {
+			t.Fatalf("UsedBytes = %d while uploading, want the 5 bytes reserved", got)
+		}
+
+		if err := s.AbortMultipartUpload(ctx, "user-1", upload.ObjectID); err != nil {
+			t.Fatalf("AbortMultipartUpload: %v", err)
+		}
+
+		if got := s.usedBytes(t, "user-1"); got != 0 {
+			t.Errorf("UsedBytes = %d after the abort, want 0", got)
+		}
+		if _, err := s.stores.Storage.GetFileByID(ctx, upload.ObjectID); !errors.Is(err, repositories.ErrFileNotFound) {
+			t.Errorf("GetFileByID after the abort: %v, want ErrFileNotFound", err)
+		}
+		if uploads, err := s.blobStore.ListMultipartUploads(ctx, ""); err != nil || len(uploads) != 0 {
+			t.Errorf("blob store still holds uploads %+v (%v)", uploads, err)
+		}
+	})
+}
+
+func TestSweepStaleMultipartUploads(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, s *testService) (key string, uploadID string)
+		wantAborted bool
+	}{
+		{name: "tracked by a session", setup: func(t *testing.T, s *testService) (string, string) {
+			file, session := s.session(t, s.initiate(t, 5).ObjectID)
+			return file.S3Key, session.UploadID
+		}},
+		{name: "nobody tracks it", wantAborted: true, setup: func(t *testing.T, s *testService) (string, string) {
+			// The server stopped after starting the upload but before
+			// recording the session.
+			key := "users/user-1/object-1"
+			uploadID, err := s.blobStore.CreateMultipartUpload(context.Background(), key, "text/plain")
+			if err != nil {
+				t.Fatalf("CreateMultipartUpload: %v", err)
+			}
+			return key, uploadID
+		}},
+		{name: "replaced by a later upload of the session", wantAborted: true, setup: func(t *testing.T, s *testService) (string, string) {
+			file, _ := s.session(t, s.initiate(t, 5).ObjectID)
+			uploadID, err := s.blobStore.CreateMultipartUpload(context.Background(), file.S3Key, "text/plain")
+			if err != nil {
+				t.Fatalf("CreateMultipartUpload: %v", err)
+			}
+			return file.S3Key, uploadID
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				memory := blobstore.NewMemoryStore(nil)
+				s := newTestServiceOn(t, driver, agedStore{BlobStore: memory, age: staleMultipartAge + time.Hour})
+				key, uploadID := tt.setup(t, s)
+
+				if _, err := s.sweepStaleMultipartUploads(ctx); err != nil {
+					t.Fatalf("sweepStaleMultipartUploads: %v", err)
+				}
+
+				uploads, err := memory.ListMultipartUploads(ctx, "")
+				if err != nil {
+					t.Fatalf("ListMultipartUploads: %v", err)
+				}
+				kept := false
+				for _, upload := range uploads {
+					kept = kept || (upload.Key == key && upload.UploadID == uploadID)
+				}
+				if kept == tt.wantAborted {
+					t.Errorf("upload %s of %s kept: %v, want %v", uploadID, key, kept, !tt.wantAborted)
+				}
+			})
+		}
+	})
+}
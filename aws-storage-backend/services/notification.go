@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+const defaultNotificationLimit = 50
+
+type NotificationService struct {
+	notifyRepo repositories.NotificationStore
+}
+
+func NewNotificationService(notifyRepo repositories.NotificationStore) *NotificationService {
+	return &NotificationService{
+		notifyRepo: notifyRepo,
+	}
+}
+
+func (s *NotificationService) ListNotifications(ctx context.Context, userID string, query models.ListNotificationsQuery) (*models.NotificationsResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultNotificationLimit
+	}
+
+	notifications, err := s.notifyRepo.ListNotifications(ctx, userID, query.Limit, query.UnreadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.NotificationsResponse{
+		Success:       true,
+		Message:       "Notifications fetched successfully",
+		Notifications: notifications,
+	}, nil
+}
+
+func (s *NotificationService) MarkNotificationRead(ctx context.Context, userID string, notificationID string) error {
+	if notificationID == "" {
+		return repositories.ErrNotificationNotFound
+	}
+
+	return s.notifyRepo.MarkRead(ctx, userID, notificationID)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// ProcessOutbox resumes the uploads, replacements and permanent deletes whose
+// request did not finish them. Quota is given back here for uploads and
+// replacements that had to be rolled back and deletes that completed, since
+// their requests left it reserved.
+func (s *StorageService) ProcessOutbox(ctx context.Context) (int, error) {
+	return s.processOutbox(ctx, time.Now())
+}
+
+// processOutbox is ProcessOutbox for the entries due by now.
+func (s *StorageService) processOutbox(ctx context.Context, now time.Time) (int, error) {
+	entries, err := s.storageRepo.ListDueOutboxEntries(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range entries {
+		entry := &entries[i]
+
+		outcome, err := s.storageRepo.ResumeOutboxEntry(ctx, entry)
+		if err != nil {
+			log.Printf("Failed to resume %s of file %s (attempt %d): %v", entry.Kind, entry.ObjectID, entry.Attempts+1, err)
+			if err := s.storageRepo.RescheduleOutboxEntry(ctx, entry, err); err != nil {
+				log.Printf("Failed to reschedule outbox entry %s: %v", entry.OutboxID, err)
+			}
+			continue
+		}
+
+		if outcome == models.OutboxHeld {
+			continue
+		}
+
+		if ((entry.Kind == models.OutboxUpload || entry.Kind == models.OutboxReplace) && outcome == models.OutboxRolledBack) ||
+			(entry.Kind == models.OutboxPurge && outcome == models.OutboxFinalized) {
+			s.releaseStorage(ctx, entry.UserID, entry.FileSize)
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// StartOutboxWorker runs ProcessOutbox every interval until ctx is done.
+func (s *StorageService) StartOutboxWorker(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "Outbox worker", interval, s.ProcessOutbox)
+}
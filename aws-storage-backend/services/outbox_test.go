@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// unreachableStore fails to delete objects while down is set, like a blob
+// store that cannot be reached.
+type unreachableStore struct {
+	blobstore.BlobStore
+	down bool
+}
+
+func (s *unreachableStore) DeleteAllVersions(ctx context.Context, key string) error {
+	if s.down {
+		return errors.New("connection refused")
+	}
+	return s.BlobStore.DeleteAllVersions(ctx, key)
+}
+
+// stuckCopyStore holds every Copy until release is closed, like a request
+// that stopped while moving an upload into its blob.
+type stuckCopyStore struct {
+	blobstore.BlobStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *stuckCopyStore) Copy(ctx context.Context, srcKey string, srcVersionID string, dstKey string) (*blobstore.ObjectInfo, error) {
+	close(s.started)
+	<-s.release
+	return s.BlobStore.Copy(ctx, srcKey, srcVersionID, dstKey)
+}
+
+func (s *testService) outbox(t *testing.T) []models.OutboxEntry {
+	t.Helper()
+
+	entries, err := s.stores.Storage.ListDueOutboxEntries(context.Background(), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListDueOutboxEntries: %v", err)
+	}
+	return entries
+}
+
+func TestProcessOutboxRetriesPurge(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		store := &unreachableStore{BlobStore: blobstore.NewMemoryStore(nil)}
+		s := newTestServiceOn(t, driver, store)
+
+		// A file with its own versions, which a purge deletes before the
+		// record.
+		file := s.replace(t, s.upload(t, "user-1", "", "a.txt", "hello").ObjectID, "hello, world")
+		used := s.usedBytes(t, "user-1")
+		if _, err := s.DeleteFile(ctx, "user-1", file.ObjectID); err != nil {
+			t.Fatalf("DeleteFile: %v", err)
+		}
+		store.down = true
+		if _, err := s.DeleteTrashedFile(ctx, "user-1", file.ObjectID); !errors.Is(err, repositories.ErrOutboxPending) {
+			t.Fatalf("DeleteTrashedFile: %v, want ErrOutboxPending", err)
+		}
+
+		// Each failed attempt is pushed back and recorded; the bytes stay
+		// reserved until the delete goes through.
+		for attempt := 1; attempt <= 2; attempt++ {
+			processed, err := s.processOutbox(ctx, time.Now().Add(time.Duration(attempt)*time.Hour))
+			if err != nil || processed != 0 {
+				t.Fatalf("processOutbox: %d, %v; want nothing processed", processed, err)
+			}
+			entries := s.outbox(t)
+			if len(entries) != 1 || entries[0].Attempts != attempt || entries[0].LastError == "" {
+				t.Fatalf("outbox after attempt %d = %+v", attempt, entries)
+			}
+			if got := s.usedBytes(t, "user-1"); got != used {
+				t.Errorf("user-1 uses %d bytes, want %d", got, used)
+			}
+		}
+
+		// Not due again until its backoff ran out.
+		if processed, err := s.processOutbox(ctx, time.Now()); err != nil || processed != 0 {
+			t.Errorf("processOutbox before the backoff ran out: %d, %v", processed, err)
+		}
+
+		store.down = false
+		processed, err := s.processOutbox(ctx, time.Now().Add(3*time.Hour))
+		if err != nil || processed != 1 {
+			t.Fatalf("processOutbox: %d, %v; want the purge finished", processed, err)
+		}
+		if _, err := s.stores.Storage.GetFileByID(ctx, file.ObjectID); !errors.Is(err, repositories.ErrFileNotFound) {
+			t.Errorf("GetFileByID: %v, want ErrFileNotFound", err)
+		}
+		if objects, err := store.List(ctx, ""); err != nil || len(objects) != 0 {
+			t.Errorf("blob store holds %v (%v), want the purged bytes gone", objects, err)
+		}
+		if used := s.usedBytes(t, "user-1"); used != 0 {
+			t.Errorf("user-1 uses %d bytes, want 0", used)
+		}
+		if entries := s.outbox(t); len(entries) != 0 {
+			t.Errorf("outbox still holds %+v", entries)
+		}
+	})
+}
+
+func TestProcessOutboxRollsBackAbandonedUpload(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		store := &stuckCopyStore{BlobStore: blobstore.NewMemoryStore(nil), started: make(chan struct{}), release: make(chan struct{})}
+		s := newTestServiceOn(t, driver, store)
+		// Bytes held by another upload still in flight, which the rollback
+		// must not give back.
+		if err := s.reserveStorage(ctx, "user-1", 7); err != nil {
+			t.Fatalf("reserveStorage: %v", err)
+		}
+
+		uploaded := make(chan error, 1)
+		go func() {
+			_, err := s.UploadFile(ctx, "user-1", fileHeader(t, "a.txt", "hello"), nil, "")
+			uploaded <- err
+		}()
+		<-store.started
+
+		// Entries held by a live request are left alone.
+		if processed, err := s.processOutbox(ctx, time.Now()); err != nil || processed != 0 {
+			t.Errorf("processOutbox within the lease: %d, %v", processed, err)
+		}
+
+		processed, err := s.processOutbox(ctx, time.Now().Add(time.Hour))
+		if err != nil || processed != 1 {
+			t.Fatalf("processOutbox: %d, %v; want the upload rolled back", processed, err)
+		}
+		close(store.release)
+		if err := <-uploaded; !errors.Is(err, repositories.ErrUploadRolledBack) {
+			t.Fatalf("UploadFile: %v, want ErrUploadRolledBack", err)
+		}
+
+		// The worker gave the reservation back, and the request did not give
+		// it back a second time.
+		if used := s.usedBytes(t, "user-1"); used != 7 {
+			t.Errorf("user-1 uses %d bytes, want 7", used)
+		}
+		files, err := s.stores.Storage.ListAllUserFiles(ctx, "user-1")
+		if err != nil || len(files) != 0 {
+			t.Errorf("user-1 has files %+v (%v), want none", files, err)
+		}
+		if entries := s.outbox(t); len(entries) != 0 {
+			t.Errorf("outbox still holds %+v", entries)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// storageQuota returns the user's own quota, or the default from config.
+//
+// Usage counts the current bytes of every file a user has not permanently
+// deleted: active and trashed files, plus pending uploads, which reserve
+// their declared size up front. Older versions kept by S3 are not counted.
+func (s *StorageService) storageQuota(user *models.User) int64 {
+	if user.StorageQuota != nil {
+		return *user.StorageQuota
+	}
+	return s.storageConfig.DefaultStorageQuota
+}
+
+// reserveStorage counts size bytes against the user's quota before they are
+// stored. Callers release the bytes again if storing fails.
+func (s *StorageService) reserveStorage(ctx context.Context, userID string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.ReserveStorage(ctx, userID, size, s.storageQuota(user))
+}
+
+// releaseStorage gives bytes back to the user's quota. The bytes are already
+// gone by then, so a failure is logged rather than returned.
+func (s *StorageService) releaseStorage(ctx context.Context, userID string, size int64) {
+	if err := s.userRepo.ReleaseStorage(ctx, userID, size); err != nil {
+		log.Printf("Failed to release %d bytes of user %s: %v", size, userID, err)
+	}
+}
+
+// resizeStorage reserves the growth of a file whose content is about to be
+// replaced. It returns a func to call with the outcome: on failure it hands
+// the reservation back, on success it releases what the file shrank by. A
+// replacement left to the outbox keeps its reservation; the worker hands it
+// back when it rolls the replacement back.
+func (s *StorageService) resizeStorage(ctx context.Context, userID string, oldSize int64, newSize int64) (func(err error), error) {
+	delta := newSize - oldSize
+	if err := s.reserveStorage(ctx, userID, delta); err != nil {
+		return nil, err
+	}
+
+	return func(err error) {
+		switch {
+		case errors.Is(err, repositories.ErrOutboxPending) || errors.Is(err, repositories.ErrUploadRolledBack):
+		case err != nil && delta > 0:
+			s.releaseStorage(ctx, userID, delta)
+		case err == nil && delta < 0:
+			s.releaseStorage(ctx, userID, -delta)
+		}
+	}, nil
+}
+
+// purgeFile gives the bytes back once the file is gone. When the repository
+// leaves the rest of the delete to the outbox, the worker gives them back.
+func (s *StorageService) purgeFile(ctx context.Context, storageObj *models.StorageObject) error {
+	if err := s.storageRepo.PurgeFile(ctx, storageObj); err != nil {
+		return err
+	}
+
+	s.releaseStorage(ctx, storageObj.UserID, storageObj.FileSize)
+	return nil
+}
+
+func (s *StorageService) deletePendingFile(ctx context.Context, storageObj *models.StorageObject) error {
+	if err := s.storageRepo.DeletePendingFile(ctx, storageObj); err != nil {
+		return err
+	}
+
+	s.releaseStorage(ctx, storageObj.UserID, storageObj.FileSize)
+	return nil
+}
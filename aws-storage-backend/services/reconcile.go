@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// DefaultReconcileMinAge leaves recent objects alone: an upload writes its
+// object before its record, so for a moment every new object looks orphaned.
+const DefaultReconcileMinAge = time.Hour
+
+// Reconcile compares what S3 holds under users/<id>/ and blobs/ with the
+// storage and blob tables. Objects nothing points at are orphans; records of
+// active or trashed files whose object is gone are dangling. With options.Fix
+// orphans are deleted and dangling records removed, releasing their quota.
+func (s *StorageService) Reconcile(ctx context.Context, options models.ReconcileOptions) (*models.ReconcileReport, error) {
+	report := &models.ReconcileReport{
+		StartedAt:       time.Now().UTC(),
+		Fix:             options.Fix,
+		OrphanObjects:   []models.OrphanObject{},
+		DanglingRecords: []models.DanglingRecord{},
+	}
+	cutoff := report.StartedAt.Add(-options.MinAge)
+
+	userIDs, err := s.reconcileUserIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userID := range userIDs {
+		if err := s.reconcileUser(ctx, userID, options.Fix, cutoff, report); err != nil {
+			return nil, err
+		}
+		report.Users++
+	}
+
+	blobs, err := s.storageRepo.ListGlobalBlobObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.ObjectsScanned += len(blobs)
+
+	for _, object := range blobs {
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+		if err := s.checkBlobObject(ctx, object, "", options.Fix, report); err != nil {
+			return nil, err
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report, nil
+}
+
+// reconcileUserIDs returns everyone who has a prefix in S3 or an account,
+// since either side may be the one left over.
+func (s *StorageService) reconcileUserIDs(ctx context.Context) ([]string, error) {
+	fromS3, err := s.storageRepo.ListUserPrefixes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fromUsers, err := s.userRepo.ListUserIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	userIDs := []string{}
+	for _, userID := range append(fromS3, fromUsers...) {
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+// reconcileUser checks one user's prefix against their records. The records
+// are read before the objects, so a file saved in between cannot look
+// dangling; its object will be in the listing.
+func (s *StorageService) reconcileUser(ctx context.Context, userID string, fix bool, cutoff time.Time, report *models.ReconcileReport) error {
+	files, err := s.storageRepo.ListAllUserFiles(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	objects, err := s.storageRepo.ListUserObjects(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	report.RecordsScanned += len(files)
+	report.ObjectsScanned += len(objects)
+
+	referenced := map[string]bool{}
+	for _, file := range files {
+		referenced[file.S3Key] = true
+	}
+
+	listed := map[string]bool{}
+	for _, object := range objects {
+		listed[object.Key] = true
+
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+
+		if repositories.IsBlobKey(object.Key) {
+			if err := s.checkBlobObject(ctx, object, userID, fix, report); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if referenced[object.Key] {
+			continue
+		}
+		s.reportOrphan(ctx, object, userID, fix, report)
+	}
+
+	for i := range files {
+		file := &files[i]
+		if !file.IsActive() && file.Status != models.StatusTrashed {
+			// Pending uploads have no object yet and the upload sweeper expires
+			// them; uploading and deleting files are finished by the outbox.
+			continue
+		}
+
+		if strings.HasPrefix(file.S3Key, "users/"+userID+"/") && listed[file.S3Key] {
+			continue
+		}
+
+		exists, err := s.storageRepo.ObjectExists(ctx, file.S3Key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		dangling := models.DanglingRecord{
+			ObjectID: file.ObjectID,
+			UserID:   file.UserID,
+			S3Key:    file.S3Key,
+			Status:   file.Status,
+			FileSize: file.FileSize,
+		}
+
+		if fix {
+			err := s.storageRepo.RemoveDanglingFile(ctx, file)
+			switch {
+			case err == nil:
+				s.releaseStorage(ctx, file.UserID, file.FileSize)
+				dangling.Repaired = true
+			case errors.Is(err, repositories.ErrFileNotFound):
+				// Changed since it was read; the next run looks again.
+				continue
+			default:
+				log.Printf("Failed to remove dangling file %s: %v", file.ObjectID, err)
+			}
+		}
+
+		report.DanglingRecords = append(report.DanglingRecords, dangling)
+	}
+
+	return nil
+}
+
+// checkBlobObject reports a blob object that has no record in the blob table.
+// Blobs with a record are looked after by their reference count.
+func (s *StorageService) checkBlobObject(ctx context.Context, object models.StoredObject, userID string, fix bool, report *models.ReconcileReport) error {
+	exists, err := s.storageRepo.BlobExists(ctx, object.Key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		s.reportOrphan(ctx, object, userID, fix, report)
+	}
+	return nil
+}
+
+// reportOrphan records an orphaned object and, when fixing, deletes it once a
+// fresh read confirms that nothing started pointing at it since the listing.
+func (s *StorageService) reportOrphan(ctx context.Context, object models.StoredObject, userID string, fix bool, report *models.ReconcileReport) {
+	orphan := models.OrphanObject{
+		Key:          object.Key,
+		UserID:       userID,
+		Size:         object.Size,
+		LastModified: object.LastModified,
+	}
+
+	if fix {
+		orphaned, err := s.stillOrphaned(ctx, object.Key)
+		if err != nil {
+			log.Printf("Failed to recheck orphaned object %s: %v", object.Key, err)
+		}
+		if err == nil && !orphaned {
+			return
+		}
+		if err == nil && s.storageRepo.DeleteOrphanObject(ctx, object) == nil {
+			orphan.Repaired = true
+		}
+	}
+
+	report.OrphanObjects = append(report.OrphanObjects, orphan)
+}
+
+func (s *StorageService) stillOrphaned(ctx context.Context, key string) (bool, error) {
+	if repositories.IsBlobKey(key) {
+		exists, err := s.storageRepo.BlobExists(ctx, key)
+		return !exists, err
+	}
+
+	file, err := s.storageRepo.GetFileByID(ctx, path.Base(key))
+	if errors.Is(err, repositories.ErrFileNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return file.S3Key != key, nil
+}
+
+// ReconcileStorage runs Reconcile with the mode from the storage config and
+// logs what it found, for the scheduled job.
+func (s *StorageService) ReconcileStorage(ctx context.Context) (int, error) {
+	report, err := s.Reconcile(ctx, models.ReconcileOptions{
+		Fix:    s.storageConfig.ReconcileFix,
+		MinAge: DefaultReconcileMinAge,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, orphan := range report.OrphanObjects {
+		log.Printf("Reconcile: orphaned object %s (%d bytes), repaired: %t", orphan.Key, orphan.Size, orphan.Repaired)
+	}
+	for _, dangling := range report.DanglingRecords {
+		log.Printf("Reconcile: file %s of user %s has no object at %s, repaired: %t", dangling.ObjectID, dangling.UserID, dangling.S3Key, dangling.Repaired)
+	}
+
+	return len(report.OrphanObjects) + len(report.DanglingRecords), nil
+}
+
+// StartReconciler runs ReconcileStorage every interval until ctx is done.
+func (s *StorageService) StartReconciler(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "Reconciler", interval, s.ReconcileStorage)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/google/uuid"
+)
+
+// put stores content under key as if it had been written by an earlier
+// request.
+func (s *testService) put(t *testing.T, key string, content string) {
+	t.Helper()
+
+	if _, err := s.blobStore.Put(context.Background(), key, strings.NewReader(content), blobstore.PutOptions{ContentType: "text/plain"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+}
+
+func TestReconcileFix(t *testing.T) {
+	tests := []struct {
+		name       string
+		minAge     time.Duration
+		setup      func(t *testing.T, s *testService) string
+		wantOrphan bool
+	}{
+		{name: "orphaned file object", wantOrphan: true, setup: func(t *testing.T, s *testService) string {
+			key := "users/user-1/" + uuid.New().String()
+			s.put(t, key, "hello")
+			return key
+		}},
+		{name: "orphaned user blob", wantOrphan: true, setup: func(t *testing.T, s *testService) string {
+			s.put(t, "users/user-1/blobs/abc", "hello")
+			return "users/user-1/blobs/abc"
+		}},
+		{name: "orphaned global blob", wantOrphan: true, setup: func(t *testing.T, s *testService) string {
+			s.put(t, "blobs/abc", "hello")
+			return "blobs/abc"
+		}},
+		{name: "object of a live file", setup: func(t *testing.T, s *testService) string {
+			file, err := s.stores.Storage.DetachBlob(context.Background(), s.upload(t, "user-1", "", "a.txt", "hello"))
+			if err != nil {
+				t.Fatalf("DetachBlob: %v", err)
+			}
+			return file.S3Key
+		}},
+		{name: "referenced blob", setup: func(t *testing.T, s *testService) string {
+			return s.upload(t, "user-1", "", "a.txt", "hello").S3Key
+		}},
+		{name: "pending upload", setup: func(t *testing.T, s *testService) string {
+			pending, err := s.stores.Storage.CreatePendingFile(context.Background(), "user-1", models.RootFolderID, "a.txt", 5, "text/plain", nil, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatalf("CreatePendingFile: %v", err)
+			}
+			s.put(t, pending.S3Key, "hello")
+			return pending.S3Key
+		}},
+		{name: "multipart upload joined but not recorded", setup: func(t *testing.T, s *testService) string {
+			ctx := context.Background()
+			upload, err := s.InitiateMultipartUpload(ctx, "user-1", models.InitiateMultipartUploadRequest{FileName: "a.txt", ContentType: "text/plain", FileSize: 5})
+			if err != nil {
+				t.Fatalf("InitiateMultipartUpload: %v", err)
+			}
+			part, err := s.UploadPart(ctx, "user-1", upload.ObjectID, 1, 5, strings.NewReader("hello"))
+			if err != nil {
+				t.Fatalf("UploadPart: %v", err)
+			}
+
+			// The request completing the upload stopped after S3 joined the
+			// parts.
+			file := s.file(t, upload.ObjectID)
+			multipart, err := s.stores.Storage.GetMultipartUpload(ctx, upload.ObjectID)
+			if err != nil {
+				t.Fatalf("GetMultipartUpload: %v", err)
+			}
+			if err := s.blobStore.CompleteMultipartUpload(ctx, file.S3Key, multipart.UploadID, []blobstore.Part{{PartNumber: 1, ETag: part.ETag, Size: 5}}); err != nil {
+				t.Fatalf("CompleteMultipartUpload: %v", err)
+			}
+			return file.S3Key
+		}},
+		{name: "orphan younger than the minimum age", minAge: time.Hour, setup: func(t *testing.T, s *testService) string {
+			key := "users/user-1/" + uuid.New().String()
+			s.put(t, key, "hello")
+			return key
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				key := tt.setup(t, s)
+
+				report, err := s.Reconcile(ctx, models.ReconcileOptions{Fix: true, MinAge: tt.minAge})
+				if err != nil {
+					t.Fatalf("Reconcile: %v", err)
+				}
+
+				var orphans []models.OrphanObject
+				for _, orphan := range report.OrphanObjects {
+					if orphan.Key == key {
+						orphans = append(orphans, orphan)
+					}
+				}
+				if got := len(orphans) == 1 && orphans[0].Repaired; got != tt.wantOrphan || len(orphans) > 1 {
+					t.Errorf("report lists %+v for %s, want it repaired as an orphan: %v", orphans, key, tt.wantOrphan)
+				}
+				if len(report.DanglingRecords) != 0 {
+					t.Errorf("report lists dangling records %+v", report.DanglingRecords)
+				}
+
+				_, err = s.blobStore.Head(ctx, key, "")
+				if exists := err == nil; exists == tt.wantOrphan {
+					t.Errorf("object %s exists: %v, want %v", key, exists, !tt.wantOrphan)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// ScrubFiles checks every stored file against its metadata, quarantining
+// failures when the storage config says so, and logs what it found.
+func (s *StorageService) ScrubFiles(ctx context.Context) (int, error) {
+	report, err := s.storageRepo.ScrubFiles(ctx, models.ScrubOptions{Quarantine: s.storageConfig.ScrubQuarantine})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, finding := range report.Findings {
+		log.Printf("Scrub: file %s of user %s (%s): %s, expected %q, found %q, quarantined: %t",
+			finding.ObjectID, finding.UserID, finding.S3Key, finding.Problem, finding.Expected, finding.Actual, finding.Quarantined)
+	}
+
+	return len(report.Findings), nil
+}
+
+// StartScrubber runs ScrubFiles every interval until ctx is done.
+func (s *StorageService) StartScrubber(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "Scrubber", interval, s.ScrubFiles)
+}
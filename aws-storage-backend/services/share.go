@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrSharePasswordRequired = errors.New("this share link is password protected")
+	ErrSharePasswordInvalid  = errors.New("invalid share link password")
+)
+
+type ShareService struct {
+	shareRepo   repositories.ShareStore
+	storageRepo repositories.ObjectStore
+}
+
+func NewShareService(shareRepo repositories.ShareStore, storageRepo repositories.ObjectStore) *ShareService {
+	return &ShareService{
+		shareRepo:   shareRepo,
+		storageRepo: storageRepo,
+	}
+}
+
+func (s *ShareService) CreateShareLink(ctx context.Context, userID string, fileID string, req models.CreateShareLinkRequest) (*models.ShareLink, error) {
+	storageObj, err := s.getOwnedFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	link := &models.ShareLink{
+		Token:        token,
+		ObjectID:     storageObj.ObjectID,
+		UserID:       userID,
+		MaxDownloads: req.MaxDownloads,
+		CreatedAt:    now,
+	}
+
+	if req.ExpiresInHours > 0 {
+		link.ExpiresAt = now.Add(time.Duration(req.ExpiresInHours) * time.Hour).Unix()
+	}
+
+	if req.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = string(hashedPassword)
+	}
+
+	created, err := s.shareRepo.CreateShareLink(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	created.HasPassword = created.PasswordHash != ""
+	return created, nil
+}
+
+func (s *ShareService) ListShareLinks(ctx context.Context, userID string, fileID string) (*models.ShareLinksResponse, error) {
+	storageObj, err := s.getOwnedFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.shareRepo.ListShareLinks(ctx, storageObj.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range links {
+		links[i].HasPassword = links[i].PasswordHash != ""
+	}
+
+	return &models.ShareLinksResponse{
+		Success: true,
+		Message: "Share links fetched successfully",
+		Links:   links,
+	}, nil
+}
+
+func (s *ShareService) RevokeShareLink(ctx context.Context, userID string, token string) (*string, error) {
+	if token == "" {
+		return nil, repositories.ErrShareLinkNotFound
+	}
+
+	if _, err := s.shareRepo.RevokeShareLink(ctx, token, userID); err != nil {
+		return nil, err
+	}
+
+	message := "Share link revoked."
+	return &message, nil
+}
+
+// GetSharedFile describes the file behind a link without counting a download.
+func (s *ShareService) GetSharedFile(ctx context.Context, token string, password string) (*models.SharedFileInfo, error) {
+	link, storageObj, err := s.openShareLink(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &models.SharedFileInfo{
+		Token:       link.Token,
+		FileName:    storageObj.FileName,
+		FileSize:    storageObj.FileSize,
+		ContentType: storageObj.ContentType,
+		ExpiresAt:   link.ExpiresAt,
+	}
+
+	if link.MaxDownloads > 0 {
+		left := link.MaxDownloads - link.DownloadCount
+		info.DownloadsLeft = &left
+	}
+
+	return info, nil
+}
+
+// DownloadSharedFile streams the file behind a link. Every request that gets
+// bytes counts against MaxDownloads, ranged ones included: a client could
+// otherwise fetch the whole file in pieces without ever using up the link.
+// Only 304 answers are free.
+func (s *ShareService) DownloadSharedFile(ctx context.Context, token string, password string, req models.DownloadRequest) (*models.FileDownload, error) {
+	link, storageObj, err := s.openShareLink(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+
+	info := models.FileInfo{
+		ObjectID:     storageObj.ObjectID,
+		FileName:     storageObj.FileName,
+		ContentType:  storageObj.ContentType,
+		Size:         storageObj.FileSize,
+		ETag:         storageObj.ETag,
+		LastModified: storageObj.UpdatedAt,
+	}
+
+	if isNotModified(info, req) {
+		return &models.FileDownload{FileInfo: info, NotModified: true}, nil
+	}
+
+	if _, err := s.shareRepo.ConsumeDownload(ctx, link.Token, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if info.ETag != "" {
+		req.IfNoneMatch = ""
+	}
+
+	download, err := s.storageRepo.OpenFile(ctx, storageObj, req)
+	if errors.Is(err, repositories.ErrNotModified) {
+		return &models.FileDownload{FileInfo: info, NotModified: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return download, nil
+}
+
+// openShareLink checks every rule on a link except the download count, which
+// ConsumeDownload enforces atomically.
+func (s *ShareService) openShareLink(ctx context.Context, token string, password string) (*models.ShareLink, *models.StorageObject, error) {
+	if token == "" {
+		return nil, nil, repositories.ErrShareLinkNotFound
+	}
+
+	link, err := s.shareRepo.GetShareLink(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if link.RevokedAt != nil || (link.ExpiresAt > 0 && time.Now().Unix() >= link.ExpiresAt) {
+		return nil, nil, repositories.ErrShareLinkUnavailable
+	}
+
+	if link.MaxDownloads > 0 && link.DownloadCount >= link.MaxDownloads {
+		return nil, nil, repositories.ErrShareLinkUnavailable
+	}
+
+	if link.PasswordHash != "" {
+		if password == "" {
+			return nil, nil, ErrSharePasswordRequired
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+			return nil, nil, ErrSharePasswordInvalid
+		}
+	}
+
+	storageObj, err := s.storageRepo.GetFile(ctx, link.ObjectID, link.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !storageObj.IsActive() {
+		return nil, nil, repositories.ErrFileNotFound
+	}
+
+	return link, storageObj, nil
+}
+
+func (s *ShareService) getOwnedFile(ctx context.Context, userID string, fileID string) (*models.StorageObject, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+
+	storageObj, err := s.storageRepo.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !storageObj.IsActive() {
+		return nil, repositories.ErrFileNotFound
+	}
+
+	return storageObj, nil
+}
+
+func newShareToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
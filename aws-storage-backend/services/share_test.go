@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+func TestDownloadSharedFileConsumesEveryFetch(t *testing.T) {
+	content := []byte("hello, shared world")
+
+	tests := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{name: "whole file twice", first: "", second: ""},
+		{name: "whole file then suffix", first: "", second: "bytes=1-"},
+		{name: "chunks", first: "bytes=0-4", second: "bytes=5-"},
+		{name: "last bytes", first: "bytes=-5", second: "bytes=-5"},
+		{name: "middle chunks", first: "bytes=3-6", second: "bytes=7-9"},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				stores := driver.OpenDefault(t)
+				shareService := NewShareService(stores.Shares, stores.Storage)
+
+				file, err := stores.Storage.UploadFile(ctx, "user-1", models.RootFolderID, "shared.txt", int64(len(content)), "text/plain", bytes.NewReader(content), nil)
+				if err != nil {
+					t.Fatalf("UploadFile: %v", err)
+				}
+				link, err := shareService.CreateShareLink(ctx, "user-1", file.ObjectID, models.CreateShareLinkRequest{MaxDownloads: 1})
+				if err != nil {
+					t.Fatalf("CreateShareLink: %v", err)
+				}
+
+				download, err := shareService.DownloadSharedFile(ctx, link.Token, "", models.DownloadRequest{Range: tt.first})
+				if err != nil {
+					t.Fatalf("first download: %v", err)
+				}
+				if _, err := io.Copy(io.Discard, download.Body); err != nil {
+					t.Fatalf("read first download: %v", err)
+				}
+				download.Body.Close()
+
+				_, err = shareService.DownloadSharedFile(ctx, link.Token, "", models.DownloadRequest{Range: tt.second})
+				if !errors.Is(err, repositories.ErrShareLinkUnavailable) {
+					t.Fatalf("second download: got %v, want ErrShareLinkUnavailable", err)
+				}
+			})
+		}
+	})
+}
+
+func TestDownloadSharedFileNotModifiedIsFree(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		stores := driver.OpenDefault(t)
+		shareService := NewShareService(stores.Shares, stores.Storage)
+
+		file, err := stores.Storage.UploadFile(ctx, "user-1", models.RootFolderID, "shared.txt", 5, "text/plain", bytes.NewReader([]byte("hello")), nil)
+		if err != nil {
+			t.Fatalf("UploadFile: %v", err)
+		}
+		link, err := shareService.CreateShareLink(ctx, "user-1", file.ObjectID, models.CreateShareLinkRequest{MaxDownloads: 1})
+		if err != nil {
+			t.Fatalf("CreateShareLink: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			download, err := shareService.DownloadSharedFile(ctx, link.Token, "", models.DownloadRequest{IfNoneMatch: file.ETag})
+			if err != nil || !download.NotModified {
+				t.Fatalf("conditional download %d: %+v, %v; want 304", i, download, err)
+			}
+		}
+
+		download, err := shareService.DownloadSharedFile(ctx, link.Token, "", models.DownloadRequest{})
+		if err != nil {
+			t.Fatalf("download after 304s: %v", err)
+		}
+		download.Body.Close()
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"mime/multipart"
+	"strings"
 	"time"
 
 	"github.com/berkkaradalan/AwsGo-Storage/config"
@@ -13,38 +14,47 @@ import (
 	"github.com/berkkaradalan/AwsGo-Storage/repositories"
 )
 
+const defaultListLimit = 50
+
+var ErrInvalidListQuery = errors.New("invalid list query: range lower bound is greater than upper bound")
+
 type StorageService struct {
-	storageRepo *repositories.StorageRepository
-	authconfig *config.AuthConfig
+	storageRepo   repositories.StorageStore
+	folderRepo    repositories.FolderStore
+	userRepo      repositories.UserStore
+	grantRepo     repositories.GrantStore
+	notifyRepo    repositories.NotificationStore
+	requestRepo   repositories.FileRequestStore
+	authconfig    *config.AuthConfig
+	storageConfig *config.StorageConfig
 }
 
-func NewStorageService(storageRepo *repositories.StorageRepository, authConfig *config.AuthConfig) *StorageService{
+func NewStorageService(storageRepo repositories.StorageStore, folderRepo repositories.FolderStore, userRepo repositories.UserStore, grantRepo repositories.GrantStore, notifyRepo repositories.NotificationStore, requestRepo repositories.FileRequestStore, authConfig *config.AuthConfig, storageConfig *config.StorageConfig) *StorageService{
 	return &StorageService{
-		storageRepo: storageRepo,
-		authconfig: authConfig,
+		storageRepo:   storageRepo,
+		folderRepo:    folderRepo,
+		userRepo:      userRepo,
+		grantRepo:     grantRepo,
+		notifyRepo:    notifyRepo,
+		requestRepo:   requestRepo,
+		authconfig:    authConfig,
+		storageConfig: storageConfig,
 	}
 }
 
-func (s *StorageService) UploadFile(ctx context.Context, userID string, file *multipart.FileHeader, description *string) (*models.UploadFileResponse, error) {
+func (s *StorageService) UploadFile(ctx context.Context, userID string, file *multipart.FileHeader, description *string, folderID string) (*models.UploadFileResponse, error) {
 	if file == nil {
 		return nil, fmt.Errorf("file is required.")
 	}
 
-	if file.Size > 50*1024*1024 { // 50 MB limit
-		return nil, fmt.Errorf("file size exceeds 50 MB limit")
-	}
-
 	contentType := file.Header.Get("Content-Type")
-	allowedTypes := map[string]bool{
-		"image/jpeg": true,
-		"image/png":  true,
-		"image/gif":  true,
-		"image/webp": true,
-		"application/pdf": true,
+	if err := s.validateUpload(file.Filename, contentType, file.Size, s.storageConfig.MaxUploadSize); err != nil {
+		return nil, err
 	}
 
-	if !allowedTypes[contentType] {
-		return nil, fmt.Errorf("file type not allowed. Allowed: JPEG, PNG, GIF, WebP, PDF")
+	parentID, err := s.resolveParentID(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
 	}
 
 	src, err := file.Open()
@@ -54,11 +64,22 @@ func (s *StorageService) UploadFile(ctx context.Context, userID string, file *mu
 	}
 	defer src.Close()
 
-	storageObj, err := s.storageRepo.UploadFile(ctx, userID, file.Filename, file.Size, contentType, src, description)
+	if err := s.reserveStorage(ctx, userID, file.Size); err != nil {
+		return nil, err
+	}
+
+	storageObj, err := s.storageRepo.UploadFile(ctx, userID, parentID, file.Filename, file.Size, contentType, src, description)
 	if err != nil {
+		// A pending upload keeps its reservation until the outbox worker
+		// decides its fate; one it rolled back was given back there.
+		if !errors.Is(err, repositories.ErrOutboxPending) && !errors.Is(err, repositories.ErrUploadRolledBack) {
+			s.releaseStorage(ctx, userID, file.Size)
+		}
 		return nil, err
 	}
 
+	s.recordVersion(ctx, storageObj, userID)
+
 	response := &models.UploadFileResponse{
 		ObjectID:    storageObj.ObjectID,
 		FileName:    storageObj.FileName,
@@ -66,24 +87,79 @@ func (s *StorageService) UploadFile(ctx context.Context, userID string, file *mu
 		ContentType: storageObj.ContentType,
 		UploadedAt:  storageObj.UploadedAt,
 		Description: storageObj.Description,
+		ParentID:    storageObj.ParentID,
+		ChecksumSHA256: storageObj.ChecksumSHA256,
+		ChecksumCRC32C: storageObj.ChecksumCRC32C,
 		Message:     "File uploaded successfully",
 	}
 
 	return response, nil
 }
 
-func (s *StorageService) ListFiles(ctx context.Context, userID string) (*models.ListStorageObjectsResponse, error) {
+// validateUpload holds the rules every upload path has to pass, whether the
+// bytes come through the server or straight to S3.
+func (s *StorageService) validateUpload(fileName string, contentType string, fileSize int64, maxSize int64) error {
+	if fileName == "" {
+		return fmt.Errorf("file name is required")
+	}
+
+	if fileSize > maxSize {
+		return fmt.Errorf("file size exceeds %d MB limit", maxSize/(1024*1024))
+	}
+
+	if !s.storageConfig.AllowsContentType(contentType) {
+		return fmt.Errorf("file type not allowed. Allowed: %s", strings.Join(s.storageConfig.AllowedContentTypes, ", "))
+	}
+
+	return nil
+}
+
+func (s *StorageService) ListFiles(ctx context.Context, userID string, query models.ListFilesQuery) (*models.ListStorageObjectsResponse, error) {
 	if userID == "" {
 		return nil, errors.New("file ID cannot be empty")
 	}
 
-	files, err := s.storageRepo.ListFiles(ctx, userID)
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	if query.SortBy == "" {
+		query.SortBy = models.SortByUploadedAt
+	}
+
+	if query.SortOrder == "" {
+		query.SortOrder = models.SortOrderDesc
+	}
+
+	if query.MinSize != nil && query.MaxSize != nil && *query.MinSize > *query.MaxSize {
+		return nil, ErrInvalidListQuery
+	}
+
+	if query.UploadedAfter != nil && query.UploadedBefore != nil && query.UploadedAfter.After(*query.UploadedBefore) {
+		return nil, ErrInvalidListQuery
+	}
+
+	// Files in a folder shared with the user are listed under the owner's ID.
+	ownerID := userID
+	if query.FolderID != "" && query.FolderID != models.RootFolderID {
+		folder, _, err := s.authorizeFolder(ctx, userID, query.FolderID, models.RoleViewer)
+		if err != nil {
+			return nil, err
+		}
+		ownerID = folder.UserID
+	}
+
+	files, err := s.storageRepo.ListFiles(ctx, ownerID, query, models.StatusActive)
 
 	if err != nil {
 		return nil, err
 	}
 
 	for i := range files.Data {
+		if files.Data[i].IsQuarantined() {
+			continue
+		}
+
 		previewURL, err := s.storageRepo.GeneratePresignedURL(
 			ctx, 
 			files.Data[i].S3Key,
@@ -100,52 +176,190 @@ func (s *StorageService) ListFiles(ctx context.Context, userID string) (*models.
 	return files, nil
 }
 
-func (s *StorageService) DownloadFile(ctx context.Context, fileID string, userID string) ([]byte, error) {
-	if fileID == "" {
-		return nil, errors.New("file ID cannot be empty")
+func (s *StorageService) DownloadFile(ctx context.Context, fileID string, userID string, req models.DownloadRequest) (*models.FileDownload, error) {
+	storageObj, err := s.authorizeFile(ctx, userID, fileID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	info := models.FileInfo{
+		ObjectID:     storageObj.ObjectID,
+		FileName:     storageObj.FileName,
+		ContentType:  storageObj.ContentType,
+		Size:         storageObj.FileSize,
+		ETag:         storageObj.ETag,
+		LastModified: storageObj.UpdatedAt,
 	}
 
-	fileData, err := s.storageRepo.DownloadFile(ctx, fileID, userID)
+	// Answer conditional requests from metadata when we can, so a cache hit
+	// never touches S3. Objects uploaded before ETags were recorded fall back
+	// to S3's own If-None-Match handling in OpenFile.
+	if isNotModified(info, req) {
+		return &models.FileDownload{FileInfo: info, NotModified: true}, nil
+	}
+
+	if info.ETag != "" {
+		req.IfNoneMatch = ""
+	}
 
+	download, err := s.storageRepo.OpenFile(ctx, storageObj, req)
+	if errors.Is(err, repositories.ErrNotModified) {
+		return &models.FileDownload{FileInfo: info, NotModified: true}, nil
+	}
 	if err != nil {
-        return nil, err
-    }
+		return nil, err
+	}
+
+	return download, nil
+}
 
-    if fileData == nil {
-        return nil, errors.New("file not found")
-    }
+func (s *StorageService) HeadFile(ctx context.Context, fileID string, userID string, req models.DownloadRequest) (*models.FileInfo, bool, error) {
+	storageObj, err := s.authorizeFile(ctx, userID, fileID, models.RoleViewer)
+	if err != nil {
+		return nil, false, err
+	}
 
-    return fileData, nil
+	info, err := s.storageRepo.HeadFile(ctx, storageObj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return info, isNotModified(*info, req), nil
 }
 
-func (s *StorageService) DeleteFile(ctx context.Context, userID string, fileID string) (*string, error) {
-	if userID == "" {
-		return nil, errors.New("file ID cannot be empty")
+// isNotModified evaluates If-None-Match and If-Modified-Since as described in
+// RFC 9110: when If-None-Match is present, If-Modified-Since is ignored.
+func isNotModified(info models.FileInfo, req models.DownloadRequest) bool {
+	if req.IfNoneMatch != "" {
+		if info.ETag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(req.IfNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == info.ETag {
+				return true
+			}
+		}
+		return false
 	}
 
-	if fileID == "" {
-		return nil, errors.New("file ID cannot be empty")
+	if req.IfModifiedSince != nil {
+		return !info.LastModified.Truncate(time.Second).After(*req.IfModifiedSince)
 	}
 
-	deleteFile, err := s.storageRepo.DeleteFile(ctx, userID, fileID)
+	return false
+}
 
+func (s *StorageService) UpdateFileMetadata(ctx context.Context, userID string, fileID string, req models.UpdateFileMetadataRequest) (*models.StorageObject, error) {
+	storageObj, err := s.getEditableFile(ctx, userID, fileID, req.Version)
 	if err != nil {
 		return nil, err
 	}
 
-	return deleteFile, nil
+	// A quarantined file is fixed by replacing its content, not by renaming
+	// it.
+	if storageObj.IsQuarantined() {
+		return nil, repositories.ErrFileQuarantined
+	}
+
+	if req.FileName == nil && req.Description == nil {
+		return storageObj, nil
+	}
+
+	return s.storageRepo.UpdateFileMetadata(ctx, fileID, storageObj.UserID, req.FileName, req.Description, storageObj.Version)
 }
 
-func (s *StorageService) GetDashboardMetrics(ctx context.Context, userID string) (*models.DashboardResponse, error) {
+func (s *StorageService) ReplaceFileContent(ctx context.Context, userID string, fileID string, file *multipart.FileHeader, expectedVersion *int64) (*models.StorageObject, error) {
+	if file == nil {
+		return nil, fmt.Errorf("file is required.")
+	}
+
+	storageObj, err := s.getEditableFile(ctx, userID, fileID, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := file.Header.Get("Content-Type")
+	if err := s.validateUpload(storageObj.FileName, contentType, file.Size, s.storageConfig.MaxUploadSize); err != nil {
+		return nil, err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		log.Printf("Failed to open file: %v", err)
+		return nil, fmt.Errorf("failed to open file")
+	}
+	defer src.Close()
+
+	// The blob may be shared with other files, so this file gets its own copy
+	// to replace, kept as its first version.
+	if storageObj.SharesBlob() {
+		storageObj, err = s.storageRepo.DetachBlob(ctx, storageObj)
+		if err != nil {
+			return nil, err
+		}
+		s.recordVersion(ctx, storageObj, storageObj.UserID)
+	}
+
+	settle, err := s.resizeStorage(ctx, storageObj.UserID, storageObj.FileSize, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.storageRepo.ReplaceFileContent(ctx, storageObj, contentType, file.Size, src)
+	settle(err)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordVersion(ctx, updated, userID)
+
+	return updated, nil
+}
+
+// getEditableFile loads an active file the user may edit and, when the client sent the version
+// it last saw, rejects the edit early if the file has moved on. A file whose
+// content is being replaced has moved on already.
+func (s *StorageService) getEditableFile(ctx context.Context, userID string, fileID string, expectedVersion *int64) (*models.StorageObject, error) {
+	storageObj, err := s.authorizeFile(ctx, userID, fileID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	if storageObj.IsReplacing() || (expectedVersion != nil && *expectedVersion != storageObj.Version) {
+		return nil, repositories.ErrVersionConflict
+	}
+
+	return storageObj, nil
+}
+
+func (s *StorageService) DeleteFile(ctx context.Context, userID string, fileID string) (*string, error) {
 	if userID == "" {
 		return nil, errors.New("file ID cannot be empty")
 	}
 
-	dashboardMetrics, err := s.storageRepo.GetDashboardMetrics(ctx, userID)
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
 
+	storageObj, err := s.storageRepo.GetFile(ctx, fileID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return dashboardMetrics, nil
-}
\ No newline at end of file
+	// Files already in the trash, or not uploaded yet, are not there to
+	// delete. Trashing again would also push back the purge deadline.
+	if !storageObj.IsActive() {
+		return nil, repositories.ErrFileNotFound
+	}
+
+	_, err = s.storageRepo.TrashFile(ctx, storageObj, time.Now().Add(s.storageConfig.TrashRetention))
+
+	if err != nil {
+		return nil, err
+	}
+
+	deletionMessage := fmt.Sprintf("Object with id :%v moved to trash.", fileID)
+
+	return &deletionMessage, nil
+}
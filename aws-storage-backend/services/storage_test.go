@@ -0,0 +1,276 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/config"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// testService is a StorageService on fresh stores, with the blob store it
+// writes to and users user-1 and user-2 already registered.
+type testService struct {
+	*StorageService
+	stores    *repositories.Stores
+	blobStore blobstore.BlobStore
+}
+
+func newTestService(t *testing.T, driver teststore.Driver) *testService {
+	t.Helper()
+	return newTestServiceOn(t, driver, blobstore.NewMemoryStore(nil))
+}
+
+// newTestServiceOn is newTestService writing to blobStore.
+func newTestServiceOn(t *testing.T, driver teststore.Driver, blobStore blobstore.BlobStore) *testService {
+	t.Helper()
+
+	cfg := config.Defaults()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Storage.AllowedContentTypes = append(cfg.Storage.AllowedContentTypes, "text/plain")
+
+	stores := driver.Open(t, blobStore, cfg)
+	service := NewStorageService(stores.Storage, stores.Folders, stores.Users, stores.Grants, stores.Notifications, stores.FileRequests, config.NewAuthConfig(cfg), config.NewStorageConfig(cfg))
+
+	for _, userID := range []string{"user-1", "user-2"} {
+		user := &models.User{UserID: userID, UserName: userID, UserEmail: userID + "@example.com"}
+		if _, err := stores.Users.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+
+	return &testService{StorageService: service, stores: stores, blobStore: blobStore}
+}
+
+// fileHeader is content as it arrives in a multipart form.
+func fileHeader(t *testing.T, name string, content string) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreatePart(map[string][]string{
+		"Content-Disposition": {`form-data; name="file"; filename="` + name + `"`},
+		"Content-Type":        {"text/plain"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePart: %v", err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatalf("ParseMultipartForm: %v", err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+// upload stores content as a new file of userID through the service.
+func (s *testService) upload(t *testing.T, userID string, folderID string, name string, content string) *models.StorageObject {
+	t.Helper()
+
+	response, err := s.UploadFile(context.Background(), userID, fileHeader(t, name, content), nil, folderID)
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	return s.file(t, response.ObjectID)
+}
+
+func (s *testService) file(t *testing.T, fileID string) *models.StorageObject {
+	t.Helper()
+
+	storageObj, err := s.stores.Storage.GetFileByID(context.Background(), fileID)
+	if err != nil {
+		t.Fatalf("GetFileByID: %v", err)
+	}
+	return storageObj
+}
+
+func (s *testService) usedBytes(t *testing.T, userID string) int64 {
+	t.Helper()
+
+	user, err := s.stores.Users.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	return user.UsedBytes
+}
+
+func TestDeleteFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, s *testService) string
+		userID  string
+		wantErr error
+	}{
+		{name: "active file", userID: "user-1", setup: func(t *testing.T, s *testService) string {
+			return s.upload(t, "user-1", "", "a.txt", "hello").ObjectID
+		}},
+		{name: "already in the trash", userID: "user-1", wantErr: repositories.ErrFileNotFound, setup: func(t *testing.T, s *testService) string {
+			file := s.upload(t, "user-1", "", "a.txt", "hello")
+			if _, err := s.DeleteFile(context.Background(), "user-1", file.ObjectID); err != nil {
+				t.Fatalf("DeleteFile: %v", err)
+			}
+			return file.ObjectID
+		}},
+		{name: "pending upload", userID: "user-1", wantErr: repositories.ErrFileNotFound, setup: func(t *testing.T, s *testService) string {
+			pending, err := s.stores.Storage.CreatePendingFile(context.Background(), "user-1", models.RootFolderID, "a.txt", 5, "text/plain", nil, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatalf("CreatePendingFile: %v", err)
+			}
+			return pending.ObjectID
+		}},
+		{name: "someone else's file", userID: "user-2", wantErr: repositories.ErrFileAccessDenied, setup: func(t *testing.T, s *testService) string {
+			return s.upload(t, "user-1", "", "a.txt", "hello").ObjectID
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s := newTestService(t, driver)
+				fileID := tt.setup(t, s)
+				before := s.file(t, fileID)
+
+				_, err := s.DeleteFile(context.Background(), tt.userID, fileID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DeleteFile: %v, want %v", err, tt.wantErr)
+				}
+
+				after := s.file(t, fileID)
+				if tt.wantErr != nil {
+					if after.Status != before.Status || after.ExpiresAt != before.ExpiresAt {
+						t.Errorf("refused delete changed the file from %s (expires %d) to %s (expires %d)", before.Status, before.ExpiresAt, after.Status, after.ExpiresAt)
+					}
+					return
+				}
+
+				purgeAt := time.Now().Add(s.storageConfig.TrashRetention).Unix()
+				if after.Status != models.StatusTrashed || after.ExpiresAt < purgeAt-60 || after.ExpiresAt > purgeAt {
+					t.Errorf("deleted file is %s and expires at %d, want trashed until about %d", after.Status, after.ExpiresAt, purgeAt)
+				}
+			})
+		}
+	})
+}
+
+func TestIsNotModified(t *testing.T) {
+	modified := time.Date(2026, 1, 15, 12, 0, 0, 500, time.UTC)
+	before := modified.Add(-time.Hour)
+	at := modified.Truncate(time.Second)
+
+	tests := []struct {
+		name string
+		etag string
+		req  models.DownloadRequest
+		want bool
+	}{
+		{name: "unconditional", etag: `"abc"`},
+		{name: "matching ETag", etag: `"abc"`, req: models.DownloadRequest{IfNoneMatch: `"abc"`}, want: true},
+		{name: "weak ETag in a list", etag: `"abc"`, req: models.DownloadRequest{IfNoneMatch: `"xyz", W/"abc"`}, want: true},
+		{name: "any ETag", etag: `"abc"`, req: models.DownloadRequest{IfNoneMatch: "*"}, want: true},
+		{name: "other ETag", etag: `"abc"`, req: models.DownloadRequest{IfNoneMatch: `"xyz"`}},
+		{name: "no ETag on record", req: models.DownloadRequest{IfNoneMatch: "*"}},
+		{name: "unchanged since", etag: `"abc"`, req: models.DownloadRequest{IfModifiedSince: &at}, want: true},
+		{name: "changed since", etag: `"abc"`, req: models.DownloadRequest{IfModifiedSince: &before}},
+		{name: "If-None-Match wins over If-Modified-Since", etag: `"abc"`, req: models.DownloadRequest{IfNoneMatch: `"xyz"`, IfModifiedSince: &at}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := models.FileInfo{ETag: tt.etag, LastModified: modified}
+			if got := isNotModified(info, tt.req); got != tt.want {
+				t.Errorf("isNotModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	tests := []struct {
+		name            string
+		req             func(file *models.StorageObject) models.DownloadRequest
+		wantErr         error
+		wantNotModified bool
+		wantContent     string
+		wantRange       string
+	}{
+		{name: "whole file", wantContent: "hello, world", req: func(file *models.StorageObject) models.DownloadRequest {
+			return models.DownloadRequest{}
+		}},
+		{name: "range", wantContent: "hello", wantRange: "bytes 0-4/12", req: func(file *models.StorageObject) models.DownloadRequest {
+			return models.DownloadRequest{Range: "bytes=0-4"}
+		}},
+		{name: "suffix range", wantContent: "world", wantRange: "bytes 7-11/12", req: func(file *models.StorageObject) models.DownloadRequest {
+			return models.DownloadRequest{Range: "bytes=-5"}
+		}},
+		{name: "range past the end", wantErr: repositories.ErrInvalidRange, req: func(file *models.StorageObject) models.DownloadRequest {
+			return models.DownloadRequest{Range: "bytes=12-"}
+		}},
+		{name: "cached copy is current", wantNotModified: true, req: func(file *models.StorageObject) models.DownloadRequest {
+			return models.DownloadRequest{IfNoneMatch: file.ETag, Range: "bytes=0-4"}
+		}},
+		{name: "cached copy is stale", wantContent: "hello, world", req: func(file *models.StorageObject) models.DownloadRequest {
+			return models.DownloadRequest{IfNoneMatch: `"stale"`}
+		}},
+		{name: "not modified since", wantNotModified: true, req: func(file *models.StorageObject) models.DownloadRequest {
+			since := file.UpdatedAt.Add(time.Second)
+			return models.DownloadRequest{IfModifiedSince: &since}
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				file := s.upload(t, "user-1", "", "a.txt", "hello, world")
+				req := tt.req(file)
+
+				info, notModified, err := s.HeadFile(ctx, file.ObjectID, "user-1", req)
+				if err != nil {
+					t.Fatalf("HeadFile: %v", err)
+				}
+				if notModified != tt.wantNotModified || info.ETag != file.ETag || info.Size != file.FileSize {
+					t.Errorf("HeadFile = %+v, not modified %v; want ETag %s, %d bytes, not modified %v", info, notModified, file.ETag, file.FileSize, tt.wantNotModified)
+				}
+
+				download, err := s.DownloadFile(ctx, file.ObjectID, "user-1", req)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DownloadFile: %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if download.NotModified != tt.wantNotModified {
+					t.Fatalf("NotModified = %v, want %v", download.NotModified, tt.wantNotModified)
+				}
+				if download.NotModified {
+					if download.Body != nil {
+						t.Errorf("not modified response has a body")
+					}
+					return
+				}
+				defer download.Body.Close()
+
+				content, err := io.ReadAll(download.Body)
+				if err != nil {
+					t.Fatalf("ReadAll: %v", err)
+				}
+				if string(content) != tt.wantContent || download.ContentLength != int64(len(tt.wantContent)) || download.ContentRange != tt.wantRange {
+					t.Errorf("got %q (%d bytes, range %q), want %q (range %q)", content, download.ContentLength, download.ContentRange, tt.wantContent, tt.wantRange)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+var ErrFileNotInTrash = errors.New("file is not in the trash")
+
+func (s *StorageService) ListTrash(ctx context.Context, userID string, query models.ListFilesQuery) (*models.ListStorageObjectsResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	if query.Limit == 0 {
+		query.Limit = defaultListLimit
+	}
+
+	if query.SortBy == "" {
+		query.SortBy = models.SortByUploadedAt
+	}
+
+	if query.SortOrder == "" {
+		query.SortOrder = models.SortOrderDesc
+	}
+
+	return s.storageRepo.ListFiles(ctx, userID, query, models.StatusTrashed)
+}
+
+// RestoreFile takes a file out of the trash. If its folder was deleted in the
+// meantime the file goes back to the root folder.
+func (s *StorageService) RestoreFile(ctx context.Context, userID string, fileID string) (*models.StorageObject, error) {
+	storageObj, err := s.getTrashedFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	parentID := models.RootFolderID
+	if storageObj.ParentID != "" && storageObj.ParentID != models.RootFolderID {
+		_, err := s.folderRepo.GetFolder(ctx, storageObj.ParentID, userID)
+		switch {
+		case err == nil:
+			parentID = storageObj.ParentID
+		case !errors.Is(err, repositories.ErrFolderNotFound):
+			return nil, err
+		}
+	}
+
+	return s.storageRepo.RestoreFile(ctx, storageObj, parentID)
+}
+
+// DeleteTrashedFile permanently deletes one file from the trash.
+func (s *StorageService) DeleteTrashedFile(ctx context.Context, userID string, fileID string) (*string, error) {
+	storageObj, err := s.getTrashedFile(ctx, userID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.purgeFile(ctx, storageObj); err != nil {
+		return nil, err
+	}
+
+	deletionMessage := fmt.Sprintf("Object with id :%v deleted.", fileID)
+	return &deletionMessage, nil
+}
+
+func (s *StorageService) EmptyTrash(ctx context.Context, userID string) (*string, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	trashed, err := s.storageRepo.ListFilesByStatus(ctx, userID, models.StatusTrashed)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := 0
+	for i := range trashed {
+		err := s.purgeFile(ctx, &trashed[i])
+		if errors.Is(err, repositories.ErrFileNotFound) {
+			continue
+		}
+		if err != nil && !errors.Is(err, repositories.ErrOutboxPending) {
+			return nil, err
+		}
+		purged++
+	}
+
+	message := fmt.Sprintf("%d objects deleted from trash.", purged)
+	return &message, nil
+}
+
+// PurgeExpiredTrash permanently deletes trashed files whose retention period
+// has run out.
+func (s *StorageService) PurgeExpiredTrash(ctx context.Context) (int, error) {
+	expired, err := s.storageRepo.ListExpiredFiles(ctx, models.StatusTrashed, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range expired {
+		err := s.purgeFile(ctx, &expired[i])
+		if errors.Is(err, repositories.ErrFileNotFound) {
+			continue
+		}
+		if err != nil && !errors.Is(err, repositories.ErrOutboxPending) {
+			log.Printf("Failed to purge trashed file %s: %v", expired[i].ObjectID, err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StartTrashPurger runs PurgeExpiredTrash every interval until ctx is done.
+func (s *StorageService) StartTrashPurger(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "Trash purger", interval, s.PurgeExpiredTrash)
+}
+
+func (s *StorageService) getTrashedFile(ctx context.Context, userID string, fileID string) (*models.StorageObject, error) {
+	if fileID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+
+	storageObj, err := s.storageRepo.GetFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if storageObj.Status != models.StatusTrashed {
+		return nil, ErrFileNotInTrash
+	}
+
+	return storageObj, nil
+}
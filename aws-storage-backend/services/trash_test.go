@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+func TestRestoreFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		deleteFolder bool
+		wantInFolder bool
+	}{
+		{name: "folder still there", wantInFolder: true},
+		{name: "folder deleted since", deleteFolder: true},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+
+				folder, err := s.CreateFolder(ctx, "user-1", models.CreateFolderRequest{Name: "docs"})
+				if err != nil {
+					t.Fatalf("CreateFolder: %v", err)
+				}
+				file := s.upload(t, "user-1", folder.FolderID, "a.txt", "hello")
+				if _, err := s.DeleteFile(ctx, "user-1", file.ObjectID); err != nil {
+					t.Fatalf("DeleteFile: %v", err)
+				}
+				if tt.deleteFolder {
+					if _, err := s.DeleteFolder(ctx, "user-1", folder.FolderID); err != nil {
+						t.Fatalf("DeleteFolder: %v", err)
+					}
+				}
+
+				restored, err := s.RestoreFile(ctx, "user-1", file.ObjectID)
+				if err != nil {
+					t.Fatalf("RestoreFile: %v", err)
+				}
+
+				wantParent := models.RootFolderID
+				if tt.wantInFolder {
+					wantParent = folder.FolderID
+				}
+				stored := s.file(t, file.ObjectID)
+				if !stored.IsActive() || stored.ParentID != wantParent || stored.DeletedAt != nil || stored.ExpiresAt != 0 {
+					t.Errorf("restored file is %s in %s (deleted %v, expires %d), want active in %s", stored.Status, stored.ParentID, stored.DeletedAt, stored.ExpiresAt, wantParent)
+				}
+				if restored.ParentID != wantParent {
+					t.Errorf("RestoreFile returned parent %s, want %s", restored.ParentID, wantParent)
+				}
+				if got := s.usedBytes(t, "user-1"); got != 5 {
+					t.Errorf("UsedBytes = %d after restoring, want 5", got)
+				}
+
+				if _, err := s.RestoreFile(ctx, "user-1", file.ObjectID); !errors.Is(err, ErrFileNotInTrash) {
+					t.Errorf("restoring again: %v, want ErrFileNotInTrash", err)
+				}
+			})
+		}
+	})
+}
+
+func TestPurgeTrashReleasesQuota(t *testing.T) {
+	tests := []struct {
+		name string
+		// retention is how long deleted files stay in the trash.
+		retention  time.Duration
+		purge      func(s *testService, fileID string) error
+		wantPurged bool
+	}{
+		{name: "delete from trash", retention: time.Hour, wantPurged: true, purge: func(s *testService, fileID string) error {
+			_, err := s.DeleteTrashedFile(context.Background(), "user-1", fileID)
+			return err
+		}},
+		{name: "empty trash", retention: time.Hour, wantPurged: true, purge: func(s *testService, fileID string) error {
+			_, err := s.EmptyTrash(context.Background(), "user-1")
+			return err
+		}},
+		{name: "retention ran out", retention: -time.Minute, wantPurged: true, purge: func(s *testService, fileID string) error {
+			_, err := s.PurgeExpiredTrash(context.Background())
+			return err
+		}},
+		{name: "retention still running", retention: time.Hour, purge: func(s *testService, fileID string) error {
+			_, err := s.PurgeExpiredTrash(context.Background())
+			return err
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				s.storageConfig.TrashRetention = tt.retention
+
+				kept := s.upload(t, "user-1", "", "kept.txt", "kept")
+				file := s.upload(t, "user-1", "", "a.txt", "hello")
+				if _, err := s.DeleteFile(ctx, "user-1", file.ObjectID); err != nil {
+					t.Fatalf("DeleteFile: %v", err)
+				}
+
+				// Trashed files still count against the quota.
+				if got := s.usedBytes(t, "user-1"); got != 9 {
+					t.Fatalf("UsedBytes = %d with the file in the trash, want 9", got)
+				}
+
+				if err := tt.purge(s, file.ObjectID); err != nil {
+					t.Fatalf("purge: %v", err)
+				}
+
+				wantUsed := int64(9)
+				if tt.wantPurged {
+					wantUsed = 4
+				}
+				if got := s.usedBytes(t, "user-1"); got != wantUsed {
+					t.Errorf("UsedBytes = %d after purging, want %d", got, wantUsed)
+				}
+
+				_, err := s.stores.Storage.GetFileByID(ctx, file.ObjectID)
+				if purged := err != nil; purged != tt.wantPurged {
+					t.Errorf("file purged: %t (%v), want %t", purged, err, tt.wantPurged)
+				}
+				if objects, err := s.blobStore.List(ctx, file.S3Key); err != nil || (len(objects) == 0) != tt.wantPurged {
+					t.Errorf("blob store holds %v (%v) under the purged file's key", objects, err)
+				}
+				if stored := s.file(t, kept.ObjectID); !stored.IsActive() {
+					t.Errorf("file outside the trash is %s after purging", stored.Status)
+				}
+			})
+		}
+	})
+}
+
+func TestDeleteTrashedFileNeedsTrashedFile(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		s := newTestService(t, driver)
+		file := s.upload(t, "user-1", "", "a.txt", "hello")
+
+		if _, err := s.DeleteTrashedFile(context.Background(), "user-1", file.ObjectID); !errors.Is(err, ErrFileNotInTrash) {
+			t.Fatalf("DeleteTrashedFile of an active file: %v, want ErrFileNotInTrash", err)
+		}
+		if got := s.usedBytes(t, "user-1"); got != 5 {
+			t.Errorf("UsedBytes = %d, want 5", got)
+		}
+	})
+}
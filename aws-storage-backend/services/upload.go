@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+const (
+	uploadURLExpiry     = 15 * time.Minute
+	uploadSessionExpiry = time.Hour
+)
+
+var ErrUploadSessionExpired = errors.New("upload session has expired")
+
+func (s *StorageService) CreateUploadSession(ctx context.Context, userID string, req models.CreateUploadSessionRequest) (*models.UploadSessionResponse, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	if err := s.validateUpload(req.FileName, req.ContentType, req.FileSize, s.storageConfig.MaxUploadSize); err != nil {
+		return nil, err
+	}
+
+	method := req.Method
+	if method == "" {
+		method = "put"
+	}
+
+	parentID, err := s.resolveParentID(ctx, userID, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The declared size is reserved now. If the session is never completed,
+	// the sweeper gives it back when it removes the pending file.
+	if err := s.reserveStorage(ctx, userID, req.FileSize); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(uploadSessionExpiry)
+	storageObj, err := s.storageRepo.CreatePendingFile(ctx, userID, parentID, req.FileName, req.FileSize, req.ContentType, req.Description, expiresAt)
+	if err != nil {
+		s.releaseStorage(ctx, userID, req.FileSize)
+		return nil, err
+	}
+
+	return s.storageRepo.PresignUpload(ctx, storageObj, method, uploadURLExpiry)
+}
+
+func (s *StorageService) CompleteUploadSession(ctx context.Context, userID string, objectID string) (*models.UploadFileResponse, error) {
+	if objectID == "" {
+		return nil, errors.New("file ID cannot be empty")
+	}
+
+	storageObj, err := s.storageRepo.GetFile(ctx, objectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if storageObj.Status != models.StatusPending {
+		return nil, repositories.ErrUploadSessionNotPending
+	}
+
+	if time.Now().Unix() > storageObj.ExpiresAt {
+		return nil, ErrUploadSessionExpired
+	}
+
+	// A failed activation leaves the session pending, so its reservation
+	// stays until the client retries or the sweeper removes it.
+	activated, err := s.storageRepo.ActivateDirectUpload(ctx, storageObj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordVersion(ctx, activated, userID)
+
+	return &models.UploadFileResponse{
+		ObjectID:       activated.ObjectID,
+		FileName:       activated.FileName,
+		FileSize:       activated.FileSize,
+		ContentType:    activated.ContentType,
+		UploadedAt:     activated.UploadedAt,
+		Description:    activated.Description,
+		ParentID:       activated.ParentID,
+		ChecksumSHA256: activated.ChecksumSHA256,
+		ChecksumCRC32C: activated.ChecksumCRC32C,
+		Message:        "File uploaded successfully",
+	}, nil
+}
+
+// SweepExpiredUploads removes pending upload sessions whose deadline passed
+// without a complete call, and aborts multipart uploads nobody tracks.
+func (s *StorageService) SweepExpiredUploads(ctx context.Context) (int, error) {
+	expired, err := s.storageRepo.ListExpiredFiles(ctx, models.StatusPending, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := range expired {
+		upload, err := s.storageRepo.GetMultipartUpload(ctx, expired[i].ObjectID)
+		if err == nil {
+			err = s.storageRepo.AbortMultipartUpload(ctx, &expired[i], upload)
+		}
+		if err != nil && !errors.Is(err, repositories.ErrMultipartUploadNotFound) {
+			log.Printf("Failed to abort multipart upload for %s: %v", expired[i].ObjectID, err)
+			continue
+		}
+
+		err = s.deletePendingFile(ctx, &expired[i])
+		if errors.Is(err, repositories.ErrUploadSessionNotPending) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to remove expired upload %s: %v", expired[i].ObjectID, err)
+			continue
+		}
+		removed++
+	}
+
+	aborted, err := s.sweepStaleMultipartUploads(ctx)
+	if err != nil {
+		return removed, err
+	}
+
+	return removed + aborted, nil
+}
+
+// StartUploadSweeper runs SweepExpiredUploads every interval until ctx is done.
+func (s *StorageService) StartUploadSweeper(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "Upload sweeper", interval, s.SweepExpiredUploads)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+// uploadSession opens an upload session for a size-byte file of user-1 that
+// expires at expiresAt, reserving its size the way CreateUploadSession does.
+func (s *testService) uploadSession(t *testing.T, size int64, expiresAt time.Time) *models.StorageObject {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := s.reserveStorage(ctx, "user-1", size); err != nil {
+		t.Fatalf("reserveStorage: %v", err)
+	}
+	pending, err := s.stores.Storage.CreatePendingFile(ctx, "user-1", models.RootFolderID, "a.txt", size, "text/plain", nil, expiresAt)
+	if err != nil {
+		t.Fatalf("CreatePendingFile: %v", err)
+	}
+	return pending
+}
+
+func TestCompleteUploadSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiresIn  time.Duration
+		sent       string
+		completed  bool
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "uploaded in time", expiresIn: time.Hour, sent: "hello", wantStatus: models.StatusActive},
+		{name: "expired", expiresIn: -time.Minute, sent: "hello", wantErr: ErrUploadSessionExpired, wantStatus: models.StatusPending},
+		{name: "nothing uploaded", expiresIn: time.Hour, wantErr: repositories.ErrUploadMismatch, wantStatus: models.StatusPending},
+		{name: "other size uploaded", expiresIn: time.Hour, sent: "hello, world", wantErr: repositories.ErrUploadMismatch, wantStatus: models.StatusPending},
+		{name: "already completed", expiresIn: time.Hour, sent: "hello", completed: true, wantErr: repositories.ErrUploadSessionNotPending, wantStatus: models.StatusActive},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				pending := s.uploadSession(t, 5, time.Now().Add(tt.expiresIn))
+				if tt.sent != "" {
+					s.put(t, pending.S3Key, tt.sent)
+				}
+				if tt.completed {
+					if _, err := s.CompleteUploadSession(ctx, "user-1", pending.ObjectID); err != nil {
+						t.Fatalf("CompleteUploadSession: %v", err)
+					}
+				}
+
+				_, err := s.CompleteUploadSession(ctx, "user-1", pending.ObjectID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CompleteUploadSession: %v, want %v", err, tt.wantErr)
+				}
+
+				if file := s.file(t, pending.ObjectID); file.Status != tt.wantStatus {
+					t.Errorf("file is %s, want %s", file.Status, tt.wantStatus)
+				}
+				// Whether it is completed or waits for the sweeper, the
+				// session keeps its reservation.
+				if got := s.usedBytes(t, "user-1"); got != 5 {
+					t.Errorf("UsedBytes = %d, want 5", got)
+				}
+			})
+		}
+	})
+}
+
+func TestSweepExpiredUploads(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, s *testService) *models.StorageObject
+		wantRemoved bool
+	}{
+		{name: "expired session", wantRemoved: true, setup: func(t *testing.T, s *testService) *models.StorageObject {
+			pending := s.uploadSession(t, 5, time.Now().Add(-time.Minute))
+			s.put(t, pending.S3Key, "hello")
+			return pending
+		}},
+		{name: "expired multipart session", wantRemoved: true, setup: func(t *testing.T, s *testService) *models.StorageObject {
+			ctx := context.Background()
+			pending := s.uploadSession(t, 5, time.Now().Add(-time.Minute))
+			upload, err := s.stores.Storage.CreateMultipartUpload(ctx, pending, 5, 1)
+			if err != nil {
+				t.Fatalf("CreateMultipartUpload: %v", err)
+			}
+			if _, err := s.blobStore.UploadPart(ctx, pending.S3Key, upload.UploadID, 1, 5, strings.NewReader("hello")); err != nil {
+				t.Fatalf("UploadPart: %v", err)
+			}
+			return pending
+		}},
+		{name: "session still open", setup: func(t *testing.T, s *testService) *models.StorageObject {
+			return s.uploadSession(t, 5, time.Now().Add(time.Hour))
+		}},
+	}
+
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestService(t, driver)
+				pending := tt.setup(t, s)
+
+				removed, err := s.SweepExpiredUploads(ctx)
+				if err != nil {
+					t.Fatalf("SweepExpiredUploads: %v", err)
+				}
+				if (removed == 1) != tt.wantRemoved || removed > 1 {
+					t.Fatalf("sweep removed %d sessions, want the session removed: %v", removed, tt.wantRemoved)
+				}
+
+				_, err = s.stores.Storage.GetFileByID(ctx, pending.ObjectID)
+				if !tt.wantRemoved {
+					if err != nil || s.usedBytes(t, "user-1") != 5 {
+						t.Errorf("open session: %v, UsedBytes %d; want it kept with its reservation", err, s.usedBytes(t, "user-1"))
+					}
+					return
+				}
+
+				if !errors.Is(err, repositories.ErrFileNotFound) {
+					t.Errorf("GetFileByID after the sweep: %v, want ErrFileNotFound", err)
+				}
+				if got := s.usedBytes(t, "user-1"); got != 0 {
+					t.Errorf("UsedBytes = %d after the sweep, want the reservation released", got)
+				}
+				if objects, err := s.blobStore.List(ctx, ""); err != nil || len(objects) != 0 {
+					t.Errorf("blob store still holds %v (%v)", objects, err)
+				}
+				if uploads, err := s.blobStore.ListMultipartUploads(ctx, ""); err != nil || len(uploads) != 0 {
+					t.Errorf("blob store still holds multipart uploads %+v (%v)", uploads, err)
+				}
+			})
+		}
+	})
+}
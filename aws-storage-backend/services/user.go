@@ -12,11 +12,11 @@ import (
 )
 
 type UserService struct {
-	userRepo *repositories.UserRepository
+	userRepo repositories.UserStore
 	authconfig *config.AuthConfig
 }
 
-func NewUserService(userRepo *repositories.UserRepository, authConfig *config.AuthConfig) *UserService {
+func NewUserService(userRepo repositories.UserStore, authConfig *config.AuthConfig) *UserService {
 	return &UserService{
 		userRepo: userRepo,
 		authconfig: authConfig,
@@ -39,18 +39,9 @@ func (s *UserService) GetUserByID(ctx context.Context, userID string) (*models.U
 	return user, nil
 }
 
+// CreateUser registers a user. A taken email or username is reported as
+// repositories.ErrEmailInUse or repositories.ErrUserNameInUse.
 func (s *UserService) CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
-	existingUser, _ := s.userRepo.GetUserByEmail(ctx, req.UserEmail)
-	if existingUser != nil {
-		return nil, errors.New("email is already in use")
-	}
-
-	existingUser, _ = s.userRepo.GetUserByUserName(ctx, req.UserName)
-
-	if existingUser != nil {
-		return nil, errors.New("username is already in use")
-	}
-
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.UserPassword), 10)
 
 	if err != nil {
@@ -60,7 +51,7 @@ func (s *UserService) CreateUser(ctx context.Context, req models.CreateUserReque
 	user := &models.User{
 		UserID: uuid.New().String(),
 		UserName: req.UserName,
-		UserEmail: req.UserEmail,
+		UserEmail: models.NormalizeEmail(req.UserEmail),
 		UserPassword: string(hashedPassword),
 	}
 
@@ -97,4 +88,38 @@ func (s *UserService) Login(ctx context.Context, email, password string) (string
 	}
 
 	return token, user, nil
-}
\ No newline at end of file
+}
+// SetVersionPolicy sets how many versions of each file the user keeps. Older
+// versions are pruned the next time a file gets a new version.
+func (s *UserService) SetVersionPolicy(ctx context.Context, userID string, maxFileVersions int) (*models.User, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	user, err := s.userRepo.UpdateVersionPolicy(ctx, userID, maxFileVersions)
+	if err != nil {
+		return nil, err
+	}
+
+	user.UserPassword = ""
+
+	return user, nil
+}
+
+// SetStorageQuota gives a user a quota of their own, or puts them back on the
+// default when quota is nil. Lowering a quota below current usage blocks new
+// uploads but deletes nothing.
+func (s *UserService) SetStorageQuota(ctx context.Context, userID string, quota *int64) (*models.User, error) {
+	if userID == "" {
+		return nil, errors.New("user ID cannot be empty")
+	}
+
+	user, err := s.userRepo.UpdateStorageQuota(ctx, userID, quota)
+	if err != nil {
+		return nil, err
+	}
+
+	user.UserPassword = ""
+
+	return user, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+	"github.com/berkkaradalan/AwsGo-Storage/repositories"
+)
+
+var ErrCurrentVersion = errors.New("the current version cannot be deleted, restore another version first")
+
+func (s *StorageService) ListFileVersions(ctx context.Context, userID string, fileID string) (*models.FileVersionsResponse, error) {
+	storageObj, err := s.authorizeFile(ctx, userID, fileID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.storageRepo.ListVersions(ctx, storageObj.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range versions {
+		versions[i].IsCurrent = versions[i].VersionID == storageObj.VersionID
+	}
+
+	return &models.FileVersionsResponse{
+		Success:  true,
+		Message:  "Versions fetched successfully",
+		ObjectID: storageObj.ObjectID,
+		Versions: versions,
+	}, nil
+}
+
+func (s *StorageService) DownloadFileVersion(ctx context.Context, userID string, fileID string, versionID string, req models.DownloadRequest) (*models.FileDownload, error) {
+	storageObj, version, err := s.getFileVersion(ctx, userID, fileID, versionID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	versionObj := *storageObj
+	versionObj.FileSize = version.FileSize
+	versionObj.ContentType = version.ContentType
+	versionObj.UpdatedAt = version.CreatedAt
+
+	req.VersionID = version.VersionID
+	download, err := s.storageRepo.OpenFile(ctx, &versionObj, req)
+	if errors.Is(err, repositories.ErrNotModified) {
+		return &models.FileDownload{
+			FileInfo: models.FileInfo{
+				ObjectID:     versionObj.ObjectID,
+				FileName:     versionObj.FileName,
+				ContentType:  versionObj.ContentType,
+				Size:         versionObj.FileSize,
+				ETag:         version.ETag,
+				LastModified: versionObj.UpdatedAt,
+			},
+			NotModified: true,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return download, nil
+}
+
+// RestoreFileVersion makes an older version current again. The restore is a
+// new version itself, so the version being restored stays in the history.
+func (s *StorageService) RestoreFileVersion(ctx context.Context, userID string, fileID string, versionID string) (*models.StorageObject, error) {
+	storageObj, version, err := s.getFileVersion(ctx, userID, fileID, versionID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	if version.VersionID == storageObj.VersionID {
+		return storageObj, nil
+	}
+
+	settle, err := s.resizeStorage(ctx, storageObj.UserID, storageObj.FileSize, version.FileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := s.storageRepo.RestoreVersion(ctx, storageObj, version)
+	settle(err)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordVersion(ctx, restored, userID)
+
+	return restored, nil
+}
+
+func (s *StorageService) DeleteFileVersion(ctx context.Context, userID string, fileID string, versionID string) (*string, error) {
+	storageObj, version, err := s.getFileVersion(ctx, userID, fileID, versionID, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if version.VersionID == storageObj.VersionID {
+		return nil, ErrCurrentVersion
+	}
+
+	if err := s.storageRepo.DeleteVersion(ctx, storageObj, version); err != nil {
+		return nil, err
+	}
+
+	deletionMessage := fmt.Sprintf("Version %v of object with id :%v deleted.", versionID, fileID)
+	return &deletionMessage, nil
+}
+
+// recordVersion records the file's current S3 version and prunes versions
+// beyond the owner's limit. The content is already stored at this point, so
+// failures are logged rather than failing the request.
+func (s *StorageService) recordVersion(ctx context.Context, storageObj *models.StorageObject, uploadedBy string) {
+	if _, err := s.storageRepo.RecordVersion(ctx, storageObj, uploadedBy); err != nil {
+		log.Printf("Failed to record version of file %s: %v", storageObj.ObjectID, err)
+		return
+	}
+
+	if err := s.pruneVersions(ctx, storageObj); err != nil {
+		log.Printf("Failed to prune versions of file %s: %v", storageObj.ObjectID, err)
+	}
+}
+
+func (s *StorageService) pruneVersions(ctx context.Context, storageObj *models.StorageObject) error {
+	keep := s.storageConfig.MaxFileVersions
+
+	owner, err := s.userRepo.GetUserByID(ctx, storageObj.UserID)
+	if err != nil {
+		return err
+	}
+	if owner.MaxFileVersions > 0 {
+		keep = owner.MaxFileVersions
+	}
+
+	versions, err := s.storageRepo.ListVersions(ctx, storageObj.ObjectID)
+	if err != nil {
+		return err
+	}
+
+	// The current version always stays, along with the newest keep-1 others.
+	kept := 1
+	for i := range versions {
+		if versions[i].VersionID == storageObj.VersionID {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+
+		if err := s.storageRepo.DeleteVersion(ctx, storageObj, &versions[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *StorageService) getFileVersion(ctx context.Context, userID string, fileID string, versionID string, role string) (*models.StorageObject, *models.FileVersion, error) {
+	storageObj, err := s.authorizeFile(ctx, userID, fileID, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version, err := s.storageRepo.GetVersion(ctx, storageObj.ObjectID, versionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return storageObj, version, nil
+}
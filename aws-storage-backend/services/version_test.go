@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/berkkaradalan/AwsGo-Storage/blobstore"
+	"github.com/berkkaradalan/AwsGo-Storage/internal/teststore"
+	"github.com/berkkaradalan/AwsGo-Storage/models"
+)
+
+// replace gives a file of user-1 new content through the service.
+func (s *testService) replace(t *testing.T, fileID string, content string) *models.StorageObject {
+	t.Helper()
+
+	if _, err := s.ReplaceFileContent(context.Background(), "user-1", fileID, fileHeader(t, "a.txt", content), nil); err != nil {
+		t.Fatalf("ReplaceFileContent: %v", err)
+	}
+	return s.file(t, fileID)
+}
+
+func (s *testService) versions(t *testing.T, fileID string) []models.FileVersion {
+	t.Helper()
+
+	response, err := s.ListFileVersions(context.Background(), "user-1", fileID)
+	if err != nil {
+		t.Fatalf("ListFileVersions: %v", err)
+	}
+	return response.Versions
+}
+
+func TestRestoreFileVersion(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+		// The first replacement detaches the file from its blob, which
+		// records the uploaded content as the oldest version.
+		uploaded := s.upload(t, "user-1", "", "a.txt", "hello")
+		s.replace(t, uploaded.ObjectID, "hello, world")
+		current := s.replace(t, uploaded.ObjectID, "hi")
+
+		versions := s.versions(t, uploaded.ObjectID)
+		if len(versions) != 3 {
+			t.Fatalf("file has %d versions, want 3", len(versions))
+		}
+		first := versions[len(versions)-1]
+
+		if _, err := s.DeleteFileVersion(ctx, "user-1", uploaded.ObjectID, current.VersionID); !errors.Is(err, ErrCurrentVersion) {
+			t.Errorf("DeleteFileVersion of the current version: %v, want ErrCurrentVersion", err)
+		}
+
+		restored, err := s.RestoreFileVersion(ctx, "user-1", uploaded.ObjectID, first.VersionID)
+		if err != nil {
+			t.Fatalf("RestoreFileVersion: %v", err)
+		}
+
+		if got := s.content(t, s.file(t, uploaded.ObjectID)); got != "hello" {
+			t.Errorf("restored file reads %q, want %q", got, "hello")
+		}
+		if restored.VersionID == first.VersionID || restored.FileSize != 5 {
+			t.Errorf("restored file is at S3 version %s with %d bytes, want a new version of 5 bytes", restored.VersionID, restored.FileSize)
+		}
+		if got := s.usedBytes(t, "user-1"); got != 5 {
+			t.Errorf("UsedBytes = %d, want 5", got)
+		}
+
+		// The restore is a version of its own; the restored one stays.
+		versions = s.versions(t, uploaded.ObjectID)
+		if len(versions) != 4 || versions[0].VersionID != restored.VersionID || versions[3].VersionID != first.VersionID {
+			t.Fatalf("versions after the restore are %+v, want the restore on top of the other 3", versions)
+		}
+		for _, version := range versions {
+			if version.IsCurrent != (version.VersionID == restored.VersionID) {
+				t.Errorf("version %s is current: %v", version.VersionID, version.IsCurrent)
+			}
+		}
+
+		// Restoring the current version changes nothing.
+		again, err := s.RestoreFileVersion(ctx, "user-1", uploaded.ObjectID, restored.VersionID)
+		if err != nil || again.VersionID != restored.VersionID || len(s.versions(t, uploaded.ObjectID)) != 4 {
+			t.Errorf("restoring the current version: %v, now at %s", err, again.VersionID)
+		}
+	})
+}
+
+func TestPruneVersions(t *testing.T) {
+	teststore.Run(t, func(t *testing.T, driver teststore.Driver) {
+		ctx := context.Background()
+		s := newTestService(t, driver)
+		if _, err := s.stores.Users.UpdateVersionPolicy(ctx, "user-1", 2); err != nil {
+			t.Fatalf("UpdateVersionPolicy: %v", err)
+		}
+
+		file := s.upload(t, "user-1", "", "a.txt", "zero")
+		one := s.replace(t, file.ObjectID, "one")
+		two := s.replace(t, file.ObjectID, "two")
+		current := s.replace(t, file.ObjectID, "three")
+
+		versions := s.versions(t, file.ObjectID)
+		if len(versions) != 2 || versions[0].VersionID != current.VersionID || versions[1].VersionID != two.VersionID {
+			t.Fatalf("kept versions %+v, want the current one and the one before", versions)
+		}
+
+		// Pruned versions are gone from the blob store too.
+		if _, err := s.blobStore.Head(ctx, one.S3Key, one.VersionID); !errors.Is(err, blobstore.ErrNotFound) {
+			t.Errorf("Head of pruned version %s: %v, want ErrNotFound", one.VersionID, err)
+		}
+		if _, err := s.blobStore.Head(ctx, two.S3Key, two.VersionID); err != nil {
+			t.Errorf("Head of kept version %s: %v", two.VersionID, err)
+		}
+		if got := s.content(t, s.file(t, file.ObjectID)); got != "three" {
+			t.Errorf("file reads %q, want %q", got, "three")
+		}
+	})
+}